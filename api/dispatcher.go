@@ -0,0 +1,41 @@
+package api
+
+import (
+	"gorm.io/gorm"
+	_auditRepository "khiemle.dev/golang-api-template/internal/audit/repository"
+	_auditService "khiemle.dev/golang-api-template/internal/audit/service"
+	"khiemle.dev/golang-api-template/internal/schemas"
+	_todoModel "khiemle.dev/golang-api-template/internal/todo/model"
+	_webhookService "khiemle.dev/golang-api-template/internal/webhook/service"
+	"khiemle.dev/golang-api-template/pkg/outbox"
+	"khiemle.dev/golang-api-template/pkg/pubsub"
+	util "khiemle.dev/golang-api-template/pkg/util"
+)
+
+// OutboxBroker is the name of the outbox subscriber posting events to
+// OUTBOX_BROKER_URL
+const OutboxBroker = "broker"
+
+// NewDispatcher creates the dispatcher of domain events with every subscriber
+// registered. It runs in the worker and is woken up through ps.
+func NewDispatcher(cfg *util.Config, db *gorm.DB, ps pubsub.PubSub) (*outbox.Dispatcher, error) {
+	dispatcher, err := outbox.NewDispatcher(db, ps, cfg.OutboxPollInterval, cfg.OutboxGapTimeout, cfg.OutboxMaxAttempts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Deliver events to the webhooks of users
+	dispatcher.Subscribe(_webhookService.OutboxSubscriber, _webhookService.NewOutboxHandler(newWebhookPublisher(cfg, db), func(todo *_todoModel.Todo) any {
+		return schemas.NewTodoResponse(todo)
+	}))
+
+	// Log who did what, including the events recorded before the first run
+	dispatcher.Subscribe(_auditService.OutboxSubscriber, _auditService.NewOutboxHandler(_auditRepository.NewGormAuditLogRepository(db)), outbox.FromOldest())
+
+	// Publish every event outside of the application
+	if cfg.OutboxBrokerURL != "" {
+		dispatcher.SubscribeBroker(OutboxBroker, outbox.NewHTTPBroker(cfg.OutboxBrokerURL, cfg.OutboxBrokerSecret, cfg.WebhookTimeout))
+	}
+
+	return dispatcher, nil
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestListSocketChecksOrigin(t *testing.T) {
+	s := newTestServer(t, "LIST_SOCKET_ALLOWED_ORIGINS=https://app.example.com, https://admin.example.com")
+	alice := s.register("alice")
+	list := s.createList(alice.AccessToken, "groceries")
+	server := httptest.NewServer(s.server.router)
+	defer server.Close()
+
+	url := fmt.Sprintf("ws%s/v1/lists/%d/ws?access_token=%s", strings.TrimPrefix(server.URL, "http"), list.ID, alice.AccessToken)
+	tests := []struct {
+		origin string
+		status int
+	}{
+		{"", http.StatusSwitchingProtocols},
+		{server.URL, http.StatusSwitchingProtocols},
+		{"https://app.example.com", http.StatusSwitchingProtocols},
+		{"https://admin.example.com", http.StatusSwitchingProtocols},
+		{"https://evil.example.com", http.StatusForbidden},
+		{"null", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			header := http.Header{}
+			if tt.origin != "" {
+				header.Set("Origin", tt.origin)
+			}
+			conn, res, err := websocket.DefaultDialer.Dial(url, header)
+			if conn != nil {
+				conn.Close()
+			}
+			if res == nil {
+				t.Fatalf("dial: %v", err)
+			}
+			if res.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", res.StatusCode, tt.status)
+			}
+		})
+	}
+}
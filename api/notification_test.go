@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	_notificationModel "khiemle.dev/golang-api-template/internal/notification/model"
+	"khiemle.dev/golang-api-template/internal/schemas"
+	"khiemle.dev/golang-api-template/pkg/jobqueue"
+)
+
+// runJobs runs the jobs enqueued so far with the handlers of the worker
+func (s *testServer) runJobs() {
+	s.t.Helper()
+
+	cfg := testConfig(s.t, "JOBS_POLL_INTERVAL=10ms")
+	worker := NewWorker(&cfg, s.db, s.server.pubsub)
+	worker.Start()
+	defer func() {
+		if err := worker.Close(context.Background()); err != nil {
+			s.t.Errorf("close worker: %v", err)
+		}
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var count int64
+		err := s.db.Model(&jobqueue.Job{}).
+			Where("status IN ?", []string{jobqueue.StatusPending, jobqueue.StatusRunning}).
+			Count(&count).Error
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		if count == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			s.t.Fatalf("%d jobs still to run", count)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// notifications returns the kinds and bodies of the notifications of the
+// user, sorted
+func (s *testServer) notifications(userID uint) []string {
+	s.t.Helper()
+
+	var notifications []_notificationModel.Notification
+	if err := s.db.Where("user_id = ?", userID).Find(&notifications).Error; err != nil {
+		s.t.Fatal(err)
+	}
+	got := []string{}
+	for _, notification := range notifications {
+		got = append(got, notification.Kind+": "+notification.Body)
+	}
+	sort.Strings(got)
+	return got
+}
+
+func TestSharedListNotifications(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.register("alice")
+	bob := s.register("bob")
+	list := s.createList(alice.AccessToken, "groceries")
+	s.share(alice.AccessToken, list.ID, bob, "editor")
+
+	listID := int(list.ID)
+	s.createTodo(alice.AccessToken, schemas.CreateTodoRequest{Name: "milk", ListID: &listID})
+	eggs := s.createTodo(bob.AccessToken, schemas.CreateTodoRequest{Name: "eggs", ListID: &listID})
+	bread := s.createTodo(alice.AccessToken, schemas.CreateTodoRequest{Name: "bread"})
+	expectStatus(t, s.do(http.MethodPut, fmt.Sprintf("/v1/todos/%d/list", eggs.ID), bob.AccessToken, schemas.MoveTodoRequest{}), http.StatusOK)
+	expectStatus(t, s.do(http.MethodPut, fmt.Sprintf("/v1/todos/%d/list", bread.ID), alice.AccessToken, schemas.MoveTodoRequest{ListID: &listID}), http.StatusOK)
+	s.runJobs()
+
+	// Nobody is told about what they did themselves
+	want := []string{
+		`todo.created: bob added "eggs" to groceries.`,
+		`todo.moved_out: bob moved "eggs" out of groceries.`,
+	}
+	if got := s.notifications(alice.User.ID); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("notifications of alice = %q, want %q", got, want)
+	}
+	want = []string{
+		"list.invited: alice invited you to groceries as editor.",
+		`todo.created: alice added "milk" to groceries.`,
+		`todo.moved_in: alice moved "bread" to groceries.`,
+	}
+	if got := s.notifications(bob.User.ID); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("notifications of bob = %q, want %q", got, want)
+	}
+}
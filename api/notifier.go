@@ -0,0 +1,85 @@
+package api
+
+import (
+	"net/http"
+
+	"gorm.io/gorm"
+	_notificationRepository "khiemle.dev/golang-api-template/internal/notification/repository"
+	_notificationService "khiemle.dev/golang-api-template/internal/notification/service"
+	_todoRepository "khiemle.dev/golang-api-template/internal/todo/repository"
+	_todoService "khiemle.dev/golang-api-template/internal/todo/service"
+	_userRepository "khiemle.dev/golang-api-template/internal/user/repository"
+	_userService "khiemle.dev/golang-api-template/internal/user/service"
+	_webhookRepository "khiemle.dev/golang-api-template/internal/webhook/repository"
+	_webhookService "khiemle.dev/golang-api-template/internal/webhook/service"
+	"khiemle.dev/golang-api-template/pkg/jobqueue"
+	"khiemle.dev/golang-api-template/pkg/notify"
+	"khiemle.dev/golang-api-template/pkg/outbox"
+	"khiemle.dev/golang-api-template/pkg/pubsub"
+	"khiemle.dev/golang-api-template/pkg/txn"
+	util "khiemle.dev/golang-api-template/pkg/util"
+)
+
+// NewNotifier creates the notifier with the configured channels. In-app
+// notifications are always available, email and webhooks once configured.
+func NewNotifier(cfg *util.Config, db *gorm.DB) *notify.Notifier {
+	notifier := notify.NewNotifier()
+	notifier.Register(notify.ChannelInApp, _notificationService.NewInAppChannel(_notificationRepository.NewGormNotificationRepository(db)))
+
+	if cfg.SMTPHost != "" {
+		notifier.Register(notify.ChannelEmail, notify.NewSMTPChannel(notify.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+		}))
+	}
+
+	if cfg.NotifyWebhookURL != "" {
+		notifier.Register(notify.ChannelWebhook, notify.NewWebhookChannel(cfg.NotifyWebhookURL, cfg.NotifyWebhookSecret))
+	}
+
+	return notifier
+}
+
+// newReminderService creates the reminder service used by the API, the
+// scheduler and the worker.
+func newReminderService(db *gorm.DB, userService _userService.UserService, notifier *notify.Notifier) _todoService.ReminderService {
+	return _todoService.NewReminderService(
+		_todoRepository.NewGormReminderRepository(db),
+		_todoRepository.NewGormTodoRepository(db),
+		_todoRepository.NewGormTodoListRepository(db),
+		_todoRepository.NewGormListMemberRepository(db),
+		userService,
+		notifier,
+	)
+}
+
+// newUserService creates the user service used by the scheduler and the
+// worker.
+func newUserService(db *gorm.DB, ps pubsub.PubSub) _userService.UserService {
+	return _userService.NewUserService(
+		_userRepository.NewGormUserRepository(db),
+		txn.NewGormTransactor(db),
+		outbox.NewRecorder(db, ps),
+	)
+}
+
+// newWebhookPublisher creates the publisher of events to the webhooks of
+// users, used by the outbox dispatcher.
+func newWebhookPublisher(cfg *util.Config, db *gorm.DB) _webhookService.Publisher {
+	return _webhookService.NewPublisher(
+		_webhookRepository.NewGormEndpointRepository(db),
+		_webhookRepository.NewGormDeliveryRepository(db),
+		jobqueue.NewClient(db),
+		cfg.WebhookMaxAttempts,
+	)
+}
+
+// newWebhookClient creates the client posting to the webhooks of users
+func newWebhookClient(cfg *util.Config) *http.Client {
+	// Checked when the config was loaded
+	allowedNetworks, _ := cfg.WebhookAllowedPrefixes()
+	return _webhookService.NewHTTPClient(cfg.WebhookTimeout, allowedNetworks)
+}
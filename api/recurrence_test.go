@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"khiemle.dev/golang-api-template/internal/schemas"
+)
+
+func TestRecurringTodoOccurrences(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.register("alice")
+
+	dueAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	first := s.createTodo(alice.AccessToken, schemas.CreateTodoRequest{Name: "water plants", DueAt: &dueAt, Recurrence: "FREQ=DAILY"})
+
+	generator, err := newRecurringTodoGenerator(s.db, s.server.pubsub)
+	if err != nil {
+		t.Fatalf("newRecurringTodoGenerator: %v", err)
+	}
+
+	// Concurrent runs create the occurrence once
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := generator.Run(context.Background()); err != nil {
+				t.Errorf("Run: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := s.listTodos(alice.AccessToken, "/v1/todos/"); len(got) != 2 {
+		t.Fatalf("todos = %v, want the first occurrence and the next one", got)
+	}
+
+	// Completing an earlier occurrence does not create another one
+	done := true
+	rec := s.do(http.MethodPatch, fmt.Sprintf("/v1/todos/%d", first.ID), alice.AccessToken, schemas.UpdateTodoRequest{Done: &done})
+	expectStatus(t, rec, http.StatusOK)
+	if got := s.listTodos(alice.AccessToken, "/v1/todos/"); len(got) != 2 {
+		t.Errorf("todos = %v after completing the first occurrence, want 2", got)
+	}
+
+	// Completing the latest one creates the next, even when it is not due
+	latest := s.listTodosResponse(alice.AccessToken, "/v1/todos/?done=false")
+	if len(latest) != 1 {
+		t.Fatalf("open todos = %+v, want the latest occurrence", latest)
+	}
+	rec = s.do(http.MethodPatch, fmt.Sprintf("/v1/todos/%d", latest[0].ID), alice.AccessToken, schemas.UpdateTodoRequest{Done: &done})
+	expectStatus(t, rec, http.StatusOK)
+	open := s.listTodosResponse(alice.AccessToken, "/v1/todos/?done=false")
+	if len(open) != 1 || !open[0].DueAt.Equal(latest[0].DueAt.Add(24*time.Hour)) {
+		t.Errorf("open todos = %+v, want one due a day after %s", open, latest[0].DueAt)
+	}
+}
+
+func TestRestoringLatestOccurrenceResumesSeries(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.register("alice")
+
+	// The next occurrence is already due
+	dueAt := time.Now().Add(-25 * time.Hour).UTC().Truncate(time.Second)
+	todo := s.createTodo(alice.AccessToken, schemas.CreateTodoRequest{Name: "water plants", DueAt: &dueAt, Recurrence: "FREQ=DAILY"})
+	rec := s.do(http.MethodDelete, fmt.Sprintf("/v1/todos/%d", todo.ID), alice.AccessToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+
+	// With every occurrence deleted the series stops
+	generator, err := newRecurringTodoGenerator(s.db, s.server.pubsub)
+	if err != nil {
+		t.Fatalf("newRecurringTodoGenerator: %v", err)
+	}
+	if err := generator.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := s.listTodos(alice.AccessToken, "/v1/todos/"); len(got) != 0 {
+		t.Fatalf("todos = %v, want none", got)
+	}
+
+	rec = s.do(http.MethodPost, fmt.Sprintf("/v1/todos/trash/%d/restore", todo.ID), alice.AccessToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if err := generator.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	todos := s.listTodosResponse(alice.AccessToken, "/v1/todos/?done=false")
+	next := dueAt.Add(24 * time.Hour)
+	if len(todos) != 2 || todos[0].ID != todo.ID || !todos[1].DueAt.Equal(next) {
+		t.Errorf("todos = %+v, want the restored occurrence and the one due %s", todos, next)
+	}
+}
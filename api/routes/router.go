@@ -2,31 +2,119 @@ package routes
 
 import (
 	"github.com/gin-gonic/gin"
+	_adminHandler "khiemle.dev/golang-api-template/internal/admin/handler"
+	_auditHandler "khiemle.dev/golang-api-template/internal/audit/handler"
 	_authHandler "khiemle.dev/golang-api-template/internal/auth/handler"
-	"khiemle.dev/golang-api-template/internal/auth/service"
+	_notificationHandler "khiemle.dev/golang-api-template/internal/notification/handler"
 	_todoHandler "khiemle.dev/golang-api-template/internal/todo/handler"
-	_userService "khiemle.dev/golang-api-template/internal/user/service"
+	_webhookHandler "khiemle.dev/golang-api-template/internal/webhook/handler"
 	"khiemle.dev/golang-api-template/pkg/middleware"
-	"khiemle.dev/golang-api-template/pkg/util/token"
 )
 
-func SetupTodoRouter(todoGroup *gin.RouterGroup, todoHandler _todoHandler.TodoHandler) {
+// Long-lived routes, exempt from the request timeout
+const (
+	TodoEventsRoute = "/v1/todos/events"
+	ListSocketRoute = "/v1/lists/:id/ws"
+	// Exports stream every matching audit log
+	AuditExportRoute = "/v1/admin/audit-logs/export"
+)
+
+func SetupTodoRouter(
+	todoGroup *gin.RouterGroup,
+	todoHandler _todoHandler.TodoHandler,
+	checklistHandler _todoHandler.ChecklistHandler,
+	reminderHandler _todoHandler.ReminderHandler,
+	todoEventHandler _todoHandler.TodoEventHandler,
+	authMiddleware gin.HandlerFunc,
+) {
+	todoGroup.Use(middleware.GetBearerTokenMiddleware(), authMiddleware)
+
+	todoGroup.GET("/events", todoEventHandler.StreamEventsHandler)
+
+	todoGroup.GET("/trash", todoHandler.ListTrashHandler)
+	todoGroup.POST("/trash/:id/restore", todoHandler.RestoreTodoHandler)
+	todoGroup.DELETE("/trash/:id", todoHandler.PurgeTodoHandler)
+
 	todoGroup.GET("/", todoHandler.ListTodoHandler)
 	todoGroup.GET("/:id", todoHandler.GetByIdHandler)
 	todoGroup.POST("/", todoHandler.CreateTodoHandler)
 	todoGroup.PATCH("/:id", todoHandler.UpdateTodoHandler)
 	todoGroup.DELETE("/:id", todoHandler.DeleteTodoHandler)
+	todoGroup.PUT("/:id/list", todoHandler.MoveTodoHandler)
+	todoGroup.PUT("/:id/parent", todoHandler.SetParentHandler)
+	todoGroup.PUT("/:id/labels/:labelId", todoHandler.AttachLabelHandler)
+	todoGroup.DELETE("/:id/labels/:labelId", todoHandler.DetachLabelHandler)
+
+	todoGroup.POST("/:id/checklist", checklistHandler.AddItemHandler)
+	todoGroup.PATCH("/:id/checklist/:itemId", checklistHandler.UpdateItemHandler)
+	todoGroup.DELETE("/:id/checklist/:itemId", checklistHandler.DeleteItemHandler)
+
+	todoGroup.GET("/:id/reminders", reminderHandler.ListReminderHandler)
+	todoGroup.POST("/:id/reminders", reminderHandler.AddReminderHandler)
+	todoGroup.DELETE("/:id/reminders/:reminderId", reminderHandler.DeleteReminderHandler)
+}
+
+func SetupTodoListRouter(
+	listGroup *gin.RouterGroup,
+	todoListHandler _todoHandler.TodoListHandler,
+	listSharingHandler _todoHandler.ListSharingHandler,
+	listLiveHandler _todoHandler.ListLiveHandler,
+	authMiddleware gin.HandlerFunc,
+) {
+	// Browsers pass the access token of sockets in the query string
+	listGroup.GET("/:id/ws", middleware.GetSocketTokenMiddleware(), authMiddleware, listLiveHandler.ConnectHandler)
+
+	listGroup.Use(middleware.GetBearerTokenMiddleware(), authMiddleware)
+
+	listGroup.GET("/", todoListHandler.ListListHandler)
+	listGroup.GET("/:id", todoListHandler.GetListHandler)
+	listGroup.POST("/", todoListHandler.CreateListHandler)
+	listGroup.PATCH("/:id", todoListHandler.UpdateListHandler)
+	listGroup.DELETE("/:id", todoListHandler.DeleteListHandler)
+	listGroup.POST("/:id/archive", todoListHandler.ArchiveListHandler)
+	listGroup.POST("/:id/unarchive", todoListHandler.UnarchiveListHandler)
+
+	listGroup.GET("/:id/invitations", listSharingHandler.ListInvitationsHandler)
+	listGroup.POST("/:id/invitations", listSharingHandler.InviteHandler)
+	listGroup.DELETE("/:id/invitations/:invitationId", listSharingHandler.RevokeInvitationHandler)
+	listGroup.GET("/:id/members", listSharingHandler.ListMembersHandler)
+	listGroup.PATCH("/:id/members/:userId", listSharingHandler.UpdateMemberHandler)
+	listGroup.DELETE("/:id/members/:userId", listSharingHandler.RemoveMemberHandler)
+	listGroup.POST("/:id/leave", listSharingHandler.LeaveListHandler)
+}
+
+func SetupInvitationRouter(
+	invitationGroup *gin.RouterGroup,
+	listSharingHandler _todoHandler.ListSharingHandler,
+	authMiddleware gin.HandlerFunc,
+) {
+	invitationGroup.Use(middleware.GetBearerTokenMiddleware(), authMiddleware)
+
+	invitationGroup.GET("/", listSharingHandler.ListReceivedInvitationsHandler)
+	invitationGroup.POST("/:id/accept", listSharingHandler.AcceptInvitationHandler)
+	invitationGroup.POST("/:id/decline", listSharingHandler.DeclineInvitationHandler)
+}
+
+func SetupLabelRouter(
+	labelGroup *gin.RouterGroup,
+	labelHandler _todoHandler.LabelHandler,
+	authMiddleware gin.HandlerFunc,
+) {
+	labelGroup.Use(middleware.GetBearerTokenMiddleware(), authMiddleware)
+
+	labelGroup.GET("/", labelHandler.ListLabelHandler)
+	labelGroup.GET("/:id", labelHandler.GetLabelHandler)
+	labelGroup.POST("/", labelHandler.CreateLabelHandler)
+	labelGroup.PATCH("/:id", labelHandler.UpdateLabelHandler)
+	labelGroup.DELETE("/:id", labelHandler.DeleteLabelHandler)
 }
 
 func SetupAuthRouter(
 	authGroup *gin.RouterGroup,
 	authHandler _authHandler.AuthHandler,
-	tokenMaker token.TokenMaker,
-	loginSessionService service.LoginSessionService,
-	userService _userService.UserService,
+	authMiddleware gin.HandlerFunc,
 ) {
 	getTokenMiddle := middleware.GetBearerTokenMiddleware()
-	authMiddleware := middleware.VerifyTokenMiddleware(tokenMaker, loginSessionService, userService)
 
 	authGroup.POST("/login", authHandler.LoginHandler)
 	authGroup.POST("/register", authHandler.RegisterHandler)
@@ -34,3 +122,47 @@ func SetupAuthRouter(
 	authGroup.GET("/verify_access_token", getTokenMiddle, authMiddleware, authHandler.VerifyAccessToken)
 	authGroup.GET("/refresh_token", getTokenMiddle, authHandler.RefreshTokenHandler)
 }
+
+func SetupNotificationRouter(
+	notificationGroup *gin.RouterGroup,
+	notificationHandler _notificationHandler.NotificationHandler,
+	authMiddleware gin.HandlerFunc,
+) {
+	notificationGroup.Use(middleware.GetBearerTokenMiddleware(), authMiddleware)
+
+	notificationGroup.GET("/", notificationHandler.ListNotificationHandler)
+	notificationGroup.POST("/read-all", notificationHandler.MarkAllReadHandler)
+	notificationGroup.POST("/:id/read", notificationHandler.MarkReadHandler)
+}
+
+func SetupWebhookRouter(
+	webhookGroup *gin.RouterGroup,
+	webhookHandler _webhookHandler.WebhookHandler,
+	authMiddleware gin.HandlerFunc,
+) {
+	webhookGroup.Use(middleware.GetBearerTokenMiddleware(), authMiddleware)
+
+	webhookGroup.GET("/", webhookHandler.ListWebhookHandler)
+	webhookGroup.POST("/", webhookHandler.CreateWebhookHandler)
+	webhookGroup.GET("/:id", webhookHandler.GetWebhookHandler)
+	webhookGroup.PATCH("/:id", webhookHandler.UpdateWebhookHandler)
+	webhookGroup.DELETE("/:id", webhookHandler.DeleteWebhookHandler)
+	webhookGroup.POST("/:id/rotate-secret", webhookHandler.RotateSecretHandler)
+	webhookGroup.POST("/:id/ping", webhookHandler.PingHandler)
+	webhookGroup.GET("/:id/deliveries", webhookHandler.ListDeliveryHandler)
+	webhookGroup.GET("/:id/deliveries/:deliveryId", webhookHandler.GetDeliveryHandler)
+	webhookGroup.POST("/:id/deliveries/:deliveryId/replay", webhookHandler.ReplayDeliveryHandler)
+}
+
+func SetupAdminRouter(
+	adminGroup *gin.RouterGroup,
+	adminHandler _adminHandler.AdminHandler,
+	auditHandler _auditHandler.AuditHandler,
+	authMiddleware gin.HandlerFunc,
+) {
+	adminGroup.Use(middleware.GetBearerTokenMiddleware(), authMiddleware, middleware.RequireAdminMiddleware())
+
+	adminGroup.GET("/schedules", adminHandler.ListSchedulesHandler)
+	adminGroup.GET("/audit-logs", auditHandler.ListAuditLogHandler)
+	adminGroup.GET("/audit-logs/export", auditHandler.ExportAuditLogHandler)
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"gorm.io/gorm"
+	_authRepository "khiemle.dev/golang-api-template/internal/auth/repository"
+	_authService "khiemle.dev/golang-api-template/internal/auth/service"
+	_todoRepository "khiemle.dev/golang-api-template/internal/todo/repository"
+	_todoService "khiemle.dev/golang-api-template/internal/todo/service"
+	_webhookRepository "khiemle.dev/golang-api-template/internal/webhook/repository"
+	_webhookService "khiemle.dev/golang-api-template/internal/webhook/service"
+	"khiemle.dev/golang-api-template/pkg/outbox"
+	"khiemle.dev/golang-api-template/pkg/pubsub"
+	"khiemle.dev/golang-api-template/pkg/scheduler"
+	"khiemle.dev/golang-api-template/pkg/txn"
+	util "khiemle.dev/golang-api-template/pkg/util"
+)
+
+// Schedule names, used to override their spec with SCHEDULE_OVERRIDES
+const (
+	ScheduleSessionCleanup = "session_cleanup"
+	ScheduleRecurringTodos = "recurring_todos"
+	ScheduleTodoReminders  = "todo_reminders"
+	ScheduleTodoEvents     = "todo_events_cleanup"
+	ScheduleTodoTrash      = "todo_trash_cleanup"
+	ScheduleWebhooks       = "webhook_deliveries_cleanup"
+	ScheduleOutbox         = "outbox_cleanup"
+)
+
+// NewScheduler creates the scheduler with every periodic task registered.
+// Changes made by the tasks are published on ps.
+func NewScheduler(cfg *util.Config, db *gorm.DB, ps pubsub.PubSub) (*scheduler.Scheduler, error) {
+	overrides, err := scheduler.ParseOverrides(cfg.ScheduleOverrides)
+	if err != nil {
+		return nil, err
+	}
+	sched := scheduler.New(db, newHolderID(), overrides, cfg.SchedulerHistoryRetention, cfg.SchedulerRunTimeout)
+
+	// Purge expired and logged out login sessions
+	sessionJanitor, err := _authService.NewSessionJanitor(_authRepository.NewGormLoginSessionRepository(db), cfg.SessionRetention)
+	if err != nil {
+		return nil, err
+	}
+	if err := sched.Register(ScheduleSessionCleanup, "@hourly", sessionJanitor.Run); err != nil {
+		return nil, err
+	}
+
+	// Create occurrences of recurring todos once the previous one is due
+	recurringTodoGenerator, err := newRecurringTodoGenerator(db, ps)
+	if err != nil {
+		return nil, err
+	}
+	if err := sched.Register(ScheduleRecurringTodos, "@every 1m", recurringTodoGenerator.Run); err != nil {
+		return nil, err
+	}
+
+	// Purge todo events streams can no longer resume from
+	todoEventJanitor, err := _todoService.NewTodoEventJanitor(_todoRepository.NewGormTodoEventRepository(db), cfg.TodoEventsRetention)
+	if err != nil {
+		return nil, err
+	}
+	if err := sched.Register(ScheduleTodoEvents, "@hourly", todoEventJanitor.Run); err != nil {
+		return nil, err
+	}
+
+	// Purge todos past the retention window from the trash
+	trashJanitor, err := newTrashJanitor(cfg, db, ps)
+	if err != nil {
+		return nil, err
+	}
+	if err := sched.Register(ScheduleTodoTrash, "@hourly", trashJanitor.Run); err != nil {
+		return nil, err
+	}
+
+	// Purge webhook deliveries past the retention window from the log
+	deliveryJanitor, err := _webhookService.NewDeliveryJanitor(_webhookRepository.NewGormDeliveryRepository(db), cfg.WebhookDeliveryRetention)
+	if err != nil {
+		return nil, err
+	}
+	if err := sched.Register(ScheduleWebhooks, "@daily", deliveryJanitor.Run); err != nil {
+		return nil, err
+	}
+
+	// Purge domain events past the retention window from the outbox
+	outboxJanitor, err := outbox.NewJanitor(db, cfg.OutboxRetention)
+	if err != nil {
+		return nil, err
+	}
+	if err := sched.Register(ScheduleOutbox, "@daily", outboxJanitor.Run); err != nil {
+		return nil, err
+	}
+
+	// Hand reminders over to the worker once they are due
+	reminderService := newReminderService(db, newUserService(db, ps), NewNotifier(cfg, db))
+	if err := sched.Register(ScheduleTodoReminders, "@every 1m", reminderService.EnqueueDue); err != nil {
+		return nil, err
+	}
+
+	return sched, nil
+}
+
+// newRecurringTodoGenerator creates the generator of the occurrences of
+// recurring todos run by the scheduler.
+func newRecurringTodoGenerator(db *gorm.DB, ps pubsub.PubSub) (*_todoService.RecurringTodoGenerator, error) {
+	return _todoService.NewRecurringTodoGenerator(
+		_todoRepository.NewGormTodoRepository(db),
+		_todoRepository.NewGormTodoSeriesRepository(db),
+		_todoRepository.NewGormChecklistItemRepository(db),
+		_todoRepository.NewGormReminderRepository(db),
+		_todoRepository.NewGormTodoListRepository(db),
+		_todoRepository.NewGormListMemberRepository(db),
+		_todoRepository.NewGormTodoEventRepository(db),
+		ps,
+		txn.NewGormTransactor(db),
+		outbox.NewRecorder(db, ps),
+	)
+}
+
+// newTrashJanitor creates the janitor of the todo trash run by the scheduler.
+func newTrashJanitor(cfg *util.Config, db *gorm.DB, ps pubsub.PubSub) (*_todoService.TrashJanitor, error) {
+	return _todoService.NewTrashJanitor(
+		_todoRepository.NewGormTodoRepository(db),
+		_todoRepository.NewGormTodoListRepository(db),
+		_todoRepository.NewGormListMemberRepository(db),
+		_todoRepository.NewGormTodoEventRepository(db),
+		ps,
+		txn.NewGormTransactor(db),
+		outbox.NewRecorder(db, ps),
+		cfg.TodoTrashRetention,
+	)
+}
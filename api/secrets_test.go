@@ -0,0 +1,87 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	_authModel "khiemle.dev/golang-api-template/internal/auth/model"
+	"khiemle.dev/golang-api-template/internal/schemas"
+	_userModel "khiemle.dev/golang-api-template/internal/user/model"
+)
+
+// TestResponsesHideSecrets checks that passwords, tokens and webhook secrets
+// only appear in the responses that hand them out
+func TestResponsesHideSecrets(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.register("alice")
+	if err := s.db.Model(&_userModel.User{}).Where("id = ?", alice.User.ID).Update("is_admin", true).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	rec := s.do(http.MethodPost, "/v1/webhooks/", alice.AccessToken, schemas.CreateWebhookRequest{
+		URL:    "https://example.com/hook",
+		Events: []string{"*"},
+	})
+	expectStatus(t, rec, http.StatusOK)
+	webhook := decodeData[schemas.WebhookSecretResponse](t, rec)
+	if webhook.Secret == "" {
+		t.Fatal("the secret of a new webhook is not returned")
+	}
+
+	rec = s.do(http.MethodPost, "/v1/todos/", alice.AccessToken, schemas.CreateTodoRequest{Name: "todo", Description: "todo"})
+	expectStatus(t, rec, http.StatusOK)
+	todo := decodeData[schemas.TodoResponse](t, rec)
+
+	// Secrets as stored, and as handed out
+	var user _userModel.User
+	if err := s.db.First(&user, alice.User.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	var loginSession _authModel.LoginSession
+	if err := s.db.First(&loginSession, alice.LoginSessionID).Error; err != nil {
+		t.Fatal(err)
+	}
+	secrets := map[string]string{
+		"hashed password": user.HashedPassword,
+		"password":        testPassword,
+		"access token":    loginSession.AccessToken,
+		"refresh token":   loginSession.RefreshToken,
+		"webhook secret":  webhook.Secret,
+	}
+	fields := []string{`"hashed_password"`, `"password"`, `"access_token"`, `"refresh_token"`, `"secret"`}
+
+	paths := []string{
+		"/v1/auth/verify_access_token",
+		"/v1/todos/",
+		fmt.Sprintf("/v1/todos/%d", todo.ID),
+		"/v1/lists/",
+		"/v1/labels/",
+		"/v1/notifications/",
+		"/v1/invitations/",
+		"/v1/webhooks/",
+		fmt.Sprintf("/v1/webhooks/%d", webhook.ID),
+		fmt.Sprintf("/v1/webhooks/%d/deliveries", webhook.ID),
+		"/v1/admin/schedules",
+		"/v1/admin/audit-logs",
+		"/v1/admin/audit-logs/export",
+	}
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			rec := s.do(http.MethodGet, path, alice.AccessToken, nil)
+			expectStatus(t, rec, http.StatusOK)
+			body := rec.Body.String()
+			for name, secret := range secrets {
+				if strings.Contains(body, secret) {
+					t.Errorf("response contains the %s", name)
+				}
+			}
+			for _, field := range fields {
+				if strings.Contains(body, field) {
+					t.Errorf("response contains the field %s", field)
+				}
+			}
+		})
+	}
+}
@@ -1,29 +1,60 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"gorm.io/gorm"
 	"khiemle.dev/golang-api-template/api/routes"
+	_adminHandler "khiemle.dev/golang-api-template/internal/admin/handler"
+	_auditHandler "khiemle.dev/golang-api-template/internal/audit/handler"
+	_auditRepository "khiemle.dev/golang-api-template/internal/audit/repository"
+	_auditService "khiemle.dev/golang-api-template/internal/audit/service"
 	_authHandler "khiemle.dev/golang-api-template/internal/auth/handler"
+	_authRepository "khiemle.dev/golang-api-template/internal/auth/repository"
 	_authService "khiemle.dev/golang-api-template/internal/auth/service"
+	_notificationHandler "khiemle.dev/golang-api-template/internal/notification/handler"
+	_notificationRepository "khiemle.dev/golang-api-template/internal/notification/repository"
+	_notificationService "khiemle.dev/golang-api-template/internal/notification/service"
 	_todoHandler "khiemle.dev/golang-api-template/internal/todo/handler"
+	_todoRepository "khiemle.dev/golang-api-template/internal/todo/repository"
 	_todoService "khiemle.dev/golang-api-template/internal/todo/service"
+	_userRepository "khiemle.dev/golang-api-template/internal/user/repository"
 	_userService "khiemle.dev/golang-api-template/internal/user/service"
+	_webhookHandler "khiemle.dev/golang-api-template/internal/webhook/handler"
+	_webhookRepository "khiemle.dev/golang-api-template/internal/webhook/repository"
+	_webhookService "khiemle.dev/golang-api-template/internal/webhook/service"
+	"khiemle.dev/golang-api-template/pkg/jobqueue"
+	"khiemle.dev/golang-api-template/pkg/middleware"
+	"khiemle.dev/golang-api-template/pkg/outbox"
+	"khiemle.dev/golang-api-template/pkg/pubsub"
+	"khiemle.dev/golang-api-template/pkg/scheduler"
+	"khiemle.dev/golang-api-template/pkg/txn"
 	util "khiemle.dev/golang-api-template/pkg/util"
 	"khiemle.dev/golang-api-template/pkg/util/token"
 )
 
 // Server represents the HTTP server.
 type Server struct {
-	router *gin.Engine
-	cfg    *util.Config
-	db     *gorm.DB
+	router     *gin.Engine
+	httpServer *http.Server
+	cfg        *util.Config
+	db         *gorm.DB
+	// scheduler is only run by the worker, the API lists its schedules
+	scheduler *scheduler.Scheduler
+	// pubsub is shared with the rest of the process, which closes it
+	pubsub pubsub.PubSub
+
+	// shutdownHooks run after the HTTP server stopped accepting requests
+	shutdownHooks []func(ctx context.Context) error
 }
 
 // NewServer creates a new HTTP server instance.
@@ -31,26 +62,79 @@ func NewServer() *Server {
 	return &Server{}
 }
 
-// Initialize initializes the HTTP server.
-func (s *Server) Initialize(cfg *util.Config, db *gorm.DB) error {
+// Initialize initializes the HTTP server. sched, see NewScheduler, is
+// listed by the admin endpoints. ps wakes up event streams and list sockets.
+func (s *Server) Initialize(cfg *util.Config, db *gorm.DB, sched *scheduler.Scheduler, ps pubsub.PubSub) error {
 	s.cfg = cfg
 	s.db = db
+	s.scheduler = sched
+	s.pubsub = ps
+
+	// Create a new Gin router. Requests are logged by LoggerMiddleware
+	// instead of gin's default text logger.
+	s.router = gin.New()
+
+	// Only take the client IP from X-Forwarded-For when set by a trusted
+	// proxy, clients could claim any address otherwise
+	trustedProxies, err := cfg.TrustedProxies()
+	if err != nil {
+		return err
+	}
+	if err := s.router.SetTrustedProxies(trustedProxies); err != nil {
+		return err
+	}
 
-	// Create a new Gin router
-	s.router = gin.Default()
+	// Let handlers read values, such as the current span, from the request context
+	s.router.ContextWithFallback = true
+
+	// Start a span for every request, continuing the caller's trace if any
+	s.router.Use(otelgin.Middleware(cfg.TracingServiceName))
+
+	s.router.Use(middleware.RequestIDMiddleware())
+	s.router.Use(middleware.LoggerMiddleware())
+	s.router.Use(middleware.ActorMiddleware())
+	s.router.Use(gin.Recovery())
+	s.router.Use(middleware.ErrorHandlerMiddleware())
+	s.router.Use(middleware.TimeoutMiddleware(cfg.HTTPRequestTimeout, routes.TodoEventsRoute, routes.ListSocketRoute, routes.AuditExportRoute))
+
+	// Report validation errors by JSON field name
+	util.UseJSONFieldNames()
 
 	// Setup routes
-	s.setupRoutes()
+	if err := s.setupRoutes(); err != nil {
+		return err
+	}
+
+	s.httpServer = &http.Server{
+		Addr:              cfg.HTTPServerAddress,
+		Handler:           s.router,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
 
 	return nil
 }
 
+// StartServer serves HTTP requests until Shutdown is called.
 func (s *Server) StartServer() error {
-	return s.router.Run(s.cfg.HTTPServerAddress)
+	err := s.httpServer.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops accepting requests, waits for in-flight ones and then runs
+// the shutdown hooks, e.g. to flush batched writes.
+func (s *Server) Shutdown(ctx context.Context) error {
+	errs := []error{s.httpServer.Shutdown(ctx)}
+	for _, hook := range s.shutdownHooks {
+		errs = append(errs, hook(ctx))
+	}
+	return errors.Join(errs...)
 }
 
 // setupRoutes sets up the routes for the server.
-func (s *Server) setupRoutes() {
+func (s *Server) setupRoutes() error {
 	log.Info().Msg("Setting up routes...")
 
 	// Routes for health check
@@ -61,14 +145,95 @@ func (s *Server) setupRoutes() {
 	// Token
 	tokenMaker := token.NewTokenMaker(s.cfg)
 
+	// Repositories
+	todoRepository := _todoRepository.NewGormTodoRepository(s.db)
+	labelRepository := _todoRepository.NewGormLabelRepository(s.db)
+	todoListRepository := _todoRepository.NewGormTodoListRepository(s.db)
+	listMemberRepository := _todoRepository.NewGormListMemberRepository(s.db)
+	listInvitationRepository := _todoRepository.NewGormListInvitationRepository(s.db)
+	checklistItemRepository := _todoRepository.NewGormChecklistItemRepository(s.db)
+	todoSeriesRepository := _todoRepository.NewGormTodoSeriesRepository(s.db)
+	reminderRepository := _todoRepository.NewGormReminderRepository(s.db)
+	todoEventRepository := _todoRepository.NewGormTodoEventRepository(s.db)
+	userRepository := _userRepository.NewGormUserRepository(s.db)
+	loginSessionRepository := _authRepository.NewGormLoginSessionRepository(s.db)
+	notificationRepository := _notificationRepository.NewGormNotificationRepository(s.db)
+	endpointRepository := _webhookRepository.NewGormEndpointRepository(s.db)
+	deliveryRepository := _webhookRepository.NewGormDeliveryRepository(s.db)
+	auditLogRepository := _auditRepository.NewGormAuditLogRepository(s.db)
+
+	// Jobs are run by the worker
+	jobs := jobqueue.NewClient(s.db)
+
+	// Wake up event streams and list sockets connected to any replica
+	ps := s.pubsub
+
+	// Write login session last used timestamps in batches
+	lastUsedBatcher := _authService.NewLastUsedBatcher(loginSessionRepository, s.cfg.SessionLastUsedFlushEvery)
+	lastUsedBatcher.Start()
+	s.shutdownHooks = append(s.shutdownHooks, lastUsedBatcher.Close)
+
+	// Services record domain events in the transaction of their changes,
+	// the worker dispatches them
+	transactor := txn.NewGormTransactor(s.db)
+	recorder := outbox.NewRecorder(s.db, ps)
+
 	// Services
-	todoService := _todoService.NewTodoService(s.db)
-	userService := _userService.NewUserService(s.db)
-	loginSessionService := _authService.NewLoginSessionService(s.db)
-	authService := _authService.NewAuthService(s.db, s.cfg, userService, loginSessionService, tokenMaker)
+	webhookService := _webhookService.NewWebhookService(endpointRepository, deliveryRepository, jobs, newWebhookClient(s.cfg), s.cfg.WebhookMaxAttempts)
+	todoService := _todoService.NewTodoService(todoRepository, labelRepository, todoListRepository, listMemberRepository, checklistItemRepository, todoSeriesRepository, reminderRepository, todoEventRepository, jobs, ps, transactor, recorder)
+	todoEventService := _todoService.NewTodoEventService(todoEventRepository, ps, s.cfg.TodoEventsGapTimeout)
+	checklistService := _todoService.NewChecklistService(checklistItemRepository, todoRepository, todoListRepository, listMemberRepository)
+	todoListService := _todoService.NewTodoListService(todoListRepository, listMemberRepository)
+	labelService := _todoService.NewLabelService(labelRepository)
+	notificationService := _notificationService.NewNotificationService(notificationRepository)
+	auditService := _auditService.NewAuditService(auditLogRepository)
+	userService := _userService.NewUserService(userRepository, transactor, recorder)
+	loginSessionService := _authService.NewLoginSessionService(loginSessionRepository, lastUsedBatcher, jobs, transactor, recorder)
+
+	// Cache session and user lookups done by the auth middleware
+	if s.cfg.AuthCacheTTL > 0 {
+		userService = _userService.NewCachedUserService(userService, s.cfg.AuthCacheTTL)
+		loginSessionService = _authService.NewCachedLoginSessionService(loginSessionService, s.cfg.AuthCacheTTL)
+	}
+
+	// Refreshing tokens goes through the cache too, so rotating them
+	// invalidates the cached session
+	authService := _authService.NewAuthService(s.cfg, userService, loginSessionService, tokenMaker, recorder)
+
+	// Pick how access tokens are verified. In stateless mode they are not
+	// checked against the login session, only against the revocation list.
+	var authMiddleware gin.HandlerFunc
+	if s.cfg.AuthStatelessAccessTokens {
+		revocationList := _authService.NewRevocationList(loginSessionRepository, s.cfg.AuthRevocationSyncEvery)
+		if err := revocationList.Start(context.Background()); err != nil {
+			return fmt.Errorf("sync revoked access tokens: %w", err)
+		}
+		s.shutdownHooks = append(s.shutdownHooks, revocationList.Close)
+
+		loginSessionService = _authService.NewRevokingLoginSessionService(loginSessionService, revocationList, s.cfg.AuthStatelessAccessTokenExpiry)
+		authMiddleware = middleware.VerifyStatelessTokenMiddleware(tokenMaker, revocationList, userService)
+	} else {
+		authMiddleware = middleware.VerifyTokenMiddleware(tokenMaker, loginSessionService, userService)
+	}
+
+	// Collaborators are looked up through the cached user service
+	listSharingService := _todoService.NewListSharingService(todoListRepository, listMemberRepository, listInvitationRepository, userService, jobs)
+	reminderService := newReminderService(s.db, userService, NewNotifier(s.cfg, s.db))
+	listLiveService := _todoService.NewListLiveService(todoRepository, todoListRepository, listMemberRepository, todoEventService, userService, ps, s.cfg.TodoEventsHeartbeat)
 
 	// Handlers
 	todoHandler := _todoHandler.NewTodoHandler(todoService)
+	checklistHandler := _todoHandler.NewChecklistHandler(checklistService)
+	reminderHandler := _todoHandler.NewReminderHandler(reminderService)
+	todoEventHandler := _todoHandler.NewTodoEventHandler(todoEventService, s.cfg.TodoEventsHeartbeat, s.cfg.TodoEventsMaxDuration)
+	labelHandler := _todoHandler.NewLabelHandler(labelService)
+	todoListHandler := _todoHandler.NewTodoListHandler(todoListService)
+	listSharingHandler := _todoHandler.NewListSharingHandler(listSharingService)
+	listLiveHandler := _todoHandler.NewListLiveHandler(listLiveService, todoService, s.cfg.TodoEventsHeartbeat, s.cfg.TodoEventsMaxDuration, s.cfg.ListSocketRateLimit, s.cfg.ListSocketRateBurst, s.cfg.ListSocketAllowedOrigins)
+	notificationHandler := _notificationHandler.NewNotificationHandler(notificationService)
+	webhookHandler := _webhookHandler.NewWebhookHandler(webhookService)
+	adminHandler := _adminHandler.NewAdminHandler(s.scheduler)
+	auditHandler := _auditHandler.NewAuditHandler(auditService)
 	authHandler := _authHandler.NewAuthHandler(s.cfg, authService, loginSessionService)
 
 	// Routes for v1 endpoints
@@ -76,15 +241,40 @@ func (s *Server) setupRoutes() {
 	{
 		// Setup todoGroupRouter
 		todoGroup := v1.Group("/todos")
-		routes.SetupTodoRouter(todoGroup, todoHandler)
+		routes.SetupTodoRouter(todoGroup, todoHandler, checklistHandler, reminderHandler, todoEventHandler, authMiddleware)
+
+		// Setup listGroupRouter
+		listGroup := v1.Group("/lists")
+		routes.SetupTodoListRouter(listGroup, todoListHandler, listSharingHandler, listLiveHandler, authMiddleware)
+
+		// Setup invitationGroupRouter
+		invitationGroup := v1.Group("/invitations")
+		routes.SetupInvitationRouter(invitationGroup, listSharingHandler, authMiddleware)
+
+		// Setup labelGroupRouter
+		labelGroup := v1.Group("/labels")
+		routes.SetupLabelRouter(labelGroup, labelHandler, authMiddleware)
+
+		// Setup notificationGroupRouter
+		notificationGroup := v1.Group("/notifications")
+		routes.SetupNotificationRouter(notificationGroup, notificationHandler, authMiddleware)
+
+		// Setup webhookGroupRouter
+		webhookGroup := v1.Group("/webhooks")
+		routes.SetupWebhookRouter(webhookGroup, webhookHandler, authMiddleware)
 
 		// Setup authGroupRouter
 		authGroup := v1.Group("/auth")
-		routes.SetupAuthRouter(authGroup, authHandler, tokenMaker, loginSessionService, userService)
+		routes.SetupAuthRouter(authGroup, authHandler, authMiddleware)
+
+		// Setup adminGroupRouter
+		adminGroup := v1.Group("/admin")
+		routes.SetupAdminRouter(adminGroup, adminHandler, auditHandler, authMiddleware)
 	}
 
 	log.Info().Msg("Setup routes for Swagger")
 	v1.GET(fmt.Sprintf("/%s/*any", s.cfg.SwaggerURL), ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	log.Info().Msg("Routes setup complete!")
+	return nil
 }
@@ -0,0 +1,322 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/schemas"
+	"khiemle.dev/golang-api-template/pkg/database/databasetest"
+	"khiemle.dev/golang-api-template/pkg/pubsub"
+	util "khiemle.dev/golang-api-template/pkg/util"
+)
+
+const testPassword = "Passw0rd!"
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	os.Exit(m.Run())
+}
+
+// testServer serves the API from a temporary database without listening
+type testServer struct {
+	t      *testing.T
+	server *Server
+	db     *gorm.DB
+}
+
+// testConfig loads the config from an app.env holding the defaults needed by
+// tests and the given KEY=value settings.
+func testConfig(t *testing.T, settings ...string) util.Config {
+	t.Helper()
+
+	dir := t.TempDir()
+	lines := append([]string{
+		"DB_DRIVER=sqlite",
+		"JWT_SECRET_KEY=707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f",
+		"LOG_FILENAME=" + filepath.Join(dir, "app.log"),
+	}, settings...)
+	err := os.WriteFile(filepath.Join(dir, "app.env"), []byte(strings.Join(lines, "\n")+"\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := util.LoadConfig(dir)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	return cfg
+}
+
+func newTestServer(t *testing.T, settings ...string) *testServer {
+	t.Helper()
+
+	cfg := testConfig(t, settings...)
+	db := databasetest.New(t)
+	ps := pubsub.New(db)
+	t.Cleanup(func() {
+		if err := ps.Close(context.Background()); err != nil {
+			t.Errorf("close pubsub: %v", err)
+		}
+	})
+	sched, err := NewScheduler(&cfg, db, ps)
+	if err != nil {
+		t.Fatalf("create scheduler: %v", err)
+	}
+	server := NewServer()
+	if err := server.Initialize(&cfg, db, sched, ps); err != nil {
+		t.Fatalf("initialize server: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := server.Shutdown(context.Background()); err != nil {
+			t.Errorf("shutdown server: %v", err)
+		}
+	})
+
+	return &testServer{t: t, server: server, db: db}
+}
+
+// do sends a request with a JSON body, if not nil, authenticated by token,
+// if not empty.
+func (s *testServer) do(method string, path string, token string, body any) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.server.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// register registers a user and logs them in
+func (s *testServer) register(username string) schemas.AuthLoginResponse {
+	s.t.Helper()
+
+	rec := s.do(http.MethodPost, "/v1/auth/register", "", schemas.AuthRegisterRequest{
+		Username:        username,
+		Email:           username + "@example.com",
+		Name:            username,
+		Password:        testPassword,
+		ConfirmPassword: testPassword,
+	})
+	expectStatus(s.t, rec, http.StatusOK)
+
+	rec = s.do(http.MethodPost, "/v1/auth/login", "", schemas.AuthLoginRequest{
+		Username: username,
+		Password: testPassword,
+	})
+	expectStatus(s.t, rec, http.StatusOK)
+	return decode[schemas.AuthLoginResponse](s.t, rec)
+}
+
+func expectStatus(t *testing.T, rec *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, status, rec.Body.String())
+	}
+}
+
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decode %T: %v: %s", v, err, rec.Body.String())
+	}
+	return v
+}
+
+// decodeData decodes the data of a schemas.APIResponse
+func decodeData[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	return decode[struct {
+		Data T `json:"data"`
+	}](t, rec).Data
+}
+
+func TestAuthFlow(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.register("alice")
+
+	rec := s.do(http.MethodGet, "/v1/auth/verify_access_token", alice.AccessToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	verified := decodeData[struct {
+		User schemas.AuthLoginUserResponse `json:"user"`
+	}](t, rec)
+	if verified.User.Username != "alice" {
+		t.Errorf("verified user = %+v, want alice", verified.User)
+	}
+
+	rec = s.do(http.MethodGet, "/v1/auth/verify_access_token", "", nil)
+	expectStatus(t, rec, http.StatusUnauthorized)
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/problem+json") {
+		t.Errorf("Content-Type of an error = %q, want application/problem+json", got)
+	}
+
+	rec = s.do(http.MethodGet, "/v1/auth/refresh_token", alice.RefreshToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+
+	// Refresh tokens are rotated
+	rec = s.do(http.MethodGet, "/v1/auth/refresh_token", alice.RefreshToken, nil)
+	expectStatus(t, rec, http.StatusUnauthorized)
+
+	rec = s.do(http.MethodDelete, "/v1/auth/logout", alice.AccessToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	rec = s.do(http.MethodGet, "/v1/auth/verify_access_token", alice.AccessToken, nil)
+	expectStatus(t, rec, http.StatusUnauthorized)
+}
+
+func TestRegisterErrors(t *testing.T) {
+	s := newTestServer(t)
+	s.register("alice")
+
+	tests := []struct {
+		name   string
+		req    schemas.AuthRegisterRequest
+		status int
+		code   string
+	}{
+		{
+			name:   "username taken",
+			req:    schemas.AuthRegisterRequest{Username: "alice", Email: "other@example.com", Name: "A", Password: testPassword, ConfirmPassword: testPassword},
+			status: http.StatusConflict,
+			code:   "username_taken",
+		},
+		{
+			name:   "email taken",
+			req:    schemas.AuthRegisterRequest{Username: "other", Email: "alice@example.com", Name: "A", Password: testPassword, ConfirmPassword: testPassword},
+			status: http.StatusConflict,
+			code:   "email_taken",
+		},
+		{
+			name:   "weak password",
+			req:    schemas.AuthRegisterRequest{Username: "bob", Email: "bob@example.com", Name: "B", Password: "password", ConfirmPassword: "password"},
+			status: http.StatusUnprocessableEntity,
+			code:   "validation_failed",
+		},
+		{
+			name:   "passwords differ",
+			req:    schemas.AuthRegisterRequest{Username: "bob", Email: "bob@example.com", Name: "B", Password: testPassword, ConfirmPassword: testPassword + "x"},
+			status: http.StatusUnprocessableEntity,
+			code:   "validation_failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(http.MethodPost, "/v1/auth/register", "", tt.req)
+			expectStatus(t, rec, tt.status)
+			if problem := decode[schemas.ProblemDetails](t, rec); problem.Code != tt.code {
+				t.Errorf("code = %q, want %q", problem.Code, tt.code)
+			}
+		})
+	}
+}
+
+func TestTodoCRUD(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.register("alice")
+	bob := s.register("bob")
+
+	rec := s.do(http.MethodPost, "/v1/todos/", alice.AccessToken, schemas.CreateTodoRequest{Name: "write tests", Description: "for the api"})
+	expectStatus(t, rec, http.StatusOK)
+	todo := decodeData[schemas.TodoResponse](t, rec)
+	if todo.OwnerID != alice.User.ID || todo.Name != "write tests" {
+		t.Fatalf("created todo = %+v", todo)
+	}
+	path := fmt.Sprintf("/v1/todos/%d", todo.ID)
+
+	rec = s.do(http.MethodGet, path, alice.AccessToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+
+	// Todos of other users look missing
+	rec = s.do(http.MethodGet, path, bob.AccessToken, nil)
+	expectStatus(t, rec, http.StatusNotFound)
+
+	rec = s.do(http.MethodPatch, path, alice.AccessToken, map[string]any{"done": true})
+	expectStatus(t, rec, http.StatusOK)
+	if todo := decodeData[schemas.TodoResponse](t, rec); !todo.Done || todo.CompletedAt == nil {
+		t.Errorf("updated todo = %+v, want it done", todo)
+	}
+
+	rec = s.do(http.MethodDelete, path, alice.AccessToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	rec = s.do(http.MethodGet, path, alice.AccessToken, nil)
+	expectStatus(t, rec, http.StatusNotFound)
+}
+
+func TestStatelessLogout(t *testing.T) {
+	s := newTestServer(t, "AUTH_STATELESS_ACCESS_TOKENS=true")
+	alice := s.register("alice")
+
+	rec := s.do(http.MethodGet, "/v1/auth/verify_access_token", alice.AccessToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+
+	rec = s.do(http.MethodDelete, "/v1/auth/logout", alice.AccessToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	rec = s.do(http.MethodGet, "/v1/auth/verify_access_token", alice.AccessToken, nil)
+	expectStatus(t, rec, http.StatusUnauthorized)
+}
+
+func TestClientIPFromTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings []string
+		clientIP string
+	}{
+		// httptest requests come from 192.0.2.1
+		{name: "no trusted proxies", clientIP: "192.0.2.1"},
+		{name: "trusted proxy", settings: []string{"HTTP_TRUSTED_PROXIES=192.0.2.0/24"}, clientIP: "203.0.113.7"},
+		{name: "untrusted proxy", settings: []string{"HTTP_TRUSTED_PROXIES=10.0.0.1"}, clientIP: "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, tt.settings...)
+			s.register("alice")
+
+			data, err := json.Marshal(schemas.AuthLoginRequest{Username: "alice", Password: testPassword})
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/v1/auth/login", bytes.NewReader(data))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Forwarded-For", "203.0.113.7")
+			rec := httptest.NewRecorder()
+			s.server.router.ServeHTTP(rec, req)
+			expectStatus(t, rec, http.StatusOK)
+			login := decode[schemas.AuthLoginResponse](t, rec)
+
+			var clientIP string
+			err = s.db.Table("login_sessions").Select("client_ip").Where("id = ?", login.LoginSessionID).Scan(&clientIP).Error
+			if err != nil {
+				t.Fatal(err)
+			}
+			if clientIP != tt.clientIP {
+				t.Errorf("client IP = %q, want %q", clientIP, tt.clientIP)
+			}
+		})
+	}
+}
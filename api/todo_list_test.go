@@ -0,0 +1,150 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"khiemle.dev/golang-api-template/internal/schemas"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+)
+
+func (s *testServer) createList(token string, name string) schemas.TodoListResponse {
+	s.t.Helper()
+	rec := s.do(http.MethodPost, "/v1/lists/", token, schemas.CreateTodoListRequest{Name: name})
+	expectStatus(s.t, rec, http.StatusOK)
+	return decodeData[schemas.TodoListResponse](s.t, rec)
+}
+
+func TestArchivedListRejectsTodos(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.register("alice")
+
+	list := s.createList(alice.AccessToken, "groceries")
+	listID := int(list.ID)
+	milk := s.createTodo(alice.AccessToken, schemas.CreateTodoRequest{Name: "milk", ListID: &listID})
+	eggs := s.createTodo(alice.AccessToken, schemas.CreateTodoRequest{Name: "eggs"})
+
+	rec := s.do(http.MethodPost, fmt.Sprintf("/v1/lists/%d/archive", list.ID), alice.AccessToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+
+	rec = s.do(http.MethodPost, "/v1/todos/", alice.AccessToken, schemas.CreateTodoRequest{Name: "bread", Description: "bread", ListID: &listID})
+	expectStatus(t, rec, http.StatusConflict)
+	rec = s.do(http.MethodPut, fmt.Sprintf("/v1/todos/%d/list", eggs.ID), alice.AccessToken, schemas.MoveTodoRequest{ListID: &listID})
+	expectStatus(t, rec, http.StatusConflict)
+	milkID := int(milk.ID)
+	rec = s.do(http.MethodPost, "/v1/todos/", alice.AccessToken, schemas.CreateTodoRequest{Name: "lactose free", Description: "lactose free", ParentID: &milkID})
+	expectStatus(t, rec, http.StatusConflict)
+
+	rec = s.do(http.MethodPost, fmt.Sprintf("/v1/lists/%d/unarchive", list.ID), alice.AccessToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	s.createTodo(alice.AccessToken, schemas.CreateTodoRequest{Name: "bread", ListID: &listID})
+	if got := s.listTodos(alice.AccessToken, fmt.Sprintf("/v1/todos/?list_id=%d", list.ID)); fmt.Sprint(got) != "[bread milk]" {
+		t.Errorf("todos of the list = %v, want [bread milk]", got)
+	}
+}
+
+// share invites the user to the list with the role and accepts the
+// invitation on their behalf
+func (s *testServer) share(ownerToken string, listID uint, invitee schemas.AuthLoginResponse, role string) {
+	s.t.Helper()
+	rec := s.do(http.MethodPost, fmt.Sprintf("/v1/lists/%d/invitations", listID), ownerToken, schemas.InviteListMemberRequest{Invitee: invitee.User.Username, Role: role})
+	expectStatus(s.t, rec, http.StatusOK)
+
+	rec = s.do(http.MethodGet, "/v1/invitations/", invitee.AccessToken, nil)
+	expectStatus(s.t, rec, http.StatusOK)
+	for _, invitation := range decode[schemas.ListListInvitationResponse](s.t, rec).Invitations {
+		if invitation.ListID == listID {
+			rec = s.do(http.MethodPost, fmt.Sprintf("/v1/invitations/%d/accept", invitation.ID), invitee.AccessToken, nil)
+			expectStatus(s.t, rec, http.StatusOK)
+			return
+		}
+	}
+	s.t.Fatalf("no invitation to list %d", listID)
+}
+
+func TestInviteDoesNotTellWhoHasAnAccount(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.register("alice")
+	s.register("bob")
+	list := s.createList(alice.AccessToken, "groceries")
+
+	path := fmt.Sprintf("/v1/lists/%d/invitations", list.ID)
+	existing := s.do(http.MethodPost, path, alice.AccessToken, schemas.InviteListMemberRequest{Invitee: "bob", Role: "editor"})
+	unknown := s.do(http.MethodPost, path, alice.AccessToken, schemas.InviteListMemberRequest{Invitee: "nobody@example.com", Role: "editor"})
+	expectStatus(t, existing, http.StatusOK)
+	expectStatus(t, unknown, http.StatusOK)
+	if existing.Body.String() != unknown.Body.String() {
+		t.Errorf("invite answered %s for an existing user and %s for an unknown one", existing.Body, unknown.Body)
+	}
+}
+
+func TestSharedTodosKeepLabelsPrivate(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.register("alice")
+	bob := s.register("bob")
+	list := s.createList(alice.AccessToken, "groceries")
+	s.share(alice.AccessToken, list.ID, bob, "editor")
+
+	listID := int(list.ID)
+	milk := s.createTodo(alice.AccessToken, schemas.CreateTodoRequest{Name: "milk", ListID: &listID})
+	s.attachLabel(alice.AccessToken, milk.ID, s.createLabel(alice.AccessToken, "dairy").ID)
+	s.attachLabel(bob.AccessToken, milk.ID, s.createLabel(bob.AccessToken, "for the party").ID)
+
+	for _, user := range []struct {
+		login schemas.AuthLoginResponse
+		label string
+		other string
+	}{{alice, "dairy", "for the party"}, {bob, "for the party", "dairy"}} {
+		rec := s.do(http.MethodGet, fmt.Sprintf("/v1/todos/%d", milk.ID), user.login.AccessToken, nil)
+		expectStatus(t, rec, http.StatusOK)
+		labels := decode[schemas.GetTodoByIdResponse](t, rec).Todo.Labels
+		if len(labels) != 1 || labels[0].Name != user.label {
+			t.Errorf("%s sees labels %+v, want only %q", user.login.User.Username, labels, user.label)
+		}
+
+		// Events streamed to the user carry the same labels
+		events := []model.TodoEvent{}
+		if err := s.db.Where("user_id = ? AND todo_id = ?", user.login.User.ID, milk.ID).Find(&events).Error; err != nil {
+			t.Fatalf("todo events: %v", err)
+		}
+		if len(events) == 0 {
+			t.Errorf("no todo events for %s", user.login.User.Username)
+		}
+		for _, event := range events {
+			if strings.Contains(event.Data, user.other) {
+				t.Errorf("event of %s carries the labels of someone else: %s", user.login.User.Username, event.Data)
+			}
+		}
+	}
+}
+
+func TestMoveTodoOutOfSharedList(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.register("alice")
+	bob := s.register("bob")
+	list := s.createList(alice.AccessToken, "groceries")
+	s.share(alice.AccessToken, list.ID, bob, "editor")
+
+	listID := int(list.ID)
+	milk := s.createTodo(alice.AccessToken, schemas.CreateTodoRequest{Name: "milk", ListID: &listID})
+	eggs := s.createTodo(bob.AccessToken, schemas.CreateTodoRequest{Name: "eggs", ListID: &listID})
+	bread := s.createTodo(bob.AccessToken, schemas.CreateTodoRequest{Name: "bread", ListID: &listID})
+	move := func(token string, todoID uint) *httptest.ResponseRecorder {
+		return s.do(http.MethodPut, fmt.Sprintf("/v1/todos/%d/list", todoID), token, schemas.MoveTodoRequest{})
+	}
+
+	// Editors only take their own todos, owners of the list any of them
+	expectStatus(t, move(bob.AccessToken, milk.ID), http.StatusForbidden)
+	expectStatus(t, move(bob.AccessToken, eggs.ID), http.StatusOK)
+	expectStatus(t, move(alice.AccessToken, bread.ID), http.StatusOK)
+
+	if got := s.listTodos(alice.AccessToken, fmt.Sprintf("/v1/todos/?list_id=%d", list.ID)); fmt.Sprint(got) != "[milk]" {
+		t.Errorf("todos of the list = %v, want [milk]", got)
+	}
+	if got := s.listTodos(bob.AccessToken, "/v1/todos/"); fmt.Sprint(got) != "[eggs milk]" {
+		t.Errorf("todos of bob = %v, want [eggs milk]", got)
+	}
+}
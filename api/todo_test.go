@@ -0,0 +1,96 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"khiemle.dev/golang-api-template/internal/schemas"
+)
+
+func (s *testServer) createTodo(token string, req schemas.CreateTodoRequest) schemas.TodoResponse {
+	s.t.Helper()
+	if req.Description == "" {
+		req.Description = req.Name
+	}
+	rec := s.do(http.MethodPost, "/v1/todos/", token, req)
+	expectStatus(s.t, rec, http.StatusOK)
+	return decodeData[schemas.TodoResponse](s.t, rec)
+}
+
+func (s *testServer) createLabel(token string, name string) schemas.LabelResponse {
+	s.t.Helper()
+	rec := s.do(http.MethodPost, "/v1/labels/", token, schemas.CreateLabelRequest{Name: name, Color: "#ff0000"})
+	expectStatus(s.t, rec, http.StatusOK)
+	return decodeData[schemas.LabelResponse](s.t, rec)
+}
+
+func (s *testServer) attachLabel(token string, todoID uint, labelID uint) {
+	s.t.Helper()
+	rec := s.do(http.MethodPut, fmt.Sprintf("/v1/todos/%d/labels/%d", todoID, labelID), token, nil)
+	expectStatus(s.t, rec, http.StatusOK)
+}
+
+// listTodosResponse returns the todos listed at path
+func (s *testServer) listTodosResponse(token string, path string) []schemas.TodoResponse {
+	s.t.Helper()
+	rec := s.do(http.MethodGet, path, token, nil)
+	expectStatus(s.t, rec, http.StatusOK)
+	return decode[schemas.ListTodoResponse](s.t, rec).Todos
+}
+
+// listTodos returns the sorted names of the todos listed at path
+func (s *testServer) listTodos(token string, path string) []string {
+	s.t.Helper()
+	names := []string{}
+	for _, todo := range s.listTodosResponse(token, path) {
+		names = append(names, todo.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestFilterTodosByLabel(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.register("alice")
+
+	work := s.createLabel(alice.AccessToken, "work")
+	urgent := s.createLabel(alice.AccessToken, "urgent")
+	report := s.createTodo(alice.AccessToken, schemas.CreateTodoRequest{Name: "report"})
+	deploy := s.createTodo(alice.AccessToken, schemas.CreateTodoRequest{Name: "deploy"})
+	s.createTodo(alice.AccessToken, schemas.CreateTodoRequest{Name: "groceries"})
+	s.attachLabel(alice.AccessToken, report.ID, work.ID)
+	s.attachLabel(alice.AccessToken, deploy.ID, work.ID)
+	s.attachLabel(alice.AccessToken, deploy.ID, urgent.ID)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"deploy", "groceries", "report"}},
+		{"?tag=work", []string{"deploy", "report"}},
+		{"?tag=work&tag=urgent", []string{"deploy"}},
+		{"?tag=work&tag=urgent&match=any", []string{"deploy", "report"}},
+		{"?tag=missing", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got := s.listTodos(alice.AccessToken, "/v1/todos/"+tt.query)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("todos = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Label names are unique per owner only
+	rec := s.do(http.MethodPost, "/v1/labels/", alice.AccessToken, schemas.CreateLabelRequest{Name: "work"})
+	expectStatus(t, rec, http.StatusConflict)
+	bob := s.register("bob")
+	s.createLabel(bob.AccessToken, "work")
+
+	// Labels of other users cannot be attached
+	rec = s.do(http.MethodPut, fmt.Sprintf("/v1/todos/%d/labels/%d", report.ID, s.createLabel(bob.AccessToken, "mine").ID), alice.AccessToken, nil)
+	expectStatus(t, rec, http.StatusNotFound)
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"khiemle.dev/golang-api-template/internal/schemas"
+	"khiemle.dev/golang-api-template/pkg/tracing"
+)
+
+// TestRequestSpans checks that queries, token and password checks are traced
+// as children of the span of their request
+func TestRequestSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	cfg := testConfig(t)
+	tp := tracing.NewTracerProvider(cfg, sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	// The server and its database are instrumented with the provider set above
+	s := newTestServer(t)
+	s.register("alice")
+	exporter.Reset()
+
+	rec := s.do(http.MethodPost, "/v1/auth/login", "", schemas.AuthLoginRequest{Username: "alice", Password: testPassword})
+	expectStatus(t, rec, http.StatusOK)
+	login := decode[schemas.AuthLoginResponse](t, rec)
+	expectChildSpans(t, exporter.GetSpans(), "bcrypt.CompareHashAndPassword", "token.Generate", "gorm.")
+	exporter.Reset()
+
+	rec = s.do(http.MethodPost, "/v1/todos/", login.AccessToken, schemas.CreateTodoRequest{Name: "traced", Description: "todo"})
+	expectStatus(t, rec, http.StatusOK)
+	expectChildSpans(t, exporter.GetSpans(), "token.Verify", "gorm.")
+}
+
+// expectChildSpans checks that spans hold a single server span and that a
+// span whose name starts with each prefix descends from it
+func expectChildSpans(t *testing.T, spans tracetest.SpanStubs, prefixes ...string) {
+	t.Helper()
+
+	byID := map[trace.SpanID]tracetest.SpanStub{}
+	var server *tracetest.SpanStub
+	names := []string{}
+	for i, span := range spans {
+		byID[span.SpanContext.SpanID()] = span
+		names = append(names, span.Name)
+		if span.SpanKind == trace.SpanKindServer {
+			if server != nil {
+				t.Fatalf("more than one server span in %v", names)
+			}
+			server = &spans[i]
+		}
+	}
+	if server == nil {
+		t.Fatalf("no server span in %v", names)
+	}
+
+	descends := func(span tracetest.SpanStub) bool {
+		for span.Parent.IsValid() {
+			if span.Parent.SpanID() == server.SpanContext.SpanID() {
+				return true
+			}
+			parent, ok := byID[span.Parent.SpanID()]
+			if !ok {
+				return false
+			}
+			span = parent
+		}
+		return false
+	}
+
+	for _, prefix := range prefixes {
+		found := false
+		for _, span := range spans {
+			if !strings.HasPrefix(span.Name, prefix) {
+				continue
+			}
+			found = true
+			if !descends(span) {
+				t.Errorf("span %q is not a child of the request span %q", span.Name, server.Name)
+			}
+		}
+		if !found {
+			t.Errorf("no %q span in %v", prefix, names)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"khiemle.dev/golang-api-template/internal/schemas"
+	_todoModel "khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/pkg/outbox"
+)
+
+func TestTrashJanitorRecordsPurges(t *testing.T) {
+	s := newTestServer(t, "TODO_TRASH_RETENTION=24h")
+	alice := s.register("alice")
+
+	expired := s.createTodo(alice.AccessToken, schemas.CreateTodoRequest{Name: "old"})
+	parentID := int(expired.ID)
+	subtask := s.createTodo(alice.AccessToken, schemas.CreateTodoRequest{Name: "old subtask", ParentID: &parentID})
+	recent := s.createTodo(alice.AccessToken, schemas.CreateTodoRequest{Name: "recent"})
+	for _, id := range []uint{expired.ID, recent.ID} {
+		rec := s.do(http.MethodDelete, fmt.Sprintf("/v1/todos/%d", id), alice.AccessToken, nil)
+		expectStatus(t, rec, http.StatusOK)
+	}
+	err := s.db.Model(&_todoModel.Todo{}).Unscoped().
+		Where("id IN ?", []uint{expired.ID, subtask.ID}).
+		Update("deleted_at", time.Now().Add(-48*time.Hour)).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	janitor, err := newTrashJanitor(s.server.cfg, s.db, s.server.pubsub)
+	if err != nil {
+		t.Fatalf("newTrashJanitor: %v", err)
+	}
+	if err := janitor.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	var remaining []uint
+	if err := s.db.Model(&_todoModel.Todo{}).Unscoped().Order("id").Pluck("id", &remaining).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining) != 1 || remaining[0] != recent.ID {
+		t.Errorf("todos = %v, want only the recently trashed %d", remaining, recent.ID)
+	}
+
+	// Purges are audited like the ones of users
+	var purged []uint
+	err = s.db.Model(&outbox.Event{}).Where("type = ?", _todoModel.TodoEventPurged).Order("aggregate_id").Pluck("aggregate_id", &purged).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(purged) != 2 || purged[0] != expired.ID || purged[1] != subtask.ID {
+		t.Errorf("todo.purged events of %v, want %d and %d", purged, expired.ID, subtask.ID)
+	}
+}
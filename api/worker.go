@@ -0,0 +1,70 @@
+package api
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	_authRepository "khiemle.dev/golang-api-template/internal/auth/repository"
+	_authService "khiemle.dev/golang-api-template/internal/auth/service"
+	_todoRepository "khiemle.dev/golang-api-template/internal/todo/repository"
+	_todoService "khiemle.dev/golang-api-template/internal/todo/service"
+	_webhookRepository "khiemle.dev/golang-api-template/internal/webhook/repository"
+	_webhookService "khiemle.dev/golang-api-template/internal/webhook/service"
+	"khiemle.dev/golang-api-template/pkg/jobqueue"
+	"khiemle.dev/golang-api-template/pkg/pubsub"
+	util "khiemle.dev/golang-api-template/pkg/util"
+)
+
+// NewWorker creates the background job worker. Handlers of every job type are
+// registered here with jobqueue.Handle.
+func NewWorker(cfg *util.Config, db *gorm.DB, ps pubsub.PubSub) *jobqueue.Worker {
+	worker := jobqueue.NewWorker(db, newHolderID(), cfg.JobsConcurrency, cfg.JobsPollInterval, cfg.JobsLockTimeout)
+
+	notifier := NewNotifier(cfg, db)
+	userService := newUserService(db, ps)
+
+	// Deliver due-date reminders through their channel
+	reminderService := newReminderService(db, userService, notifier)
+	jobqueue.Handle(worker, _todoService.JobDeliverReminder, reminderService.Deliver)
+
+	// Tell list members about changes to shared lists, and invitees they were
+	// invited
+	sharedTodoNotifier := _todoService.NewSharedTodoNotifier(
+		_todoRepository.NewGormTodoRepository(db),
+		_todoRepository.NewGormTodoListRepository(db),
+		_todoRepository.NewGormListMemberRepository(db),
+		userService,
+		notifier,
+	)
+	jobqueue.Handle(worker, _todoService.JobNotifyTodoUpdated, sharedTodoNotifier.Notify)
+	jobqueue.Handle(worker, _todoService.JobNotifyListActivity, sharedTodoNotifier.NotifyActivity)
+
+	// Tell users about logins from unknown devices
+	loginAlerter := _authService.NewLoginAlerter(_authRepository.NewGormLoginSessionRepository(db), notifier)
+	jobqueue.Handle(worker, _authService.JobNotifyNewLogin, loginAlerter.Notify)
+
+	// Deliver events to the webhooks of users, disabling failing ones
+	deliverer := _webhookService.NewDeliverer(
+		_webhookRepository.NewGormEndpointRepository(db),
+		_webhookRepository.NewGormDeliveryRepository(db),
+		notifier,
+		newWebhookClient(cfg),
+		cfg.WebhookDisableAfterFailures,
+	)
+	jobqueue.Handle(worker, _webhookService.JobDeliverWebhook, deliverer.Deliver)
+
+	return worker
+}
+
+// newHolderID returns the id claiming jobs and scheduled runs for this process,
+// made of the host name and process id with a random suffix so restarted
+// processes do not reuse it.
+func newHolderID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), uuid.NewString()[:8])
+}
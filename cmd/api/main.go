@@ -1,8 +1,24 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	// Embed the timezone database, the runtime image does not ship one and
+	// recurring todos are expanded in the timezone of their user
+	_ "time/tzdata"
+
 	"khiemle.dev/golang-api-template/api"
 	"khiemle.dev/golang-api-template/pkg/database"
+	"khiemle.dev/golang-api-template/pkg/jobqueue"
+	"khiemle.dev/golang-api-template/pkg/metrics"
+	"khiemle.dev/golang-api-template/pkg/outbox"
+	"khiemle.dev/golang-api-template/pkg/pubsub"
+	"khiemle.dev/golang-api-template/pkg/tracing"
 	util "khiemle.dev/golang-api-template/pkg/util"
 
 	"github.com/rs/zerolog/log"
@@ -10,6 +26,14 @@ import (
 	_ "khiemle.dev/golang-api-template/docs"
 )
 
+// Process modes, the API and the background job worker can run in the same
+// process or be scaled separately
+const (
+	modeAPI    = "api"
+	modeWorker = "worker"
+	modeAll    = "all"
+)
+
 //	@title			Golang API Template
 //	@version		0.1.0
 //	@description	This is the template for Golang API.
@@ -27,11 +51,43 @@ import (
 // @name						Authorization
 // @description				Enter JWT ***
 func main() {
+	mode := flag.String("mode", modeAll, "what to run: api, worker or all")
+	flag.Parse()
+	if *mode != modeAPI && *mode != modeWorker && *mode != modeAll {
+		log.Fatal().Msgf("Unknown mode %q", *mode)
+		return
+	}
+
 	cfg, err := util.LoadConfig(".")
 	if err != nil {
 		log.Fatal().Err(err).Msg("Could not load config")
 		return
 	}
+	util.ConfigLogger(cfg)
+
+	// Setup OpenTelemetry tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not setup tracing")
+		return
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Error().Err(err).Msg("Could not shutdown tracing")
+		}
+	}()
+
+	// Setup OpenTelemetry metrics
+	shutdownMetrics, err := metrics.Setup(context.Background(), cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not setup metrics")
+		return
+	}
+	defer func() {
+		if err := shutdownMetrics(context.Background()); err != nil {
+			log.Error().Err(err).Msg("Could not shutdown metrics")
+		}
+	}()
 
 	// Initialize a new GORM DB instance
 	db, err := database.NewGormDB(cfg)
@@ -55,20 +111,102 @@ func main() {
 	}
 	log.Info().Msg("Ran database migrations")
 
-	// Create API server
-	server := api.NewServer()
-	err = server.Initialize(&cfg, db)
-	if err != nil {
-		log.Fatal().Err(err).Msg("Could not initialize server")
+	// Todos created before they had owners go to TODO_UNOWNED_OWNER
+	if _, err := database.ClaimUnownedTodos(context.Background(), db, cfg.TodoUnownedOwner); err != nil {
+		log.Fatal().Err(err).Msg("Could not claim todos without owner")
 		return
 	}
-	log.Info().Msg("Initialized server")
 
-	// Start server
-	err = server.StartServer()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// A single connection listens for notifications of every component,
+	// closed once they all stopped
+	ps := pubsub.New(db)
+
+	// Schedules are run by the worker and listed by the API
+	sched, err := api.NewScheduler(&cfg, db, ps)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Could not start server")
+		log.Fatal().Err(err).Msg("Could not create scheduler")
 		return
 	}
-	log.Info().Msgf("Listening and serving HTTP on %s", cfg.HTTPServerAddress)
+
+	// Create and start API server
+	var server *api.Server
+	if *mode == modeAPI || *mode == modeAll {
+		server = api.NewServer()
+		err = server.Initialize(&cfg, db, sched, ps)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Could not initialize server")
+			return
+		}
+		log.Info().Msg("Initialized server")
+
+		go func() {
+			err := server.StartServer()
+			if err != nil {
+				log.Fatal().Err(err).Msg("Could not start server")
+			}
+		}()
+		log.Info().Msgf("Listening and serving HTTP on %s", cfg.HTTPServerAddress)
+	}
+
+	// Start background job worker, outbox dispatcher and periodic tasks
+	var worker *jobqueue.Worker
+	var dispatcher *outbox.Dispatcher
+	if *mode == modeWorker || *mode == modeAll {
+		worker = api.NewWorker(&cfg, db, ps)
+		worker.Start()
+		log.Info().Msgf("Running background jobs with concurrency %d", cfg.JobsConcurrency)
+
+		dispatcher, err = api.NewDispatcher(&cfg, db, ps)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Could not create outbox dispatcher")
+			return
+		}
+		dispatcher.Start()
+		log.Info().Msg("Dispatching domain events")
+
+		sched.Start()
+		log.Info().Msg("Running scheduled tasks")
+	}
+
+	// Wait for interrupt signal to gracefully shutdown
+	<-ctx.Done()
+	log.Info().Msg("Shutting down...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if server != nil {
+		err = server.Shutdown(shutdownCtx)
+		if err != nil {
+			log.Error().Err(err).Msg("Could not shutdown server gracefully")
+		}
+		log.Info().Msg("Server stopped")
+	}
+	if worker != nil {
+		err = worker.Close(shutdownCtx)
+		if err != nil {
+			log.Error().Err(err).Msg("Could not wait for running jobs")
+		}
+		log.Info().Msg("Worker stopped")
+	}
+	if dispatcher != nil {
+		err = dispatcher.Close(shutdownCtx)
+		if err != nil {
+			log.Error().Err(err).Msg("Could not wait for outbox subscribers")
+		}
+		log.Info().Msg("Outbox dispatcher stopped")
+	}
+	if *mode == modeWorker || *mode == modeAll {
+		err = sched.Close(shutdownCtx)
+		if err != nil {
+			log.Error().Err(err).Msg("Could not wait for scheduled tasks")
+		}
+		log.Info().Msg("Scheduler stopped")
+	}
+	err = ps.Close(shutdownCtx)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not close pubsub")
+	}
 }
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"khiemle.dev/golang-api-template/internal/schemas"
+	"khiemle.dev/golang-api-template/pkg/middleware"
+	"khiemle.dev/golang-api-template/pkg/scheduler"
+)
+
+type AdminHandler interface {
+	ListSchedulesHandler(c *gin.Context)
+}
+
+type adminHandler struct {
+	scheduler *scheduler.Scheduler
+}
+
+func NewAdminHandler(scheduler *scheduler.Scheduler) AdminHandler {
+	return &adminHandler{
+		scheduler: scheduler,
+	}
+}
+
+// ListSchedulesHandler godoc
+
+// @Summary		List schedules
+// @Description	List periodic tasks with their last and next run
+// @Tags			admin
+// @Accept			json
+// @Produce		json
+// @Success		200	{object}	schemas.ListSchedulesResponse
+// @Failure		401	{object}	schemas.ProblemDetails
+// @Failure		403	{object}	schemas.ProblemDetails
+// @Failure		500	{object}	schemas.ProblemDetails
+// @Router			/admin/schedules [get]
+// @Security		BearerAuth
+func (h *adminHandler) ListSchedulesHandler(c *gin.Context) {
+	infos, err := h.scheduler.List(c.Request.Context())
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.ListSchedulesResponse{
+		Status:    http.StatusOK,
+		Message:   http.StatusText(http.StatusOK),
+		Schedules: schemas.NewScheduleResponses(infos),
+	})
+}
@@ -0,0 +1,215 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"khiemle.dev/golang-api-template/internal/audit/model"
+	"khiemle.dev/golang-api-template/internal/audit/service"
+	"khiemle.dev/golang-api-template/internal/schemas"
+	"khiemle.dev/golang-api-template/pkg/middleware"
+)
+
+// csvHeader names the columns of CSV exports
+var csvHeader = []string{
+	"id", "event_id", "occurred_at", "action", "actor_id", "login_session_id", "client_ip", "user_agent",
+	"request_id", "target_type", "target_id", "before", "after", "details",
+}
+
+type AuditHandler interface {
+	ListAuditLogHandler(c *gin.Context)
+	ExportAuditLogHandler(c *gin.Context)
+}
+
+type auditHandler struct {
+	auditService service.AuditService
+}
+
+func NewAuditHandler(auditService service.AuditService) AuditHandler {
+	return &auditHandler{
+		auditService: auditService,
+	}
+}
+
+// ListAuditLogHandler godoc
+
+// @Summary		List audit logs
+// @Description	List audit logs, newest first. Actions ending with .* match every action with the prefix, e.g. todo.*
+// @Tags			admin
+// @Accept			json
+// @Produce		json
+// @Param			actor_id			query		int		false	"user who performed the action"
+// @Param			login_session_id	query		int		false	"login session of the actor"
+// @Param			action				query		string	false	"action, e.g. auth.login_failed or todo.*"
+// @Param			target_type			query		string	false	"todo, user or login_session"
+// @Param			target_id			query		int		false	"id of the target"
+// @Param			client_ip			query		string	false	"IP address of the client"
+// @Param			from				query		string	false	"RFC 3339 time, inclusive"
+// @Param			to					query		string	false	"RFC 3339 time, exclusive"
+// @Param			before				query		int		false	"next_before of the previous page"
+// @Param			limit				query		int		false	"page size, 50 by default and at most 500"
+// @Success		200					{object}	schemas.ListAuditLogResponse
+// @Failure		401					{object}	schemas.ProblemDetails
+// @Failure		403					{object}	schemas.ProblemDetails
+// @Failure		422					{object}	schemas.ProblemDetails
+// @Failure		500					{object}	schemas.ProblemDetails
+// @Router			/admin/audit-logs [get]
+// @Security		BearerAuth
+func (h *auditHandler) ListAuditLogHandler(c *gin.Context) {
+	req := schemas.ListAuditLogRequest{}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	page, err := h.auditService.ListAuditLogs(c.Request.Context(), service.ListAuditLogsArgs{
+		AuditLogFilter: auditLogFilter(req.AuditLogFilterRequest),
+		Before:         req.Before,
+		Limit:          req.Limit,
+	})
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.NewListAuditLogResponse(http.StatusOK, http.StatusText(http.StatusOK), page))
+}
+
+// ExportAuditLogHandler godoc
+
+// @Summary		Export audit logs
+// @Description	Download every audit log matching the filters, newest first, as CSV or as JSON lines
+// @Tags			admin
+// @Accept			json
+// @Produce		text/csv
+// @Produce		application/x-ndjson
+// @Param			format				query		string	false	"csv (default) or json"
+// @Param			actor_id			query		int		false	"user who performed the action"
+// @Param			login_session_id	query		int		false	"login session of the actor"
+// @Param			action				query		string	false	"action, e.g. auth.login_failed or todo.*"
+// @Param			target_type			query		string	false	"todo, user or login_session"
+// @Param			target_id			query		int		false	"id of the target"
+// @Param			client_ip			query		string	false	"IP address of the client"
+// @Param			from				query		string	false	"RFC 3339 time, inclusive"
+// @Param			to					query		string	false	"RFC 3339 time, exclusive"
+// @Success		200					{file}		file
+// @Failure		401					{object}	schemas.ProblemDetails
+// @Failure		403					{object}	schemas.ProblemDetails
+// @Failure		422					{object}	schemas.ProblemDetails
+// @Failure		500					{object}	schemas.ProblemDetails
+// @Router			/admin/audit-logs/export [get]
+// @Security		BearerAuth
+func (h *auditHandler) ExportAuditLogHandler(c *gin.Context) {
+	req := schemas.ExportAuditLogRequest{}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	format := req.Format
+	if format == "" {
+		format = schemas.AuditLogFormatCSV
+	}
+	contentType, extension := "text/csv", "csv"
+	if format == schemas.AuditLogFormatJSON {
+		contentType, extension = "application/x-ndjson", "jsonl"
+	}
+
+	// Logs are written as they are read, errors past the first batch can
+	// only cut the file short
+	var encoder *json.Encoder
+	var writer *csv.Writer
+	started := false
+	write := func(auditLogs []model.AuditLog) error {
+		if !started {
+			started = true
+			filename := fmt.Sprintf("audit-logs-%s.%s", time.Now().UTC().Format("20060102T150405Z"), extension)
+			c.Header("Content-Type", contentType)
+			c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+			c.Status(http.StatusOK)
+
+			if format == schemas.AuditLogFormatJSON {
+				encoder = json.NewEncoder(c.Writer)
+			} else {
+				writer = csv.NewWriter(c.Writer)
+				if err := writer.Write(csvHeader); err != nil {
+					return err
+				}
+			}
+		}
+
+		for i := range auditLogs {
+			var err error
+			if encoder != nil {
+				err = encoder.Encode(schemas.NewAuditLogResponse(&auditLogs[i]))
+			} else {
+				err = writer.Write(csvRecord(&auditLogs[i]))
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if writer != nil {
+			writer.Flush()
+			if err := writer.Error(); err != nil {
+				return err
+			}
+		}
+		c.Writer.Flush()
+		return nil
+	}
+
+	err := h.auditService.ExportAuditLogs(c.Request.Context(), auditLogFilter(req.AuditLogFilterRequest), write)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+	// Empty exports still have the CSV header
+	if !started {
+		_ = write(nil)
+	}
+}
+
+func auditLogFilter(req schemas.AuditLogFilterRequest) service.AuditLogFilter {
+	return service.AuditLogFilter{
+		ActorID:        req.ActorID,
+		LoginSessionID: req.LoginSessionID,
+		Action:         req.Action,
+		TargetType:     req.TargetType,
+		TargetID:       req.TargetID,
+		ClientIP:       req.ClientIP,
+		From:           req.From,
+		To:             req.To,
+	}
+}
+
+// csvRecord returns the columns of auditLog in the order of csvHeader
+func csvRecord(auditLog *model.AuditLog) []string {
+	optionalID := func(id *uint) string {
+		if id == nil {
+			return ""
+		}
+		return strconv.FormatUint(uint64(*id), 10)
+	}
+	return []string{
+		strconv.FormatUint(uint64(auditLog.ID), 10),
+		auditLog.EventID,
+		auditLog.OccurredAt.UTC().Format(time.RFC3339Nano),
+		auditLog.Action,
+		optionalID(auditLog.ActorID),
+		optionalID(auditLog.LoginSessionID),
+		auditLog.ClientIP,
+		auditLog.UserAgent,
+		auditLog.RequestID,
+		auditLog.TargetType,
+		optionalID(auditLog.TargetID),
+		auditLog.Before,
+		auditLog.After,
+		auditLog.Details,
+	}
+}
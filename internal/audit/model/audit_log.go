@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+// Actions of the audit logs of login sessions. The other actions are the
+// types of the domain events they record, such as todo.updated or
+// user.password_changed.
+const (
+	ActionLogin          = "auth.login"
+	ActionLoginFailed    = "auth.login_failed"
+	ActionSessionRevoked = "auth.session_revoked"
+)
+
+// AuditLog records who did what, made of a domain event. Logs are never
+// updated nor deleted.
+type AuditLog struct {
+	ID uint `json:"id" gorm:"primaryKey;autoIncrement"`
+	// EventID is the id of the domain event, so it is logged once
+	EventID string `json:"event_id" gorm:"size:36;not null;uniqueIndex"`
+	Action  string `json:"action" gorm:"size:100;not null;index"`
+	// ActorID and LoginSessionID are nil for anonymous clients, such as
+	// failed logins, and for work of the application itself
+	ActorID        *uint  `json:"actor_id" gorm:"index"`
+	LoginSessionID *uint  `json:"login_session_id" gorm:"index"`
+	ClientIP       string `json:"client_ip" gorm:"size:64;not null"`
+	UserAgent      string `json:"user_agent" gorm:"size:512;not null"`
+	RequestID      string `json:"request_id" gorm:"size:128;not null"`
+	TargetType     string `json:"target_type" gorm:"size:50;not null;index:idx_audit_logs_target,priority:1"`
+	// TargetID is nil when the target does not exist, such as the user of a
+	// login with an unknown username
+	TargetID *uint `json:"target_id" gorm:"index:idx_audit_logs_target,priority:2"`
+	// Before and After are JSON objects with the fields of the target that
+	// changed, empty before it was created and after it was deleted
+	Before string `json:"before" gorm:"type:text;not null"`
+	After  string `json:"after" gorm:"type:text;not null"`
+	// Details is a JSON object describing actions that change nothing, such
+	// as the username and reason of failed logins
+	Details    string    `json:"details" gorm:"type:text;not null"`
+	OccurredAt time.Time `json:"occurred_at" gorm:"not null;index"`
+	CreatedAt  time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
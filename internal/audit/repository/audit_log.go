@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"khiemle.dev/golang-api-template/internal/audit/model"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+// likeEscaper escapes the wildcards of LIKE patterns, with ! as escape
+// character
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// ListAuditLogFilter selects a page of audit logs, newest first. Zero values
+// mean no filter.
+type ListAuditLogFilter struct {
+	ActorID        uint
+	LoginSessionID uint
+	// Action keeps logs of the action, or of every action starting with the
+	// prefix before ".*", e.g. todo.*
+	Action     string
+	TargetType string
+	TargetID   uint
+	ClientIP   string
+	// From and To keep logs that occurred in [From, To)
+	From time.Time
+	To   time.Time
+	// BeforeID keeps logs older than the given one, 0 starts from the
+	// newest.
+	BeforeID uint
+	Limit    int
+}
+
+// AuditLogRepository persists audit logs. Logs are append-only, there is no
+// way to update or delete them.
+type AuditLogRepository interface {
+	// Create stores the log, nothing when a log of the same event exists.
+	Create(ctx context.Context, auditLog *model.AuditLog) error
+	List(ctx context.Context, filter ListAuditLogFilter) ([]model.AuditLog, error)
+}
+
+type gormAuditLogRepository struct {
+	db *gorm.DB
+}
+
+func NewGormAuditLogRepository(db *gorm.DB) AuditLogRepository {
+	return &gormAuditLogRepository{
+		db: db,
+	}
+}
+
+func (r *gormAuditLogRepository) Create(ctx context.Context, auditLog *model.AuditLog) error {
+	return txn.DB(ctx, r.db).Clauses(clause.OnConflict{DoNothing: true}).Create(auditLog).Error
+}
+
+func (r *gormAuditLogRepository) List(ctx context.Context, filter ListAuditLogFilter) ([]model.AuditLog, error) {
+	query := txn.DB(ctx, r.db)
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.LoginSessionID != 0 {
+		query = query.Where("login_session_id = ?", filter.LoginSessionID)
+	}
+	if prefix, ok := strings.CutSuffix(filter.Action, ".*"); ok {
+		// Backslashes are no portable escape character, MySQL reads them in
+		// string literals
+		query = query.Where("action LIKE ? ESCAPE '!'", likeEscaper.Replace(prefix)+".%")
+	} else if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != 0 {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.ClientIP != "" {
+		query = query.Where("client_ip = ?", filter.ClientIP)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("occurred_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("occurred_at < ?", filter.To)
+	}
+	if filter.BeforeID != 0 {
+		query = query.Where("id < ?", filter.BeforeID)
+	}
+
+	auditLogs := []model.AuditLog{}
+	err := query.Order("id DESC").Limit(filter.Limit).Find(&auditLogs).Error
+	if err != nil {
+		return nil, err
+	}
+	return auditLogs, nil
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"khiemle.dev/golang-api-template/internal/audit/model"
+)
+
+type memoryAuditLogRepository struct {
+	mu        sync.RWMutex
+	nextID    uint
+	auditLogs map[uint]model.AuditLog
+}
+
+// NewMemoryAuditLogRepository creates an AuditLogRepository backed by a map,
+// for tests and demos that run without a database.
+func NewMemoryAuditLogRepository() AuditLogRepository {
+	return &memoryAuditLogRepository{
+		auditLogs: map[uint]model.AuditLog{},
+	}
+}
+
+func (r *memoryAuditLogRepository) Create(ctx context.Context, auditLog *model.AuditLog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.auditLogs {
+		if existing.EventID == auditLog.EventID {
+			return nil
+		}
+	}
+
+	r.nextID++
+	auditLog.ID = r.nextID
+	auditLog.CreatedAt = time.Now()
+	r.auditLogs[auditLog.ID] = *auditLog
+	return nil
+}
+
+func (r *memoryAuditLogRepository) List(ctx context.Context, filter ListAuditLogFilter) ([]model.AuditLog, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	auditLogs := []model.AuditLog{}
+	for _, auditLog := range r.auditLogs {
+		if filter.ActorID != 0 && !equalID(auditLog.ActorID, filter.ActorID) {
+			continue
+		}
+		if filter.LoginSessionID != 0 && !equalID(auditLog.LoginSessionID, filter.LoginSessionID) {
+			continue
+		}
+		if prefix, ok := strings.CutSuffix(filter.Action, ".*"); ok {
+			if !strings.HasPrefix(auditLog.Action, prefix+".") {
+				continue
+			}
+		} else if filter.Action != "" && auditLog.Action != filter.Action {
+			continue
+		}
+		if filter.TargetType != "" && auditLog.TargetType != filter.TargetType {
+			continue
+		}
+		if filter.TargetID != 0 && !equalID(auditLog.TargetID, filter.TargetID) {
+			continue
+		}
+		if filter.ClientIP != "" && auditLog.ClientIP != filter.ClientIP {
+			continue
+		}
+		if !filter.From.IsZero() && auditLog.OccurredAt.Before(filter.From) {
+			continue
+		}
+		if !filter.To.IsZero() && !auditLog.OccurredAt.Before(filter.To) {
+			continue
+		}
+		if filter.BeforeID != 0 && auditLog.ID >= filter.BeforeID {
+			continue
+		}
+		auditLogs = append(auditLogs, auditLog)
+	}
+	sort.Slice(auditLogs, func(i, j int) bool {
+		return auditLogs[i].ID > auditLogs[j].ID
+	})
+	if len(auditLogs) > filter.Limit {
+		auditLogs = auditLogs[:filter.Limit]
+	}
+	return auditLogs, nil
+}
+
+func equalID(id *uint, want uint) bool {
+	return id != nil && *id == want
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"khiemle.dev/golang-api-template/internal/audit/model"
+	"khiemle.dev/golang-api-template/internal/audit/repository"
+)
+
+// Page sizes of ListAuditLogs
+const (
+	DefaultAuditLogPageSize = 50
+	MaxAuditLogPageSize     = 500
+)
+
+// exportBatchSize is how many logs ExportAuditLogs reads at once
+const exportBatchSize = 500
+
+// AuditLogFilter selects audit logs. Zero values mean no filter.
+type AuditLogFilter struct {
+	ActorID        uint
+	LoginSessionID uint
+	// Action is an action, or a prefix of actions followed by ".*" such as
+	// todo.*
+	Action     string
+	TargetType string
+	TargetID   uint
+	ClientIP   string
+	// From and To keep logs that occurred in [From, To)
+	From time.Time
+	To   time.Time
+}
+
+type ListAuditLogsArgs struct {
+	AuditLogFilter
+	// Before is the NextBefore cursor of the previous page, 0 for the first.
+	Before uint
+	Limit  int
+}
+
+// AuditLogPage is a page of audit logs, newest first.
+type AuditLogPage struct {
+	AuditLogs []model.AuditLog
+	// NextBefore is the cursor of the next page, nil on the last one.
+	NextBefore *uint
+}
+
+// AuditService reads the audit log, for administrators. Logs are written by
+// the outbox handler of the package.
+type AuditService interface {
+	ListAuditLogs(ctx context.Context, args ListAuditLogsArgs) (*AuditLogPage, error)
+	// ExportAuditLogs calls fn with every log matching filter, newest first,
+	// in batches. It stops at the first error of fn.
+	ExportAuditLogs(ctx context.Context, filter AuditLogFilter, fn func(auditLogs []model.AuditLog) error) error
+}
+
+type auditService struct {
+	auditLogRepository repository.AuditLogRepository
+}
+
+func NewAuditService(auditLogRepository repository.AuditLogRepository) AuditService {
+	return &auditService{
+		auditLogRepository: auditLogRepository,
+	}
+}
+
+func (s *auditService) ListAuditLogs(ctx context.Context, args ListAuditLogsArgs) (*AuditLogPage, error) {
+	limit := args.Limit
+	if limit <= 0 {
+		limit = DefaultAuditLogPageSize
+	}
+	limit = min(limit, MaxAuditLogPageSize)
+
+	// Fetch one more to know whether there is a next page
+	auditLogs, err := s.auditLogRepository.List(ctx, args.repositoryFilter(args.Before, limit+1))
+	if err != nil {
+		return nil, err
+	}
+
+	page := &AuditLogPage{AuditLogs: auditLogs}
+	if len(auditLogs) > limit {
+		page.AuditLogs = auditLogs[:limit]
+		next := page.AuditLogs[limit-1].ID
+		page.NextBefore = &next
+	}
+	return page, nil
+}
+
+func (s *auditService) ExportAuditLogs(ctx context.Context, filter AuditLogFilter, fn func(auditLogs []model.AuditLog) error) error {
+	var before uint
+	for {
+		auditLogs, err := s.auditLogRepository.List(ctx, filter.repositoryFilter(before, exportBatchSize))
+		if err != nil {
+			return err
+		}
+		if len(auditLogs) == 0 {
+			return nil
+		}
+		if err := fn(auditLogs); err != nil {
+			return err
+		}
+		if len(auditLogs) < exportBatchSize {
+			return nil
+		}
+		before = auditLogs[len(auditLogs)-1].ID
+	}
+}
+
+func (f AuditLogFilter) repositoryFilter(beforeID uint, limit int) repository.ListAuditLogFilter {
+	return repository.ListAuditLogFilter{
+		ActorID:        f.ActorID,
+		LoginSessionID: f.LoginSessionID,
+		Action:         f.Action,
+		TargetType:     f.TargetType,
+		TargetID:       f.TargetID,
+		ClientIP:       f.ClientIP,
+		From:           f.From,
+		To:             f.To,
+		BeforeID:       beforeID,
+		Limit:          limit,
+	}
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"khiemle.dev/golang-api-template/internal/audit/model"
+	"khiemle.dev/golang-api-template/internal/audit/repository"
+	_authModel "khiemle.dev/golang-api-template/internal/auth/model"
+	_todoModel "khiemle.dev/golang-api-template/internal/todo/model"
+	_userModel "khiemle.dev/golang-api-template/internal/user/model"
+	"khiemle.dev/golang-api-template/pkg/outbox"
+)
+
+// OutboxSubscriber is the name of the outbox subscriber writing the audit
+// log.
+const OutboxSubscriber = "audit"
+
+// ignoredFields are left out of the changes of targets as they change with
+// every update
+var ignoredFields = []string{"updated_at"}
+
+// NewOutboxHandler creates the outbox handler logging domain events. Logs are
+// written in the transaction advancing the cursor of the subscriber, so each
+// event is logged once. Events that cannot be decoded are moved to the dead
+// letters instead of holding back the later logs.
+func NewOutboxHandler(auditLogRepository repository.AuditLogRepository) outbox.Handler {
+	return func(ctx context.Context, event *outbox.Event) error {
+		auditLog, err := newAuditLog(event)
+		if err != nil {
+			return outbox.Permanent(err)
+		}
+		if auditLog == nil {
+			return nil
+		}
+		return auditLogRepository.Create(ctx, auditLog)
+	}
+}
+
+// newAuditLog returns the audit log of a domain event, nil when it is not
+// audited
+func newAuditLog(event *outbox.Event) (*model.AuditLog, error) {
+	auditLog := &model.AuditLog{
+		EventID:        event.EventID,
+		Action:         event.Type,
+		ActorID:        optionalID(event.Actor.UserID),
+		LoginSessionID: optionalID(event.Actor.LoginSessionID),
+		ClientIP:       event.Actor.ClientIP,
+		UserAgent:      event.Actor.UserAgent,
+		RequestID:      event.Actor.RequestID,
+		TargetType:     event.AggregateType,
+		TargetID:       optionalID(event.AggregateID),
+		OccurredAt:     event.OccurredAt,
+	}
+
+	var err error
+	switch event.Type {
+	case _todoModel.TodoEventCreated, _todoModel.TodoEventUpdated, _todoModel.TodoEventDeleted,
+		_todoModel.TodoEventRestored, _todoModel.TodoEventPurged:
+		change := _todoModel.TodoChange{}
+		if err := event.Decode(&change); err != nil {
+			return nil, err
+		}
+		switch event.Type {
+		case _todoModel.TodoEventCreated, _todoModel.TodoEventRestored:
+			auditLog.Before, auditLog.After, err = changes(nil, change.Todo)
+		case _todoModel.TodoEventUpdated:
+			auditLog.Before, auditLog.After, err = changes(change.Before, change.Todo)
+		case _todoModel.TodoEventDeleted, _todoModel.TodoEventPurged:
+			auditLog.Before, auditLog.After, err = changes(change.Todo, nil)
+		}
+
+	case _userModel.EventUserRegistered, _userModel.EventUserUpdated, _userModel.EventUserPasswordChanged, _userModel.EventUserDeleted:
+		// Hashed passwords are not encoded, password changes are logged
+		// without changes
+		change := _userModel.UserChange{}
+		if err := event.Decode(&change); err != nil {
+			return nil, err
+		}
+		auditLog.Before, auditLog.After, err = changes(change.Before, change.After)
+
+	case _authModel.EventSessionCreated, _authModel.EventSessionRevoked:
+		change := _authModel.SessionChange{}
+		if err := event.Decode(&change); err != nil {
+			return nil, err
+		}
+		auditLog.Action = model.ActionLogin
+		if event.Type == _authModel.EventSessionRevoked {
+			auditLog.Action = model.ActionSessionRevoked
+		}
+		// Logins are anonymous requests, attribute them to the new session.
+		// Stateless access tokens do not tell the session logging out.
+		if auditLog.ActorID == nil {
+			auditLog.ActorID = optionalID(change.UserID)
+		}
+		if auditLog.LoginSessionID == nil {
+			auditLog.LoginSessionID = optionalID(change.LoginSessionID)
+		}
+		if auditLog.ClientIP == "" {
+			auditLog.ClientIP = change.ClientIP
+			auditLog.UserAgent = change.UserAgent
+		}
+
+	case _authModel.EventLoginFailed:
+		auditLog.Action = model.ActionLoginFailed
+		auditLog.Details = event.Payload
+
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return auditLog, nil
+}
+
+// changes returns the fields of before and after that differ, as JSON
+// objects. All of them are returned when the target was created or deleted,
+// i.e. before or after is nil.
+func changes(before any, after any) (string, string, error) {
+	beforeFields, err := fields(before)
+	if err != nil {
+		return "", "", err
+	}
+	afterFields, err := fields(after)
+	if err != nil {
+		return "", "", err
+	}
+
+	if beforeFields != nil && afterFields != nil {
+		for name, value := range beforeFields {
+			if bytes.Equal(value, afterFields[name]) {
+				delete(beforeFields, name)
+				delete(afterFields, name)
+			}
+		}
+	}
+	for _, name := range ignoredFields {
+		delete(beforeFields, name)
+		delete(afterFields, name)
+	}
+	return encodeFields(beforeFields), encodeFields(afterFields), nil
+}
+
+// fields decodes the JSON fields of v, nil when v is nil
+func fields(v any) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		// v was a nil pointer, encoded as null
+		return nil, nil
+	}
+	return fields, nil
+}
+
+// encodeFields encodes fields as a JSON object, empty when there are none
+func encodeFields(fields map[string]json.RawMessage) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(fields)
+	return string(data)
+}
+
+func optionalID(id uint) *uint {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
@@ -0,0 +1,71 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"khiemle.dev/golang-api-template/internal/audit/repository"
+	"khiemle.dev/golang-api-template/internal/audit/service"
+	_todoModel "khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/pkg/database/databasetest"
+	"khiemle.dev/golang-api-template/pkg/outbox"
+	"khiemle.dev/golang-api-template/pkg/pubsub"
+)
+
+func TestOutboxHandlerDeadLettersUndecodableEvents(t *testing.T) {
+	ctx := context.Background()
+	db := databasetest.New(t)
+	ps := pubsub.NewLocal()
+
+	// Recorded before the subscriber first ran, the undecodable one first
+	undecodable, err := outbox.NewEvent(_todoModel.TodoEventCreated, "todo", 1, "not a change")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := outbox.NewEvent(_todoModel.TodoEventCreated, "todo", 2, _todoModel.TodoChange{
+		Todo: &_todoModel.Todo{ID: 2, Name: "Write report"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := outbox.NewRecorder(db, ps).Record(ctx, undecodable, created); err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher, err := outbox.NewDispatcher(db, ps, 10*time.Millisecond, time.Second, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auditLogRepository := repository.NewGormAuditLogRepository(db)
+	dispatcher.Subscribe(service.OutboxSubscriber, service.NewOutboxHandler(auditLogRepository), outbox.FromOldest())
+	dispatcher.Start()
+	defer dispatcher.Close(ctx)
+
+	// Logged without waiting for retries of the undecodable event
+	deadline := time.Now().Add(time.Second)
+	for {
+		auditLogs, err := auditLogRepository.List(ctx, repository.ListAuditLogFilter{Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(auditLogs) == 1 {
+			if auditLogs[0].EventID != created.EventID {
+				t.Errorf("audit log of event %s, want %s", auditLogs[0].EventID, created.EventID)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("audit logs = %+v, want the created todo", auditLogs)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	deadLetters := []outbox.DeadLetter{}
+	if err := db.Find(&deadLetters).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(deadLetters) != 1 || deadLetters[0].EventID != undecodable.EventID || deadLetters[0].Attempts != 1 {
+		t.Errorf("dead letters = %+v, want the undecodable event", deadLetters)
+	}
+}
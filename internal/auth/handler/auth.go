@@ -1,17 +1,14 @@
 package handler
 
 import (
-	"errors"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
-	"gorm.io/gorm"
 	"khiemle.dev/golang-api-template/internal/auth/service"
 	"khiemle.dev/golang-api-template/internal/constant"
 	"khiemle.dev/golang-api-template/internal/schemas"
-	"khiemle.dev/golang-api-template/internal/user/model"
+	"khiemle.dev/golang-api-template/pkg/apperror"
 	"khiemle.dev/golang-api-template/pkg/middleware"
 	"khiemle.dev/golang-api-template/pkg/util"
 	_token "khiemle.dev/golang-api-template/pkg/util/token"
@@ -48,51 +45,26 @@ func NewAuthHandler(cfg *util.Config, authService service.AuthService, loginSess
 // @Produce		json
 // @Param			request	body		schemas.AuthLoginRequest	true	"Enter username and password"
 // @Success		200		{object}	schemas.AuthLoginResponse
-// @Failure		400		{object}	schemas.APIResponse
-// @Failure		401		{object}	schemas.APIResponse
-// @Failure		500		{object}	schemas.APIResponse
+// @Failure		400		{object}	schemas.ProblemDetails
+// @Failure		401		{object}	schemas.ProblemDetails
+// @Failure		422		{object}	schemas.ProblemDetails
+// @Failure		500		{object}	schemas.ProblemDetails
 // @Router			/auth/login [post]
 func (h *authHandler) LoginHandler(c *gin.Context) {
 	req := schemas.AuthLoginRequest{}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, schemas.APIResponse{
-			Status:  http.StatusBadRequest,
-			Message: err.Error(),
-			Data:    nil,
-		})
-		return
-	}
-
-	user, data, err := h.authService.LoginByUsernamePassword(c, req.Username, req.Password)
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		c.AbortWithStatusJSON(http.StatusNotFound, schemas.APIResponse{
-			Status:  http.StatusNotFound,
-			Message: "username not found",
-			Data:    nil,
-		})
-		return
-	}
-
-	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, schemas.APIResponse{
-			Status:  http.StatusUnauthorized,
-			Message: "Wrong password",
-			Data:    nil,
-		})
+		middleware.AbortWithError(c, err)
 		return
 	}
 
+	user, data, err := h.authService.LoginByUsernamePassword(c.Request.Context(), req.Username, req.Password)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, schemas.APIResponse{
-			Status:  http.StatusInternalServerError,
-			Message: err.Error(),
-			Data:    nil,
-		})
+		middleware.AbortWithError(c, err)
 		return
 	}
 
 	loginSession, err := h.loginSessionService.Create(
-		c,
+		c.Request.Context(),
 		service.CreateLoginSessionArgs{
 			TokenID:               data.Payload.TokenID,
 			UserId:                user.ID,
@@ -105,11 +77,7 @@ func (h *authHandler) LoginHandler(c *gin.Context) {
 		},
 	)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, schemas.APIResponse{
-			Status:  http.StatusInternalServerError,
-			Message: err.Error(),
-			Data:    nil,
-		})
+		middleware.AbortWithError(c, err)
 		return
 	}
 
@@ -117,14 +85,9 @@ func (h *authHandler) LoginHandler(c *gin.Context) {
 	c.SetCookie("refresh_token", data.RefreshToken, int(data.RefreshTokenExpiresIn.Seconds()), "/", h.cfg.CookieDomain, false, true)
 
 	c.JSON(http.StatusOK, schemas.AuthLoginResponse{
-		Status:  http.StatusOK,
-		Message: http.StatusText(http.StatusOK),
-		User: schemas.AuthLoginUserResponse{
-			ID:       user.ID,
-			Name:     user.Name,
-			Username: user.Username,
-			Email:    user.Email,
-		},
+		Status:                http.StatusOK,
+		Message:               http.StatusText(http.StatusOK),
+		User:                  schemas.NewAuthLoginUserResponse(user),
 		LoginSessionID:        loginSession.ID,
 		AccessToken:           data.AccessToken,
 		RefreshToken:          data.RefreshToken,
@@ -142,47 +105,42 @@ func (h *authHandler) LoginHandler(c *gin.Context) {
 // @Produce		json
 // @Param			request	body		schemas.AuthRegisterRequest	true	"Enter user information"
 // @Success		200		{object}	schemas.AuthRegisterResponse
-// @Failure		400		{object}	schemas.APIResponse
-// @Failure		500		{object}	schemas.APIResponse
+// @Failure		400		{object}	schemas.ProblemDetails
+// @Failure		409		{object}	schemas.ProblemDetails
+// @Failure		422		{object}	schemas.ProblemDetails
+// @Failure		500		{object}	schemas.ProblemDetails
 // @Router			/auth/register [post]
 func (h *authHandler) RegisterHandler(c *gin.Context) {
 	req := schemas.AuthRegisterRequest{}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, schemas.APIResponse{
-			Status:  http.StatusBadRequest,
-			Message: err.Error(),
-			Data:    nil,
-		})
+		middleware.AbortWithError(c, err)
 		return
 	}
 
-	// Validate email
+	// Validate email and password
+	fieldErrs := []apperror.FieldError{}
 	if !util.IsValidEmail(req.Email) {
-		c.AbortWithStatusJSON(http.StatusBadRequest, schemas.APIResponse{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid email",
-			Data:    nil,
+		fieldErrs = append(fieldErrs, apperror.FieldError{
+			Field:   "email",
+			Code:    "email",
+			Message: "must be a valid email address",
 		})
-		return
 	}
-
-	// Validate password
 	if !util.IsValidPassword(req.Password) {
-		c.AbortWithStatusJSON(http.StatusBadRequest, schemas.APIResponse{
-			Status:  http.StatusBadRequest,
-			Message: "Password must be at least 8 characters long and contain at least 1 uppercase letter, 1 lowercase letter, and 1 number",
-			Data:    nil,
+		fieldErrs = append(fieldErrs, apperror.FieldError{
+			Field:   "password",
+			Code:    "password_strength",
+			Message: "must be at least 8 characters long and contain at least 1 uppercase letter, 1 lowercase letter, 1 number and 1 special character",
 		})
+	}
+	if len(fieldErrs) > 0 {
+		middleware.AbortWithError(c, apperror.Validation(fieldErrs...))
 		return
 	}
 
-	user, err := h.authService.RegisterUser(c, req.Username, req.Email, req.Name, req.Password, req.ConfirmPassword)
+	user, err := h.authService.RegisterUser(c.Request.Context(), req.Username, req.Email, req.Name, req.Password, req.ConfirmPassword)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, schemas.APIResponse{
-			Status:  http.StatusInternalServerError,
-			Message: err.Error(),
-			Data:    nil,
-		})
+		middleware.AbortWithError(c, err)
 		return
 	}
 
@@ -201,25 +159,20 @@ func (h *authHandler) RegisterHandler(c *gin.Context) {
 // @Accept			json
 // @Produce		json
 // @Success		200	{object}	schemas.AuthLoginUserResponse
-// @Failure		401	{object}	schemas.APIResponse
-// @Failure		500	{object}	schemas.APIResponse
+// @Failure		401	{object}	schemas.ProblemDetails
+// @Failure		500	{object}	schemas.ProblemDetails
 // @Router			/auth/verify_access_token [get]
 // @Security		BearerAuth
 func (h *authHandler) VerifyAccessToken(c *gin.Context) {
 	payload := c.MustGet(middleware.AuthorizationPayloadKey).(_token.TokenPayload)
-	currentUser := c.MustGet(middleware.AuthorizationCurrentUser).(*model.User)
+	currentUser := middleware.CurrentUser(c)
 
 	c.JSON(http.StatusOK, schemas.APIResponse{
 		Status:  http.StatusOK,
 		Message: http.StatusText(http.StatusOK),
 		Data: gin.H{
 			"payload": payload,
-			"user": schemas.AuthLoginUserResponse{
-				ID:       currentUser.ID,
-				Name:     currentUser.Name,
-				Username: currentUser.Username,
-				Email:    currentUser.Email,
-			},
+			"user":    schemas.NewAuthLoginUserResponse(currentUser),
 		},
 	})
 }
@@ -232,21 +185,17 @@ func (h *authHandler) VerifyAccessToken(c *gin.Context) {
 // @Accept			json
 // @Produce		json
 // @Success		200	{object}	schemas.APIResponse
-// @Failure		401	{object}	schemas.APIResponse
-// @Failure		500	{object}	schemas.APIResponse
+// @Failure		401	{object}	schemas.ProblemDetails
+// @Failure		500	{object}	schemas.ProblemDetails
 // @Router			/auth/logout [get]
 // @Security		BearerAuth
 func (h *authHandler) LogoutHandler(c *gin.Context) {
 	payload := c.MustGet(middleware.AuthorizationPayloadKey).(_token.TokenPayload)
 
 	// Delete login session
-	err := h.loginSessionService.DeleteByTokenID(c, payload.TokenID)
+	err := h.loginSessionService.DeleteByTokenID(c.Request.Context(), payload.TokenID)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, schemas.APIResponse{
-			Status:  http.StatusInternalServerError,
-			Message: err.Error(),
-			Data:    nil,
-		})
+		middleware.AbortWithError(c, err)
 		return
 	}
 
@@ -268,34 +217,25 @@ func (h *authHandler) LogoutHandler(c *gin.Context) {
 // @Accept			json
 // @Produce		json
 // @Success		200	{object}	schemas.AuthRefreshResponse
-// @Failure		401	{object}	schemas.APIResponse
-// @Failure		500	{object}	schemas.APIResponse
+// @Failure		401	{object}	schemas.ProblemDetails
+// @Failure		500	{object}	schemas.ProblemDetails
 // @Router			/auth/refresh_token [get]
 // @Security		BearerAuth
 func (h *authHandler) RefreshTokenHandler(ctx *gin.Context) {
 	refreshToken := ctx.MustGet(middleware.AuthorizationHeaderToken).(string)
 
-	user, data, err := h.authService.RefreshToken(ctx, refreshToken)
+	user, data, err := h.authService.RefreshToken(ctx.Request.Context(), refreshToken)
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusInternalServerError, schemas.APIResponse{
-			Status:  http.StatusInternalServerError,
-			Message: err.Error(),
-			Data:    nil,
-		})
+		middleware.AbortWithError(ctx, err)
 		return
 	}
 
 	ctx.SetCookie(constant.AccessToken, data.AccessToken, int(data.AccessTokenExpiresIn.Seconds()), "/", h.cfg.CookieDomain, false, true)
 
 	ctx.JSON(http.StatusOK, schemas.AuthRefreshResponse{
-		Status:  http.StatusOK,
-		Message: http.StatusText(http.StatusOK),
-		User: schemas.AuthLoginUserResponse{
-			ID:       user.ID,
-			Name:     user.Name,
-			Username: user.Username,
-			Email:    user.Email,
-		},
+		Status:                http.StatusOK,
+		Message:               http.StatusText(http.StatusOK),
+		User:                  schemas.NewAuthLoginUserResponse(user),
 		LoginSessionID:        data.LoginSessionID,
 		AccessToken:           data.AccessToken,
 		AccessTokenExpiresIn:  data.AccessTokenExpiresIn,
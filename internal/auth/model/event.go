@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+// Domain events of login sessions, recorded in the outbox with the session
+// as aggregate
+const (
+	AggregateLoginSession = "login_session"
+
+	EventSessionCreated = "session.created"
+	// EventSessionRevoked is recorded when the user logs out, not when
+	// expired sessions are purged
+	EventSessionRevoked = "session.revoked"
+	// EventLoginFailed is recorded when credentials are rejected, with the
+	// user as aggregate, or no aggregate when the username does not exist
+	EventLoginFailed = "session.login_failed"
+)
+
+// Reasons of LoginFailure
+const (
+	LoginFailureUnknownUser   = "unknown_user"
+	LoginFailureWrongPassword = "wrong_password"
+)
+
+// SessionChange is the payload of the events of login sessions.
+type SessionChange struct {
+	LoginSessionID uint      `json:"login_session_id"`
+	UserID         uint      `json:"user_id"`
+	UserAgent      string    `json:"user_agent"`
+	ClientIP       string    `json:"client_ip"`
+	OccurredAt     time.Time `json:"occurred_at"`
+}
+
+func NewSessionChange(loginSession *LoginSession, occurredAt time.Time) SessionChange {
+	return SessionChange{
+		LoginSessionID: loginSession.ID,
+		UserID:         loginSession.UserId,
+		UserAgent:      loginSession.UserAgent,
+		ClientIP:       loginSession.ClientIP,
+		OccurredAt:     occurredAt,
+	}
+}
+
+// LoginFailure is the payload of EventLoginFailed.
+type LoginFailure struct {
+	Username   string    `json:"username"`
+	Reason     string    `json:"reason"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
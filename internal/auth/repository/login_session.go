@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/auth/model"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+// LoginSessionRepository persists login sessions. Lookups of missing sessions
+// return gorm.ErrRecordNotFound and a reused token id returns
+// gorm.ErrDuplicatedKey regardless of the implementation.
+type LoginSessionRepository interface {
+	Create(ctx context.Context, loginSession *model.LoginSession) error
+	FindByID(ctx context.Context, id uint) (*model.LoginSession, error)
+	FindByTokenID(ctx context.Context, tokenID uuid.UUID) (*model.LoginSession, error)
+	// Update loads the session, applies fn and saves the result atomically.
+	Update(ctx context.Context, id uint, fn func(loginSession *model.LoginSession) error) (*model.LoginSession, error)
+	// DeleteByTokenID soft deletes the session so it can still be reported
+	// by ListRevoked.
+	DeleteByTokenID(ctx context.Context, tokenID uuid.UUID) error
+	// ListRevoked returns deleted sessions whose access token has not expired
+	// at now.
+	ListRevoked(ctx context.Context, now time.Time) ([]model.LoginSession, error)
+	// PurgeExpired hard deletes sessions, including deleted ones, whose
+	// refresh token expired before the given time.
+	PurgeExpired(ctx context.Context, before time.Time) (int64, error)
+	// PurgeDeleted hard deletes sessions deleted before the given time, once
+	// their access token expired at now.
+	PurgeDeleted(ctx context.Context, deletedBefore time.Time, now time.Time) (int64, error)
+	// CountEarlier counts the sessions of the user created before beforeID,
+	// only those from userAgent when it is not empty. Deleted sessions count
+	// until they are purged.
+	CountEarlier(ctx context.Context, userID uint, beforeID uint, userAgent string) (int64, error)
+	// UpdateLastUsedAt sets LastUsedAt of many sessions at once, never moving
+	// it backwards. Missing sessions are ignored.
+	UpdateLastUsedAt(ctx context.Context, lastUsedAt map[uint]time.Time) error
+}
+
+// purgeBatchSize bounds how many rows a single purge statement deletes, so
+// large backlogs do not hold locks for long.
+const purgeBatchSize = 1000
+
+type gormLoginSessionRepository struct {
+	db *gorm.DB
+}
+
+func NewGormLoginSessionRepository(db *gorm.DB) LoginSessionRepository {
+	return &gormLoginSessionRepository{
+		db: db,
+	}
+}
+
+func (r *gormLoginSessionRepository) Create(ctx context.Context, loginSession *model.LoginSession) error {
+	return txn.DB(ctx, r.db).Create(loginSession).Error
+}
+
+func (r *gormLoginSessionRepository) FindByID(ctx context.Context, id uint) (*model.LoginSession, error) {
+	loginSession := model.LoginSession{}
+	err := txn.DB(ctx, r.db).First(&loginSession, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &loginSession, nil
+}
+
+func (r *gormLoginSessionRepository) FindByTokenID(ctx context.Context, tokenID uuid.UUID) (*model.LoginSession, error) {
+	loginSession := model.LoginSession{}
+	err := txn.DB(ctx, r.db).First(&loginSession, "token_id = ?", tokenID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &loginSession, nil
+}
+
+func (r *gormLoginSessionRepository) Update(ctx context.Context, id uint, fn func(loginSession *model.LoginSession) error) (*model.LoginSession, error) {
+	loginSession := model.LoginSession{}
+	err := txn.DB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&loginSession, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := fn(&loginSession); err != nil {
+			return err
+		}
+		return tx.Save(&loginSession).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &loginSession, nil
+}
+
+func (r *gormLoginSessionRepository) DeleteByTokenID(ctx context.Context, tokenID uuid.UUID) error {
+	return txn.DB(ctx, r.db).Delete(&model.LoginSession{}, "token_id = ?", tokenID).Error
+}
+
+func (r *gormLoginSessionRepository) CountEarlier(ctx context.Context, userID uint, beforeID uint, userAgent string) (int64, error) {
+	query := txn.DB(ctx, r.db).Unscoped().Model(&model.LoginSession{}).
+		Where("user_id = ? AND id < ?", userID, beforeID)
+	if userAgent != "" {
+		query = query.Where("user_agent = ?", userAgent)
+	}
+
+	var count int64
+	err := query.Count(&count).Error
+	return count, err
+}
+
+func (r *gormLoginSessionRepository) ListRevoked(ctx context.Context, now time.Time) ([]model.LoginSession, error) {
+	loginSessions := []model.LoginSession{}
+	err := txn.DB(ctx, r.db).Unscoped().
+		Select("id", "token_id", "access_token_expires_in", "deleted_at").
+		Where("deleted_at IS NOT NULL AND access_token_expires_in > ?", now).
+		Find(&loginSessions).Error
+	if err != nil {
+		return nil, err
+	}
+	return loginSessions, nil
+}
+
+func (r *gormLoginSessionRepository) PurgeExpired(ctx context.Context, before time.Time) (int64, error) {
+	return r.purge(ctx, "refresh_token_expires_in < ?", before)
+}
+
+func (r *gormLoginSessionRepository) PurgeDeleted(ctx context.Context, deletedBefore time.Time, now time.Time) (int64, error) {
+	return r.purge(ctx, "deleted_at < ? AND access_token_expires_in < ?", deletedBefore, now)
+}
+
+// purge hard deletes matching sessions in batches. Ids are selected first as
+// MySQL does not support LIMIT in a DELETE subquery.
+func (r *gormLoginSessionRepository) purge(ctx context.Context, query string, args ...any) (int64, error) {
+	var total int64
+	for {
+		ids := []uint{}
+		err := txn.DB(ctx, r.db).Unscoped().Model(&model.LoginSession{}).
+			Where(query, args...).
+			Limit(purgeBatchSize).
+			Pluck("id", &ids).Error
+		if err != nil {
+			return total, err
+		}
+		if len(ids) == 0 {
+			return total, nil
+		}
+
+		result := txn.DB(ctx, r.db).Unscoped().Delete(&model.LoginSession{}, ids)
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+
+		if len(ids) < purgeBatchSize {
+			return total, nil
+		}
+	}
+}
+
+func (r *gormLoginSessionRepository) UpdateLastUsedAt(ctx context.Context, lastUsedAt map[uint]time.Time) error {
+	return txn.DB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		for id, usedAt := range lastUsedAt {
+			err := tx.Model(&model.LoginSession{}).
+				Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, usedAt).
+				UpdateColumn("last_used_at", usedAt).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/auth/model"
+)
+
+type memoryLoginSessionRepository struct {
+	mu            sync.RWMutex
+	nextID        uint
+	loginSessions map[uint]model.LoginSession
+}
+
+// NewMemoryLoginSessionRepository creates a LoginSessionRepository backed by a
+// map, for tests and demos that run without a database. Token ids are unique
+// as with the database index.
+func NewMemoryLoginSessionRepository() LoginSessionRepository {
+	return &memoryLoginSessionRepository{
+		loginSessions: map[uint]model.LoginSession{},
+	}
+}
+
+func (r *memoryLoginSessionRepository) Create(ctx context.Context, loginSession *model.LoginSession) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, other := range r.loginSessions {
+		if other.TokenID == loginSession.TokenID {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+
+	r.nextID++
+	now := time.Now()
+	loginSession.ID = r.nextID
+	loginSession.CreatedAt = now
+	loginSession.UpdatedAt = now
+	r.loginSessions[loginSession.ID] = *loginSession
+	return nil
+}
+
+func (r *memoryLoginSessionRepository) FindByID(ctx context.Context, id uint) (*model.LoginSession, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	loginSession, ok := r.loginSessions[id]
+	if !ok || loginSession.DeletedAt.Valid {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &loginSession, nil
+}
+
+func (r *memoryLoginSessionRepository) FindByTokenID(ctx context.Context, tokenID uuid.UUID) (*model.LoginSession, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, loginSession := range r.loginSessions {
+		if loginSession.TokenID == tokenID && !loginSession.DeletedAt.Valid {
+			return &loginSession, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryLoginSessionRepository) Update(ctx context.Context, id uint, fn func(loginSession *model.LoginSession) error) (*model.LoginSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	loginSession, ok := r.loginSessions[id]
+	if !ok || loginSession.DeletedAt.Valid {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err := fn(&loginSession); err != nil {
+		return nil, err
+	}
+	loginSession.UpdatedAt = time.Now()
+	r.loginSessions[id] = loginSession
+	return &loginSession, nil
+}
+
+func (r *memoryLoginSessionRepository) DeleteByTokenID(ctx context.Context, tokenID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, loginSession := range r.loginSessions {
+		if loginSession.TokenID == tokenID && !loginSession.DeletedAt.Valid {
+			loginSession.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+			r.loginSessions[id] = loginSession
+		}
+	}
+	return nil
+}
+
+func (r *memoryLoginSessionRepository) CountEarlier(ctx context.Context, userID uint, beforeID uint, userAgent string) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var count int64
+	for _, loginSession := range r.loginSessions {
+		if loginSession.UserId != userID || loginSession.ID >= beforeID {
+			continue
+		}
+		if userAgent != "" && loginSession.UserAgent != userAgent {
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (r *memoryLoginSessionRepository) ListRevoked(ctx context.Context, now time.Time) ([]model.LoginSession, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	loginSessions := []model.LoginSession{}
+	for _, loginSession := range r.loginSessions {
+		if loginSession.DeletedAt.Valid && loginSession.AccessTokenExpiresIn.After(now) {
+			loginSessions = append(loginSessions, loginSession)
+		}
+	}
+	return loginSessions, nil
+}
+
+func (r *memoryLoginSessionRepository) PurgeExpired(ctx context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var count int64
+	for id, loginSession := range r.loginSessions {
+		if loginSession.RefreshTokenExpiresIn.Before(before) {
+			delete(r.loginSessions, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryLoginSessionRepository) PurgeDeleted(ctx context.Context, deletedBefore time.Time, now time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var count int64
+	for id, loginSession := range r.loginSessions {
+		if loginSession.DeletedAt.Valid && loginSession.DeletedAt.Time.Before(deletedBefore) && loginSession.AccessTokenExpiresIn.Before(now) {
+			delete(r.loginSessions, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryLoginSessionRepository) UpdateLastUsedAt(ctx context.Context, lastUsedAt map[uint]time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, usedAt := range lastUsedAt {
+		loginSession, ok := r.loginSessions[id]
+		if !ok || loginSession.DeletedAt.Valid {
+			continue
+		}
+		if loginSession.LastUsedAt == nil || loginSession.LastUsedAt.Before(usedAt) {
+			usedAt := usedAt
+			loginSession.LastUsedAt = &usedAt
+			r.loginSessions[id] = loginSession
+		}
+	}
+	return nil
+}
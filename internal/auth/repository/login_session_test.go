@@ -0,0 +1,170 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/auth/model"
+	"khiemle.dev/golang-api-template/internal/auth/repository"
+	_userModel "khiemle.dev/golang-api-template/internal/user/model"
+	"khiemle.dev/golang-api-template/pkg/database/databasetest"
+)
+
+// TestLoginSessionRepository runs the same contract against every
+// implementation
+func TestLoginSessionRepository(t *testing.T) {
+	implementations := map[string]func(t *testing.T) (repository.LoginSessionRepository, uint){
+		"gorm": func(t *testing.T) (repository.LoginSessionRepository, uint) {
+			db := databasetest.New(t)
+			user := _userModel.User{Username: "alice", Email: "alice@example.com", Name: "Alice", HashedPassword: "x"}
+			if err := db.Create(&user).Error; err != nil {
+				t.Fatalf("create user: %v", err)
+			}
+			return repository.NewGormLoginSessionRepository(db), user.ID
+		},
+		"memory": func(t *testing.T) (repository.LoginSessionRepository, uint) {
+			return repository.NewMemoryLoginSessionRepository(), 1
+		},
+	}
+	for name, newRepository := range implementations {
+		t.Run(name, func(t *testing.T) {
+			r, userID := newRepository(t)
+			testLoginSessionRepository(t, r, userID)
+		})
+	}
+}
+
+func testLoginSessionRepository(t *testing.T, r repository.LoginSessionRepository, userID uint) {
+	ctx := context.Background()
+	now := time.Now()
+
+	create := func(userAgent string, accessTTL time.Duration, refreshTTL time.Duration) *model.LoginSession {
+		t.Helper()
+		loginSession := &model.LoginSession{
+			TokenID:               uuid.New(),
+			UserId:                userID,
+			UserAgent:             userAgent,
+			ClientIP:              "127.0.0.1",
+			AccessToken:           "access",
+			RefreshToken:          "refresh",
+			AccessTokenExpiresIn:  now.Add(accessTTL),
+			RefreshTokenExpiresIn: now.Add(refreshTTL),
+		}
+		if err := r.Create(ctx, loginSession); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		return loginSession
+	}
+
+	first := create("firefox", time.Hour, 24*time.Hour)
+
+	t.Run("find", func(t *testing.T) {
+		loginSession, err := r.FindByTokenID(ctx, first.TokenID)
+		if err != nil {
+			t.Fatalf("FindByTokenID: %v", err)
+		}
+		if loginSession.ID != first.ID {
+			t.Errorf("FindByTokenID returned session %d, want %d", loginSession.ID, first.ID)
+		}
+		if _, err := r.FindByTokenID(ctx, uuid.New()); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("FindByTokenID of a missing session = %v, want gorm.ErrRecordNotFound", err)
+		}
+		if _, err := r.FindByID(ctx, 999); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("FindByID of a missing session = %v, want gorm.ErrRecordNotFound", err)
+		}
+	})
+
+	t.Run("duplicate token id", func(t *testing.T) {
+		duplicate := *first
+		duplicate.ID = 0
+		if err := r.Create(ctx, &duplicate); !errors.Is(err, gorm.ErrDuplicatedKey) {
+			t.Errorf("Create with a reused token id = %v, want gorm.ErrDuplicatedKey", err)
+		}
+	})
+
+	t.Run("last used", func(t *testing.T) {
+		later := now.Add(time.Minute)
+		if err := r.UpdateLastUsedAt(ctx, map[uint]time.Time{first.ID: later, 999: later}); err != nil {
+			t.Fatalf("UpdateLastUsedAt: %v", err)
+		}
+		if err := r.UpdateLastUsedAt(ctx, map[uint]time.Time{first.ID: now}); err != nil {
+			t.Fatalf("UpdateLastUsedAt: %v", err)
+		}
+		loginSession, err := r.FindByID(ctx, first.ID)
+		if err != nil {
+			t.Fatalf("FindByID: %v", err)
+		}
+		if loginSession.LastUsedAt == nil || !loginSession.LastUsedAt.Equal(later) {
+			t.Errorf("LastUsedAt = %v, want %v", loginSession.LastUsedAt, later)
+		}
+	})
+
+	t.Run("count earlier", func(t *testing.T) {
+		second := create("chrome", time.Hour, 24*time.Hour)
+		third := create("firefox", time.Hour, 24*time.Hour)
+
+		count, err := r.CountEarlier(ctx, userID, third.ID, "")
+		if err != nil || count != 2 {
+			t.Errorf("CountEarlier = %d, %v, want 2", count, err)
+		}
+		count, err = r.CountEarlier(ctx, userID, third.ID, "firefox")
+		if err != nil || count != 1 {
+			t.Errorf("CountEarlier of firefox = %d, %v, want 1", count, err)
+		}
+
+		// Deleted sessions count until they are purged
+		if err := r.DeleteByTokenID(ctx, second.TokenID); err != nil {
+			t.Fatalf("DeleteByTokenID: %v", err)
+		}
+		count, err = r.CountEarlier(ctx, userID, third.ID, "")
+		if err != nil || count != 2 {
+			t.Errorf("CountEarlier with a deleted session = %d, %v, want 2", count, err)
+		}
+	})
+
+	t.Run("revoke and purge", func(t *testing.T) {
+		revoked := create("safari", time.Hour, 24*time.Hour)
+		expired := create("safari", -2*time.Hour, -time.Hour)
+		if err := r.DeleteByTokenID(ctx, revoked.TokenID); err != nil {
+			t.Fatalf("DeleteByTokenID: %v", err)
+		}
+		if err := r.DeleteByTokenID(ctx, expired.TokenID); err != nil {
+			t.Fatalf("DeleteByTokenID: %v", err)
+		}
+		if _, err := r.FindByTokenID(ctx, revoked.TokenID); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("FindByTokenID of a deleted session = %v, want gorm.ErrRecordNotFound", err)
+		}
+
+		// Only deleted sessions whose access token is still valid
+		loginSessions, err := r.ListRevoked(ctx, now)
+		if err != nil {
+			t.Fatalf("ListRevoked: %v", err)
+		}
+		tokenIDs := map[uuid.UUID]bool{}
+		for _, loginSession := range loginSessions {
+			tokenIDs[loginSession.TokenID] = true
+		}
+		if !tokenIDs[revoked.TokenID] || tokenIDs[expired.TokenID] {
+			t.Errorf("ListRevoked = %v, want %s and not %s", tokenIDs, revoked.TokenID, expired.TokenID)
+		}
+
+		purged, err := r.PurgeExpired(ctx, now)
+		if err != nil || purged != 1 {
+			t.Errorf("PurgeExpired = %d, %v, want 1", purged, err)
+		}
+
+		// The revoked session is kept while its access token is valid
+		purged, err = r.PurgeDeleted(ctx, now.Add(time.Minute), now)
+		if err != nil || purged != 0 {
+			t.Errorf("PurgeDeleted = %d, %v, want 0", purged, err)
+		}
+		purged, err = r.PurgeDeleted(ctx, now.Add(time.Minute), now.Add(2*time.Hour))
+		if err != nil || purged != 2 {
+			t.Errorf("PurgeDeleted after the access tokens expired = %d, %v, want 2", purged, err)
+		}
+	})
+}
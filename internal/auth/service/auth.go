@@ -1,14 +1,18 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"net/http"
 	"time"
 
-	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
-	"gorm.io/gorm"
+	_authModel "khiemle.dev/golang-api-template/internal/auth/model"
 	"khiemle.dev/golang-api-template/internal/user/model"
 	_userService "khiemle.dev/golang-api-template/internal/user/service"
+	"khiemle.dev/golang-api-template/pkg/apperror"
+	"khiemle.dev/golang-api-template/pkg/outbox"
+	"khiemle.dev/golang-api-template/pkg/tracing"
 	"khiemle.dev/golang-api-template/pkg/util"
 	"khiemle.dev/golang-api-template/pkg/util/token"
 )
@@ -30,52 +34,69 @@ type RefreshTokenData struct {
 	RefreshTokenExpiresIn time.Duration
 }
 
+var (
+	ErrInvalidCredentials = apperror.New(apperror.CodeInvalidCredentials, http.StatusUnauthorized, "invalid username or password")
+	ErrInvalidToken       = apperror.Unauthorized(apperror.CodeInvalidToken, "token is invalid or expired")
+)
+
 type AuthService interface {
-	LoginByUsernamePassword(ctx *gin.Context, username string, password string) (*model.User, *LoginByUserNamePasswordData, error)
-	RegisterUser(ctx *gin.Context, username string, email string, name string, password string, confirmPassword string) (*model.User, error)
-	RefreshToken(ctx *gin.Context, refreshToken string) (*model.User, *RefreshTokenData, error)
+	LoginByUsernamePassword(ctx context.Context, username string, password string) (*model.User, *LoginByUserNamePasswordData, error)
+	RegisterUser(ctx context.Context, username string, email string, name string, password string, confirmPassword string) (*model.User, error)
+	RefreshToken(ctx context.Context, refreshToken string) (*model.User, *RefreshTokenData, error)
 }
 
 type authService struct {
-	db                  *gorm.DB
 	cfg                 *util.Config
 	userService         _userService.UserService
 	loginSessionService LoginSessionService
 	tokenMaker          token.TokenMaker
+	outbox              outbox.Recorder
 }
 
 func NewAuthService(
-	db *gorm.DB,
 	cfg *util.Config,
 	userService _userService.UserService,
 	loginSessionService LoginSessionService,
-	tokenMaker token.TokenMaker) AuthService {
+	tokenMaker token.TokenMaker,
+	recorder outbox.Recorder) AuthService {
 	return &authService{
-		db:                  db,
 		cfg:                 cfg,
 		userService:         userService,
 		loginSessionService: loginSessionService,
 		tokenMaker:          tokenMaker,
+		outbox:              recorder,
 	}
 }
 
-func (s *authService) LoginByUsernamePassword(ctx *gin.Context, username string, password string) (*model.User, *LoginByUserNamePasswordData, error) {
+func (s *authService) LoginByUsernamePassword(ctx context.Context, username string, password string) (*model.User, *LoginByUserNamePasswordData, error) {
 	user, err := s.userService.GetUserByUsername(ctx, username)
+	if errors.Is(err, _userService.ErrUserNotFound) {
+		return nil, nil, s.loginFailed(ctx, username, nil, _authModel.LoginFailureUnknownUser)
+	}
 	if err != nil {
 		return nil, nil, err
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(password)); err != nil {
+	_, span := tracing.Tracer().Start(ctx, "bcrypt.CompareHashAndPassword")
+	err = bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(password))
+	tracing.End(span, err)
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return nil, nil, s.loginFailed(ctx, username, user, _authModel.LoginFailureWrongPassword)
+	}
+	if err != nil {
 		return nil, nil, err
 	}
 
 	// Generate token
+	_, span = tracing.Tracer().Start(ctx, "token.Generate")
 	payload := token.NewPayload(user.ID)
-	accessToken, err := s.tokenMaker.GenerateToken(payload, time.Duration(s.cfg.AccessTokenExpiryInHours)*time.Hour)
+	accessToken, err := s.tokenMaker.GenerateToken(payload.WithType(token.TokenTypeAccess), s.accessTokenExpiry())
 	if err != nil {
+		tracing.End(span, err)
 		return nil, nil, err
 	}
-	refreshToken, err := s.tokenMaker.GenerateToken(payload, time.Duration(s.cfg.RefreshTokenExpiryInHours)*time.Hour)
+	refreshToken, err := s.tokenMaker.GenerateToken(payload.WithType(token.TokenTypeRefresh), time.Duration(s.cfg.RefreshTokenExpiryInHours)*time.Hour)
+	tracing.End(span, err)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -84,16 +105,16 @@ func (s *authService) LoginByUsernamePassword(ctx *gin.Context, username string,
 		Payload:               payload,
 		AccessToken:           accessToken,
 		RefreshToken:          refreshToken,
-		AccessTokenExpiresIn:  time.Duration(s.cfg.AccessTokenExpiryInHours) * time.Hour,
+		AccessTokenExpiresIn:  s.accessTokenExpiry(),
 		RefreshTokenExpiresIn: time.Duration(s.cfg.RefreshTokenExpiryInHours) * time.Hour,
 	}
 
 	return user, data, nil
 }
 
-func (s *authService) RegisterUser(ctx *gin.Context, username string, email string, name string, password string, confirmPassword string) (*model.User, error) {
+func (s *authService) RegisterUser(ctx context.Context, username string, email string, name string, password string, confirmPassword string) (*model.User, error) {
 	if password != confirmPassword {
-		return nil, errors.New("password and confirm password does not match")
+		return nil, _userService.ErrPasswordConfirm
 	}
 
 	user, err := s.userService.CreateUser(ctx, username, email, name, password)
@@ -104,18 +125,36 @@ func (s *authService) RegisterUser(ctx *gin.Context, username string, email stri
 	return user, nil
 }
 
-func (s *authService) RefreshToken(ctx *gin.Context, refreshToken string) (*model.User, *RefreshTokenData, error) {
+func (s *authService) RefreshToken(ctx context.Context, refreshToken string) (*model.User, *RefreshTokenData, error) {
+	_, span := tracing.Tracer().Start(ctx, "token.Verify")
 	payload, err := s.tokenMaker.VerifyToken(refreshToken)
+	tracing.End(span, err)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, ErrInvalidToken.WithCause(err)
+	}
+	if payload.TokenType == token.TokenTypeAccess {
+		return nil, nil, ErrInvalidToken
 	}
 
 	loginSession, err := s.loginSessionService.FindByTokenID(ctx, payload.TokenID)
+	if errors.Is(err, ErrSessionNotFound) {
+		return nil, nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The session may come from a cache, compare with the stored refresh
+	// token so a rotated one cannot be reused on another replica
+	loginSession, err = s.loginSessionService.FindById(ctx, loginSession.ID)
+	if errors.Is(err, ErrSessionNotFound) {
+		return nil, nil, ErrInvalidToken
+	}
 	if err != nil {
 		return nil, nil, err
 	}
 	if refreshToken != loginSession.RefreshToken {
-		return nil, nil, errors.New("refreshToken does not match")
+		return nil, nil, ErrInvalidToken
 	}
 
 	user, err := s.userService.GetUserById(ctx, loginSession.UserId)
@@ -124,17 +163,20 @@ func (s *authService) RefreshToken(ctx *gin.Context, refreshToken string) (*mode
 	}
 
 	// generate access token
-	accessTokenExp := time.Duration(s.cfg.AccessTokenExpiryInHours) * time.Hour
+	_, span = tracing.Tracer().Start(ctx, "token.Generate")
+	accessTokenExp := s.accessTokenExpiry()
 	accessTokenExpIn := time.Now().Add(accessTokenExp)
-	accessToken, err := s.tokenMaker.GenerateToken(payload, accessTokenExp)
+	accessToken, err := s.tokenMaker.GenerateToken(payload.WithType(token.TokenTypeAccess), accessTokenExp)
 	if err != nil {
+		tracing.End(span, err)
 		return nil, nil, err
 	}
 
 	// do refresh token rotation
 	refreshTokenExp := time.Duration(s.cfg.RefreshTokenExpiryInHours) * time.Hour
 	refreshTokenExpIn := time.Now().Add(refreshTokenExp)
-	newRefreshToken, err := s.tokenMaker.GenerateToken(payload, refreshTokenExp)
+	newRefreshToken, err := s.tokenMaker.GenerateToken(payload.WithType(token.TokenTypeRefresh), refreshTokenExp)
+	tracing.End(span, err)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -158,3 +200,34 @@ func (s *authService) RefreshToken(ctx *gin.Context, refreshToken string) (*mode
 		RefreshTokenExpiresIn: refreshTokenExp,
 	}, nil
 }
+
+// loginFailed records the rejected login in the outbox and returns
+// ErrInvalidCredentials, or the error recording it. user is nil when the
+// username does not exist.
+func (s *authService) loginFailed(ctx context.Context, username string, user *model.User, reason string) error {
+	var userID uint
+	if user != nil {
+		userID = user.ID
+	}
+	event, err := outbox.NewEvent(_authModel.EventLoginFailed, model.AggregateUser, userID, _authModel.LoginFailure{
+		Username:   username,
+		Reason:     reason,
+		OccurredAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.outbox.Record(ctx, event); err != nil {
+		return err
+	}
+	return ErrInvalidCredentials
+}
+
+// accessTokenExpiry returns how long access tokens are valid. Stateless access
+// tokens cannot be checked against the database so they are kept short-lived.
+func (s *authService) accessTokenExpiry() time.Duration {
+	if s.cfg.AuthStatelessAccessTokens {
+		return s.cfg.AuthStatelessAccessTokenExpiry
+	}
+	return time.Duration(s.cfg.AccessTokenExpiryInHours) * time.Hour
+}
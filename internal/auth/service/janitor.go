@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"khiemle.dev/golang-api-template/internal/auth/repository"
+	"khiemle.dev/golang-api-template/pkg/metrics"
+)
+
+// SessionJanitor hard deletes login sessions whose refresh token expired and
+// sessions that were logged out longer than the retention window ago. It is
+// run periodically by the scheduler.
+type SessionJanitor struct {
+	loginSessionRepository repository.LoginSessionRepository
+	retention              time.Duration
+	purged                 metric.Int64Counter
+}
+
+func NewSessionJanitor(loginSessionRepository repository.LoginSessionRepository, retention time.Duration) (*SessionJanitor, error) {
+	purged, err := metrics.Meter().Int64Counter(
+		"auth.login_sessions.purged",
+		metric.WithDescription("Number of login sessions hard deleted by the janitor"),
+		metric.WithUnit("{session}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SessionJanitor{
+		loginSessionRepository: loginSessionRepository,
+		retention:              retention,
+		purged:                 purged,
+	}, nil
+}
+
+// Run purges expired and logged out sessions.
+func (j *SessionJanitor) Run(ctx context.Context) error {
+	now := time.Now()
+	expired, err := j.loginSessionRepository.PurgeExpired(ctx, now)
+	j.purged.Add(ctx, expired, metric.WithAttributes(attribute.String("reason", "expired")))
+	if err != nil {
+		return err
+	}
+	deleted, err := j.loginSessionRepository.PurgeDeleted(ctx, now.Add(-j.retention), now)
+	j.purged.Add(ctx, deleted, metric.WithAttributes(attribute.String("reason", "deleted")))
+	if err != nil {
+		return err
+	}
+
+	event := log.Debug()
+	if expired+deleted > 0 {
+		event = log.Info()
+	}
+	event.
+		Int64("expired", expired).
+		Int64("deleted", deleted).
+		Msg("Purged login sessions")
+	return nil
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"khiemle.dev/golang-api-template/internal/auth/repository"
+)
+
+// LastUsedBatcher coalesces LoginSession.LastUsedAt updates in memory and
+// writes them in a single batch every interval, so authenticated reads do not
+// each cause a database write.
+type LastUsedBatcher struct {
+	loginSessionRepository repository.LoginSessionRepository
+	interval               time.Duration
+
+	mu      sync.Mutex
+	pending map[uint]time.Time
+
+	started bool
+	stop    chan struct{}
+	// stopOnce lets overlapping shutdown paths both call Close
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func NewLastUsedBatcher(loginSessionRepository repository.LoginSessionRepository, interval time.Duration) *LastUsedBatcher {
+	return &LastUsedBatcher{
+		loginSessionRepository: loginSessionRepository,
+		interval:               interval,
+		pending:                map[uint]time.Time{},
+		stop:                   make(chan struct{}),
+		done:                   make(chan struct{}),
+	}
+}
+
+// Touch records that the session was used at the given time.
+func (b *LastUsedBatcher) Touch(loginSessionID uint, usedAt time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if current, ok := b.pending[loginSessionID]; !ok || current.Before(usedAt) {
+		b.pending[loginSessionID] = usedAt
+	}
+}
+
+// Flush writes all pending timestamps. On failure they are kept for the next
+// flush.
+func (b *LastUsedBatcher) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	batch := b.pending
+	b.pending = map[uint]time.Time{}
+	b.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	err := b.loginSessionRepository.UpdateLastUsedAt(ctx, batch)
+	if err != nil {
+		for id, usedAt := range batch {
+			b.Touch(id, usedAt)
+		}
+		return err
+	}
+
+	log.Debug().Int("count", len(batch)).Msg("Flushed login session last used timestamps")
+	return nil
+}
+
+// Start flushes pending timestamps every interval until Close is called.
+func (b *LastUsedBatcher) Start() {
+	b.mu.Lock()
+	b.started = true
+	b.mu.Unlock()
+
+	go func() {
+		defer close(b.done)
+
+		ticker := time.NewTicker(b.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := b.Flush(context.Background()); err != nil {
+					log.Error().Err(err).Msg("Could not flush login session last used timestamps")
+				}
+			case <-b.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the background flushes and writes what is still pending. It
+// may be called more than once.
+func (b *LastUsedBatcher) Close(ctx context.Context) error {
+	b.mu.Lock()
+	started := b.started
+	b.mu.Unlock()
+
+	if started {
+		b.stopOnce.Do(func() { close(b.stop) })
+		<-b.done
+	}
+	return b.Flush(ctx)
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"khiemle.dev/golang-api-template/internal/auth/model"
+	"khiemle.dev/golang-api-template/internal/auth/repository"
+)
+
+func TestLastUsedBatcherCloseTwice(t *testing.T) {
+	ctx := context.Background()
+	loginSessionRepository := repository.NewMemoryLoginSessionRepository()
+	loginSession := &model.LoginSession{
+		TokenID:               uuid.New(),
+		UserId:                1,
+		AccessTokenExpiresIn:  time.Now().Add(time.Hour),
+		RefreshTokenExpiresIn: time.Now().Add(time.Hour),
+	}
+	if err := loginSessionRepository.Create(ctx, loginSession); err != nil {
+		t.Fatal(err)
+	}
+
+	batcher := NewLastUsedBatcher(loginSessionRepository, time.Hour)
+	batcher.Start()
+	usedAt := time.Now().Truncate(time.Second)
+	batcher.Touch(loginSession.ID, usedAt)
+
+	// Overlapping shutdown paths both close the batcher
+	if err := batcher.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := batcher.Close(ctx); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+
+	got, err := loginSessionRepository.FindByID(ctx, loginSession.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.LastUsedAt == nil || !got.LastUsedAt.Equal(usedAt) {
+		t.Errorf("LastUsedAt = %v, want %v", got.LastUsedAt, usedAt)
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"khiemle.dev/golang-api-template/internal/auth/repository"
+	"khiemle.dev/golang-api-template/pkg/notify"
+)
+
+// JobNotifyNewLogin checks whether a login came from an unknown device
+const JobNotifyNewLogin = "notify_new_login"
+
+// NewLogin is the payload of JobNotifyNewLogin.
+type NewLogin struct {
+	LoginSessionID uint      `json:"login_session_id"`
+	UserID         uint      `json:"user_id"`
+	UserAgent      string    `json:"user_agent"`
+	ClientIP       string    `json:"client_ip"`
+	LoggedInAt     time.Time `json:"logged_in_at"`
+}
+
+// LoginAlerter tells users about logins from devices, identified by their
+// user agent, they never logged in from before.
+type LoginAlerter struct {
+	loginSessionRepository repository.LoginSessionRepository
+	notifier               *notify.Notifier
+}
+
+func NewLoginAlerter(loginSessionRepository repository.LoginSessionRepository, notifier *notify.Notifier) *LoginAlerter {
+	return &LoginAlerter{
+		loginSessionRepository: loginSessionRepository,
+		notifier:               notifier,
+	}
+}
+
+// Notify sends an in-app notification when the login is not the first one
+// of the user and no earlier login, still kept, came from the same device.
+func (a *LoginAlerter) Notify(ctx context.Context, job NewLogin) error {
+	earlier, err := a.loginSessionRepository.CountEarlier(ctx, job.UserID, job.LoginSessionID, "")
+	if err != nil {
+		return err
+	}
+	if earlier == 0 {
+		return nil
+	}
+	known, err := a.loginSessionRepository.CountEarlier(ctx, job.UserID, job.LoginSessionID, job.UserAgent)
+	if err != nil {
+		return err
+	}
+	if known > 0 {
+		return nil
+	}
+
+	device := job.UserAgent
+	if device == "" {
+		device = "an unknown device"
+	}
+	return a.notifier.Send(ctx, notify.ChannelInApp, notify.Message{
+		ID:      fmt.Sprintf("login-%d", job.LoginSessionID),
+		UserID:  job.UserID,
+		Kind:    "auth.new_login",
+		Subject: "New login to your account",
+		Body: fmt.Sprintf("Your account was logged into from %s (%s) at %s. If this was not you, change your password.",
+			device, job.ClientIP, job.LoggedInAt.UTC().Format(time.RFC1123)),
+		Data: map[string]any{
+			"login_session_id": job.LoginSessionID,
+			"user_agent":       job.UserAgent,
+			"client_ip":        job.ClientIP,
+		},
+	})
+}
@@ -1,14 +1,23 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/rs/zerolog"
 	"gorm.io/gorm"
 	"khiemle.dev/golang-api-template/internal/auth/model"
+	"khiemle.dev/golang-api-template/internal/auth/repository"
+	"khiemle.dev/golang-api-template/pkg/apperror"
+	"khiemle.dev/golang-api-template/pkg/jobqueue"
+	"khiemle.dev/golang-api-template/pkg/outbox"
+	"khiemle.dev/golang-api-template/pkg/txn"
 )
 
+var ErrSessionNotFound = apperror.Unauthorized(apperror.CodeSessionNotFound, "login session not found")
+
 type CreateLoginSessionArgs struct {
 	TokenID               uuid.UUID
 	UserId                uint
@@ -28,37 +37,54 @@ type UpdateLoginSessionArgs struct {
 	LastUsedAt            *time.Time
 }
 
+// LoginSessionService manages login sessions. Logins and logouts are
+// recorded in the outbox as domain events of the session.
 type LoginSessionService interface {
-	Create(c *gin.Context, args CreateLoginSessionArgs) (*model.LoginSession, error)
-	FindById(c *gin.Context, id uint) (*model.LoginSession, error)
-	FindByTokenID(c *gin.Context, tokenID uuid.UUID) (*model.LoginSession, error)
-	UpdateSession(c *gin.Context, id uint, args UpdateLoginSessionArgs) (*model.LoginSession, error)
-	DeleteByTokenID(c *gin.Context, tokenID uuid.UUID) error
+	Create(ctx context.Context, args CreateLoginSessionArgs) (*model.LoginSession, error)
+	FindById(ctx context.Context, id uint) (*model.LoginSession, error)
+	FindByTokenID(ctx context.Context, tokenID uuid.UUID) (*model.LoginSession, error)
+	UpdateSession(ctx context.Context, id uint, args UpdateLoginSessionArgs) (*model.LoginSession, error)
+	DeleteByTokenID(ctx context.Context, tokenID uuid.UUID) error
+	// MarkUsed records that the session was used. The write is batched and
+	// happens asynchronously.
+	MarkUsed(id uint, usedAt time.Time)
 }
 
 type loginSessionService struct {
-	db *gorm.DB
+	loginSessionRepository repository.LoginSessionRepository
+	lastUsedBatcher        *LastUsedBatcher
+	jobs                   jobqueue.Client
+	transactor             txn.Transactor
+	outbox                 outbox.Recorder
 }
 
-func NewLoginSessionService(db *gorm.DB) LoginSessionService {
+func NewLoginSessionService(
+	loginSessionRepository repository.LoginSessionRepository,
+	lastUsedBatcher *LastUsedBatcher,
+	jobs jobqueue.Client,
+	transactor txn.Transactor,
+	recorder outbox.Recorder,
+) LoginSessionService {
 	return &loginSessionService{
-		db: db,
+		loginSessionRepository: loginSessionRepository,
+		lastUsedBatcher:        lastUsedBatcher,
+		jobs:                   jobs,
+		transactor:             transactor,
+		outbox:                 recorder,
 	}
 }
 
-func (s *loginSessionService) FindById(c *gin.Context, id uint) (*model.LoginSession, error) {
-	loginSession := model.LoginSession{}
-	tx := s.db.First(&loginSession, "id = ?", id)
-	return &loginSession, tx.Error
+func (s *loginSessionService) FindById(ctx context.Context, id uint) (*model.LoginSession, error) {
+	loginSession, err := s.loginSessionRepository.FindByID(ctx, id)
+	return loginSession, translateError(err)
 }
 
-func (s *loginSessionService) FindByTokenID(c *gin.Context, tokenID uuid.UUID) (*model.LoginSession, error) {
-	loginSession := model.LoginSession{}
-	tx := s.db.First(&loginSession, "token_id = ?", tokenID)
-	return &loginSession, tx.Error
+func (s *loginSessionService) FindByTokenID(ctx context.Context, tokenID uuid.UUID) (*model.LoginSession, error) {
+	loginSession, err := s.loginSessionRepository.FindByTokenID(ctx, tokenID)
+	return loginSession, translateError(err)
 }
 
-func (s *loginSessionService) Create(c *gin.Context, args CreateLoginSessionArgs) (*model.LoginSession, error) {
+func (s *loginSessionService) Create(ctx context.Context, args CreateLoginSessionArgs) (*model.LoginSession, error) {
 	loginSession := model.LoginSession{
 		TokenID:               args.TokenID,
 		UserId:                args.UserId,
@@ -69,18 +95,38 @@ func (s *loginSessionService) Create(c *gin.Context, args CreateLoginSessionArgs
 		AccessTokenExpiresIn:  args.AccessTokenExpiresIn,
 		RefreshTokenExpiresIn: args.RefreshTokenExpiresIn,
 	}
-	tx := s.db.Create(&loginSession)
-	return &loginSession, tx.Error
-}
+	err := s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		if err := s.loginSessionRepository.Create(ctx, &loginSession); err != nil {
+			return err
+		}
 
-func (s *loginSessionService) UpdateSession(c *gin.Context, id uint, args UpdateLoginSessionArgs) (*model.LoginSession, error) {
-	loginSession := model.LoginSession{}
-	err := s.db.Transaction(func(tx *gorm.DB) error {
-		err := tx.First(&loginSession, "id = ?", id).Error
-		if err != nil {
+		// The login succeeded even if the user cannot be told about it. The
+		// job is enqueued in a savepoint, as a failed statement would abort
+		// the whole transaction on PostgreSQL.
+		err := s.transactor.Transaction(ctx, func(ctx context.Context) error {
+			_, err := s.jobs.Enqueue(ctx, JobNotifyNewLogin, NewLogin{
+				LoginSessionID: loginSession.ID,
+				UserID:         loginSession.UserId,
+				UserAgent:      loginSession.UserAgent,
+				ClientIP:       loginSession.ClientIP,
+				LoggedInAt:     loginSession.CreatedAt,
+			})
 			return err
+		})
+		if err != nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("Could not enqueue new login notification")
 		}
 
+		return s.record(ctx, model.EventSessionCreated, &loginSession, loginSession.CreatedAt)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &loginSession, nil
+}
+
+func (s *loginSessionService) UpdateSession(ctx context.Context, id uint, args UpdateLoginSessionArgs) (*model.LoginSession, error) {
+	loginSession, err := s.loginSessionRepository.Update(ctx, id, func(loginSession *model.LoginSession) error {
 		if args.AccessToken != nil {
 			loginSession.AccessToken = *args.AccessToken
 		}
@@ -96,13 +142,45 @@ func (s *loginSessionService) UpdateSession(c *gin.Context, id uint, args Update
 		if args.LastUsedAt != nil {
 			loginSession.LastUsedAt = args.LastUsedAt
 		}
-		return tx.Save(&loginSession).Error
+		return nil
 	})
-	return &loginSession, err
+	return loginSession, translateError(err)
 }
 
-func (s *loginSessionService) DeleteByTokenID(c *gin.Context, tokenID uuid.UUID) error {
-	loginSession := model.LoginSession{}
-	tx := s.db.Delete(&loginSession, "token_id = ?", tokenID)
-	return tx.Error
+// DeleteByTokenID revokes the session. Sessions already deleted are ignored.
+func (s *loginSessionService) DeleteByTokenID(ctx context.Context, tokenID uuid.UUID) error {
+	return s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		loginSession, err := s.loginSessionRepository.FindByTokenID(ctx, tokenID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.loginSessionRepository.DeleteByTokenID(ctx, tokenID); err != nil {
+			return err
+		}
+		return s.record(ctx, model.EventSessionRevoked, loginSession, time.Now())
+	})
+}
+
+func (s *loginSessionService) MarkUsed(id uint, usedAt time.Time) {
+	s.lastUsedBatcher.Touch(id, usedAt)
+}
+
+// record records a domain event of the session in the outbox
+func (s *loginSessionService) record(ctx context.Context, eventType string, loginSession *model.LoginSession, at time.Time) error {
+	event, err := outbox.NewEvent(eventType, model.AggregateLoginSession, loginSession.ID, model.NewSessionChange(loginSession, at))
+	if err != nil {
+		return err
+	}
+	return s.outbox.Record(ctx, event)
+}
+
+// translateError turns repository errors into domain errors
+func translateError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrSessionNotFound
+	}
+	return err
 }
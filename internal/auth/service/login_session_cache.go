@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"khiemle.dev/golang-api-template/internal/auth/model"
+	"khiemle.dev/golang-api-template/pkg/cache"
+)
+
+const loginSessionCacheSize = 10000
+
+type cachedLoginSessionService struct {
+	LoginSessionService
+	byTokenID *cache.TTLCache[uuid.UUID, model.LoginSession]
+}
+
+// NewCachedLoginSessionService caches FindByTokenID lookups of next for ttl.
+// Entries are invalidated when the session is updated or deleted through this
+// service; other replicas see the change once their entry expires.
+func NewCachedLoginSessionService(next LoginSessionService, ttl time.Duration) LoginSessionService {
+	return &cachedLoginSessionService{
+		LoginSessionService: next,
+		byTokenID:           cache.NewTTLCache[uuid.UUID, model.LoginSession](ttl, loginSessionCacheSize),
+	}
+}
+
+func (s *cachedLoginSessionService) FindByTokenID(ctx context.Context, tokenID uuid.UUID) (*model.LoginSession, error) {
+	if loginSession, ok := s.byTokenID.Get(tokenID); ok {
+		return &loginSession, nil
+	}
+
+	loginSession, err := s.LoginSessionService.FindByTokenID(ctx, tokenID)
+	if err != nil {
+		return nil, err
+	}
+	s.byTokenID.Set(tokenID, *loginSession)
+	return loginSession, nil
+}
+
+func (s *cachedLoginSessionService) UpdateSession(ctx context.Context, id uint, args UpdateLoginSessionArgs) (*model.LoginSession, error) {
+	loginSession, err := s.LoginSessionService.UpdateSession(ctx, id, args)
+	if err != nil {
+		return nil, err
+	}
+	s.byTokenID.Delete(loginSession.TokenID)
+	return loginSession, nil
+}
+
+func (s *cachedLoginSessionService) DeleteByTokenID(ctx context.Context, tokenID uuid.UUID) error {
+	defer s.byTokenID.Delete(tokenID)
+	return s.LoginSessionService.DeleteByTokenID(ctx, tokenID)
+}
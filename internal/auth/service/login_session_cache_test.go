@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"khiemle.dev/golang-api-template/internal/auth/model"
+)
+
+// countingLoginSessionService serves a single session and counts lookups
+type countingLoginSessionService struct {
+	LoginSessionService
+	loginSession model.LoginSession
+	lookups      int
+}
+
+func (s *countingLoginSessionService) FindByTokenID(ctx context.Context, tokenID uuid.UUID) (*model.LoginSession, error) {
+	s.lookups++
+	if tokenID != s.loginSession.TokenID {
+		return nil, ErrSessionNotFound
+	}
+	loginSession := s.loginSession
+	return &loginSession, nil
+}
+
+func (s *countingLoginSessionService) UpdateSession(ctx context.Context, id uint, args UpdateLoginSessionArgs) (*model.LoginSession, error) {
+	s.loginSession.RefreshToken = *args.RefreshToken
+	loginSession := s.loginSession
+	return &loginSession, nil
+}
+
+func (s *countingLoginSessionService) DeleteByTokenID(ctx context.Context, tokenID uuid.UUID) error {
+	return nil
+}
+
+func TestCachedLoginSessionService(t *testing.T) {
+	ctx := context.Background()
+	next := &countingLoginSessionService{
+		loginSession: model.LoginSession{ID: 1, TokenID: uuid.New(), RefreshToken: "first"},
+	}
+	cached := NewCachedLoginSessionService(next, time.Minute)
+	tokenID := next.loginSession.TokenID
+
+	find := func() *model.LoginSession {
+		t.Helper()
+		loginSession, err := cached.FindByTokenID(ctx, tokenID)
+		if err != nil {
+			t.Fatalf("FindByTokenID: %v", err)
+		}
+		return loginSession
+	}
+
+	find()
+	find()
+	if next.lookups != 1 {
+		t.Errorf("lookups = %d, want 1", next.lookups)
+	}
+
+	// Updates invalidate the cached session
+	second := "second"
+	if _, err := cached.UpdateSession(ctx, 1, UpdateLoginSessionArgs{RefreshToken: &second}); err != nil {
+		t.Fatalf("UpdateSession: %v", err)
+	}
+	if loginSession := find(); loginSession.RefreshToken != "second" || next.lookups != 2 {
+		t.Errorf("after UpdateSession got %q with %d lookups, want the updated session", loginSession.RefreshToken, next.lookups)
+	}
+
+	if err := cached.DeleteByTokenID(ctx, tokenID); err != nil {
+		t.Fatalf("DeleteByTokenID: %v", err)
+	}
+	find()
+	if next.lookups != 3 {
+		t.Errorf("lookups after DeleteByTokenID = %d, want 3", next.lookups)
+	}
+}
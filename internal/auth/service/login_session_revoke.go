@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type revokingLoginSessionService struct {
+	LoginSessionService
+	revocationList *RevocationList
+	accessTokenTTL time.Duration
+}
+
+// NewRevokingLoginSessionService adds the token id of deleted sessions to
+// revocationList right away, so this replica rejects the access token before
+// the next sync. accessTokenTTL bounds how long the entry is kept.
+func NewRevokingLoginSessionService(next LoginSessionService, revocationList *RevocationList, accessTokenTTL time.Duration) LoginSessionService {
+	return &revokingLoginSessionService{
+		LoginSessionService: next,
+		revocationList:      revocationList,
+		accessTokenTTL:      accessTokenTTL,
+	}
+}
+
+func (s *revokingLoginSessionService) DeleteByTokenID(ctx context.Context, tokenID uuid.UUID) error {
+	if err := s.LoginSessionService.DeleteByTokenID(ctx, tokenID); err != nil {
+		return err
+	}
+	s.revocationList.Revoke(tokenID, time.Now().Add(s.accessTokenTTL))
+	return nil
+}
@@ -0,0 +1,74 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"khiemle.dev/golang-api-template/internal/auth/model"
+	"khiemle.dev/golang-api-template/internal/auth/repository"
+	"khiemle.dev/golang-api-template/internal/auth/service"
+	_userModel "khiemle.dev/golang-api-template/internal/user/model"
+	"khiemle.dev/golang-api-template/pkg/database/databasetest"
+	"khiemle.dev/golang-api-template/pkg/jobqueue"
+	"khiemle.dev/golang-api-template/pkg/outbox"
+	"khiemle.dev/golang-api-template/pkg/pubsub"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+// failingClient stores the job, then fails as if a later statement did
+type failingClient struct {
+	jobqueue.Client
+}
+
+func (c failingClient) Enqueue(ctx context.Context, jobType string, payload any, opts ...jobqueue.EnqueueOption) (*jobqueue.Job, error) {
+	if _, err := c.Client.Enqueue(ctx, jobType, payload, opts...); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("queue unavailable")
+}
+
+func TestCreateLoginSessionSurvivesFailedNotification(t *testing.T) {
+	ctx := context.Background()
+	db := databasetest.New(t)
+	user := _userModel.User{Username: "alice", Email: "alice@example.com", Name: "Alice", HashedPassword: "x"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	svc := service.NewLoginSessionService(
+		repository.NewGormLoginSessionRepository(db),
+		nil,
+		failingClient{jobqueue.NewClient(db)},
+		txn.NewGormTransactor(db),
+		outbox.NewRecorder(db, pubsub.NewLocal()),
+	)
+
+	loginSession, err := svc.Create(ctx, service.CreateLoginSessionArgs{
+		TokenID:               uuid.New(),
+		UserId:                user.ID,
+		AccessTokenExpiresIn:  time.Now().Add(time.Hour),
+		RefreshTokenExpiresIn: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// The writes of the failed enqueue are rolled back, the session and its
+	// event are committed
+	var jobs, events int64
+	if err := db.Model(&jobqueue.Job{}).Count(&jobs).Error; err != nil {
+		t.Fatal(err)
+	}
+	err = db.Model(&outbox.Event{}).Where("type = ? AND aggregate_id = ?", model.EventSessionCreated, loginSession.ID).Count(&events).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobs != 0 || events != 1 {
+		t.Errorf("got %d jobs and %d events, want 0 and 1", jobs, events)
+	}
+	if _, err := svc.FindById(ctx, loginSession.ID); err != nil {
+		t.Errorf("FindById: %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"khiemle.dev/golang-api-template/internal/auth/repository"
+)
+
+// RevocationList is an in-memory denylist of token ids whose login session
+// was deleted before the access token expired. It is synced from the database
+// every interval so logouts on other replicas are picked up, and entries are
+// dropped once the access token would have expired anyway.
+type RevocationList struct {
+	loginSessionRepository repository.LoginSessionRepository
+	interval               time.Duration
+
+	mu      sync.RWMutex
+	revoked map[uuid.UUID]time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewRevocationList(loginSessionRepository repository.LoginSessionRepository, interval time.Duration) *RevocationList {
+	return &RevocationList{
+		loginSessionRepository: loginSessionRepository,
+		interval:               interval,
+		revoked:                map[uuid.UUID]time.Time{},
+	}
+}
+
+// IsRevoked reports whether access tokens with the given id must be rejected.
+func (l *RevocationList) IsRevoked(tokenID uuid.UUID) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	until, ok := l.revoked[tokenID]
+	return ok && time.Now().Before(until)
+}
+
+// Revoke adds the token id to the list until the given time, without waiting
+// for the next sync.
+func (l *RevocationList) Revoke(tokenID uuid.UUID, until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if current, ok := l.revoked[tokenID]; !ok || current.Before(until) {
+		l.revoked[tokenID] = until
+	}
+}
+
+// Sync loads revoked sessions from the database and prunes expired entries.
+func (l *RevocationList) Sync(ctx context.Context) error {
+	now := time.Now()
+	loginSessions, err := l.loginSessionRepository.ListRevoked(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for tokenID, until := range l.revoked {
+		if !now.Before(until) {
+			delete(l.revoked, tokenID)
+		}
+	}
+	for _, loginSession := range loginSessions {
+		if current, ok := l.revoked[loginSession.TokenID]; !ok || current.Before(loginSession.AccessTokenExpiresIn) {
+			l.revoked[loginSession.TokenID] = loginSession.AccessTokenExpiresIn
+		}
+	}
+
+	log.Debug().Int("count", len(l.revoked)).Msg("Synced revoked access tokens")
+	return nil
+}
+
+// Start syncs the list once and then every interval until Close is called.
+// Requests must not be served before the first sync succeeded, otherwise
+// tokens revoked before startup would be accepted.
+func (l *RevocationList) Start(ctx context.Context) error {
+	if err := l.Sync(ctx); err != nil {
+		return err
+	}
+
+	l.stop = make(chan struct{})
+	l.done = make(chan struct{})
+
+	go func() {
+		defer close(l.done)
+
+		ticker := time.NewTicker(l.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := l.Sync(context.Background()); err != nil {
+					log.Error().Err(err).Msg("Could not sync revoked access tokens")
+				}
+			case <-l.stop:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Close stops the background syncs.
+func (l *RevocationList) Close(ctx context.Context) error {
+	if l.stop == nil {
+		return nil
+	}
+
+	close(l.stop)
+	select {
+	case <-l.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"khiemle.dev/golang-api-template/internal/auth/model"
+	"khiemle.dev/golang-api-template/internal/auth/repository"
+)
+
+func TestRevocationList(t *testing.T) {
+	ctx := context.Background()
+	loginSessionRepository := repository.NewMemoryLoginSessionRepository()
+	revocationList := NewRevocationList(loginSessionRepository, time.Hour)
+
+	create := func(accessTTL time.Duration) *model.LoginSession {
+		t.Helper()
+		loginSession := &model.LoginSession{
+			TokenID:               uuid.New(),
+			UserId:                1,
+			AccessTokenExpiresIn:  time.Now().Add(accessTTL),
+			RefreshTokenExpiresIn: time.Now().Add(24 * time.Hour),
+		}
+		if err := loginSessionRepository.Create(ctx, loginSession); err != nil {
+			t.Fatal(err)
+		}
+		return loginSession
+	}
+
+	// Logged out on another replica
+	loggedOut := create(time.Hour)
+	expired := create(-time.Minute)
+	active := create(time.Hour)
+	for _, loginSession := range []*model.LoginSession{loggedOut, expired} {
+		if err := loginSessionRepository.DeleteByTokenID(ctx, loginSession.TokenID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := revocationList.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { revocationList.Close(ctx) })
+
+	if !revocationList.IsRevoked(loggedOut.TokenID) {
+		t.Error("a token logged out before the first sync is accepted")
+	}
+	if revocationList.IsRevoked(expired.TokenID) || revocationList.IsRevoked(active.TokenID) {
+		t.Error("an expired or active token is revoked")
+	}
+
+	// Logged out on this replica
+	revocationList.Revoke(active.TokenID, time.Now().Add(time.Hour))
+	if !revocationList.IsRevoked(active.TokenID) {
+		t.Error("a token revoked locally is accepted")
+	}
+
+	// Entries are dropped once the access token expired
+	revocationList.Revoke(expired.TokenID, time.Now().Add(-time.Second))
+	if revocationList.IsRevoked(expired.TokenID) {
+		t.Error("a token is revoked after it expired")
+	}
+}
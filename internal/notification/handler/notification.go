@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"khiemle.dev/golang-api-template/internal/notification/service"
+	"khiemle.dev/golang-api-template/internal/schemas"
+	"khiemle.dev/golang-api-template/pkg/apperror"
+	"khiemle.dev/golang-api-template/pkg/middleware"
+)
+
+type NotificationHandler interface {
+	ListNotificationHandler(c *gin.Context)
+	MarkReadHandler(c *gin.Context)
+	MarkAllReadHandler(c *gin.Context)
+}
+
+type notificationHandler struct {
+	notificationService service.NotificationService
+}
+
+func NewNotificationHandler(notificationService service.NotificationService) NotificationHandler {
+	return &notificationHandler{
+		notificationService: notificationService,
+	}
+}
+
+// ListNotificationHandler godoc
+
+// @Summary		List notifications
+// @Description	List notifications of the current user, newest first
+// @Tags			notifications
+// @Accept			json
+// @Produce		json
+// @Param			status	query		string	false	"all (default), unread or read"
+// @Param			before	query		int		false	"next_before of the previous page"
+// @Param			limit	query		int		false	"page size, 20 by default and at most 100"
+// @Success		200		{object}	schemas.ListNotificationResponse
+// @Failure		401		{object}	schemas.ProblemDetails
+// @Failure		422		{object}	schemas.ProblemDetails
+// @Failure		500		{object}	schemas.ProblemDetails
+// @Router			/notifications/ [get]
+// @Security		BearerAuth
+func (h *notificationHandler) ListNotificationHandler(c *gin.Context) {
+	req := schemas.ListNotificationRequest{}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	args := service.ListNotificationsArgs{
+		Before: req.Before,
+		Limit:  req.Limit,
+	}
+	switch req.Status {
+	case schemas.NotificationStatusUnread:
+		read := false
+		args.Read = &read
+	case schemas.NotificationStatusRead:
+		read := true
+		args.Read = &read
+	}
+
+	page, err := h.notificationService.ListNotifications(c.Request.Context(), middleware.CurrentUserID(c), args)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.NewListNotificationResponse(http.StatusOK, http.StatusText(http.StatusOK), page))
+}
+
+// MarkReadHandler godoc
+
+// @Summary		Mark notification read
+// @Description	Mark a notification of the current user read
+// @Tags			notifications
+// @Accept			json
+// @Produce		json
+// @Param			id	path		int	true	"Notification id"
+// @Success		200	{object}	schemas.APIResponse{data=schemas.NotificationResponse}
+// @Failure		401	{object}	schemas.ProblemDetails
+// @Failure		404	{object}	schemas.ProblemDetails
+// @Failure		500	{object}	schemas.ProblemDetails
+// @Router			/notifications/{id}/read [post]
+// @Security		BearerAuth
+func (h *notificationHandler) MarkReadHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		middleware.AbortWithError(c, apperror.Validation(apperror.FieldError{
+			Field:   "id",
+			Code:    "integer",
+			Message: "must be an integer",
+		}))
+		return
+	}
+
+	notification, err := h.notificationService.MarkRead(c.Request.Context(), middleware.CurrentUserID(c), id)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    schemas.NewNotificationResponse(notification),
+	})
+}
+
+// MarkAllReadHandler godoc
+
+// @Summary		Mark all notifications read
+// @Description	Mark every unread notification of the current user read
+// @Tags			notifications
+// @Accept			json
+// @Produce		json
+// @Success		200	{object}	schemas.MarkAllNotificationsReadResponse
+// @Failure		401	{object}	schemas.ProblemDetails
+// @Failure		500	{object}	schemas.ProblemDetails
+// @Router			/notifications/read-all [post]
+// @Security		BearerAuth
+func (h *notificationHandler) MarkAllReadHandler(c *gin.Context) {
+	updated, err := h.notificationService.MarkAllRead(c.Request.Context(), middleware.CurrentUserID(c))
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.MarkAllNotificationsReadResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Updated: updated,
+	})
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// Notification is a message in the in-app inbox of a user.
+type Notification struct {
+	ID     uint   `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID uint   `json:"user_id" gorm:"not null;index;uniqueIndex:idx_notifications_user_id_dedup_key"`
+	Kind   string `json:"kind" gorm:"size:100;not null"`
+	Title  string `json:"title" gorm:"size:255;not null"`
+	Body   string `json:"body" gorm:"type:text;not null"`
+	// Data is a JSON object with details for clients, such as the todo id
+	Data string `json:"data" gorm:"type:text;not null"`
+	// DedupKey makes delivering the same message twice a no-op
+	DedupKey  *string    `json:"-" gorm:"size:200;uniqueIndex:idx_notifications_user_id_dedup_key"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
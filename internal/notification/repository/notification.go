@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/notification/model"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+// ListNotificationFilter selects a page of the notifications of UserID,
+// newest first.
+type ListNotificationFilter struct {
+	UserID uint
+	// Read keeps read or unread notifications. Nil means no filter.
+	Read *bool
+	// BeforeID keeps notifications older than the given one, 0 starts from
+	// the newest.
+	BeforeID uint
+	Limit    int
+}
+
+// NotificationRepository persists the in-app notifications of users. A
+// second notification of a user with the same dedup key returns
+// gorm.ErrDuplicatedKey and lookups of missing notifications, or of another
+// user, return gorm.ErrRecordNotFound regardless of the implementation.
+type NotificationRepository interface {
+	Create(ctx context.Context, notification *model.Notification) error
+	List(ctx context.Context, filter ListNotificationFilter) ([]model.Notification, error)
+	CountUnread(ctx context.Context, userID uint) (int64, error)
+	// MarkRead sets ReadAt of the notification unless it was already read.
+	MarkRead(ctx context.Context, userID uint, id uint, at time.Time) (*model.Notification, error)
+	// MarkAllRead marks every unread notification of the user read and
+	// returns how many were.
+	MarkAllRead(ctx context.Context, userID uint, at time.Time) (int64, error)
+}
+
+type gormNotificationRepository struct {
+	db *gorm.DB
+}
+
+func NewGormNotificationRepository(db *gorm.DB) NotificationRepository {
+	return &gormNotificationRepository{
+		db: db,
+	}
+}
+
+func (r *gormNotificationRepository) Create(ctx context.Context, notification *model.Notification) error {
+	return txn.DB(ctx, r.db).Create(notification).Error
+}
+
+func (r *gormNotificationRepository) List(ctx context.Context, filter ListNotificationFilter) ([]model.Notification, error) {
+	query := txn.DB(ctx, r.db).Where("user_id = ?", filter.UserID)
+	if filter.Read != nil {
+		if *filter.Read {
+			query = query.Where("read_at IS NOT NULL")
+		} else {
+			query = query.Where("read_at IS NULL")
+		}
+	}
+	if filter.BeforeID != 0 {
+		query = query.Where("id < ?", filter.BeforeID)
+	}
+
+	notifications := []model.Notification{}
+	err := query.Order("id DESC").Limit(filter.Limit).Find(&notifications).Error
+	if err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func (r *gormNotificationRepository) CountUnread(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := txn.DB(ctx, r.db).Model(&model.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *gormNotificationRepository) MarkRead(ctx context.Context, userID uint, id uint, at time.Time) (*model.Notification, error) {
+	notification := model.Notification{}
+	err := txn.DB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.Notification{}).
+			Where("id = ? AND user_id = ? AND read_at IS NULL", id, userID).
+			Update("read_at", at).Error
+		if err != nil {
+			return err
+		}
+		return tx.First(&notification, "id = ? AND user_id = ?", id, userID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
+
+func (r *gormNotificationRepository) MarkAllRead(ctx context.Context, userID uint, at time.Time) (int64, error) {
+	tx := txn.DB(ctx, r.db).Model(&model.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", at)
+	return tx.RowsAffected, tx.Error
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/notification/model"
+)
+
+type memoryNotificationRepository struct {
+	mu            sync.RWMutex
+	nextID        uint
+	notifications map[uint]model.Notification
+}
+
+// NewMemoryNotificationRepository creates a NotificationRepository backed by
+// a map, for tests and demos that run without a database.
+func NewMemoryNotificationRepository() NotificationRepository {
+	return &memoryNotificationRepository{
+		notifications: map[uint]model.Notification{},
+	}
+}
+
+func (r *memoryNotificationRepository) Create(ctx context.Context, notification *model.Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if notification.DedupKey != nil {
+		for _, existing := range r.notifications {
+			if existing.UserID == notification.UserID && existing.DedupKey != nil && *existing.DedupKey == *notification.DedupKey {
+				return gorm.ErrDuplicatedKey
+			}
+		}
+	}
+
+	r.nextID++
+	notification.ID = r.nextID
+	notification.CreatedAt = time.Now()
+	r.notifications[notification.ID] = *notification
+	return nil
+}
+
+func (r *memoryNotificationRepository) List(ctx context.Context, filter ListNotificationFilter) ([]model.Notification, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	notifications := []model.Notification{}
+	for _, notification := range r.notifications {
+		if notification.UserID != filter.UserID {
+			continue
+		}
+		if filter.Read != nil && (notification.ReadAt != nil) != *filter.Read {
+			continue
+		}
+		if filter.BeforeID != 0 && notification.ID >= filter.BeforeID {
+			continue
+		}
+		notifications = append(notifications, notification)
+	}
+	sort.Slice(notifications, func(i, j int) bool {
+		return notifications[i].ID > notifications[j].ID
+	})
+	if len(notifications) > filter.Limit {
+		notifications = notifications[:filter.Limit]
+	}
+	return notifications, nil
+}
+
+func (r *memoryNotificationRepository) CountUnread(ctx context.Context, userID uint) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var count int64
+	for _, notification := range r.notifications {
+		if notification.UserID == userID && notification.ReadAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryNotificationRepository) MarkRead(ctx context.Context, userID uint, id uint, at time.Time) (*model.Notification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	notification, ok := r.notifications[id]
+	if !ok || notification.UserID != userID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if notification.ReadAt == nil {
+		notification.ReadAt = &at
+		r.notifications[id] = notification
+	}
+	return &notification, nil
+}
+
+func (r *memoryNotificationRepository) MarkAllRead(ctx context.Context, userID uint, at time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var count int64
+	for id, notification := range r.notifications {
+		if notification.UserID == userID && notification.ReadAt == nil {
+			notification.ReadAt = &at
+			r.notifications[id] = notification
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/notification/model"
+	"khiemle.dev/golang-api-template/internal/notification/repository"
+	"khiemle.dev/golang-api-template/pkg/notify"
+)
+
+type inAppChannel struct {
+	notificationRepository repository.NotificationRepository
+}
+
+// NewInAppChannel creates a channel storing messages in the inbox of the
+// user. Messages with the ID of one already stored are dropped.
+func NewInAppChannel(notificationRepository repository.NotificationRepository) notify.Channel {
+	return &inAppChannel{
+		notificationRepository: notificationRepository,
+	}
+}
+
+func (c *inAppChannel) Send(ctx context.Context, msg notify.Message) error {
+	data := msg.Data
+	if data == nil {
+		data = map[string]any{}
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	notification := model.Notification{
+		UserID: msg.UserID,
+		Kind:   msg.Kind,
+		Title:  msg.Subject,
+		Body:   msg.Body,
+		Data:   string(encoded),
+	}
+	if msg.ID != "" {
+		notification.DedupKey = &msg.ID
+	}
+
+	err = c.notificationRepository.Create(ctx, &notification)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil
+	}
+	return err
+}
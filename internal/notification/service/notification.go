@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/notification/model"
+	"khiemle.dev/golang-api-template/internal/notification/repository"
+	"khiemle.dev/golang-api-template/pkg/apperror"
+)
+
+// Page sizes of ListNotifications
+const (
+	DefaultNotificationPageSize = 20
+	MaxNotificationPageSize     = 100
+)
+
+var ErrNotificationNotFound = apperror.NotFound(apperror.CodeNotificationNotFound, "notification not found")
+
+type ListNotificationsArgs struct {
+	// Read keeps read or unread notifications. Nil means all of them.
+	Read *bool
+	// Before is the NextBefore cursor of the previous page, 0 for the first.
+	Before uint
+	Limit  int
+}
+
+// NotificationPage is a page of notifications, newest first.
+type NotificationPage struct {
+	Notifications []model.Notification
+	UnreadCount   int64
+	// NextBefore is the cursor of the next page, nil on the last one.
+	NextBefore *uint
+}
+
+type NotificationService interface {
+	ListNotifications(ctx context.Context, userID uint, args ListNotificationsArgs) (*NotificationPage, error)
+	MarkRead(ctx context.Context, userID uint, id int) (*model.Notification, error)
+	MarkAllRead(ctx context.Context, userID uint) (int64, error)
+}
+
+type notificationService struct {
+	notificationRepository repository.NotificationRepository
+}
+
+func NewNotificationService(notificationRepository repository.NotificationRepository) NotificationService {
+	return &notificationService{
+		notificationRepository: notificationRepository,
+	}
+}
+
+func (s *notificationService) ListNotifications(ctx context.Context, userID uint, args ListNotificationsArgs) (*NotificationPage, error) {
+	limit := args.Limit
+	if limit <= 0 {
+		limit = DefaultNotificationPageSize
+	}
+	limit = min(limit, MaxNotificationPageSize)
+
+	// Fetch one more to know whether there is a next page
+	notifications, err := s.notificationRepository.List(ctx, repository.ListNotificationFilter{
+		UserID:   userID,
+		Read:     args.Read,
+		BeforeID: args.Before,
+		Limit:    limit + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &NotificationPage{Notifications: notifications}
+	if len(notifications) > limit {
+		page.Notifications = notifications[:limit]
+		next := page.Notifications[limit-1].ID
+		page.NextBefore = &next
+	}
+
+	page.UnreadCount, err = s.notificationRepository.CountUnread(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func (s *notificationService) MarkRead(ctx context.Context, userID uint, id int) (*model.Notification, error) {
+	notification, err := s.notificationRepository.MarkRead(ctx, userID, uint(id), time.Now())
+	return notification, translateError(err)
+}
+
+func (s *notificationService) MarkAllRead(ctx context.Context, userID uint) (int64, error) {
+	return s.notificationRepository.MarkAllRead(ctx, userID, time.Now())
+}
+
+// translateError turns repository errors into domain errors
+func translateError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotificationNotFound
+	}
+	return err
+}
@@ -0,0 +1,113 @@
+package schemas
+
+import (
+	"encoding/json"
+	"time"
+
+	"khiemle.dev/golang-api-template/internal/audit/model"
+	"khiemle.dev/golang-api-template/internal/audit/service"
+)
+
+// Values of ExportAuditLogRequest.Format
+const (
+	AuditLogFormatCSV  = "csv"
+	AuditLogFormatJSON = "json"
+)
+
+// AuditLogFilterRequest selects audit logs. Times are RFC 3339, from is
+// inclusive and to exclusive.
+type AuditLogFilterRequest struct {
+	ActorID        uint      `json:"actor_id" form:"actor_id"`
+	LoginSessionID uint      `json:"login_session_id" form:"login_session_id"`
+	Action         string    `json:"action" form:"action" binding:"max=100"`
+	TargetType     string    `json:"target_type" form:"target_type" binding:"max=50"`
+	TargetID       uint      `json:"target_id" form:"target_id"`
+	ClientIP       string    `json:"client_ip" form:"client_ip" binding:"omitempty,ip"`
+	From           time.Time `json:"from" form:"from"`
+	To             time.Time `json:"to" form:"to"`
+}
+
+// ListAuditLogRequest pages through audit logs newest first. Pass the
+// next_before of a page as before to get the following one.
+type ListAuditLogRequest struct {
+	AuditLogFilterRequest
+	Before uint `json:"before" form:"before"`
+	Limit  int  `json:"limit" form:"limit" binding:"omitempty,min=1,max=500"`
+}
+
+// ExportAuditLogRequest exports every audit log matching the filter, as CSV
+// by default or as JSON lines.
+type ExportAuditLogRequest struct {
+	AuditLogFilterRequest
+	Format string `json:"format" form:"format" binding:"omitempty,oneof=csv json"`
+}
+
+// AuditLogResponse is the public representation of model.AuditLog. Before
+// and After hold the fields of the target that changed.
+type AuditLogResponse struct {
+	ID             uint            `json:"id" binding:"required"`
+	EventID        string          `json:"event_id" binding:"required"`
+	Action         string          `json:"action" binding:"required"`
+	ActorID        *uint           `json:"actor_id"`
+	LoginSessionID *uint           `json:"login_session_id"`
+	ClientIP       string          `json:"client_ip" binding:"required"`
+	UserAgent      string          `json:"user_agent" binding:"required"`
+	RequestID      string          `json:"request_id" binding:"required"`
+	TargetType     string          `json:"target_type" binding:"required"`
+	TargetID       *uint           `json:"target_id"`
+	Before         json.RawMessage `json:"before" swaggertype:"object"`
+	After          json.RawMessage `json:"after" swaggertype:"object"`
+	Details        json.RawMessage `json:"details" swaggertype:"object"`
+	OccurredAt     time.Time       `json:"occurred_at" binding:"required"`
+}
+
+func NewAuditLogResponse(auditLog *model.AuditLog) AuditLogResponse {
+	return AuditLogResponse{
+		ID:             auditLog.ID,
+		EventID:        auditLog.EventID,
+		Action:         auditLog.Action,
+		ActorID:        auditLog.ActorID,
+		LoginSessionID: auditLog.LoginSessionID,
+		ClientIP:       auditLog.ClientIP,
+		UserAgent:      auditLog.UserAgent,
+		RequestID:      auditLog.RequestID,
+		TargetType:     auditLog.TargetType,
+		TargetID:       auditLog.TargetID,
+		Before:         rawJSONOrNull(auditLog.Before),
+		After:          rawJSONOrNull(auditLog.After),
+		Details:        rawJSONOrNull(auditLog.Details),
+		OccurredAt:     auditLog.OccurredAt,
+	}
+}
+
+func NewAuditLogResponses(auditLogs []model.AuditLog) []AuditLogResponse {
+	res := make([]AuditLogResponse, 0, len(auditLogs))
+	for i := range auditLogs {
+		res = append(res, NewAuditLogResponse(&auditLogs[i]))
+	}
+	return res
+}
+
+type ListAuditLogResponse struct {
+	Status     int                `json:"status" binding:"required"`
+	Message    string             `json:"message" binding:"required"`
+	AuditLogs  []AuditLogResponse `json:"audit_logs" binding:"required"`
+	NextBefore *uint              `json:"next_before"`
+}
+
+func NewListAuditLogResponse(status int, message string, page *service.AuditLogPage) ListAuditLogResponse {
+	return ListAuditLogResponse{
+		Status:     status,
+		Message:    message,
+		AuditLogs:  NewAuditLogResponses(page.AuditLogs),
+		NextBefore: page.NextBefore,
+	}
+}
+
+// rawJSONOrNull returns data, null when it is empty
+func rawJSONOrNull(data string) json.RawMessage {
+	if data == "" {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(data)
+}
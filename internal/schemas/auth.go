@@ -1,6 +1,11 @@
 package schemas
 
-import "time"
+import (
+	"time"
+
+	_authModel "khiemle.dev/golang-api-template/internal/auth/model"
+	"khiemle.dev/golang-api-template/internal/user/model"
+)
 
 type AuthLoginRequest struct {
 	Username string `json:"username" binding:"required"`
@@ -12,6 +17,17 @@ type AuthLoginUserResponse struct {
 	Username string `json:"username" binding:"required"`
 	Email    string `json:"email" binding:"required"`
 	Name     string `json:"name" binding:"required"`
+	IsAdmin  bool   `json:"is_admin" binding:"required"`
+}
+
+func NewAuthLoginUserResponse(user *model.User) AuthLoginUserResponse {
+	return AuthLoginUserResponse{
+		ID:       user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Name:     user.Name,
+		IsAdmin:  user.IsAdmin,
+	}
 }
 
 type AuthLoginResponse struct {
@@ -49,3 +65,27 @@ type AuthRefreshResponse struct {
 	RefreshToken          string                `json:"refresh_token" binding:"required"`
 	RefreshTokenExpiresIn time.Duration         `json:"refresh_token_expires_in" binding:"required" swaggertype:"primitive,integer"`
 }
+
+// LoginSessionResponse is the public representation of model.LoginSession,
+// the tokens themselves are never exposed.
+type LoginSessionResponse struct {
+	ID                    uint       `json:"id" binding:"required"`
+	UserAgent             string     `json:"user_agent" binding:"required"`
+	ClientIP              string     `json:"client_ip" binding:"required"`
+	AccessTokenExpiresIn  time.Time  `json:"access_token_expires_in" binding:"required"`
+	RefreshTokenExpiresIn time.Time  `json:"refresh_token_expires_in" binding:"required"`
+	LastUsedAt            *time.Time `json:"last_used_at"`
+	CreatedAt             time.Time  `json:"created_at" binding:"required"`
+}
+
+func NewLoginSessionResponse(loginSession *_authModel.LoginSession) LoginSessionResponse {
+	return LoginSessionResponse{
+		ID:                    loginSession.ID,
+		UserAgent:             loginSession.UserAgent,
+		ClientIP:              loginSession.ClientIP,
+		AccessTokenExpiresIn:  loginSession.AccessTokenExpiresIn,
+		RefreshTokenExpiresIn: loginSession.RefreshTokenExpiresIn,
+		LastUsedAt:            loginSession.LastUsedAt,
+		CreatedAt:             loginSession.CreatedAt,
+	}
+}
@@ -0,0 +1,45 @@
+package schemas
+
+import (
+	"time"
+
+	"khiemle.dev/golang-api-template/internal/todo/model"
+)
+
+type CreateChecklistItemRequest struct {
+	Text string `json:"text" binding:"required,max=500"`
+}
+
+type UpdateChecklistItemRequest struct {
+	Text string `json:"text" binding:"omitempty,max=500"`
+	Done *bool  `json:"done"`
+}
+
+// ChecklistItemResponse is the public representation of model.ChecklistItem.
+type ChecklistItemResponse struct {
+	ID        uint      `json:"id" binding:"required"`
+	Text      string    `json:"text" binding:"required"`
+	Done      bool      `json:"done" binding:"required"`
+	Position  int       `json:"position" binding:"required"`
+	CreatedAt time.Time `json:"created_at" binding:"required"`
+	UpdatedAt time.Time `json:"updated_at" binding:"required"`
+}
+
+func NewChecklistItemResponse(item *model.ChecklistItem) ChecklistItemResponse {
+	return ChecklistItemResponse{
+		ID:        item.ID,
+		Text:      item.Text,
+		Done:      item.Done,
+		Position:  item.Position,
+		CreatedAt: item.CreatedAt,
+		UpdatedAt: item.UpdatedAt,
+	}
+}
+
+func NewChecklistItemResponses(items []model.ChecklistItem) []ChecklistItemResponse {
+	res := make([]ChecklistItemResponse, 0, len(items))
+	for i := range items {
+		res = append(res, NewChecklistItemResponse(&items[i]))
+	}
+	return res
+}
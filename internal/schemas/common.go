@@ -1,7 +1,22 @@
 package schemas
 
+import "khiemle.dev/golang-api-template/pkg/apperror"
+
 type APIResponse struct {
 	Status  int    `json:"status" binding:"required"`
 	Message string `json:"message" binding:"required"`
 	Data    any    `json:"data"`
 }
+
+// ProblemDetails is an RFC 7807 error response, sent with the
+// application/problem+json content type.
+type ProblemDetails struct {
+	Type      string                `json:"type" binding:"required"`
+	Title     string                `json:"title" binding:"required"`
+	Status    int                   `json:"status" binding:"required"`
+	Detail    string                `json:"detail,omitempty"`
+	Instance  string                `json:"instance,omitempty"`
+	Code      string                `json:"code" binding:"required"`
+	RequestID string                `json:"request_id,omitempty"`
+	Errors    []apperror.FieldError `json:"errors,omitempty"`
+}
@@ -0,0 +1,50 @@
+package schemas
+
+import (
+	"time"
+
+	"khiemle.dev/golang-api-template/internal/todo/model"
+)
+
+type CreateLabelRequest struct {
+	Name  string `json:"name" binding:"required,max=100"`
+	Color string `json:"color" binding:"omitempty,hexcolor,len=7"`
+}
+
+type UpdateLabelRequest struct {
+	Name  string `json:"name" binding:"omitempty,max=100"`
+	Color string `json:"color" binding:"omitempty,hexcolor,len=7"`
+}
+
+// LabelResponse is the public representation of model.Label.
+type LabelResponse struct {
+	ID        uint      `json:"id" binding:"required"`
+	Name      string    `json:"name" binding:"required"`
+	Color     string    `json:"color" binding:"required"`
+	CreatedAt time.Time `json:"created_at" binding:"required"`
+	UpdatedAt time.Time `json:"updated_at" binding:"required"`
+}
+
+func NewLabelResponse(label *model.Label) LabelResponse {
+	return LabelResponse{
+		ID:        label.ID,
+		Name:      label.Name,
+		Color:     label.Color,
+		CreatedAt: label.CreatedAt,
+		UpdatedAt: label.UpdatedAt,
+	}
+}
+
+func NewLabelResponses(labels []model.Label) []LabelResponse {
+	res := make([]LabelResponse, 0, len(labels))
+	for i := range labels {
+		res = append(res, NewLabelResponse(&labels[i]))
+	}
+	return res
+}
+
+type ListLabelResponse struct {
+	Status  int             `json:"status" binding:"required"`
+	Message string          `json:"message" binding:"required"`
+	Labels  []LabelResponse `json:"labels" binding:"required"`
+}
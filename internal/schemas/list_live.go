@@ -0,0 +1,135 @@
+package schemas
+
+import (
+	"encoding/json"
+
+	"khiemle.dev/golang-api-template/internal/todo/service"
+	_userModel "khiemle.dev/golang-api-template/internal/user/model"
+)
+
+// Types of the messages sent to clients of the list socket. Changes of todos
+// use the todo event types, e.g. todo.updated.
+const (
+	ListSocketSnapshot = "snapshot"
+	ListSocketPresence = "presence"
+	ListSocketResult   = "result"
+	ListSocketError    = "error"
+)
+
+// Types of the mutations clients send over the list socket
+const (
+	ListSocketCreateTodo = "todo.create"
+	ListSocketUpdateTodo = "todo.update"
+	ListSocketDeleteTodo = "todo.delete"
+	ListSocketMoveTodo   = "todo.move"
+	ListSocketSetParent  = "todo.set_parent"
+)
+
+// ListSocketRequest is a mutation sent over the list socket. Data holds the
+// request of the matching REST endpoint, plus the id of the todo when it
+// changes one. The reply carries the same request_id.
+type ListSocketRequest struct {
+	Type      string          `json:"type" binding:"required,oneof=todo.create todo.update todo.delete todo.move todo.set_parent"`
+	RequestID string          `json:"request_id" binding:"max=64"`
+	Data      json.RawMessage `json:"data" swaggertype:"object"`
+}
+
+// ListSocketTodoRequest names the todo a mutation applies to.
+type ListSocketTodoRequest struct {
+	ID int `json:"id" binding:"required,min=1"`
+}
+
+type ListSocketUpdateTodoRequest struct {
+	ListSocketTodoRequest
+	UpdateTodoRequest
+}
+
+type ListSocketMoveTodoRequest struct {
+	ListSocketTodoRequest
+	MoveTodoRequest
+}
+
+type ListSocketSetParentRequest struct {
+	ListSocketTodoRequest
+	SetParentRequest
+}
+
+// ListSnapshotMessage is sent first, with the todos of the list.
+type ListSnapshotMessage struct {
+	Type  string           `json:"type" binding:"required"`
+	List  TodoListResponse `json:"list" binding:"required"`
+	Todos []TodoResponse   `json:"todos" binding:"required"`
+}
+
+func NewListSnapshotMessage(session *service.ListSession) ListSnapshotMessage {
+	return ListSnapshotMessage{
+		Type:  ListSocketSnapshot,
+		List:  NewTodoListResponse(session.List),
+		Todos: NewTodoResponses(session.Todos),
+	}
+}
+
+// ListViewerResponse is a user viewing a list.
+type ListViewerResponse struct {
+	UserID   uint   `json:"user_id" binding:"required"`
+	Username string `json:"username" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+}
+
+// ListPresenceMessage lists every user viewing the list, sent whenever one
+// joins or leaves.
+type ListPresenceMessage struct {
+	Type    string               `json:"type" binding:"required"`
+	Viewers []ListViewerResponse `json:"viewers" binding:"required"`
+}
+
+func NewListPresenceMessage(users []_userModel.User) ListPresenceMessage {
+	viewers := make([]ListViewerResponse, 0, len(users))
+	for _, user := range users {
+		viewers = append(viewers, ListViewerResponse{
+			UserID:   user.ID,
+			Username: user.Username,
+			Name:     user.Name,
+		})
+	}
+	return ListPresenceMessage{
+		Type:    ListSocketPresence,
+		Viewers: viewers,
+	}
+}
+
+// ListChangeMessage is a change of a todo of the list, made by any user. Todo
+// is null when the todo was deleted or moved out of the list.
+type ListChangeMessage struct {
+	Type   string        `json:"type" binding:"required"`
+	TodoID uint          `json:"todo_id" binding:"required"`
+	Todo   *TodoResponse `json:"todo"`
+}
+
+func NewListChangeMessage(change *service.ListChange) ListChangeMessage {
+	var todo *TodoResponse
+	if change.Todo != nil {
+		res := NewTodoResponse(change.Todo)
+		todo = &res
+	}
+	return ListChangeMessage{
+		Type:   change.Type,
+		TodoID: change.TodoID,
+		Todo:   todo,
+	}
+}
+
+// ListResultMessage replies to a mutation that succeeded. Todo is null for
+// deletions.
+type ListResultMessage struct {
+	Type      string        `json:"type" binding:"required"`
+	RequestID string        `json:"request_id"`
+	Todo      *TodoResponse `json:"todo"`
+}
+
+// ListErrorMessage replies to a mutation that failed.
+type ListErrorMessage struct {
+	Type      string         `json:"type" binding:"required"`
+	RequestID string         `json:"request_id"`
+	Error     ProblemDetails `json:"error" binding:"required"`
+}
@@ -0,0 +1,92 @@
+package schemas
+
+import (
+	"time"
+
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/internal/todo/service"
+)
+
+// InviteListMemberRequest invites a user, by username or email, to a list.
+type InviteListMemberRequest struct {
+	Invitee string `json:"invitee" binding:"required"`
+	Role    string `json:"role" binding:"required,oneof=viewer editor owner"`
+}
+
+type UpdateListMemberRequest struct {
+	Role string `json:"role" binding:"required,oneof=viewer editor owner"`
+}
+
+// ListInvitationResponse is the public representation of model.ListInvitation.
+type ListInvitationResponse struct {
+	ID          uint       `json:"id" binding:"required"`
+	ListID      uint       `json:"list_id" binding:"required"`
+	InviterID   uint       `json:"inviter_id" binding:"required"`
+	InviteeID   uint       `json:"invitee_id" binding:"required"`
+	Role        string     `json:"role" binding:"required"`
+	Status      string     `json:"status" binding:"required"`
+	RespondedAt *time.Time `json:"responded_at"`
+	CreatedAt   time.Time  `json:"created_at" binding:"required"`
+}
+
+func NewListInvitationResponse(invitation *model.ListInvitation) ListInvitationResponse {
+	return ListInvitationResponse{
+		ID:          invitation.ID,
+		ListID:      invitation.ListID,
+		InviterID:   invitation.InviterID,
+		InviteeID:   invitation.InviteeID,
+		Role:        string(invitation.Role),
+		Status:      invitation.Status,
+		RespondedAt: invitation.RespondedAt,
+		CreatedAt:   invitation.CreatedAt,
+	}
+}
+
+func NewListInvitationResponses(invitations []model.ListInvitation) []ListInvitationResponse {
+	res := make([]ListInvitationResponse, 0, len(invitations))
+	for i := range invitations {
+		res = append(res, NewListInvitationResponse(&invitations[i]))
+	}
+	return res
+}
+
+type ListListInvitationResponse struct {
+	Status      int                      `json:"status" binding:"required"`
+	Message     string                   `json:"message" binding:"required"`
+	Invitations []ListInvitationResponse `json:"invitations" binding:"required"`
+}
+
+// ListMemberResponse is a user with a role on a list.
+type ListMemberResponse struct {
+	UserID   uint      `json:"user_id" binding:"required"`
+	Username string    `json:"username" binding:"required"`
+	Name     string    `json:"name" binding:"required"`
+	Role     string    `json:"role" binding:"required"`
+	Creator  bool      `json:"creator" binding:"required"`
+	JoinedAt time.Time `json:"joined_at" binding:"required"`
+}
+
+func NewListMemberResponse(collaborator *service.ListCollaborator) ListMemberResponse {
+	return ListMemberResponse{
+		UserID:   collaborator.User.ID,
+		Username: collaborator.User.Username,
+		Name:     collaborator.User.Name,
+		Role:     string(collaborator.Role),
+		Creator:  collaborator.Creator,
+		JoinedAt: collaborator.JoinedAt,
+	}
+}
+
+func NewListMemberResponses(collaborators []service.ListCollaborator) []ListMemberResponse {
+	res := make([]ListMemberResponse, 0, len(collaborators))
+	for i := range collaborators {
+		res = append(res, NewListMemberResponse(&collaborators[i]))
+	}
+	return res
+}
+
+type ListListMemberResponse struct {
+	Status  int                  `json:"status" binding:"required"`
+	Message string               `json:"message" binding:"required"`
+	Members []ListMemberResponse `json:"members" binding:"required"`
+}
@@ -0,0 +1,85 @@
+package schemas
+
+import (
+	"encoding/json"
+	"time"
+
+	"khiemle.dev/golang-api-template/internal/notification/model"
+	"khiemle.dev/golang-api-template/internal/notification/service"
+)
+
+// Values of ListNotificationRequest.Status
+const (
+	NotificationStatusAll    = "all"
+	NotificationStatusUnread = "unread"
+	NotificationStatusRead   = "read"
+)
+
+// ListNotificationRequest pages through notifications newest first. Pass the
+// next_before of a page as before to get the following one.
+type ListNotificationRequest struct {
+	Status string `json:"status" form:"status" binding:"omitempty,oneof=all unread read"`
+	Before uint   `json:"before" form:"before"`
+	Limit  int    `json:"limit" form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// NotificationResponse is the public representation of model.Notification.
+type NotificationResponse struct {
+	ID        uint            `json:"id" binding:"required"`
+	Kind      string          `json:"kind" binding:"required"`
+	Title     string          `json:"title" binding:"required"`
+	Body      string          `json:"body" binding:"required"`
+	Data      json.RawMessage `json:"data" swaggertype:"object" binding:"required"`
+	Read      bool            `json:"read" binding:"required"`
+	ReadAt    *time.Time      `json:"read_at"`
+	CreatedAt time.Time       `json:"created_at" binding:"required"`
+}
+
+func NewNotificationResponse(notification *model.Notification) NotificationResponse {
+	data := json.RawMessage(notification.Data)
+	if len(data) == 0 {
+		data = json.RawMessage("{}")
+	}
+	return NotificationResponse{
+		ID:        notification.ID,
+		Kind:      notification.Kind,
+		Title:     notification.Title,
+		Body:      notification.Body,
+		Data:      data,
+		Read:      notification.ReadAt != nil,
+		ReadAt:    notification.ReadAt,
+		CreatedAt: notification.CreatedAt,
+	}
+}
+
+func NewNotificationResponses(notifications []model.Notification) []NotificationResponse {
+	res := make([]NotificationResponse, 0, len(notifications))
+	for i := range notifications {
+		res = append(res, NewNotificationResponse(&notifications[i]))
+	}
+	return res
+}
+
+type ListNotificationResponse struct {
+	Status        int                    `json:"status" binding:"required"`
+	Message       string                 `json:"message" binding:"required"`
+	Notifications []NotificationResponse `json:"notifications" binding:"required"`
+	UnreadCount   int64                  `json:"unread_count" binding:"required"`
+	NextBefore    *uint                  `json:"next_before"`
+}
+
+func NewListNotificationResponse(status int, message string, page *service.NotificationPage) ListNotificationResponse {
+	return ListNotificationResponse{
+		Status:        status,
+		Message:       message,
+		Notifications: NewNotificationResponses(page.Notifications),
+		UnreadCount:   page.UnreadCount,
+		NextBefore:    page.NextBefore,
+	}
+}
+
+type MarkAllNotificationsReadResponse struct {
+	Status  int    `json:"status" binding:"required"`
+	Message string `json:"message" binding:"required"`
+	Updated int64  `json:"updated" binding:"required"`
+}
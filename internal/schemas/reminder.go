@@ -0,0 +1,51 @@
+package schemas
+
+import (
+	"time"
+
+	"khiemle.dev/golang-api-template/internal/todo/model"
+)
+
+// CreateReminderRequest reminds the user minutes_before the todo is due,
+// through channel: in_app (default), email or webhook when enabled.
+type CreateReminderRequest struct {
+	MinutesBefore int    `json:"minutes_before" binding:"min=0,max=40320"`
+	Channel       string `json:"channel" binding:"omitempty,max=20"`
+}
+
+// ReminderResponse is the public representation of model.Reminder.
+type ReminderResponse struct {
+	ID            uint       `json:"id" binding:"required"`
+	TodoID        uint       `json:"todo_id" binding:"required"`
+	MinutesBefore int        `json:"minutes_before" binding:"required"`
+	Channel       string     `json:"channel" binding:"required"`
+	RemindAt      time.Time  `json:"remind_at" binding:"required"`
+	SentAt        *time.Time `json:"sent_at"`
+	CreatedAt     time.Time  `json:"created_at" binding:"required"`
+}
+
+func NewReminderResponse(reminder *model.Reminder) ReminderResponse {
+	return ReminderResponse{
+		ID:            reminder.ID,
+		TodoID:        reminder.TodoID,
+		MinutesBefore: reminder.MinutesBefore,
+		Channel:       reminder.Channel,
+		RemindAt:      reminder.RemindAt,
+		SentAt:        reminder.SentAt,
+		CreatedAt:     reminder.CreatedAt,
+	}
+}
+
+func NewReminderResponses(reminders []model.Reminder) []ReminderResponse {
+	res := make([]ReminderResponse, 0, len(reminders))
+	for i := range reminders {
+		res = append(res, NewReminderResponse(&reminders[i]))
+	}
+	return res
+}
+
+type ListReminderResponse struct {
+	Status    int                `json:"status" binding:"required"`
+	Message   string             `json:"message" binding:"required"`
+	Reminders []ReminderResponse `json:"reminders" binding:"required"`
+}
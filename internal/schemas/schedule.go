@@ -0,0 +1,54 @@
+package schemas
+
+import (
+	"time"
+
+	"khiemle.dev/golang-api-template/pkg/scheduler"
+)
+
+type ScheduleRunResponse struct {
+	ScheduledAt time.Time  `json:"scheduled_at" binding:"required"`
+	Holder      string     `json:"holder" binding:"required"`
+	Status      string     `json:"status" binding:"required"`
+	Error       string     `json:"error,omitempty"`
+	StartedAt   time.Time  `json:"started_at" binding:"required"`
+	FinishedAt  *time.Time `json:"finished_at"`
+}
+
+type ScheduleResponse struct {
+	Name      string               `json:"name" binding:"required"`
+	Spec      string               `json:"spec" binding:"required"`
+	Enabled   bool                 `json:"enabled" binding:"required"`
+	NextRunAt *time.Time           `json:"next_run_at"`
+	LastRun   *ScheduleRunResponse `json:"last_run"`
+}
+
+func NewScheduleResponses(infos []scheduler.Info) []ScheduleResponse {
+	res := make([]ScheduleResponse, 0, len(infos))
+	for _, info := range infos {
+		schedule := ScheduleResponse{
+			Name:      info.Name,
+			Spec:      info.Spec,
+			Enabled:   info.Enabled,
+			NextRunAt: info.NextRunAt,
+		}
+		if run := info.LastRun; run != nil {
+			schedule.LastRun = &ScheduleRunResponse{
+				ScheduledAt: run.ScheduledAt,
+				Holder:      run.Holder,
+				Status:      run.Status,
+				Error:       run.Error,
+				StartedAt:   run.StartedAt,
+				FinishedAt:  run.FinishedAt,
+			}
+		}
+		res = append(res, schedule)
+	}
+	return res
+}
+
+type ListSchedulesResponse struct {
+	Status    int                `json:"status" binding:"required"`
+	Message   string             `json:"message" binding:"required"`
+	Schedules []ScheduleResponse `json:"schedules" binding:"required"`
+}
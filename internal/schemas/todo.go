@@ -1,25 +1,210 @@
 package schemas
 
-import "khiemle.dev/golang-api-template/internal/todo/model"
+import (
+	"encoding/json"
+	"time"
 
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/internal/todo/service"
+)
+
+// Scopes of an update to an occurrence of a recurring todo
+const (
+	ScopeOccurrence = "occurrence"
+	ScopeSeries     = "series"
+)
+
+// CreateTodoRequest creates a todo. Recurrence is an RRULE such as
+// FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR repeating the todo from due_at, in
+// timezone (UTC by default).
 type CreateTodoRequest struct {
-	Name        string `json:"name" binding:"required"`
-	Description string `json:"description" binding:"required"`
+	Name        string     `json:"name" binding:"required"`
+	Description string     `json:"description" binding:"required"`
+	ListID      *int       `json:"list_id" binding:"omitempty,min=1"`
+	ParentID    *int       `json:"parent_id" binding:"omitempty,min=1"`
+	DueAt       *time.Time `json:"due_at"`
+	Recurrence  string     `json:"recurrence" binding:"omitempty,max=500"`
+	Timezone    string     `json:"timezone" binding:"omitempty,max=64"`
+}
+
+// TodoResponse is the public representation of model.Todo.
+type TodoResponse struct {
+	ID          uint            `json:"id" binding:"required"`
+	OwnerID     uint            `json:"owner_id" binding:"required"`
+	Name        string          `json:"name" binding:"required"`
+	Description string          `json:"description" binding:"required"`
+	ListID      *uint           `json:"list_id"`
+	ParentID    *uint           `json:"parent_id"`
+	DueAt       *time.Time      `json:"due_at"`
+	SeriesID    *uint           `json:"series_id"`
+	Done        bool            `json:"done" binding:"required"`
+	CompletedAt *time.Time      `json:"completed_at"`
+	Labels      []LabelResponse `json:"labels" binding:"required"`
+	CreatedAt   time.Time       `json:"created_at" binding:"required"`
+	UpdatedAt   time.Time       `json:"updated_at" binding:"required"`
+}
+
+func NewTodoResponse(todo *model.Todo) TodoResponse {
+	return TodoResponse{
+		ID:          todo.ID,
+		OwnerID:     todo.OwnerID,
+		Name:        todo.Name,
+		Description: todo.Description,
+		ListID:      todo.ListID,
+		ParentID:    todo.ParentID,
+		DueAt:       todo.DueAt,
+		SeriesID:    todo.SeriesID,
+		Done:        todo.Done,
+		CompletedAt: todo.CompletedAt,
+		Labels:      NewLabelResponses(todo.Labels),
+		CreatedAt:   todo.CreatedAt,
+		UpdatedAt:   todo.UpdatedAt,
+	}
+}
+
+func NewTodoResponses(todos []model.Todo) []TodoResponse {
+	res := make([]TodoResponse, 0, len(todos))
+	for i := range todos {
+		res = append(res, NewTodoResponse(&todos[i]))
+	}
+	return res
+}
+
+// DeletedTodoResponse is the data of the events of deleted todos.
+type DeletedTodoResponse struct {
+	ID uint `json:"id" binding:"required"`
+}
+
+// NewTodoEventData returns the data of a todo event as sent to clients: the
+// TodoResponse of the todo, or a DeletedTodoResponse once deleted.
+func NewTodoEventData(event *model.TodoEvent) (any, error) {
+	if event.Type == model.TodoEventDeleted {
+		return DeletedTodoResponse{ID: event.TodoID}, nil
+	}
+	todo := model.Todo{}
+	if err := json.Unmarshal([]byte(event.Data), &todo); err != nil {
+		return nil, err
+	}
+	return NewTodoResponse(&todo), nil
+}
+
+// TrashedTodoResponse is a todo of the trash, with when it was deleted.
+type TrashedTodoResponse struct {
+	TodoResponse
+	DeletedAt time.Time `json:"deleted_at" binding:"required"`
+}
+
+func NewTrashedTodoResponses(todos []model.Todo) []TrashedTodoResponse {
+	res := make([]TrashedTodoResponse, 0, len(todos))
+	for i := range todos {
+		res = append(res, TrashedTodoResponse{
+			TodoResponse: NewTodoResponse(&todos[i]),
+			DeletedAt:    todos[i].DeletedAt.Time,
+		})
+	}
+	return res
+}
+
+type ListTrashResponse struct {
+	Status  int                   `json:"status" binding:"required"`
+	Message string                `json:"message" binding:"required"`
+	Todos   []TrashedTodoResponse `json:"todos" binding:"required"`
+}
+
+// ListTodoRequest filters todos by list, state and label name. Match is "all"
+// (default) to keep todos having every tag, or "any" to keep those having at
+// least one.
+type ListTodoRequest struct {
+	ListID *int     `json:"list_id" form:"list_id" binding:"omitempty,min=1"`
+	Done   *bool    `json:"done" form:"done"`
+	Tags   []string `json:"tag" form:"tag"`
+	Match  string   `json:"match" form:"match" binding:"omitempty,oneof=all any"`
 }
 
 type ListTodoResponse struct {
-	Status  int          `json:"status" binding:"required"`
-	Message string       `json:"message" binding:"required"`
-	Todos   []model.Todo `json:"todos" binding:"required"`
+	Status  int            `json:"status" binding:"required"`
+	Message string         `json:"message" binding:"required"`
+	Todos   []TodoResponse `json:"todos" binding:"required"`
 }
 
 type GetTodoByIdResponse struct {
-	Status  int        `json:"status" binding:"required"`
-	Message string     `json:"message" binding:"required"`
-	Todo    model.Todo `json:"todo" binding:"required"`
+	Status  int                 `json:"status" binding:"required"`
+	Message string              `json:"message" binding:"required"`
+	Todo    TodoDetailsResponse `json:"todo" binding:"required"`
+}
+
+// TodoProgressResponse counts done subtasks, at every level, and done
+// checklist items of a todo.
+type TodoProgressResponse struct {
+	SubtasksDone   int `json:"subtasks_done" binding:"required"`
+	SubtasksTotal  int `json:"subtasks_total" binding:"required"`
+	ChecklistDone  int `json:"checklist_done" binding:"required"`
+	ChecklistTotal int `json:"checklist_total" binding:"required"`
+}
+
+// RecurrenceResponse describes how a recurring todo repeats. NextDueAt is
+// null once the rule has no more occurrences.
+type RecurrenceResponse struct {
+	RRule     string     `json:"rrule" binding:"required"`
+	Timezone  string     `json:"timezone" binding:"required"`
+	NextDueAt *time.Time `json:"next_due_at"`
+}
+
+// TodoDetailsResponse is a todo with its direct subtasks, checklist and
+// progress. Recurrence is null for todos that do not repeat.
+type TodoDetailsResponse struct {
+	TodoResponse
+	Subtasks   []TodoResponse          `json:"subtasks" binding:"required"`
+	Checklist  []ChecklistItemResponse `json:"checklist" binding:"required"`
+	Progress   TodoProgressResponse    `json:"progress" binding:"required"`
+	Recurrence *RecurrenceResponse     `json:"recurrence"`
+}
+
+func NewTodoDetailsResponse(details *service.TodoDetails) TodoDetailsResponse {
+	var recurrence *RecurrenceResponse
+	if details.Series != nil && details.Series.RRule != "" {
+		recurrence = &RecurrenceResponse{
+			RRule:     details.Series.RRule,
+			Timezone:  details.Series.Timezone,
+			NextDueAt: details.Series.NextDueAt,
+		}
+	}
+
+	return TodoDetailsResponse{
+		TodoResponse: NewTodoResponse(details.Todo),
+		Subtasks:     NewTodoResponses(details.Subtasks),
+		Checklist:    NewChecklistItemResponses(details.Checklist),
+		Progress: TodoProgressResponse{
+			SubtasksDone:   details.Progress.SubtasksDone,
+			SubtasksTotal:  details.Progress.SubtasksTotal,
+			ChecklistDone:  details.Progress.ChecklistDone,
+			ChecklistTotal: details.Progress.ChecklistTotal,
+		},
+		Recurrence: recurrence,
+	}
 }
 
+// UpdateTodoRequest changes a todo. For occurrences of a recurring todo,
+// scope "series" also renames the open occurrences and the ones to come.
+// Recurrence always applies to the whole series, an empty one stops it.
 type UpdateTodoRequest struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Done        *bool      `json:"done"`
+	DueAt       *time.Time `json:"due_at"`
+	Recurrence  *string    `json:"recurrence" binding:"omitempty,max=500"`
+	Timezone    string     `json:"timezone" binding:"omitempty,max=64"`
+	Scope       string     `json:"scope" binding:"omitempty,oneof=occurrence series"`
+}
+
+// MoveTodoRequest moves a todo to another list, or out of its list when
+// list_id is null.
+type MoveTodoRequest struct {
+	ListID *int `json:"list_id" binding:"omitempty,min=1"`
+}
+
+// SetParentRequest moves a todo under another todo, or to the top level when
+// parent_id is null.
+type SetParentRequest struct {
+	ParentID *int `json:"parent_id" binding:"omitempty,min=1"`
 }
@@ -0,0 +1,65 @@
+package schemas
+
+import (
+	"time"
+
+	"khiemle.dev/golang-api-template/internal/todo/model"
+)
+
+type CreateTodoListRequest struct {
+	Name        string `json:"name" binding:"required,max=255"`
+	Description string `json:"description"`
+}
+
+type UpdateTodoListRequest struct {
+	Name        string `json:"name" binding:"omitempty,max=255"`
+	Description string `json:"description"`
+}
+
+// ListTodoListRequest excludes archived lists unless Archived is set.
+type ListTodoListRequest struct {
+	Archived bool `json:"archived" form:"archived"`
+}
+
+// TodoListResponse is the public representation of model.TodoList.
+type TodoListResponse struct {
+	ID          uint       `json:"id" binding:"required"`
+	Name        string     `json:"name" binding:"required"`
+	Description string     `json:"description" binding:"required"`
+	Role        string     `json:"role" binding:"required"`
+	Archived    bool       `json:"archived" binding:"required"`
+	ArchivedAt  *time.Time `json:"archived_at"`
+	OpenCount   int64      `json:"open_count" binding:"required"`
+	DoneCount   int64      `json:"done_count" binding:"required"`
+	CreatedAt   time.Time  `json:"created_at" binding:"required"`
+	UpdatedAt   time.Time  `json:"updated_at" binding:"required"`
+}
+
+func NewTodoListResponse(list *model.TodoList) TodoListResponse {
+	return TodoListResponse{
+		ID:          list.ID,
+		Name:        list.Name,
+		Description: list.Description,
+		Role:        string(list.Role),
+		Archived:    list.Archived(),
+		ArchivedAt:  list.ArchivedAt,
+		OpenCount:   list.OpenCount,
+		DoneCount:   list.DoneCount,
+		CreatedAt:   list.CreatedAt,
+		UpdatedAt:   list.UpdatedAt,
+	}
+}
+
+func NewTodoListResponses(lists []model.TodoList) []TodoListResponse {
+	res := make([]TodoListResponse, 0, len(lists))
+	for i := range lists {
+		res = append(res, NewTodoListResponse(&lists[i]))
+	}
+	return res
+}
+
+type ListTodoListResponse struct {
+	Status  int                `json:"status" binding:"required"`
+	Message string             `json:"message" binding:"required"`
+	Lists   []TodoListResponse `json:"lists" binding:"required"`
+}
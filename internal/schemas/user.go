@@ -0,0 +1,30 @@
+package schemas
+
+import (
+	"time"
+
+	"khiemle.dev/golang-api-template/internal/user/model"
+)
+
+// UserResponse is the public representation of model.User.
+type UserResponse struct {
+	ID        uint      `json:"id" binding:"required"`
+	Username  string    `json:"username" binding:"required"`
+	Email     string    `json:"email" binding:"required"`
+	Name      string    `json:"name" binding:"required"`
+	IsAdmin   bool      `json:"is_admin" binding:"required"`
+	CreatedAt time.Time `json:"created_at" binding:"required"`
+	UpdatedAt time.Time `json:"updated_at" binding:"required"`
+}
+
+func NewUserResponse(user *model.User) UserResponse {
+	return UserResponse{
+		ID:        user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		Name:      user.Name,
+		IsAdmin:   user.IsAdmin,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+}
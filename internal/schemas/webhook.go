@@ -0,0 +1,161 @@
+package schemas
+
+import (
+	"encoding/json"
+	"time"
+
+	"khiemle.dev/golang-api-template/internal/webhook/model"
+	"khiemle.dev/golang-api-template/internal/webhook/service"
+)
+
+// CreateWebhookRequest registers an endpoint receiving the listed events,
+// "todo.*" for every todo event and "*" for every event. Only administrators
+// may set all_users to receive the events of every user.
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" binding:"required,http_url,max=2048"`
+	Description string   `json:"description" binding:"max=255"`
+	Events      []string `json:"events" binding:"required,min=1,max=20,dive,oneof=* todo.* user.* todo.created todo.updated todo.deleted user.created user.login"`
+	AllUsers    bool     `json:"all_users"`
+}
+
+// UpdateWebhookRequest changes the fields that are set. Enabling an endpoint
+// forgets its failed attempts.
+type UpdateWebhookRequest struct {
+	URL         *string  `json:"url" binding:"omitempty,http_url,max=2048"`
+	Description *string  `json:"description" binding:"omitempty,max=255"`
+	Events      []string `json:"events" binding:"omitempty,min=1,max=20,dive,oneof=* todo.* user.* todo.created todo.updated todo.deleted user.created user.login"`
+	AllUsers    *bool    `json:"all_users"`
+	Enabled     *bool    `json:"enabled"`
+}
+
+// WebhookResponse is the public representation of model.Endpoint, without
+// its secret.
+type WebhookResponse struct {
+	ID             uint       `json:"id" binding:"required"`
+	URL            string     `json:"url" binding:"required"`
+	Description    string     `json:"description" binding:"required"`
+	Events         []string   `json:"events" binding:"required"`
+	AllUsers       bool       `json:"all_users" binding:"required"`
+	Enabled        bool       `json:"enabled" binding:"required"`
+	FailureCount   int        `json:"failure_count" binding:"required"`
+	DisabledAt     *time.Time `json:"disabled_at"`
+	DisabledReason string     `json:"disabled_reason" binding:"required"`
+	CreatedAt      time.Time  `json:"created_at" binding:"required"`
+	UpdatedAt      time.Time  `json:"updated_at" binding:"required"`
+}
+
+func NewWebhookResponse(endpoint *model.Endpoint) WebhookResponse {
+	return WebhookResponse{
+		ID:             endpoint.ID,
+		URL:            endpoint.URL,
+		Description:    endpoint.Description,
+		Events:         endpoint.EventTypes(),
+		AllUsers:       endpoint.AllUsers,
+		Enabled:        endpoint.DisabledAt == nil,
+		FailureCount:   endpoint.FailureCount,
+		DisabledAt:     endpoint.DisabledAt,
+		DisabledReason: endpoint.DisabledReason,
+		CreatedAt:      endpoint.CreatedAt,
+		UpdatedAt:      endpoint.UpdatedAt,
+	}
+}
+
+func NewWebhookResponses(endpoints []model.Endpoint) []WebhookResponse {
+	res := make([]WebhookResponse, 0, len(endpoints))
+	for i := range endpoints {
+		res = append(res, NewWebhookResponse(&endpoints[i]))
+	}
+	return res
+}
+
+// WebhookSecretResponse is returned when the secret of an endpoint is
+// generated, the only time it is shown.
+type WebhookSecretResponse struct {
+	WebhookResponse
+	Secret string `json:"secret" binding:"required"`
+}
+
+func NewWebhookSecretResponse(endpoint *model.Endpoint) WebhookSecretResponse {
+	return WebhookSecretResponse{
+		WebhookResponse: NewWebhookResponse(endpoint),
+		Secret:          endpoint.Secret,
+	}
+}
+
+type ListWebhookResponse struct {
+	Status   int               `json:"status" binding:"required"`
+	Message  string            `json:"message" binding:"required"`
+	Webhooks []WebhookResponse `json:"webhooks" binding:"required"`
+}
+
+// ListDeliveryRequest pages through the deliveries of an endpoint newest
+// first. Pass the next_before of a page as before to get the following one.
+type ListDeliveryRequest struct {
+	Status string `json:"status" form:"status" binding:"omitempty,oneof=pending succeeded failed"`
+	Before uint   `json:"before" form:"before"`
+	Limit  int    `json:"limit" form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// DeliveryResponse is the public representation of model.Delivery. Payload
+// is the body posted to the endpoint.
+type DeliveryResponse struct {
+	ID             uint            `json:"id" binding:"required"`
+	WebhookID      uint            `json:"webhook_id" binding:"required"`
+	EventID        string          `json:"event_id" binding:"required"`
+	EventType      string          `json:"event_type" binding:"required"`
+	Payload        json.RawMessage `json:"payload" swaggertype:"object" binding:"required"`
+	Status         string          `json:"status" binding:"required"`
+	Attempts       int             `json:"attempts" binding:"required"`
+	MaxAttempts    int             `json:"max_attempts" binding:"required"`
+	ResponseStatus int             `json:"response_status" binding:"required"`
+	ResponseBody   string          `json:"response_body" binding:"required"`
+	Error          string          `json:"error" binding:"required"`
+	DurationMs     int64           `json:"duration_ms" binding:"required"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+	CreatedAt      time.Time       `json:"created_at" binding:"required"`
+}
+
+func NewDeliveryResponse(delivery *model.Delivery) DeliveryResponse {
+	return DeliveryResponse{
+		ID:             delivery.ID,
+		WebhookID:      delivery.EndpointID,
+		EventID:        delivery.EventID,
+		EventType:      delivery.EventType,
+		Payload:        json.RawMessage(delivery.Payload),
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		MaxAttempts:    delivery.MaxAttempts,
+		ResponseStatus: delivery.ResponseStatus,
+		ResponseBody:   delivery.ResponseBody,
+		Error:          delivery.Error,
+		DurationMs:     delivery.DurationMs,
+		LastAttemptAt:  delivery.LastAttemptAt,
+		DeliveredAt:    delivery.DeliveredAt,
+		CreatedAt:      delivery.CreatedAt,
+	}
+}
+
+func NewDeliveryResponses(deliveries []model.Delivery) []DeliveryResponse {
+	res := make([]DeliveryResponse, 0, len(deliveries))
+	for i := range deliveries {
+		res = append(res, NewDeliveryResponse(&deliveries[i]))
+	}
+	return res
+}
+
+type ListDeliveryResponse struct {
+	Status     int                `json:"status" binding:"required"`
+	Message    string             `json:"message" binding:"required"`
+	Deliveries []DeliveryResponse `json:"deliveries" binding:"required"`
+	NextBefore *uint              `json:"next_before"`
+}
+
+func NewListDeliveryResponse(status int, message string, page *service.DeliveryPage) ListDeliveryResponse {
+	return ListDeliveryResponse{
+		Status:     status,
+		Message:    message,
+		Deliveries: NewDeliveryResponses(page.Deliveries),
+		NextBefore: page.NextBefore,
+	}
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"khiemle.dev/golang-api-template/internal/schemas"
+	"khiemle.dev/golang-api-template/internal/todo/service"
+	"khiemle.dev/golang-api-template/pkg/middleware"
+)
+
+type ChecklistHandler interface {
+	AddItemHandler(c *gin.Context)
+	UpdateItemHandler(c *gin.Context)
+	DeleteItemHandler(c *gin.Context)
+}
+
+type checklistHandler struct {
+	checklistService service.ChecklistService
+}
+
+func NewChecklistHandler(checklistService service.ChecklistService) ChecklistHandler {
+	return &checklistHandler{
+		checklistService: checklistService,
+	}
+}
+
+// Handler for add checklist item to todo
+func (h *checklistHandler) AddItemHandler(c *gin.Context) {
+	req := schemas.CreateChecklistItemRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	todoID, err := parseID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	item, err := h.checklistService.AddItem(c.Request.Context(), middleware.CurrentUserID(c), todoID, req.Text)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    schemas.NewChecklistItemResponse(item),
+	})
+}
+
+// Handler for update checklist item
+func (h *checklistHandler) UpdateItemHandler(c *gin.Context) {
+	req := schemas.UpdateChecklistItemRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	todoID, itemID, err := parseChecklistItemIDs(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	item, err := h.checklistService.UpdateItem(c.Request.Context(), middleware.CurrentUserID(c), todoID, itemID, req.Text, req.Done)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    schemas.NewChecklistItemResponse(item),
+	})
+}
+
+// Handler for delete checklist item
+func (h *checklistHandler) DeleteItemHandler(c *gin.Context) {
+	todoID, itemID, err := parseChecklistItemIDs(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	err = h.checklistService.DeleteItem(c.Request.Context(), middleware.CurrentUserID(c), todoID, itemID)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    nil,
+	})
+}
+
+// parseChecklistItemIDs reads the id and itemId path parameters
+func parseChecklistItemIDs(c *gin.Context) (int, int, error) {
+	todoID, err := parseID(c)
+	if err != nil {
+		return 0, 0, err
+	}
+	itemID, err := parseIntParam(c, "itemId")
+	if err != nil {
+		return 0, 0, err
+	}
+	return todoID, itemID, nil
+}
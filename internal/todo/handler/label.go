@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"khiemle.dev/golang-api-template/internal/schemas"
+	"khiemle.dev/golang-api-template/internal/todo/service"
+	"khiemle.dev/golang-api-template/pkg/middleware"
+)
+
+type LabelHandler interface {
+	CreateLabelHandler(c *gin.Context)
+	GetLabelHandler(c *gin.Context)
+	UpdateLabelHandler(c *gin.Context)
+	ListLabelHandler(c *gin.Context)
+	DeleteLabelHandler(c *gin.Context)
+}
+
+type labelHandler struct {
+	labelService service.LabelService
+}
+
+func NewLabelHandler(labelService service.LabelService) LabelHandler {
+	return &labelHandler{
+		labelService: labelService,
+	}
+}
+
+// Handler for create label
+func (h *labelHandler) CreateLabelHandler(c *gin.Context) {
+	req := schemas.CreateLabelRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	label, err := h.labelService.CreateLabel(c.Request.Context(), middleware.CurrentUserID(c), req.Name, req.Color)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    schemas.NewLabelResponse(label),
+	})
+}
+
+// Handler for get label
+func (h *labelHandler) GetLabelHandler(c *gin.Context) {
+	id, err := parseID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	label, err := h.labelService.GetLabel(c.Request.Context(), middleware.CurrentUserID(c), id)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    schemas.NewLabelResponse(label),
+	})
+}
+
+// Handler for update label
+func (h *labelHandler) UpdateLabelHandler(c *gin.Context) {
+	req := schemas.UpdateLabelRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	id, err := parseID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	label, err := h.labelService.UpdateLabel(c.Request.Context(), middleware.CurrentUserID(c), id, req.Name, req.Color)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    schemas.NewLabelResponse(label),
+	})
+}
+
+// Handler for list label
+func (h *labelHandler) ListLabelHandler(c *gin.Context) {
+	labels, err := h.labelService.ListLabels(c.Request.Context(), middleware.CurrentUserID(c))
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.ListLabelResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Labels:  schemas.NewLabelResponses(labels),
+	})
+}
+
+// Handler for delete label, the label is removed from its todos
+func (h *labelHandler) DeleteLabelHandler(c *gin.Context) {
+	id, err := parseID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	err = h.labelService.DeleteLabel(c.Request.Context(), middleware.CurrentUserID(c), id)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    nil,
+	})
+}
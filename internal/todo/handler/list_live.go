@@ -0,0 +1,427 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog"
+	"golang.org/x/time/rate"
+	"khiemle.dev/golang-api-template/internal/schemas"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/internal/todo/service"
+	"khiemle.dev/golang-api-template/pkg/apperror"
+	"khiemle.dev/golang-api-template/pkg/middleware"
+)
+
+const (
+	// socketWriteWait bounds how long writing a message may take. Clients
+	// that stop reading for that long are disconnected.
+	socketWriteWait = 10 * time.Second
+	// maxSocketMessageSize bounds the messages clients send
+	maxSocketMessageSize = 64 << 10
+	// socketSendBuffer is how many replies may wait to be written before
+	// messages of the client are no longer read
+	socketSendBuffer = 16
+	// socketRequestTimeout bounds how long a mutation may take
+	socketRequestTimeout = 30 * time.Second
+)
+
+var errRateLimited = apperror.New(apperror.CodeRateLimited, http.StatusTooManyRequests, "too many messages, slow down")
+
+type ListLiveHandler interface {
+	ConnectHandler(c *gin.Context)
+}
+
+type listLiveHandler struct {
+	listLiveService service.ListLiveService
+	todoService     service.TodoService
+	upgrader        websocket.Upgrader
+	heartbeat       time.Duration
+	maxDuration     time.Duration
+	rateLimit       rate.Limit
+	rateBurst       int
+}
+
+// NewListLiveHandler creates the handler of list sockets. Sockets are pinged
+// every heartbeat and closed after maxDuration, so clients reconnect with a
+// fresh access token. Each socket may send rateLimit messages per second,
+// with bursts of rateBurst. Browsers may only open sockets from pages of the
+// API's origin or of allowedOrigins.
+func NewListLiveHandler(
+	listLiveService service.ListLiveService,
+	todoService service.TodoService,
+	heartbeat time.Duration,
+	maxDuration time.Duration,
+	rateLimit float64,
+	rateBurst int,
+	allowedOrigins []string,
+) ListLiveHandler {
+	return &listLiveHandler{
+		listLiveService: listLiveService,
+		todoService:     todoService,
+		upgrader:        websocket.Upgrader{CheckOrigin: checkOrigin(allowedOrigins)},
+		heartbeat:       heartbeat,
+		maxDuration:     maxDuration,
+		rateLimit:       rate.Limit(rateLimit),
+		rateBurst:       rateBurst,
+	}
+}
+
+// Handler for follow and edit a todo list over a WebSocket
+func (h *listLiveHandler) ConnectHandler(c *gin.Context) {
+	id, err := parseID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+	if !websocket.IsWebSocketUpgrade(c.Request) {
+		middleware.AbortWithError(c, apperror.BadRequest("expected a WebSocket upgrade request"))
+		return
+	}
+	if !h.upgrader.CheckOrigin(c.Request) {
+		middleware.AbortWithError(c, apperror.Forbidden("origin not allowed"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	userID := middleware.CurrentUserID(c)
+	session, err := h.listLiveService.Join(ctx, userID, id)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+	defer session.Close(context.WithoutCancel(ctx))
+
+	// The upgrader replies to failed handshakes itself
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	socket := &listSocket{
+		handler: h,
+		c:       c,
+		conn:    conn,
+		session: session,
+		userID:  userID,
+		replies: make(chan any, socketSendBuffer),
+	}
+	socket.run(ctx)
+}
+
+// listSocket is a client following a list. Only the goroutine running write
+// writes to the connection, read handles the messages of the client one at
+// a time.
+type listSocket struct {
+	handler *listLiveHandler
+	c       *gin.Context
+	conn    *websocket.Conn
+	session *service.ListSession
+	userID  uint
+	replies chan any
+}
+
+func (s *listSocket) run(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer cancel()
+		s.read(ctx)
+	}()
+
+	s.write(ctx)
+	cancel()
+	// Unblock the reader
+	s.conn.Close()
+	<-done
+}
+
+// write sends the snapshot of the list, then its changes, its viewers and
+// the replies to the client until the socket closes. Changes are read from
+// the database only once the previous ones were written, so slow clients hold
+// back their own changes instead of buffering them.
+func (s *listSocket) write(ctx context.Context) {
+	if err := s.send(schemas.NewListSnapshotMessage(s.session)); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(s.handler.heartbeat)
+	defer heartbeat.Stop()
+	deadline := time.NewTimer(s.handler.maxDuration)
+	defer deadline.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline.C:
+			s.close(websocket.CloseNormalClosure, "session expired, reconnect")
+			return
+		case reply := <-s.replies:
+			if err := s.send(reply); err != nil {
+				return
+			}
+		case <-s.session.PresenceChanged():
+			viewers, err := s.session.Viewers(ctx)
+			if err != nil {
+				s.fail(ctx, err)
+				return
+			}
+			if err := s.send(schemas.NewListPresenceMessage(viewers)); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteWait))
+			if err != nil {
+				return
+			}
+			err = s.session.Refresh(ctx)
+			if errors.Is(err, service.ErrTodoListNotFound) {
+				s.close(websocket.ClosePolicyViolation, "todo list is no longer accessible")
+				return
+			}
+			if err != nil {
+				s.fail(ctx, err)
+			}
+			// Also catch up on wake-ups lost while pubsub reconnected
+			if err := s.sendChanges(ctx); err != nil {
+				return
+			}
+		case <-s.session.Wake():
+			if err := s.sendChanges(ctx); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *listSocket) sendChanges(ctx context.Context) error {
+	changes, err := s.session.Next(ctx)
+	if err != nil {
+		s.fail(ctx, err)
+		return err
+	}
+	for i := range changes {
+		if err := s.send(schemas.NewListChangeMessage(&changes[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *listSocket) send(message any) error {
+	if err := s.conn.SetWriteDeadline(time.Now().Add(socketWriteWait)); err != nil {
+		return err
+	}
+	return s.conn.WriteJSON(message)
+}
+
+func (s *listSocket) close(code int, reason string) {
+	message := websocket.FormatCloseMessage(code, reason)
+	_ = s.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(socketWriteWait))
+}
+
+func (s *listSocket) fail(ctx context.Context, err error) {
+	if ctx.Err() == nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("List socket failed")
+	}
+}
+
+// read handles the mutations sent by the client until the socket closes.
+// Clients that stop answering pings for two heartbeats are disconnected.
+func (s *listSocket) read(ctx context.Context) {
+	pongWait := 2 * s.handler.heartbeat
+	s.conn.SetReadLimit(maxSocketMessageSize)
+	_ = s.conn.SetReadDeadline(time.Now().Add(pongWait))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	limiter := rate.NewLimiter(s.handler.rateLimit, s.handler.rateBurst)
+	for {
+		messageType, message, err := s.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				zerolog.Ctx(ctx).Debug().Err(err).Msg("List socket closed")
+			}
+			return
+		}
+
+		var reply any
+		switch {
+		case messageType != websocket.TextMessage:
+			reply = s.errorReply(ctx, "", apperror.BadRequest("messages must be JSON text"))
+		case !limiter.Allow():
+			reply = s.errorReply(ctx, requestID(message), errRateLimited)
+		default:
+			reply = s.handle(ctx, message)
+		}
+
+		// Stop reading while the client does not read the replies
+		select {
+		case s.replies <- reply:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// handle applies a mutation and returns the reply
+func (s *listSocket) handle(ctx context.Context, message []byte) any {
+	req := schemas.ListSocketRequest{}
+	if err := binding.JSON.BindBody(message, &req); err != nil {
+		return s.errorReply(ctx, req.RequestID, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, socketRequestTimeout)
+	defer cancel()
+
+	todo, err := s.mutate(ctx, &req)
+	if err != nil {
+		return s.errorReply(ctx, req.RequestID, err)
+	}
+
+	var res *schemas.TodoResponse
+	if todo != nil {
+		todoRes := schemas.NewTodoResponse(todo)
+		res = &todoRes
+	}
+	return schemas.ListResultMessage{
+		Type:      schemas.ListSocketResult,
+		RequestID: req.RequestID,
+		Todo:      res,
+	}
+}
+
+// mutate applies the mutation through the todo service, with the same checks
+// as the REST endpoints. It only changes todos of the list.
+func (s *listSocket) mutate(ctx context.Context, req *schemas.ListSocketRequest) (*model.Todo, error) {
+	todoService := s.handler.todoService
+
+	switch req.Type {
+	case schemas.ListSocketCreateTodo:
+		body := schemas.CreateTodoRequest{}
+		if err := binding.JSON.BindBody(req.Data, &body); err != nil {
+			return nil, err
+		}
+		listID := int(s.session.List.ID)
+		return todoService.CreateTodo(ctx, s.userID, service.CreateTodoArgs{
+			Name:        body.Name,
+			Description: body.Description,
+			ListID:      &listID,
+			ParentID:    body.ParentID,
+			DueAt:       body.DueAt,
+			Recurrence:  body.Recurrence,
+			Timezone:    body.Timezone,
+		})
+
+	case schemas.ListSocketUpdateTodo:
+		body := schemas.ListSocketUpdateTodoRequest{}
+		if err := s.bindTodo(ctx, req, &body, &body.ListSocketTodoRequest); err != nil {
+			return nil, err
+		}
+		return todoService.UpdateTodo(ctx, s.userID, body.ID, service.UpdateTodoArgs{
+			Name:        body.Name,
+			Description: body.Description,
+			Done:        body.Done,
+			DueAt:       body.DueAt,
+			Recurrence:  body.Recurrence,
+			Timezone:    body.Timezone,
+			Series:      body.Scope == schemas.ScopeSeries,
+		})
+
+	case schemas.ListSocketDeleteTodo:
+		body := schemas.ListSocketTodoRequest{}
+		if err := s.bindTodo(ctx, req, &body, &body); err != nil {
+			return nil, err
+		}
+		return nil, todoService.DeleteTodo(ctx, s.userID, body.ID)
+
+	case schemas.ListSocketMoveTodo:
+		body := schemas.ListSocketMoveTodoRequest{}
+		if err := s.bindTodo(ctx, req, &body, &body.ListSocketTodoRequest); err != nil {
+			return nil, err
+		}
+		return todoService.MoveTodo(ctx, s.userID, body.ID, body.ListID)
+
+	case schemas.ListSocketSetParent:
+		body := schemas.ListSocketSetParentRequest{}
+		if err := s.bindTodo(ctx, req, &body, &body.ListSocketTodoRequest); err != nil {
+			return nil, err
+		}
+		return todoService.SetParent(ctx, s.userID, body.ID, body.ParentID)
+	}
+	return nil, apperror.BadRequest("unknown message type")
+}
+
+// bindTodo binds the data of the mutation to body and checks the todo named
+// by target is in the list. Todos of other lists are reported as not found.
+func (s *listSocket) bindTodo(ctx context.Context, req *schemas.ListSocketRequest, body any, target *schemas.ListSocketTodoRequest) error {
+	if err := binding.JSON.BindBody(req.Data, body); err != nil {
+		return err
+	}
+	todo, err := s.handler.todoService.GetById(ctx, s.userID, target.ID)
+	if err != nil {
+		return err
+	}
+	if todo.ListID == nil || *todo.ListID != s.session.List.ID {
+		return service.ErrTodoNotFound
+	}
+	return nil
+}
+
+// requestID returns the request_id of a message that was not handled, if any
+func requestID(message []byte) string {
+	req := schemas.ListSocketRequest{}
+	_ = json.Unmarshal(message, &req)
+	return req.RequestID
+}
+
+func (s *listSocket) errorReply(ctx context.Context, requestID string, err error) schemas.ListErrorMessage {
+	appErr := apperror.From(err)
+	if appErr.Status >= http.StatusInternalServerError {
+		zerolog.Ctx(ctx).Error().Err(appErr).Msg("List socket request failed")
+	}
+	return schemas.ListErrorMessage{
+		Type:      schemas.ListSocketError,
+		RequestID: requestID,
+		Error:     middleware.NewProblemDetails(s.c, appErr),
+	}
+}
+
+// checkOrigin accepts handshakes without an Origin header, which browsers
+// always send, and the ones from pages of the API's origin or of
+// allowedOrigins. Sockets are authenticated with an access token, the origin
+// also keeps other sites from opening them with a token they got hold of.
+func checkOrigin(allowedOrigins []string) func(r *http.Request) bool {
+	allowed := map[string]bool{}
+	for _, origin := range allowedOrigins {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			allowed[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+		}
+	}
+
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+		return strings.EqualFold(u.Host, r.Host) || allowed[strings.ToLower(origin)]
+	}
+}
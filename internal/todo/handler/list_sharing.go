@@ -0,0 +1,266 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"khiemle.dev/golang-api-template/internal/schemas"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/internal/todo/service"
+	"khiemle.dev/golang-api-template/pkg/middleware"
+)
+
+type ListSharingHandler interface {
+	InviteHandler(c *gin.Context)
+	ListInvitationsHandler(c *gin.Context)
+	RevokeInvitationHandler(c *gin.Context)
+	ListReceivedInvitationsHandler(c *gin.Context)
+	AcceptInvitationHandler(c *gin.Context)
+	DeclineInvitationHandler(c *gin.Context)
+	ListMembersHandler(c *gin.Context)
+	UpdateMemberHandler(c *gin.Context)
+	RemoveMemberHandler(c *gin.Context)
+	LeaveListHandler(c *gin.Context)
+}
+
+type listSharingHandler struct {
+	listSharingService service.ListSharingService
+}
+
+func NewListSharingHandler(listSharingService service.ListSharingService) ListSharingHandler {
+	return &listSharingHandler{
+		listSharingService: listSharingService,
+	}
+}
+
+// Handler for invite user to todo list. The response is the same whether the
+// invitee has an account or not.
+func (h *listSharingHandler) InviteHandler(c *gin.Context) {
+	req := schemas.InviteListMemberRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	id, err := parseID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	err = h.listSharingService.Invite(c.Request.Context(), middleware.CurrentUserID(c), id, req.Invitee, model.ListRole(req.Role))
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    nil,
+	})
+}
+
+// Handler for list pending invitations to todo list
+func (h *listSharingHandler) ListInvitationsHandler(c *gin.Context) {
+	id, err := parseID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	invitations, err := h.listSharingService.ListInvitations(c.Request.Context(), middleware.CurrentUserID(c), id)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.ListListInvitationResponse{
+		Status:      http.StatusOK,
+		Message:     http.StatusText(http.StatusOK),
+		Invitations: schemas.NewListInvitationResponses(invitations),
+	})
+}
+
+// Handler for revoke invitation to todo list
+func (h *listSharingHandler) RevokeInvitationHandler(c *gin.Context) {
+	id, err := parseID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+	invitationID, err := parseIntParam(c, "invitationId")
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	err = h.listSharingService.RevokeInvitation(c.Request.Context(), middleware.CurrentUserID(c), id, invitationID)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    nil,
+	})
+}
+
+// Handler for list pending invitations of current user
+func (h *listSharingHandler) ListReceivedInvitationsHandler(c *gin.Context) {
+	invitations, err := h.listSharingService.ListReceivedInvitations(c.Request.Context(), middleware.CurrentUserID(c))
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.ListListInvitationResponse{
+		Status:      http.StatusOK,
+		Message:     http.StatusText(http.StatusOK),
+		Invitations: schemas.NewListInvitationResponses(invitations),
+	})
+}
+
+// Handler for accept invitation, responds with the joined todo list
+func (h *listSharingHandler) AcceptInvitationHandler(c *gin.Context) {
+	id, err := parseID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	list, err := h.listSharingService.AcceptInvitation(c.Request.Context(), middleware.CurrentUserID(c), id)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    schemas.NewTodoListResponse(list),
+	})
+}
+
+// Handler for decline invitation
+func (h *listSharingHandler) DeclineInvitationHandler(c *gin.Context) {
+	id, err := parseID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	err = h.listSharingService.DeclineInvitation(c.Request.Context(), middleware.CurrentUserID(c), id)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    nil,
+	})
+}
+
+// Handler for list members of todo list
+func (h *listSharingHandler) ListMembersHandler(c *gin.Context) {
+	id, err := parseID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	members, err := h.listSharingService.ListMembers(c.Request.Context(), middleware.CurrentUserID(c), id)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.ListListMemberResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Members: schemas.NewListMemberResponses(members),
+	})
+}
+
+// Handler for change role of todo list member
+func (h *listSharingHandler) UpdateMemberHandler(c *gin.Context) {
+	req := schemas.UpdateListMemberRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	id, err := parseID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+	memberID, err := parseIntParam(c, "userId")
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	member, err := h.listSharingService.UpdateMember(c.Request.Context(), middleware.CurrentUserID(c), id, memberID, model.ListRole(req.Role))
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    schemas.NewListMemberResponse(member),
+	})
+}
+
+// Handler for remove member from todo list
+func (h *listSharingHandler) RemoveMemberHandler(c *gin.Context) {
+	id, err := parseID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+	memberID, err := parseIntParam(c, "userId")
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	err = h.listSharingService.RemoveMember(c.Request.Context(), middleware.CurrentUserID(c), id, memberID)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    nil,
+	})
+}
+
+// Handler for leave todo list
+func (h *listSharingHandler) LeaveListHandler(c *gin.Context) {
+	id, err := parseID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	err = h.listSharingService.LeaveList(c.Request.Context(), middleware.CurrentUserID(c), id)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    nil,
+	})
+}
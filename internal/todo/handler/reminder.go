@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"khiemle.dev/golang-api-template/internal/schemas"
+	"khiemle.dev/golang-api-template/internal/todo/service"
+	"khiemle.dev/golang-api-template/pkg/middleware"
+)
+
+type ReminderHandler interface {
+	AddReminderHandler(c *gin.Context)
+	ListReminderHandler(c *gin.Context)
+	DeleteReminderHandler(c *gin.Context)
+}
+
+type reminderHandler struct {
+	reminderService service.ReminderService
+}
+
+func NewReminderHandler(reminderService service.ReminderService) ReminderHandler {
+	return &reminderHandler{
+		reminderService: reminderService,
+	}
+}
+
+// Handler for add reminder to todo
+func (h *reminderHandler) AddReminderHandler(c *gin.Context) {
+	req := schemas.CreateReminderRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	todoID, err := parseID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	reminder, err := h.reminderService.AddReminder(c.Request.Context(), middleware.CurrentUserID(c), todoID, req.MinutesBefore, req.Channel)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    schemas.NewReminderResponse(reminder),
+	})
+}
+
+// Handler for list reminders of the current user on todo
+func (h *reminderHandler) ListReminderHandler(c *gin.Context) {
+	todoID, err := parseID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	reminders, err := h.reminderService.ListReminders(c.Request.Context(), middleware.CurrentUserID(c), todoID)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.ListReminderResponse{
+		Status:    http.StatusOK,
+		Message:   http.StatusText(http.StatusOK),
+		Reminders: schemas.NewReminderResponses(reminders),
+	})
+}
+
+// Handler for delete reminder
+func (h *reminderHandler) DeleteReminderHandler(c *gin.Context) {
+	todoID, err := parseID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+	id, err := parseIntParam(c, "reminderId")
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	err = h.reminderService.DeleteReminder(c.Request.Context(), middleware.CurrentUserID(c), todoID, id)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    nil,
+	})
+}
@@ -1,15 +1,14 @@
 package handler
 
 import (
-	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"github.com/rs/zerolog/log"
-	"gorm.io/gorm"
 	"khiemle.dev/golang-api-template/internal/schemas"
 	"khiemle.dev/golang-api-template/internal/todo/service"
+	"khiemle.dev/golang-api-template/pkg/apperror"
+	"khiemle.dev/golang-api-template/pkg/middleware"
 )
 
 type TodoHandler interface {
@@ -18,6 +17,13 @@ type TodoHandler interface {
 	UpdateTodoHandler(c *gin.Context)
 	ListTodoHandler(c *gin.Context)
 	DeleteTodoHandler(c *gin.Context)
+	ListTrashHandler(c *gin.Context)
+	RestoreTodoHandler(c *gin.Context)
+	PurgeTodoHandler(c *gin.Context)
+	MoveTodoHandler(c *gin.Context)
+	SetParentHandler(c *gin.Context)
+	AttachLabelHandler(c *gin.Context)
+	DetachLabelHandler(c *gin.Context)
 }
 
 type todoHandler struct {
@@ -32,63 +38,51 @@ func NewTodoHandler(todoService service.TodoService) TodoHandler {
 
 // Handler for create todo
 func (h *todoHandler) CreateTodoHandler(c *gin.Context) {
-	log.Info().Msg("I'm in")
 	req := schemas.CreateTodoRequest{}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		log.Error().Err(err)
-		c.AbortWithStatusJSON(http.StatusBadRequest, schemas.APIResponse{
-			Status:  http.StatusBadRequest,
-			Message: err.Error(),
-			Data:    nil,
-		})
+		middleware.AbortWithError(c, err)
 		return
 	}
 
-	todo, err := h.todoService.CreateTodo(c, req.Name, req.Description)
+	todo, err := h.todoService.CreateTodo(c.Request.Context(), middleware.CurrentUserID(c), service.CreateTodoArgs{
+		Name:        req.Name,
+		Description: req.Description,
+		ListID:      req.ListID,
+		ParentID:    req.ParentID,
+		DueAt:       req.DueAt,
+		Recurrence:  req.Recurrence,
+		Timezone:    req.Timezone,
+	})
 	if err != nil {
-		log.Fatal().Msg("Error creating todo")
-		log.Error().Err(err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, schemas.APIResponse{
-			Status:  http.StatusInternalServerError,
-			Message: err.Error(),
-			Data:    nil,
-		})
+		middleware.AbortWithError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, schemas.APIResponse{
 		Status:  http.StatusOK,
 		Message: http.StatusText(http.StatusOK),
-		Data:    todo,
+		Data:    schemas.NewTodoResponse(todo),
 	})
 }
 
-// Handler for get todo
+// Handler for get todo, with its subtasks, checklist and progress
 func (h *todoHandler) GetByIdHandler(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
+	id, err := parseID(c)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, schemas.APIResponse{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid id",
-			Data:    nil,
-		})
+		middleware.AbortWithError(c, err)
 		return
 	}
 
-	todo, err := h.todoService.GetById(c, id)
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		c.AbortWithStatusJSON(http.StatusNotFound, schemas.APIResponse{
-			Status:  http.StatusNotFound,
-			Message: err.Error(),
-			Data:    nil,
-		})
+	details, err := h.todoService.GetDetails(c.Request.Context(), middleware.CurrentUserID(c), id)
+	if err != nil {
+		middleware.AbortWithError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, schemas.GetTodoByIdResponse{
 		Status:  http.StatusOK,
 		Message: http.StatusText(http.StatusOK),
-		Todo:    *todo,
+		Todo:    schemas.NewTodoDetailsResponse(details),
 	})
 }
 
@@ -96,97 +90,131 @@ func (h *todoHandler) GetByIdHandler(c *gin.Context) {
 func (h *todoHandler) UpdateTodoHandler(c *gin.Context) {
 	req := schemas.UpdateTodoRequest{}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, schemas.APIResponse{
-			Status:  http.StatusBadRequest,
-			Message: err.Error(),
-			Data:    nil,
-		})
+		middleware.AbortWithError(c, err)
 		return
 	}
 
-	id, err := strconv.Atoi(c.Param("id"))
+	id, err := parseID(c)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, schemas.APIResponse{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid id",
-			Data:    nil,
-		})
-		return
-	}
-
-	todo, err := h.todoService.UpdateTodo(c, id, req.Name, req.Description)
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		c.AbortWithStatusJSON(http.StatusNotFound, schemas.APIResponse{
-			Status:  http.StatusNotFound,
-			Message: err.Error(),
-			Data:    nil,
-		})
+		middleware.AbortWithError(c, err)
 		return
 	}
 
+	todo, err := h.todoService.UpdateTodo(c.Request.Context(), middleware.CurrentUserID(c), id, service.UpdateTodoArgs{
+		Name:        req.Name,
+		Description: req.Description,
+		Done:        req.Done,
+		DueAt:       req.DueAt,
+		Recurrence:  req.Recurrence,
+		Timezone:    req.Timezone,
+		Series:      req.Scope == schemas.ScopeSeries,
+	})
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, schemas.APIResponse{
-			Status:  http.StatusInternalServerError,
-			Message: err.Error(),
-			Data:    nil,
-		})
+		middleware.AbortWithError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, schemas.APIResponse{
 		Status:  http.StatusOK,
 		Message: http.StatusText(http.StatusOK),
-		Data:    todo,
+		Data:    schemas.NewTodoResponse(todo),
 	})
 }
 
 // Handler for list todo
 func (h *todoHandler) ListTodoHandler(c *gin.Context) {
-	todos, err := h.todoService.ListTodo(c)
+	req := schemas.ListTodoRequest{}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	todos, err := h.todoService.ListTodo(c.Request.Context(), middleware.CurrentUserID(c), service.ListTodoArgs{
+		ListID:   req.ListID,
+		Done:     req.Done,
+		Labels:   req.Tags,
+		MatchAll: req.Match != "any",
+	})
 	if err != nil {
-		log.Error().Err(err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, schemas.APIResponse{
-			Status:  http.StatusInternalServerError,
-			Message: err.Error(),
-			Data:    nil,
-		})
+		middleware.AbortWithError(c, err)
+		return
 	}
 
 	c.JSON(http.StatusOK, schemas.ListTodoResponse{
 		Status:  http.StatusOK,
 		Message: http.StatusText(http.StatusOK),
-		Todos:   todos,
+		Todos:   schemas.NewTodoResponses(todos),
 	})
 }
 
 // Handler for delete todo
 func (h *todoHandler) DeleteTodoHandler(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
+	id, err := parseID(c)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, schemas.APIResponse{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid id",
-			Data:    nil,
-		})
+		middleware.AbortWithError(c, err)
 		return
 	}
 
-	err = h.todoService.DeleteTodo(c, id)
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		c.AbortWithStatusJSON(http.StatusNotFound, schemas.APIResponse{
-			Status:  http.StatusNotFound,
-			Message: err.Error(),
-			Data:    nil,
-		})
+	err = h.todoService.DeleteTodo(c.Request.Context(), middleware.CurrentUserID(c), id)
+	if err != nil {
+		middleware.AbortWithError(c, err)
 		return
 	}
 
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    nil,
+	})
+}
+
+// Handler for list todos of the trash
+func (h *todoHandler) ListTrashHandler(c *gin.Context) {
+	todos, err := h.todoService.ListTrash(c.Request.Context(), middleware.CurrentUserID(c))
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, schemas.APIResponse{
-			Status:  http.StatusInternalServerError,
-			Message: err.Error(),
-			Data:    nil,
-		})
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.ListTrashResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Todos:   schemas.NewTrashedTodoResponses(todos),
+	})
+}
+
+// Handler for restore todo from the trash
+func (h *todoHandler) RestoreTodoHandler(c *gin.Context) {
+	id, err := parseID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	todo, err := h.todoService.RestoreTodo(c.Request.Context(), middleware.CurrentUserID(c), id)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    schemas.NewTodoResponse(todo),
+	})
+}
+
+// Handler for delete todo of the trash for good
+func (h *todoHandler) PurgeTodoHandler(c *gin.Context) {
+	id, err := parseID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	err = h.todoService.PurgeTodo(c.Request.Context(), middleware.CurrentUserID(c), id)
+	if err != nil {
+		middleware.AbortWithError(c, err)
 		return
 	}
 
@@ -196,3 +224,129 @@ func (h *todoHandler) DeleteTodoHandler(c *gin.Context) {
 		Data:    nil,
 	})
 }
+
+// Handler for move todo to another list
+func (h *todoHandler) MoveTodoHandler(c *gin.Context) {
+	req := schemas.MoveTodoRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	id, err := parseID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	todo, err := h.todoService.MoveTodo(c.Request.Context(), middleware.CurrentUserID(c), id, req.ListID)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    schemas.NewTodoResponse(todo),
+	})
+}
+
+// Handler for move todo under another todo
+func (h *todoHandler) SetParentHandler(c *gin.Context) {
+	req := schemas.SetParentRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	id, err := parseID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	todo, err := h.todoService.SetParent(c.Request.Context(), middleware.CurrentUserID(c), id, req.ParentID)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    schemas.NewTodoResponse(todo),
+	})
+}
+
+// Handler for attach label to todo
+func (h *todoHandler) AttachLabelHandler(c *gin.Context) {
+	id, labelID, err := parseTodoLabelIDs(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	todo, err := h.todoService.AttachLabel(c.Request.Context(), middleware.CurrentUserID(c), id, labelID)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    schemas.NewTodoResponse(todo),
+	})
+}
+
+// Handler for detach label from todo
+func (h *todoHandler) DetachLabelHandler(c *gin.Context) {
+	id, labelID, err := parseTodoLabelIDs(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	todo, err := h.todoService.DetachLabel(c.Request.Context(), middleware.CurrentUserID(c), id, labelID)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    schemas.NewTodoResponse(todo),
+	})
+}
+
+// parseID reads the id path parameter
+func parseID(c *gin.Context) (int, error) {
+	return parseIntParam(c, "id")
+}
+
+// parseTodoLabelIDs reads the id and labelId path parameters
+func parseTodoLabelIDs(c *gin.Context) (int, int, error) {
+	id, err := parseID(c)
+	if err != nil {
+		return 0, 0, err
+	}
+	labelID, err := parseIntParam(c, "labelId")
+	if err != nil {
+		return 0, 0, err
+	}
+	return id, labelID, nil
+}
+
+func parseIntParam(c *gin.Context, name string) (int, error) {
+	value, err := strconv.Atoi(c.Param(name))
+	if err != nil {
+		return 0, apperror.Validation(apperror.FieldError{
+			Field:   name,
+			Code:    "integer",
+			Message: "must be an integer",
+		})
+	}
+	return value, nil
+}
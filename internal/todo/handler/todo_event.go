@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"khiemle.dev/golang-api-template/internal/schemas"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/internal/todo/service"
+	"khiemle.dev/golang-api-template/pkg/apperror"
+	"khiemle.dev/golang-api-template/pkg/middleware"
+)
+
+// Event sent when a stream cannot resume, clients should reload their todos
+const resetEvent = "reset"
+
+type TodoEventHandler interface {
+	StreamEventsHandler(c *gin.Context)
+}
+
+type todoEventHandler struct {
+	todoEventService service.TodoEventService
+	heartbeat        time.Duration
+	maxDuration      time.Duration
+}
+
+// NewTodoEventHandler creates the handler of the event stream. A comment is
+// sent every heartbeat to keep connections open and streams end after
+// maxDuration, so clients reconnect with a fresh access token.
+func NewTodoEventHandler(todoEventService service.TodoEventService, heartbeat time.Duration, maxDuration time.Duration) TodoEventHandler {
+	return &todoEventHandler{
+		todoEventService: todoEventService,
+		heartbeat:        heartbeat,
+		maxDuration:      maxDuration,
+	}
+}
+
+// Handler for stream todo events as Server-Sent Events
+func (h *todoEventHandler) StreamEventsHandler(c *gin.Context) {
+	lastEventID, err := parseLastEventID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	stream, err := h.todoEventService.Subscribe(ctx, middleware.CurrentUserID(c), lastEventID)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+	defer stream.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // disable proxy buffering
+	c.Status(http.StatusOK)
+
+	if stream.Reset {
+		fmt.Fprintf(c.Writer, "event: %s\ndata: {}\n\n", resetEvent)
+	} else {
+		fmt.Fprint(c.Writer, ": connected\n\n")
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	deadline := time.NewTimer(h.maxDuration)
+	defer deadline.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline.C:
+			return
+		case <-heartbeat.C:
+			// Also catch up on wake-ups lost while pubsub reconnected
+			fmt.Fprint(c.Writer, ": ping\n\n")
+		case <-stream.Wake():
+		}
+
+		events, err := stream.Next(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				zerolog.Ctx(ctx).Error().Err(err).Msg("Could not read todo events")
+			}
+			return
+		}
+		for i := range events {
+			if err := writeTodoEvent(c, &events[i]); err != nil {
+				zerolog.Ctx(ctx).Error().Err(err).Msg("Could not write todo event")
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+func writeTodoEvent(c *gin.Context, event *model.TodoEvent) error {
+	res, err := schemas.NewTodoEventData(event)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return nil
+}
+
+// parseLastEventID reads the Last-Event-ID header sent by reconnecting
+// clients, or the last_event_id query parameter on the first connection
+func parseLastEventID(c *gin.Context) (*uint, error) {
+	value := c.GetHeader("Last-Event-ID")
+	if value == "" {
+		value = c.Query("last_event_id")
+	}
+	if value == "" {
+		return nil, nil
+	}
+
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return nil, apperror.Validation(apperror.FieldError{
+			Field:   "last_event_id",
+			Code:    "integer",
+			Message: "must be an event id",
+		})
+	}
+	lastEventID := uint(id)
+	return &lastEventID, nil
+}
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"khiemle.dev/golang-api-template/internal/schemas"
+	"khiemle.dev/golang-api-template/internal/todo/service"
+	"khiemle.dev/golang-api-template/pkg/middleware"
+)
+
+type TodoListHandler interface {
+	CreateListHandler(c *gin.Context)
+	GetListHandler(c *gin.Context)
+	UpdateListHandler(c *gin.Context)
+	ListListHandler(c *gin.Context)
+	DeleteListHandler(c *gin.Context)
+	ArchiveListHandler(c *gin.Context)
+	UnarchiveListHandler(c *gin.Context)
+}
+
+type todoListHandler struct {
+	todoListService service.TodoListService
+}
+
+func NewTodoListHandler(todoListService service.TodoListService) TodoListHandler {
+	return &todoListHandler{
+		todoListService: todoListService,
+	}
+}
+
+// Handler for create todo list
+func (h *todoListHandler) CreateListHandler(c *gin.Context) {
+	req := schemas.CreateTodoListRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	list, err := h.todoListService.CreateList(c.Request.Context(), middleware.CurrentUserID(c), req.Name, req.Description)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    schemas.NewTodoListResponse(list),
+	})
+}
+
+// Handler for get todo list
+func (h *todoListHandler) GetListHandler(c *gin.Context) {
+	id, err := parseID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	list, err := h.todoListService.GetList(c.Request.Context(), middleware.CurrentUserID(c), id)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    schemas.NewTodoListResponse(list),
+	})
+}
+
+// Handler for update todo list
+func (h *todoListHandler) UpdateListHandler(c *gin.Context) {
+	req := schemas.UpdateTodoListRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	id, err := parseID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	list, err := h.todoListService.UpdateList(c.Request.Context(), middleware.CurrentUserID(c), id, req.Name, req.Description)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    schemas.NewTodoListResponse(list),
+	})
+}
+
+// Handler for list todo lists
+func (h *todoListHandler) ListListHandler(c *gin.Context) {
+	req := schemas.ListTodoListRequest{}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	lists, err := h.todoListService.ListLists(c.Request.Context(), middleware.CurrentUserID(c), req.Archived)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.ListTodoListResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Lists:   schemas.NewTodoListResponses(lists),
+	})
+}
+
+// Handler for delete todo list, its todos are deleted too
+func (h *todoListHandler) DeleteListHandler(c *gin.Context) {
+	id, err := parseID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	err = h.todoListService.DeleteList(c.Request.Context(), middleware.CurrentUserID(c), id)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    nil,
+	})
+}
+
+// Handler for archive todo list
+func (h *todoListHandler) ArchiveListHandler(c *gin.Context) {
+	id, err := parseID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	list, err := h.todoListService.ArchiveList(c.Request.Context(), middleware.CurrentUserID(c), id)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    schemas.NewTodoListResponse(list),
+	})
+}
+
+// Handler for unarchive todo list
+func (h *todoListHandler) UnarchiveListHandler(c *gin.Context) {
+	id, err := parseID(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	list, err := h.todoListService.UnarchiveList(c.Request.Context(), middleware.CurrentUserID(c), id)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    schemas.NewTodoListResponse(list),
+	})
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// ChecklistItem is a lightweight step of a todo, lighter than a subtask.
+type ChecklistItem struct {
+	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	TodoID    uint      `json:"todo_id" gorm:"not null;index"`
+	Todo      *Todo     `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Text      string    `json:"text" gorm:"size:500;not null"`
+	Done      bool      `json:"done" gorm:"not null;default:false"`
+	Position  int       `json:"position" gorm:"not null;default:0"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// Label tags todos of its owner. Names are unique per owner.
+type Label struct {
+	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	OwnerID   uint      `json:"owner_id" gorm:"not null;uniqueIndex:idx_labels_owner_id_name"`
+	Name      string    `json:"name" gorm:"size:100;not null;uniqueIndex:idx_labels_owner_id_name"`
+	Color     string    `json:"color" gorm:"size:7;not null"` // #rrggbb
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+	InvitationRevoked  = "revoked"
+)
+
+// ListInvitation invites a user to join a todo list with a role. The invitee
+// becomes a member once they accept it.
+type ListInvitation struct {
+	ID          uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	ListID      uint       `json:"list_id" gorm:"not null;index"`
+	List        *TodoList  `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	InviterID   uint       `json:"inviter_id" gorm:"not null"`
+	InviteeID   uint       `json:"invitee_id" gorm:"not null;index"`
+	Role        ListRole   `json:"role" gorm:"size:20;not null"`
+	Status      string     `json:"status" gorm:"size:20;not null"`
+	RespondedAt *time.Time `json:"responded_at"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
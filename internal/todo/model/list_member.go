@@ -0,0 +1,37 @@
+package model
+
+import "time"
+
+// ListRole is what a user may do on a shared todo list.
+type ListRole string
+
+const (
+	// ListRoleViewer reads the list and its todos
+	ListRoleViewer ListRole = "viewer"
+	// ListRoleEditor also creates, updates and deletes todos of the list
+	ListRoleEditor ListRole = "editor"
+	// ListRoleOwner also manages the list, its members and invitations
+	ListRoleOwner ListRole = "owner"
+)
+
+var listRoleRanks = map[ListRole]int{
+	ListRoleViewer: 1,
+	ListRoleEditor: 2,
+	ListRoleOwner:  3,
+}
+
+// AtLeast reports whether r grants everything other grants.
+func (r ListRole) AtLeast(other ListRole) bool {
+	return listRoleRanks[r] >= listRoleRanks[other]
+}
+
+// ListMember gives a user a role on a todo list. The user who created the list,
+// TodoList.OwnerID, is always an owner and has no member row.
+type ListMember struct {
+	ListID    uint      `json:"list_id" gorm:"primaryKey;autoIncrement:false"`
+	List      *TodoList `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	UserID    uint      `json:"user_id" gorm:"primaryKey;autoIncrement:false;index"`
+	Role      ListRole  `json:"role" gorm:"size:20;not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
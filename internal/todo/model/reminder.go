@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// Reminder notifies a user through a channel some minutes before a todo is
+// due. Every user sets their own reminders, also on shared todos.
+type Reminder struct {
+	ID            uint   `json:"id" gorm:"primaryKey;autoIncrement"`
+	TodoID        uint   `json:"todo_id" gorm:"not null;uniqueIndex:idx_reminders_todo_user_offset_channel"`
+	Todo          *Todo  `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	UserID        uint   `json:"user_id" gorm:"not null;uniqueIndex:idx_reminders_todo_user_offset_channel"`
+	MinutesBefore int    `json:"minutes_before" gorm:"not null;uniqueIndex:idx_reminders_todo_user_offset_channel"`
+	Channel       string `json:"channel" gorm:"size:20;not null;uniqueIndex:idx_reminders_todo_user_offset_channel"`
+	// RemindAt is the due date of the todo minus MinutesBefore
+	RemindAt time.Time `json:"remind_at" gorm:"not null;index"`
+	// EnqueuedAt is set once a delivery job was enqueued, SentAt once the
+	// channel accepted the reminder
+	EnqueuedAt *time.Time `json:"enqueued_at"`
+	SentAt     *time.Time `json:"sent_at"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// Schedule sets when the reminder fires for a todo due at dueAt. A
+// rescheduled reminder is delivered again.
+func (r *Reminder) Schedule(dueAt time.Time) {
+	r.RemindAt = dueAt.Add(-time.Duration(r.MinutesBefore) * time.Minute)
+	r.EnqueuedAt = nil
+	r.SentAt = nil
+}
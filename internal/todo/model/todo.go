@@ -1,12 +1,66 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MaxTodoDepth is how many levels a todo tree may have: a top-level todo,
+// its subtasks and their subtasks.
+const MaxTodoDepth = 3
 
 // Todo represents a TODO item
 type Todo struct {
-	ID          uint      `json:"id" gorm:"primaryKey;autoIncrement"`
-	Name        string    `json:"name" gorm:"not null"`
-	Description string    `json:"description" gorm:"not null"`
-	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt   time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	ID          uint   `json:"id" gorm:"primaryKey;autoIncrement"`
+	OwnerID     uint   `json:"owner_id" gorm:"not null;default:0;index"`
+	Name        string `json:"name" gorm:"not null"`
+	Description string `json:"description" gorm:"not null"`
+	// ListID is nil for todos outside of any list. Deleting a list deletes
+	// its todos, also the trashed ones.
+	ListID *uint     `json:"list_id" gorm:"index"`
+	List   *TodoList `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	// ParentID is set for subtasks, which are always in the list of their
+	// parent. Deleting a todo deletes its subtasks.
+	ParentID *uint      `json:"parent_id" gorm:"index"`
+	Parent   *Todo      `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	DueAt    *time.Time `json:"due_at" gorm:"uniqueIndex:idx_todos_series_id_due_at,priority:2"`
+	// SeriesID is set for occurrences of a recurring todo. A series has at
+	// most one occurrence per due date.
+	SeriesID    *uint       `json:"series_id" gorm:"uniqueIndex:idx_todos_series_id_due_at,priority:1"`
+	Series      *TodoSeries `json:"-" gorm:"constraint:OnDelete:SET NULL"`
+	Done        bool        `json:"done" gorm:"not null;default:false"`
+	CompletedAt *time.Time  `json:"completed_at"`
+	Labels      []Label     `json:"labels" gorm:"many2many:todo_labels;constraint:OnDelete:CASCADE"`
+	CreatedAt   time.Time   `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time   `json:"updated_at" gorm:"autoUpdateTime"`
+	// DeletedAt is set while the todo is in the trash. Subtasks trashed with
+	// their parent share its DeletedAt.
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+}
+
+// SetDone marks the todo done or open, recording when it was completed.
+func (t *Todo) SetDone(done bool, now time.Time) {
+	if t.Done == done {
+		return
+	}
+	t.Done = done
+	if done {
+		t.CompletedAt = &now
+	} else {
+		t.CompletedAt = nil
+	}
+}
+
+// LabelsOf returns the labels of the todo owned by the user. Labels are
+// private to their owner, but todos of shared lists carry the labels every
+// member attached.
+func (t *Todo) LabelsOf(userID uint) []Label {
+	labels := []Label{}
+	for _, label := range t.Labels {
+		if label.OwnerID == userID {
+			labels = append(labels, label)
+		}
+	}
+	return labels
 }
@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+// Types of TodoEvent, also the types of the domain events of todos recorded
+// in the outbox with the todo as aggregate. Deleted todos go to the trash.
+const (
+	TodoEventCreated = "todo.created"
+	TodoEventUpdated = "todo.updated"
+	TodoEventDeleted = "todo.deleted"
+)
+
+// Types of the domain events of todos taken out of the trash, which streams
+// see as created, and deleted from it for good, which streams do not see.
+const (
+	TodoEventRestored = "todo.restored"
+	TodoEventPurged   = "todo.purged"
+)
+
+const AggregateTodo = "todo"
+
+// TodoChange is the payload of the domain events of todos. Todo is the todo
+// after the change, or before it was deleted or purged, and Before the todo
+// before an update. UserIDs lists the users who can see Todo and
+// RemovedUserIDs the ones who could only see it before, as it moved to
+// another list.
+type TodoChange struct {
+	Todo           *Todo  `json:"todo"`
+	Before         *Todo  `json:"before,omitempty"`
+	UserIDs        []uint `json:"user_ids"`
+	RemovedUserIDs []uint `json:"removed_user_ids,omitempty"`
+}
+
+// TodoEvent records a change of a todo for one of the users who can see it,
+// so their streams can resume after a disconnect. Events are purged after
+// TODO_EVENTS_RETENTION.
+type TodoEvent struct {
+	ID     uint   `json:"id" gorm:"primaryKey;autoIncrement;index:idx_todo_events_user_id_id,priority:2"`
+	UserID uint   `json:"user_id" gorm:"not null;index:idx_todo_events_user_id_id,priority:1"`
+	Type   string `json:"type" gorm:"size:50;not null"`
+	TodoID uint   `json:"todo_id" gorm:"not null"`
+	// Data is the todo as JSON with the labels of the user, only its id once
+	// deleted
+	Data      string    `json:"data" gorm:"type:text;not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime;index"`
+}
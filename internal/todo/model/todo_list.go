@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// TodoList groups todos, like a project. It is created by its owner and can
+// be shared with other users through ListMember. Archived lists are read-only
+// containers: todos cannot be added to them.
+type TodoList struct {
+	ID          uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	OwnerID     uint       `json:"owner_id" gorm:"not null;index"`
+	Name        string     `json:"name" gorm:"size:255;not null"`
+	Description string     `json:"description" gorm:"not null;default:''"`
+	ArchivedAt  *time.Time `json:"archived_at"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+
+	// OpenCount and DoneCount are computed when the list is loaded
+	OpenCount int64 `json:"open_count" gorm:"-"`
+	DoneCount int64 `json:"done_count" gorm:"-"`
+	// Role is the role of the user who loaded the list, set by the service
+	Role ListRole `json:"role" gorm:"-"`
+}
+
+// Archived reports whether the list was archived.
+func (l *TodoList) Archived() bool {
+	return l.ArchivedAt != nil
+}
@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// TodoSeries describes a recurring todo. Each occurrence is a todo of its
+// own, created from the series when the previous occurrence is completed or
+// its due date passes.
+type TodoSeries struct {
+	ID          uint   `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name        string `json:"name" gorm:"not null"`
+	Description string `json:"description" gorm:"not null"`
+	// RRule is an RFC 5545 recurrence rule without DTSTART, such as
+	// FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR. It is empty once the series stopped.
+	RRule string `json:"rrule" gorm:"column:rrule;size:500;not null"`
+	// Timezone is the IANA zone the rule is expanded in, so occurrences keep
+	// their wall clock time across DST changes.
+	Timezone string `json:"timezone" gorm:"size:64;not null"`
+	// StartAt is the DTSTART of the rule, the due date of its first
+	// occurrence.
+	StartAt time.Time `json:"start_at" gorm:"not null"`
+	// LastDueAt is the due date of the latest occurrence and NextDueAt the
+	// due date of the one after it, nil when the rule has no more.
+	LastDueAt time.Time  `json:"last_due_at" gorm:"not null;index"`
+	NextDueAt *time.Time `json:"next_due_at"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// Active reports whether the series still creates occurrences.
+func (s *TodoSeries) Active() bool {
+	return s.RRule != "" && s.NextDueAt != nil
+}
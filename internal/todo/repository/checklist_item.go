@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+// ChecklistItemRepository persists checklist items, scoped to their todo.
+// Lookups of missing items, or items of another todo, return
+// gorm.ErrRecordNotFound regardless of the implementation.
+type ChecklistItemRepository interface {
+	Create(ctx context.Context, item *model.ChecklistItem) error
+	// List returns the items of the todo by position.
+	List(ctx context.Context, todoID uint) ([]model.ChecklistItem, error)
+	// Update loads the item, applies fn and saves the result atomically.
+	Update(ctx context.Context, todoID uint, id uint, fn func(item *model.ChecklistItem) error) (*model.ChecklistItem, error)
+	Delete(ctx context.Context, todoID uint, id uint) error
+}
+
+type gormChecklistItemRepository struct {
+	db *gorm.DB
+}
+
+func NewGormChecklistItemRepository(db *gorm.DB) ChecklistItemRepository {
+	return &gormChecklistItemRepository{
+		db: db,
+	}
+}
+
+func (r *gormChecklistItemRepository) Create(ctx context.Context, item *model.ChecklistItem) error {
+	return txn.DB(ctx, r.db).Omit("Todo").Create(item).Error
+}
+
+func (r *gormChecklistItemRepository) List(ctx context.Context, todoID uint) ([]model.ChecklistItem, error) {
+	items := []model.ChecklistItem{}
+	err := txn.DB(ctx, r.db).Where("todo_id = ?", todoID).Order("position, id").Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *gormChecklistItemRepository) Update(ctx context.Context, todoID uint, id uint, fn func(item *model.ChecklistItem) error) (*model.ChecklistItem, error) {
+	item := model.ChecklistItem{}
+	err := txn.DB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&item, "id = ? AND todo_id = ?", id, todoID).Error; err != nil {
+			return err
+		}
+		if err := fn(&item); err != nil {
+			return err
+		}
+		return tx.Omit("Todo").Save(&item).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (r *gormChecklistItemRepository) Delete(ctx context.Context, todoID uint, id uint) error {
+	tx := txn.DB(ctx, r.db).Delete(&model.ChecklistItem{}, "id = ? AND todo_id = ?", id, todoID)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+)
+
+type memoryChecklistItemRepository struct {
+	mu     sync.RWMutex
+	nextID uint
+	items  map[uint]model.ChecklistItem
+}
+
+// NewMemoryChecklistItemRepository creates a ChecklistItemRepository backed
+// by a map, for tests and demos that run without a database.
+func NewMemoryChecklistItemRepository() ChecklistItemRepository {
+	return &memoryChecklistItemRepository{
+		items: map[uint]model.ChecklistItem{},
+	}
+}
+
+func (r *memoryChecklistItemRepository) Create(ctx context.Context, item *model.ChecklistItem) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	now := time.Now()
+	item.ID = r.nextID
+	item.CreatedAt = now
+	item.UpdatedAt = now
+	r.items[item.ID] = *item
+	return nil
+}
+
+func (r *memoryChecklistItemRepository) List(ctx context.Context, todoID uint) ([]model.ChecklistItem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	items := []model.ChecklistItem{}
+	for _, item := range r.items {
+		if item.TodoID == todoID {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Position != items[j].Position {
+			return items[i].Position < items[j].Position
+		}
+		return items[i].ID < items[j].ID
+	})
+	return items, nil
+}
+
+func (r *memoryChecklistItemRepository) Update(ctx context.Context, todoID uint, id uint, fn func(item *model.ChecklistItem) error) (*model.ChecklistItem, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item, ok := r.items[id]
+	if !ok || item.TodoID != todoID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err := fn(&item); err != nil {
+		return nil, err
+	}
+	item.UpdatedAt = time.Now()
+	r.items[id] = item
+	return &item, nil
+}
+
+func (r *memoryChecklistItemRepository) Delete(ctx context.Context, todoID uint, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item, ok := r.items[id]
+	if !ok || item.TodoID != todoID {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.items, id)
+	return nil
+}
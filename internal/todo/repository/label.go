@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+// LabelRepository persists labels, scoped to their owner. Lookups of missing
+// labels, or labels of another owner, return gorm.ErrRecordNotFound and a name
+// already used by the owner returns gorm.ErrDuplicatedKey regardless of the
+// implementation.
+type LabelRepository interface {
+	Create(ctx context.Context, label *model.Label) error
+	FindByID(ctx context.Context, ownerID uint, id uint) (*model.Label, error)
+	// FindByIDs returns the labels with the given ids whatever their owner,
+	// sorted by name. Missing labels are skipped.
+	FindByIDs(ctx context.Context, ids []uint) ([]model.Label, error)
+	List(ctx context.Context, ownerID uint) ([]model.Label, error)
+	// Update loads the label, applies fn and saves the result atomically.
+	Update(ctx context.Context, ownerID uint, id uint, fn func(label *model.Label) error) (*model.Label, error)
+	// Delete removes the label and detaches it from its todos.
+	Delete(ctx context.Context, ownerID uint, id uint) error
+}
+
+type gormLabelRepository struct {
+	db *gorm.DB
+}
+
+func NewGormLabelRepository(db *gorm.DB) LabelRepository {
+	return &gormLabelRepository{
+		db: db,
+	}
+}
+
+func (r *gormLabelRepository) Create(ctx context.Context, label *model.Label) error {
+	return txn.DB(ctx, r.db).Create(label).Error
+}
+
+func (r *gormLabelRepository) FindByID(ctx context.Context, ownerID uint, id uint) (*model.Label, error) {
+	label := model.Label{}
+	err := txn.DB(ctx, r.db).First(&label, "id = ? AND owner_id = ?", id, ownerID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &label, nil
+}
+
+func (r *gormLabelRepository) FindByIDs(ctx context.Context, ids []uint) ([]model.Label, error) {
+	labels := []model.Label{}
+	if len(ids) == 0 {
+		return labels, nil
+	}
+	err := txn.DB(ctx, r.db).Where("id IN ?", ids).Order("name").Find(&labels).Error
+	if err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+func (r *gormLabelRepository) List(ctx context.Context, ownerID uint) ([]model.Label, error) {
+	labels := []model.Label{}
+	err := txn.DB(ctx, r.db).Where("owner_id = ?", ownerID).Order("name").Find(&labels).Error
+	if err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+func (r *gormLabelRepository) Update(ctx context.Context, ownerID uint, id uint, fn func(label *model.Label) error) (*model.Label, error) {
+	label := model.Label{}
+	err := txn.DB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&label, "id = ? AND owner_id = ?", id, ownerID).Error; err != nil {
+			return err
+		}
+		if err := fn(&label); err != nil {
+			return err
+		}
+		return tx.Save(&label).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &label, nil
+}
+
+func (r *gormLabelRepository) Delete(ctx context.Context, ownerID uint, id uint) error {
+	// Rows of the join table are removed by the foreign key cascade
+	tx := txn.DB(ctx, r.db).Delete(&model.Label{}, "id = ? AND owner_id = ?", id, ownerID)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
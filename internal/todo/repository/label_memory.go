@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+)
+
+type memoryLabelRepository struct {
+	mu     sync.RWMutex
+	nextID uint
+	labels map[uint]model.Label
+}
+
+// NewMemoryLabelRepository creates a LabelRepository backed by a map, for
+// tests and demos that run without a database. Names are unique per owner as
+// with the database index.
+func NewMemoryLabelRepository() LabelRepository {
+	return &memoryLabelRepository{
+		labels: map[uint]model.Label{},
+	}
+}
+
+func (r *memoryLabelRepository) Create(ctx context.Context, label *model.Label) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.nameTaken(label.OwnerID, label.Name, 0) {
+		return gorm.ErrDuplicatedKey
+	}
+
+	r.nextID++
+	now := time.Now()
+	label.ID = r.nextID
+	label.CreatedAt = now
+	label.UpdatedAt = now
+	r.labels[label.ID] = *label
+	return nil
+}
+
+func (r *memoryLabelRepository) FindByID(ctx context.Context, ownerID uint, id uint) (*model.Label, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	label, ok := r.labels[id]
+	if !ok || label.OwnerID != ownerID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &label, nil
+}
+
+func (r *memoryLabelRepository) FindByIDs(ctx context.Context, ids []uint) ([]model.Label, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	labels := []model.Label{}
+	for _, id := range ids {
+		if label, ok := r.labels[id]; ok {
+			labels = append(labels, label)
+		}
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	return labels, nil
+}
+
+func (r *memoryLabelRepository) List(ctx context.Context, ownerID uint) ([]model.Label, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	labels := []model.Label{}
+	for _, label := range r.labels {
+		if label.OwnerID == ownerID {
+			labels = append(labels, label)
+		}
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	return labels, nil
+}
+
+func (r *memoryLabelRepository) Update(ctx context.Context, ownerID uint, id uint, fn func(label *model.Label) error) (*model.Label, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	label, ok := r.labels[id]
+	if !ok || label.OwnerID != ownerID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err := fn(&label); err != nil {
+		return nil, err
+	}
+	if r.nameTaken(label.OwnerID, label.Name, label.ID) {
+		return nil, gorm.ErrDuplicatedKey
+	}
+	label.UpdatedAt = time.Now()
+	r.labels[id] = label
+	return &label, nil
+}
+
+func (r *memoryLabelRepository) Delete(ctx context.Context, ownerID uint, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	label, ok := r.labels[id]
+	if !ok || label.OwnerID != ownerID {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.labels, id)
+	return nil
+}
+
+// nameTaken reports whether another label of the owner has the name
+func (r *memoryLabelRepository) nameTaken(ownerID uint, name string, exceptID uint) bool {
+	for _, other := range r.labels {
+		if other.ID != exceptID && other.OwnerID == ownerID && other.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+// ListInvitationFilter narrows down the invitations returned by List. Zero
+// values mean no filter.
+type ListInvitationFilter struct {
+	ListID    uint
+	InviteeID uint
+	Status    string
+}
+
+// ListInvitationRepository persists invitations to todo lists. Lookups of
+// missing invitations return gorm.ErrRecordNotFound regardless of the
+// implementation.
+type ListInvitationRepository interface {
+	Create(ctx context.Context, invitation *model.ListInvitation) error
+	FindByID(ctx context.Context, id uint) (*model.ListInvitation, error)
+	// List returns matching invitations, newest first.
+	List(ctx context.Context, filter ListInvitationFilter) ([]model.ListInvitation, error)
+	// Update loads the invitation, applies fn and saves the result atomically.
+	Update(ctx context.Context, id uint, fn func(invitation *model.ListInvitation) error) (*model.ListInvitation, error)
+}
+
+type gormListInvitationRepository struct {
+	db *gorm.DB
+}
+
+func NewGormListInvitationRepository(db *gorm.DB) ListInvitationRepository {
+	return &gormListInvitationRepository{
+		db: db,
+	}
+}
+
+func (r *gormListInvitationRepository) Create(ctx context.Context, invitation *model.ListInvitation) error {
+	return txn.DB(ctx, r.db).Omit("List").Create(invitation).Error
+}
+
+func (r *gormListInvitationRepository) FindByID(ctx context.Context, id uint) (*model.ListInvitation, error) {
+	invitation := model.ListInvitation{}
+	err := txn.DB(ctx, r.db).First(&invitation, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *gormListInvitationRepository) List(ctx context.Context, filter ListInvitationFilter) ([]model.ListInvitation, error) {
+	query := txn.DB(ctx, r.db)
+	if filter.ListID != 0 {
+		query = query.Where("list_id = ?", filter.ListID)
+	}
+	if filter.InviteeID != 0 {
+		query = query.Where("invitee_id = ?", filter.InviteeID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	invitations := []model.ListInvitation{}
+	err := query.Order("id DESC").Find(&invitations).Error
+	if err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+func (r *gormListInvitationRepository) Update(ctx context.Context, id uint, fn func(invitation *model.ListInvitation) error) (*model.ListInvitation, error) {
+	invitation := model.ListInvitation{}
+	err := txn.DB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&invitation, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := fn(&invitation); err != nil {
+			return err
+		}
+		return tx.Omit("List").Save(&invitation).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+)
+
+type memoryListInvitationRepository struct {
+	mu          sync.RWMutex
+	nextID      uint
+	invitations map[uint]model.ListInvitation
+}
+
+// NewMemoryListInvitationRepository creates a ListInvitationRepository backed
+// by a map, for tests and demos that run without a database.
+func NewMemoryListInvitationRepository() ListInvitationRepository {
+	return &memoryListInvitationRepository{
+		invitations: map[uint]model.ListInvitation{},
+	}
+}
+
+func (r *memoryListInvitationRepository) Create(ctx context.Context, invitation *model.ListInvitation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	now := time.Now()
+	invitation.ID = r.nextID
+	invitation.CreatedAt = now
+	invitation.UpdatedAt = now
+	r.invitations[invitation.ID] = *invitation
+	return nil
+}
+
+func (r *memoryListInvitationRepository) FindByID(ctx context.Context, id uint) (*model.ListInvitation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	invitation, ok := r.invitations[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &invitation, nil
+}
+
+func (r *memoryListInvitationRepository) List(ctx context.Context, filter ListInvitationFilter) ([]model.ListInvitation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	invitations := []model.ListInvitation{}
+	for _, invitation := range r.invitations {
+		if filter.ListID != 0 && invitation.ListID != filter.ListID {
+			continue
+		}
+		if filter.InviteeID != 0 && invitation.InviteeID != filter.InviteeID {
+			continue
+		}
+		if filter.Status != "" && invitation.Status != filter.Status {
+			continue
+		}
+		invitations = append(invitations, invitation)
+	}
+	sort.Slice(invitations, func(i, j int) bool { return invitations[i].ID > invitations[j].ID })
+	return invitations, nil
+}
+
+func (r *memoryListInvitationRepository) Update(ctx context.Context, id uint, fn func(invitation *model.ListInvitation) error) (*model.ListInvitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	invitation, ok := r.invitations[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err := fn(&invitation); err != nil {
+		return nil, err
+	}
+	invitation.UpdatedAt = time.Now()
+	r.invitations[id] = invitation
+	return &invitation, nil
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+// ListMemberRepository persists the members of todo lists. Lookups of missing
+// members return gorm.ErrRecordNotFound regardless of the implementation.
+type ListMemberRepository interface {
+	// Save adds the member, or changes their role if they already are one.
+	Save(ctx context.Context, member *model.ListMember) error
+	Find(ctx context.Context, listID uint, userID uint) (*model.ListMember, error)
+	// List returns the members of the list in the order they joined.
+	List(ctx context.Context, listID uint) ([]model.ListMember, error)
+	// ListByUser returns the memberships of the user.
+	ListByUser(ctx context.Context, userID uint) ([]model.ListMember, error)
+	Delete(ctx context.Context, listID uint, userID uint) error
+}
+
+type gormListMemberRepository struct {
+	db *gorm.DB
+}
+
+func NewGormListMemberRepository(db *gorm.DB) ListMemberRepository {
+	return &gormListMemberRepository{
+		db: db,
+	}
+}
+
+func (r *gormListMemberRepository) Save(ctx context.Context, member *model.ListMember) error {
+	return txn.DB(ctx, r.db).
+		Omit("List").
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "list_id"}, {Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"role", "updated_at"}),
+		}).
+		Create(member).Error
+}
+
+func (r *gormListMemberRepository) Find(ctx context.Context, listID uint, userID uint) (*model.ListMember, error) {
+	member := model.ListMember{}
+	err := txn.DB(ctx, r.db).First(&member, "list_id = ? AND user_id = ?", listID, userID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (r *gormListMemberRepository) List(ctx context.Context, listID uint) ([]model.ListMember, error) {
+	members := []model.ListMember{}
+	err := txn.DB(ctx, r.db).Where("list_id = ?", listID).Order("created_at, user_id").Find(&members).Error
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r *gormListMemberRepository) ListByUser(ctx context.Context, userID uint) ([]model.ListMember, error) {
+	members := []model.ListMember{}
+	err := txn.DB(ctx, r.db).Where("user_id = ?", userID).Order("list_id").Find(&members).Error
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r *gormListMemberRepository) Delete(ctx context.Context, listID uint, userID uint) error {
+	tx := txn.DB(ctx, r.db).Delete(&model.ListMember{}, "list_id = ? AND user_id = ?", listID, userID)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+)
+
+type listMemberKey struct {
+	listID uint
+	userID uint
+}
+
+type memoryListMemberRepository struct {
+	mu      sync.RWMutex
+	members map[listMemberKey]model.ListMember
+}
+
+// NewMemoryListMemberRepository creates a ListMemberRepository backed by a
+// map, for tests and demos that run without a database.
+func NewMemoryListMemberRepository() ListMemberRepository {
+	return &memoryListMemberRepository{
+		members: map[listMemberKey]model.ListMember{},
+	}
+}
+
+func (r *memoryListMemberRepository) Save(ctx context.Context, member *model.ListMember) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := listMemberKey{listID: member.ListID, userID: member.UserID}
+	now := time.Now()
+	if existing, ok := r.members[key]; ok {
+		member.CreatedAt = existing.CreatedAt
+	} else {
+		member.CreatedAt = now
+	}
+	member.UpdatedAt = now
+	r.members[key] = *member
+	return nil
+}
+
+func (r *memoryListMemberRepository) Find(ctx context.Context, listID uint, userID uint) (*model.ListMember, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	member, ok := r.members[listMemberKey{listID: listID, userID: userID}]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &member, nil
+}
+
+func (r *memoryListMemberRepository) List(ctx context.Context, listID uint) ([]model.ListMember, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	members := []model.ListMember{}
+	for _, member := range r.members {
+		if member.ListID == listID {
+			members = append(members, member)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if !members[i].CreatedAt.Equal(members[j].CreatedAt) {
+			return members[i].CreatedAt.Before(members[j].CreatedAt)
+		}
+		return members[i].UserID < members[j].UserID
+	})
+	return members, nil
+}
+
+func (r *memoryListMemberRepository) ListByUser(ctx context.Context, userID uint) ([]model.ListMember, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	members := []model.ListMember{}
+	for _, member := range r.members {
+		if member.UserID == userID {
+			members = append(members, member)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].ListID < members[j].ListID })
+	return members, nil
+}
+
+func (r *memoryListMemberRepository) Delete(ctx context.Context, listID uint, userID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := listMemberKey{listID: listID, userID: userID}
+	if _, ok := r.members[key]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.members, key)
+	return nil
+}
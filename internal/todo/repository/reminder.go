@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/pkg/jobqueue"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+// EnqueueReminderFunc enqueues the delivery of a reminder with jobs, which
+// writes in the transaction marking the reminder enqueued.
+type EnqueueReminderFunc func(ctx context.Context, jobs jobqueue.Client, reminder *model.Reminder) error
+
+// ReminderRepository persists reminders. Lookups of missing reminders return
+// gorm.ErrRecordNotFound and a reminder identical to an existing one returns
+// gorm.ErrDuplicatedKey regardless of the implementation.
+type ReminderRepository interface {
+	Create(ctx context.Context, reminder *model.Reminder) error
+	FindByID(ctx context.Context, id uint) (*model.Reminder, error)
+	// List returns the reminders of the todo, of every user, by remind time.
+	List(ctx context.Context, todoID uint) ([]model.Reminder, error)
+	// Update loads the reminder, applies fn and saves the result atomically.
+	Update(ctx context.Context, id uint, fn func(reminder *model.Reminder) error) (*model.Reminder, error)
+	Delete(ctx context.Context, todoID uint, userID uint, id uint) error
+	// Reschedule moves the reminders of the todo to its new due date.
+	Reschedule(ctx context.Context, todoID uint, dueAt time.Time) error
+	// EnqueueDue calls enqueue for up to limit reminders due at now, skipping
+	// those of done todos. Each reminder is marked enqueued in the same
+	// transaction as its job, so it is enqueued exactly once. It returns how
+	// many reminders were enqueued.
+	EnqueueDue(ctx context.Context, now time.Time, limit int, enqueue EnqueueReminderFunc) (int, error)
+}
+
+type gormReminderRepository struct {
+	db *gorm.DB
+}
+
+func NewGormReminderRepository(db *gorm.DB) ReminderRepository {
+	return &gormReminderRepository{
+		db: db,
+	}
+}
+
+func (r *gormReminderRepository) Create(ctx context.Context, reminder *model.Reminder) error {
+	return txn.DB(ctx, r.db).Omit("Todo").Create(reminder).Error
+}
+
+func (r *gormReminderRepository) FindByID(ctx context.Context, id uint) (*model.Reminder, error) {
+	reminder := model.Reminder{}
+	err := txn.DB(ctx, r.db).First(&reminder, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &reminder, nil
+}
+
+func (r *gormReminderRepository) List(ctx context.Context, todoID uint) ([]model.Reminder, error) {
+	reminders := []model.Reminder{}
+	err := txn.DB(ctx, r.db).Where("todo_id = ?", todoID).Order("remind_at, id").Find(&reminders).Error
+	if err != nil {
+		return nil, err
+	}
+	return reminders, nil
+}
+
+func (r *gormReminderRepository) Update(ctx context.Context, id uint, fn func(reminder *model.Reminder) error) (*model.Reminder, error) {
+	reminder := model.Reminder{}
+	err := txn.DB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&reminder, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := fn(&reminder); err != nil {
+			return err
+		}
+		return tx.Omit("Todo").Save(&reminder).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &reminder, nil
+}
+
+func (r *gormReminderRepository) Delete(ctx context.Context, todoID uint, userID uint, id uint) error {
+	tx := txn.DB(ctx, r.db).Delete(&model.Reminder{}, "id = ? AND todo_id = ? AND user_id = ?", id, todoID, userID)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *gormReminderRepository) Reschedule(ctx context.Context, todoID uint, dueAt time.Time) error {
+	return txn.DB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		reminders := []model.Reminder{}
+		if err := tx.Where("todo_id = ?", todoID).Find(&reminders).Error; err != nil {
+			return err
+		}
+		for i := range reminders {
+			reminders[i].Schedule(dueAt)
+			if err := tx.Omit("Todo").Save(&reminders[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *gormReminderRepository) EnqueueDue(ctx context.Context, now time.Time, limit int, enqueue EnqueueReminderFunc) (int, error) {
+	due := []model.Reminder{}
+	err := txn.DB(ctx, r.db).
+		Select("reminders.*").
+		Joins("JOIN todos ON todos.id = reminders.todo_id").
+		Where("reminders.enqueued_at IS NULL AND reminders.remind_at <= ? AND todos.done = ? AND todos.deleted_at IS NULL", now, false).
+		Order("reminders.remind_at, reminders.id").
+		Limit(limit).
+		Find(&due).Error
+	if err != nil {
+		return 0, err
+	}
+
+	enqueued := 0
+	for i := range due {
+		claimed := false
+		err := txn.DB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&model.Reminder{}).
+				Where("id = ? AND enqueued_at IS NULL", due[i].ID).
+				Update("enqueued_at", now)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			claimed = true
+			return enqueue(ctx, jobqueue.NewClient(tx), &due[i])
+		})
+		if err != nil {
+			return enqueued, err
+		}
+		if claimed {
+			enqueued++
+		}
+	}
+	return enqueued, nil
+}
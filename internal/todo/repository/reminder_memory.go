@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/pkg/jobqueue"
+)
+
+type memoryReminderRepository struct {
+	mu             sync.Mutex
+	nextID         uint
+	reminders      map[uint]model.Reminder
+	todoRepository TodoRepository
+	jobs           jobqueue.Client
+}
+
+// NewMemoryReminderRepository creates a ReminderRepository backed by a map,
+// for tests and demos that run without a database. Todos are looked up in
+// todoRepository and due reminders are enqueued with jobs.
+func NewMemoryReminderRepository(todoRepository TodoRepository, jobs jobqueue.Client) ReminderRepository {
+	return &memoryReminderRepository{
+		reminders:      map[uint]model.Reminder{},
+		todoRepository: todoRepository,
+		jobs:           jobs,
+	}
+}
+
+func (r *memoryReminderRepository) Create(ctx context.Context, reminder *model.Reminder) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.reminders {
+		if existing.TodoID == reminder.TodoID && existing.UserID == reminder.UserID &&
+			existing.MinutesBefore == reminder.MinutesBefore && existing.Channel == reminder.Channel {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+
+	r.nextID++
+	now := time.Now()
+	reminder.ID = r.nextID
+	reminder.CreatedAt = now
+	reminder.UpdatedAt = now
+	r.reminders[reminder.ID] = *reminder
+	return nil
+}
+
+func (r *memoryReminderRepository) FindByID(ctx context.Context, id uint) (*model.Reminder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reminder, ok := r.reminders[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &reminder, nil
+}
+
+func (r *memoryReminderRepository) List(ctx context.Context, todoID uint) ([]model.Reminder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reminders := []model.Reminder{}
+	for _, reminder := range r.reminders {
+		if reminder.TodoID == todoID {
+			reminders = append(reminders, reminder)
+		}
+	}
+	sortReminders(reminders)
+	return reminders, nil
+}
+
+func (r *memoryReminderRepository) Update(ctx context.Context, id uint, fn func(reminder *model.Reminder) error) (*model.Reminder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reminder, ok := r.reminders[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err := fn(&reminder); err != nil {
+		return nil, err
+	}
+	reminder.UpdatedAt = time.Now()
+	r.reminders[id] = reminder
+	return &reminder, nil
+}
+
+func (r *memoryReminderRepository) Delete(ctx context.Context, todoID uint, userID uint, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reminder, ok := r.reminders[id]
+	if !ok || reminder.TodoID != todoID || reminder.UserID != userID {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.reminders, id)
+	return nil
+}
+
+func (r *memoryReminderRepository) Reschedule(ctx context.Context, todoID uint, dueAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, reminder := range r.reminders {
+		if reminder.TodoID == todoID {
+			reminder.Schedule(dueAt)
+			reminder.UpdatedAt = time.Now()
+			r.reminders[id] = reminder
+		}
+	}
+	return nil
+}
+
+func (r *memoryReminderRepository) EnqueueDue(ctx context.Context, now time.Time, limit int, enqueue EnqueueReminderFunc) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	due := []model.Reminder{}
+	for _, reminder := range r.reminders {
+		if reminder.EnqueuedAt == nil && !reminder.RemindAt.After(now) {
+			due = append(due, reminder)
+		}
+	}
+	sortReminders(due)
+
+	enqueued := 0
+	for i := range due {
+		if enqueued == limit {
+			break
+		}
+		todo, err := r.todoRepository.FindByID(ctx, due[i].TodoID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return enqueued, err
+		}
+		if todo.Done {
+			continue
+		}
+		if err := enqueue(ctx, r.jobs, &due[i]); err != nil {
+			return enqueued, err
+		}
+		due[i].EnqueuedAt = &now
+		r.reminders[due[i].ID] = due[i]
+		enqueued++
+	}
+	return enqueued, nil
+}
+
+// sortReminders orders reminders by remind time, then id
+func sortReminders(reminders []model.Reminder) {
+	sort.Slice(reminders, func(i, j int) bool {
+		if !reminders[i].RemindAt.Equal(reminders[j].RemindAt) {
+			return reminders[i].RemindAt.Before(reminders[j].RemindAt)
+		}
+		return reminders[i].ID < reminders[j].ID
+	})
+}
@@ -0,0 +1,337 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+// ListTodoFilter narrows down the todos returned by List. Todos of ListIDs
+// are returned, plus todos of OwnerID outside of any list when IncludeUnlisted
+// is set.
+type ListTodoFilter struct {
+	OwnerID         uint
+	ListIDs         []uint
+	IncludeUnlisted bool
+	// Done keeps done or open todos. Nil means no filter.
+	Done *bool
+	// Labels keeps todos having any of the named labels of OwnerID, or all of
+	// them when MatchAll is set. Empty means no label filter.
+	Labels   []string
+	MatchAll bool
+}
+
+// TodoRepository persists todos. Lookups of missing todos return
+// gorm.ErrRecordNotFound and a second occurrence of a series on the same due
+// date returns gorm.ErrDuplicatedKey regardless of the implementation; callers
+// check who may access them. Returned todos have their labels loaded.
+//
+// Trashed todos are left out of every method but the ones of the trash. The
+// trash holds the trashed todos whose parent is not trashed, their subtasks
+// come and go with them.
+type TodoRepository interface {
+	Create(ctx context.Context, todo *model.Todo) error
+	FindByID(ctx context.Context, id uint) (*model.Todo, error)
+	List(ctx context.Context, filter ListTodoFilter) ([]model.Todo, error)
+	// Update loads the todo, applies fn and saves the result atomically.
+	Update(ctx context.Context, id uint, fn func(todo *model.Todo) error) (*model.Todo, error)
+	// Trash moves the todos to the trash at the given time.
+	Trash(ctx context.Context, ids []uint, at time.Time) error
+	// FindTrashed returns a todo of the trash.
+	FindTrashed(ctx context.Context, id uint) (*model.Todo, error)
+	// FindTrashedSubtask returns a trashed todo left out of the trash because
+	// its parent is trashed too.
+	FindTrashedSubtask(ctx context.Context, id uint) (*model.Todo, error)
+	// ListTrash returns the todos of the trash selected by filter, most
+	// recently trashed first.
+	ListTrash(ctx context.Context, filter ListTodoFilter) ([]model.Todo, error)
+	// ListTrashedChildren returns the direct subtasks of any of the parents
+	// that were trashed at the given time.
+	ListTrashedChildren(ctx context.Context, parentIDs []uint, at time.Time) ([]model.Todo, error)
+	// Restore takes the todos out of the trash.
+	Restore(ctx context.Context, ids []uint) error
+	// Delete removes the todo with its subtasks for good, trashed or not.
+	Delete(ctx context.Context, id uint) error
+	// ListExpiredTrash returns up to limit todos of the trash, as with
+	// FindTrashed, that were trashed before the given time, oldest first.
+	ListExpiredTrash(ctx context.Context, before time.Time, limit int) ([]model.Todo, error)
+	// ListSeries returns the occurrences of a recurring todo by due date.
+	ListSeries(ctx context.Context, seriesID uint) ([]model.Todo, error)
+	// ListChildren returns the direct subtasks of any of the parents.
+	ListChildren(ctx context.Context, parentIDs []uint) ([]model.Todo, error)
+	// SetDone marks the todos done or open. CompletedAt is set to at for todos
+	// that become done and cleared for todos that are reopened.
+	SetDone(ctx context.Context, ids []uint, done bool, at time.Time) error
+	// SetList moves the todos to the list, or out of any list when listID is
+	// nil. A non-zero ownerID also becomes their owner.
+	SetList(ctx context.Context, ids []uint, listID *uint, ownerID uint) error
+	// AttachLabel and DetachLabel are idempotent. Callers check that the user
+	// may edit the todo and owns the label.
+	AttachLabel(ctx context.Context, todoID uint, labelID uint) error
+	DetachLabel(ctx context.Context, todoID uint, labelID uint) error
+}
+
+type gormTodoRepository struct {
+	db *gorm.DB
+}
+
+func NewGormTodoRepository(db *gorm.DB) TodoRepository {
+	return &gormTodoRepository{
+		db: db,
+	}
+}
+
+// preloadLabels loads labels of todos sorted by name
+func preloadLabels(db *gorm.DB) *gorm.DB {
+	return db.Preload("Labels", func(db *gorm.DB) *gorm.DB {
+		return db.Order("labels.name")
+	})
+}
+
+func (r *gormTodoRepository) Create(ctx context.Context, todo *model.Todo) error {
+	return txn.DB(ctx, r.db).Omit("List", "Parent", "Series").Create(todo).Error
+}
+
+func (r *gormTodoRepository) FindByID(ctx context.Context, id uint) (*model.Todo, error) {
+	todo := model.Todo{}
+	err := preloadLabels(txn.DB(ctx, r.db)).First(&todo, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+func (r *gormTodoRepository) List(ctx context.Context, filter ListTodoFilter) ([]model.Todo, error) {
+	scope := r.db.Where("1 = 0")
+	if len(filter.ListIDs) > 0 {
+		scope = scope.Or("list_id IN ?", filter.ListIDs)
+	}
+	if filter.IncludeUnlisted {
+		scope = scope.Or("list_id IS NULL AND owner_id = ?", filter.OwnerID)
+	}
+
+	query := preloadLabels(txn.DB(ctx, r.db)).Where(scope)
+	if filter.Done != nil {
+		query = query.Where("done = ?", *filter.Done)
+	}
+
+	if len(filter.Labels) > 0 {
+		labeled := r.db.Table(todoLabelsTable).
+			Select("todo_labels.todo_id").
+			Joins("JOIN labels ON labels.id = todo_labels.label_id").
+			Where("labels.owner_id = ? AND labels.name IN ?", filter.OwnerID, filter.Labels)
+		if filter.MatchAll {
+			labeled = labeled.
+				Group("todo_labels.todo_id").
+				Having("COUNT(DISTINCT labels.id) = ?", len(uniqueStrings(filter.Labels)))
+		}
+		query = query.Where("id IN (?)", labeled)
+	}
+
+	todos := []model.Todo{}
+	err := query.Order("id").Find(&todos).Error
+	if err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+func (r *gormTodoRepository) Update(ctx context.Context, id uint, fn func(todo *model.Todo) error) (*model.Todo, error) {
+	todo := model.Todo{}
+	err := txn.DB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := preloadLabels(tx).First(&todo, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := fn(&todo); err != nil {
+			return err
+		}
+		return tx.Omit("Labels", "List", "Parent", "Series").Save(&todo).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+func (r *gormTodoRepository) Trash(ctx context.Context, ids []uint, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return txn.DB(ctx, r.db).Model(&model.Todo{}).Where("id IN ?", ids).Update("deleted_at", at).Error
+}
+
+// inTrash keeps trashed todos whose parent is not trashed
+func (r *gormTodoRepository) inTrash(db *gorm.DB) *gorm.DB {
+	liveParents := r.db.Model(&model.Todo{}).Select("id")
+	return db.Unscoped().
+		Where("todos.deleted_at IS NOT NULL").
+		Where("todos.parent_id IS NULL OR todos.parent_id IN (?)", liveParents)
+}
+
+func (r *gormTodoRepository) FindTrashed(ctx context.Context, id uint) (*model.Todo, error) {
+	todo := model.Todo{}
+	err := r.inTrash(preloadLabels(txn.DB(ctx, r.db))).First(&todo, "todos.id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+func (r *gormTodoRepository) FindTrashedSubtask(ctx context.Context, id uint) (*model.Todo, error) {
+	trashedParents := r.db.Unscoped().Model(&model.Todo{}).Where("deleted_at IS NOT NULL").Select("id")
+	todo := model.Todo{}
+	err := preloadLabels(txn.DB(ctx, r.db)).Unscoped().
+		Where("todos.deleted_at IS NOT NULL AND todos.parent_id IN (?)", trashedParents).
+		First(&todo, "todos.id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+func (r *gormTodoRepository) ListTrash(ctx context.Context, filter ListTodoFilter) ([]model.Todo, error) {
+	scope := r.db.Where("1 = 0")
+	if len(filter.ListIDs) > 0 {
+		scope = scope.Or("todos.list_id IN ?", filter.ListIDs)
+	}
+	if filter.IncludeUnlisted {
+		scope = scope.Or("todos.list_id IS NULL AND todos.owner_id = ?", filter.OwnerID)
+	}
+
+	todos := []model.Todo{}
+	err := r.inTrash(preloadLabels(txn.DB(ctx, r.db))).
+		Where(scope).
+		Order("todos.deleted_at DESC, todos.id").
+		Find(&todos).Error
+	if err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+func (r *gormTodoRepository) ListTrashedChildren(ctx context.Context, parentIDs []uint, at time.Time) ([]model.Todo, error) {
+	todos := []model.Todo{}
+	if len(parentIDs) == 0 {
+		return todos, nil
+	}
+	err := preloadLabels(txn.DB(ctx, r.db)).Unscoped().
+		Where("parent_id IN ? AND deleted_at = ?", parentIDs, at).
+		Order("id").
+		Find(&todos).Error
+	if err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+func (r *gormTodoRepository) Restore(ctx context.Context, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return txn.DB(ctx, r.db).Unscoped().Model(&model.Todo{}).Where("id IN ?", ids).Update("deleted_at", nil).Error
+}
+
+func (r *gormTodoRepository) Delete(ctx context.Context, id uint) error {
+	// Subtasks are removed by the foreign key cascade
+	tx := txn.DB(ctx, r.db).Unscoped().Delete(&model.Todo{}, "id = ?", id)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *gormTodoRepository) ListExpiredTrash(ctx context.Context, before time.Time, limit int) ([]model.Todo, error) {
+	todos := []model.Todo{}
+	err := r.inTrash(preloadLabels(txn.DB(ctx, r.db))).
+		Where("todos.deleted_at < ?", before).
+		Order("todos.deleted_at, todos.id").
+		Limit(limit).
+		Find(&todos).Error
+	if err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+func (r *gormTodoRepository) ListSeries(ctx context.Context, seriesID uint) ([]model.Todo, error) {
+	todos := []model.Todo{}
+	err := preloadLabels(txn.DB(ctx, r.db)).Where("series_id = ?", seriesID).Order("due_at, id").Find(&todos).Error
+	if err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+func (r *gormTodoRepository) ListChildren(ctx context.Context, parentIDs []uint) ([]model.Todo, error) {
+	todos := []model.Todo{}
+	if len(parentIDs) == 0 {
+		return todos, nil
+	}
+	err := preloadLabels(txn.DB(ctx, r.db)).Where("parent_id IN ?", parentIDs).Order("id").Find(&todos).Error
+	if err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+func (r *gormTodoRepository) SetDone(ctx context.Context, ids []uint, done bool, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	var completedAt *time.Time
+	if done {
+		completedAt = &at
+	}
+	return txn.DB(ctx, r.db).Model(&model.Todo{}).
+		Where("id IN ? AND done <> ?", ids, done).
+		Updates(map[string]any{"done": done, "completed_at": completedAt}).Error
+}
+
+func (r *gormTodoRepository) SetList(ctx context.Context, ids []uint, listID *uint, ownerID uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	updates := map[string]any{"list_id": listID}
+	if ownerID != 0 {
+		updates["owner_id"] = ownerID
+	}
+	return txn.DB(ctx, r.db).Model(&model.Todo{}).Where("id IN ?", ids).Updates(updates).Error
+}
+
+// todoLabelsTable is the join table of Todo.Labels. Rows are written directly
+// so attaching never upserts the label itself.
+const todoLabelsTable = "todo_labels"
+
+func (r *gormTodoRepository) AttachLabel(ctx context.Context, todoID uint, labelID uint) error {
+	return txn.DB(ctx, r.db).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Table(todoLabelsTable).
+		Create(map[string]any{"todo_id": todoID, "label_id": labelID}).Error
+}
+
+func (r *gormTodoRepository) DetachLabel(ctx context.Context, todoID uint, labelID uint) error {
+	return txn.DB(ctx, r.db).
+		Table(todoLabelsTable).
+		Where("todo_id = ? AND label_id = ?", todoID, labelID).
+		Delete(map[string]any{}).Error
+}
+
+// uniqueStrings returns values without duplicates, in their original order
+func uniqueStrings(values []string) []string {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+// TodoEventRepository persists the events streamed to users. Ids increase
+// with time. Lookups of missing events return gorm.ErrRecordNotFound
+// regardless of the implementation.
+type TodoEventRepository interface {
+	Create(ctx context.Context, events []model.TodoEvent) error
+	Find(ctx context.Context, userID uint, id uint) (*model.TodoEvent, error)
+	// ListAfter returns up to limit events of the user with an id above
+	// afterID, oldest first.
+	ListAfter(ctx context.Context, userID uint, afterID uint, limit int) ([]model.TodoEvent, error)
+	// LastID returns the id of the newest event of any user, 0 if none.
+	LastID(ctx context.Context) (uint, error)
+	// LastIDBefore returns the id of the newest event of any user created
+	// before the given time, 0 if none.
+	LastIDBefore(ctx context.Context, before time.Time) (uint, error)
+	// PurgeBefore deletes events created before the given time.
+	PurgeBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type gormTodoEventRepository struct {
+	db *gorm.DB
+}
+
+func NewGormTodoEventRepository(db *gorm.DB) TodoEventRepository {
+	return &gormTodoEventRepository{
+		db: db,
+	}
+}
+
+func (r *gormTodoEventRepository) Create(ctx context.Context, events []model.TodoEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return txn.DB(ctx, r.db).Create(&events).Error
+}
+
+func (r *gormTodoEventRepository) Find(ctx context.Context, userID uint, id uint) (*model.TodoEvent, error) {
+	event := model.TodoEvent{}
+	err := txn.DB(ctx, r.db).First(&event, "id = ? AND user_id = ?", id, userID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (r *gormTodoEventRepository) ListAfter(ctx context.Context, userID uint, afterID uint, limit int) ([]model.TodoEvent, error) {
+	events := []model.TodoEvent{}
+	err := txn.DB(ctx, r.db).
+		Where("user_id = ? AND id > ?", userID, afterID).
+		Order("id").
+		Limit(limit).
+		Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (r *gormTodoEventRepository) LastID(ctx context.Context) (uint, error) {
+	var lastID uint
+	err := txn.DB(ctx, r.db).Model(&model.TodoEvent{}).Select("COALESCE(MAX(id), 0)").Scan(&lastID).Error
+	return lastID, err
+}
+
+func (r *gormTodoEventRepository) LastIDBefore(ctx context.Context, before time.Time) (uint, error) {
+	var lastID uint
+	err := txn.DB(ctx, r.db).Model(&model.TodoEvent{}).
+		Where("created_at < ?", before).
+		Select("COALESCE(MAX(id), 0)").
+		Scan(&lastID).Error
+	return lastID, err
+}
+
+func (r *gormTodoEventRepository) PurgeBefore(ctx context.Context, before time.Time) (int64, error) {
+	tx := txn.DB(ctx, r.db).Where("created_at < ?", before).Delete(&model.TodoEvent{})
+	return tx.RowsAffected, tx.Error
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+)
+
+type memoryTodoEventRepository struct {
+	mu sync.RWMutex
+	// events are sorted by id
+	events []model.TodoEvent
+	nextID uint
+}
+
+// NewMemoryTodoEventRepository creates a TodoEventRepository backed by a
+// slice, for tests and demos that run without a database.
+func NewMemoryTodoEventRepository() TodoEventRepository {
+	return &memoryTodoEventRepository{}
+}
+
+func (r *memoryTodoEventRepository) Create(ctx context.Context, events []model.TodoEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for i := range events {
+		r.nextID++
+		events[i].ID = r.nextID
+		events[i].CreatedAt = now
+		r.events = append(r.events, events[i])
+	}
+	return nil
+}
+
+func (r *memoryTodoEventRepository) Find(ctx context.Context, userID uint, id uint) (*model.TodoEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, event := range r.events {
+		if event.ID == id && event.UserID == userID {
+			return &event, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryTodoEventRepository) ListAfter(ctx context.Context, userID uint, afterID uint, limit int) ([]model.TodoEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	events := []model.TodoEvent{}
+	for _, event := range r.events {
+		if len(events) == limit {
+			break
+		}
+		if event.UserID == userID && event.ID > afterID {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (r *memoryTodoEventRepository) LastID(ctx context.Context) (uint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.events) == 0 {
+		return 0, nil
+	}
+	return r.events[len(r.events)-1].ID, nil
+}
+
+func (r *memoryTodoEventRepository) LastIDBefore(ctx context.Context, before time.Time) (uint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var lastID uint
+	for _, event := range r.events {
+		if event.CreatedAt.Before(before) {
+			lastID = event.ID
+		}
+	}
+	return lastID, nil
+}
+
+func (r *memoryTodoEventRepository) PurgeBefore(ctx context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kept := r.events[:0]
+	for _, event := range r.events {
+		if !event.CreatedAt.Before(before) {
+			kept = append(kept, event)
+		}
+	}
+	purged := int64(len(r.events) - len(kept))
+	r.events = kept
+	return purged, nil
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+// TodoListRepository persists todo lists. Lookups of missing lists return
+// gorm.ErrRecordNotFound regardless of the implementation; callers check who
+// may access them. Returned lists have their open and done counts loaded.
+type TodoListRepository interface {
+	Create(ctx context.Context, list *model.TodoList) error
+	FindByID(ctx context.Context, id uint) (*model.TodoList, error)
+	// FindForUpdate is FindByID locking the list until the transaction of ctx
+	// ends, so it cannot be archived or deleted in the meantime.
+	FindForUpdate(ctx context.Context, id uint) (*model.TodoList, error)
+	// List returns the lists the user owns or is a member of.
+	List(ctx context.Context, userID uint, includeArchived bool) ([]model.TodoList, error)
+	// AccessibleIDs returns the ids of the lists the user owns or is a member
+	// of, archived or not.
+	AccessibleIDs(ctx context.Context, userID uint) ([]uint, error)
+	// Update loads the list, applies fn and saves the result atomically.
+	Update(ctx context.Context, id uint, fn func(list *model.TodoList) error) (*model.TodoList, error)
+	// Delete removes the list with its todos, members and invitations.
+	Delete(ctx context.Context, id uint) error
+}
+
+type gormTodoListRepository struct {
+	db *gorm.DB
+}
+
+func NewGormTodoListRepository(db *gorm.DB) TodoListRepository {
+	return &gormTodoListRepository{
+		db: db,
+	}
+}
+
+func (r *gormTodoListRepository) Create(ctx context.Context, list *model.TodoList) error {
+	return txn.DB(ctx, r.db).Create(list).Error
+}
+
+func (r *gormTodoListRepository) FindByID(ctx context.Context, id uint) (*model.TodoList, error) {
+	return r.find(ctx, txn.DB(ctx, r.db), id)
+}
+
+func (r *gormTodoListRepository) FindForUpdate(ctx context.Context, id uint) (*model.TodoList, error) {
+	// SQLite ignores the locking clause, its transactions already take the
+	// write lock when they begin
+	return r.find(ctx, txn.DB(ctx, r.db).Clauses(clause.Locking{Strength: "UPDATE"}), id)
+}
+
+func (r *gormTodoListRepository) find(ctx context.Context, db *gorm.DB, id uint) (*model.TodoList, error) {
+	list := model.TodoList{}
+	err := db.First(&list, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := r.loadCounts(ctx, []*model.TodoList{&list}); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func (r *gormTodoListRepository) List(ctx context.Context, userID uint, includeArchived bool) ([]model.TodoList, error) {
+	query := r.accessible(txn.DB(ctx, r.db), userID)
+	if !includeArchived {
+		query = query.Where("archived_at IS NULL")
+	}
+
+	lists := []model.TodoList{}
+	if err := query.Order("name, id").Find(&lists).Error; err != nil {
+		return nil, err
+	}
+
+	ptrs := make([]*model.TodoList, 0, len(lists))
+	for i := range lists {
+		ptrs = append(ptrs, &lists[i])
+	}
+	if err := r.loadCounts(ctx, ptrs); err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+func (r *gormTodoListRepository) AccessibleIDs(ctx context.Context, userID uint) ([]uint, error) {
+	ids := []uint{}
+	err := r.accessible(txn.DB(ctx, r.db).Model(&model.TodoList{}), userID).Pluck("id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// accessible keeps the lists owned by the user or shared with them
+func (r *gormTodoListRepository) accessible(db *gorm.DB, userID uint) *gorm.DB {
+	members := r.db.Model(&model.ListMember{}).Select("list_id").Where("user_id = ?", userID)
+	return db.Where("owner_id = ? OR id IN (?)", userID, members)
+}
+
+func (r *gormTodoListRepository) Update(ctx context.Context, id uint, fn func(list *model.TodoList) error) (*model.TodoList, error) {
+	list := model.TodoList{}
+	err := txn.DB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&list, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := fn(&list); err != nil {
+			return err
+		}
+		return tx.Save(&list).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := r.loadCounts(ctx, []*model.TodoList{&list}); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func (r *gormTodoListRepository) Delete(ctx context.Context, id uint) error {
+	// Todos, members and invitations are removed by the foreign key cascade
+	tx := txn.DB(ctx, r.db).Delete(&model.TodoList{}, "id = ?", id)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// loadCounts sets the open and done counts of lists with a single query
+func (r *gormTodoListRepository) loadCounts(ctx context.Context, lists []*model.TodoList) error {
+	if len(lists) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(lists))
+	for _, list := range lists {
+		ids = append(ids, list.ID)
+	}
+
+	rows := []struct {
+		ListID uint
+		Done   bool
+		Count  int64
+	}{}
+	err := txn.DB(ctx, r.db).Model(&model.Todo{}).
+		Select("list_id, done, COUNT(*) AS count").
+		Where("list_id IN ?", ids).
+		Group("list_id, done").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	byID := map[uint]*model.TodoList{}
+	for _, list := range lists {
+		list.OpenCount, list.DoneCount = 0, 0
+		byID[list.ID] = list
+	}
+	for _, row := range rows {
+		if row.Done {
+			byID[row.ListID].DoneCount = row.Count
+		} else {
+			byID[row.ListID].OpenCount = row.Count
+		}
+	}
+	return nil
+}
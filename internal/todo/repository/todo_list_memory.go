@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+)
+
+type memoryTodoListRepository struct {
+	mu                   sync.RWMutex
+	nextID               uint
+	lists                map[uint]model.TodoList
+	todoRepository       TodoRepository
+	listMemberRepository ListMemberRepository
+}
+
+// NewMemoryTodoListRepository creates a TodoListRepository backed by a map,
+// for tests and demos that run without a database. Counts are computed from
+// todoRepository and access from listMemberRepository. Deleted lists remove
+// their todos and members there, as with the database cascade.
+func NewMemoryTodoListRepository(todoRepository TodoRepository, listMemberRepository ListMemberRepository) TodoListRepository {
+	return &memoryTodoListRepository{
+		lists:                map[uint]model.TodoList{},
+		todoRepository:       todoRepository,
+		listMemberRepository: listMemberRepository,
+	}
+}
+
+func (r *memoryTodoListRepository) Create(ctx context.Context, list *model.TodoList) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	now := time.Now()
+	list.ID = r.nextID
+	list.CreatedAt = now
+	list.UpdatedAt = now
+	r.lists[list.ID] = *list
+	return nil
+}
+
+func (r *memoryTodoListRepository) FindByID(ctx context.Context, id uint) (*model.TodoList, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list, ok := r.lists[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err := r.loadCounts(ctx, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// FindForUpdate is FindByID, the memory repository has no transactions
+func (r *memoryTodoListRepository) FindForUpdate(ctx context.Context, id uint) (*model.TodoList, error) {
+	return r.FindByID(ctx, id)
+}
+
+func (r *memoryTodoListRepository) List(ctx context.Context, userID uint, includeArchived bool) ([]model.TodoList, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	shared, err := r.sharedWith(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	lists := []model.TodoList{}
+	for _, list := range r.lists {
+		if list.OwnerID != userID && !shared[list.ID] {
+			continue
+		}
+		if list.Archived() && !includeArchived {
+			continue
+		}
+		if err := r.loadCounts(ctx, &list); err != nil {
+			return nil, err
+		}
+		lists = append(lists, list)
+	}
+	sort.Slice(lists, func(i, j int) bool {
+		if lists[i].Name != lists[j].Name {
+			return lists[i].Name < lists[j].Name
+		}
+		return lists[i].ID < lists[j].ID
+	})
+	return lists, nil
+}
+
+func (r *memoryTodoListRepository) AccessibleIDs(ctx context.Context, userID uint) ([]uint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	shared, err := r.sharedWith(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []uint{}
+	for _, list := range r.lists {
+		if list.OwnerID == userID || shared[list.ID] {
+			ids = append(ids, list.ID)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// sharedWith returns the ids of the lists the user is a member of
+func (r *memoryTodoListRepository) sharedWith(ctx context.Context, userID uint) (map[uint]bool, error) {
+	members, err := r.listMemberRepository.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	shared := map[uint]bool{}
+	for _, member := range members {
+		shared[member.ListID] = true
+	}
+	return shared, nil
+}
+
+func (r *memoryTodoListRepository) Update(ctx context.Context, id uint, fn func(list *model.TodoList) error) (*model.TodoList, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list, ok := r.lists[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err := fn(&list); err != nil {
+		return nil, err
+	}
+	list.UpdatedAt = time.Now()
+	r.lists[id] = list
+
+	if err := r.loadCounts(ctx, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func (r *memoryTodoListRepository) Delete(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.lists[id]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+
+	todos, err := r.todoRepository.List(ctx, ListTodoFilter{ListIDs: []uint{id}})
+	if err != nil {
+		return err
+	}
+	trashed, err := r.todoRepository.ListTrash(ctx, ListTodoFilter{ListIDs: []uint{id}})
+	if err != nil {
+		return err
+	}
+	for _, todo := range append(todos, trashed...) {
+		if err := r.todoRepository.Delete(ctx, todo.ID); err != nil {
+			return err
+		}
+	}
+
+	members, err := r.listMemberRepository.List(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		if err := r.listMemberRepository.Delete(ctx, id, member.UserID); err != nil {
+			return err
+		}
+	}
+
+	delete(r.lists, id)
+	return nil
+}
+
+// loadCounts sets the open and done counts of list
+func (r *memoryTodoListRepository) loadCounts(ctx context.Context, list *model.TodoList) error {
+	todos, err := r.todoRepository.List(ctx, ListTodoFilter{ListIDs: []uint{list.ID}})
+	if err != nil {
+		return err
+	}
+
+	list.OpenCount, list.DoneCount = 0, 0
+	for _, todo := range todos {
+		if todo.Done {
+			list.DoneCount++
+		} else {
+			list.OpenCount++
+		}
+	}
+	return nil
+}
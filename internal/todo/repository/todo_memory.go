@@ -0,0 +1,426 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+)
+
+type memoryTodoRepository struct {
+	mu              sync.RWMutex
+	nextID          uint
+	todos           map[uint]model.Todo
+	todoLabels      map[uint]map[uint]bool
+	labelRepository LabelRepository
+}
+
+// NewMemoryTodoRepository creates a TodoRepository backed by a map, for tests
+// and demos that run without a database. Labels are looked up in
+// labelRepository, so deleted labels disappear from todos as with the database
+// cascade.
+func NewMemoryTodoRepository(labelRepository LabelRepository) TodoRepository {
+	return &memoryTodoRepository{
+		todos:           map[uint]model.Todo{},
+		todoLabels:      map[uint]map[uint]bool{},
+		labelRepository: labelRepository,
+	}
+}
+
+func (r *memoryTodoRepository) Create(ctx context.Context, todo *model.Todo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if todo.SeriesID != nil && todo.DueAt != nil {
+		for _, existing := range r.todos {
+			if existing.SeriesID != nil && *existing.SeriesID == *todo.SeriesID &&
+				existing.DueAt != nil && existing.DueAt.Equal(*todo.DueAt) {
+				return gorm.ErrDuplicatedKey
+			}
+		}
+	}
+
+	r.nextID++
+	now := time.Now()
+	todo.ID = r.nextID
+	todo.Labels = []model.Label{}
+	todo.CreatedAt = now
+	todo.UpdatedAt = now
+	r.todos[todo.ID] = *todo
+	return nil
+}
+
+func (r *memoryTodoRepository) FindByID(ctx context.Context, id uint) (*model.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todo, ok := r.todos[id]
+	if !ok || todo.DeletedAt.Valid {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err := r.loadLabels(ctx, &todo); err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+func (r *memoryTodoRepository) List(ctx context.Context, filter ListTodoFilter) ([]model.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	wanted := uniqueStrings(filter.Labels)
+	todos := []model.Todo{}
+	for _, todo := range r.todos {
+		if todo.DeletedAt.Valid || !inScope(todo, filter) {
+			continue
+		}
+		if filter.Done != nil && todo.Done != *filter.Done {
+			continue
+		}
+		if err := r.loadLabels(ctx, &todo); err != nil {
+			return nil, err
+		}
+		if len(wanted) > 0 && !matchLabels(todo.Labels, filter.OwnerID, wanted, filter.MatchAll) {
+			continue
+		}
+		todos = append(todos, todo)
+	}
+	sort.Slice(todos, func(i, j int) bool { return todos[i].ID < todos[j].ID })
+	return todos, nil
+}
+
+func (r *memoryTodoRepository) Update(ctx context.Context, id uint, fn func(todo *model.Todo) error) (*model.Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo, ok := r.todos[id]
+	if !ok || todo.DeletedAt.Valid {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err := r.loadLabels(ctx, &todo); err != nil {
+		return nil, err
+	}
+	if err := fn(&todo); err != nil {
+		return nil, err
+	}
+	todo.UpdatedAt = time.Now()
+	r.todos[id] = todo
+	return &todo, nil
+}
+
+func (r *memoryTodoRepository) Trash(ctx context.Context, ids []uint, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range ids {
+		todo, ok := r.todos[id]
+		if !ok || todo.DeletedAt.Valid {
+			continue
+		}
+		todo.DeletedAt = gorm.DeletedAt{Time: at, Valid: true}
+		r.todos[id] = todo
+	}
+	return nil
+}
+
+// inTrash reports whether the todo is trashed and its parent is not
+func (r *memoryTodoRepository) inTrash(todo model.Todo) bool {
+	if !todo.DeletedAt.Valid {
+		return false
+	}
+	if todo.ParentID == nil {
+		return true
+	}
+	parent, ok := r.todos[*todo.ParentID]
+	return ok && !parent.DeletedAt.Valid
+}
+
+func (r *memoryTodoRepository) FindTrashed(ctx context.Context, id uint) (*model.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todo, ok := r.todos[id]
+	if !ok || !r.inTrash(todo) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err := r.loadLabels(ctx, &todo); err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+func (r *memoryTodoRepository) FindTrashedSubtask(ctx context.Context, id uint) (*model.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todo, ok := r.todos[id]
+	if !ok || !todo.DeletedAt.Valid || todo.ParentID == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if parent, ok := r.todos[*todo.ParentID]; !ok || !parent.DeletedAt.Valid {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err := r.loadLabels(ctx, &todo); err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+func (r *memoryTodoRepository) ListTrash(ctx context.Context, filter ListTodoFilter) ([]model.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todos := []model.Todo{}
+	for _, todo := range r.todos {
+		if !r.inTrash(todo) || !inScope(todo, filter) {
+			continue
+		}
+		if err := r.loadLabels(ctx, &todo); err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+	sort.Slice(todos, func(i, j int) bool {
+		if !todos[i].DeletedAt.Time.Equal(todos[j].DeletedAt.Time) {
+			return todos[i].DeletedAt.Time.After(todos[j].DeletedAt.Time)
+		}
+		return todos[i].ID < todos[j].ID
+	})
+	return todos, nil
+}
+
+func (r *memoryTodoRepository) ListTrashedChildren(ctx context.Context, parentIDs []uint, at time.Time) ([]model.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	parents := map[uint]bool{}
+	for _, id := range parentIDs {
+		parents[id] = true
+	}
+
+	todos := []model.Todo{}
+	for _, todo := range r.todos {
+		if todo.ParentID == nil || !parents[*todo.ParentID] || !todo.DeletedAt.Valid || !todo.DeletedAt.Time.Equal(at) {
+			continue
+		}
+		if err := r.loadLabels(ctx, &todo); err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+	sort.Slice(todos, func(i, j int) bool { return todos[i].ID < todos[j].ID })
+	return todos, nil
+}
+
+func (r *memoryTodoRepository) Restore(ctx context.Context, ids []uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range ids {
+		if todo, ok := r.todos[id]; ok && todo.DeletedAt.Valid {
+			todo.DeletedAt = gorm.DeletedAt{}
+			todo.UpdatedAt = time.Now()
+			r.todos[id] = todo
+		}
+	}
+	return nil
+}
+
+func (r *memoryTodoRepository) Delete(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.todos[id]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	r.deleteTree(id)
+	return nil
+}
+
+// deleteTree removes the todo and its subtasks, as the database cascade does
+func (r *memoryTodoRepository) deleteTree(id uint) {
+	for _, todo := range r.todos {
+		if todo.ParentID != nil && *todo.ParentID == id {
+			r.deleteTree(todo.ID)
+		}
+	}
+	delete(r.todos, id)
+	delete(r.todoLabels, id)
+}
+
+func (r *memoryTodoRepository) ListExpiredTrash(ctx context.Context, before time.Time, limit int) ([]model.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todos := []model.Todo{}
+	for _, todo := range r.todos {
+		if !r.inTrash(todo) || !todo.DeletedAt.Time.Before(before) {
+			continue
+		}
+		if err := r.loadLabels(ctx, &todo); err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+	sort.Slice(todos, func(i, j int) bool {
+		if !todos[i].DeletedAt.Time.Equal(todos[j].DeletedAt.Time) {
+			return todos[i].DeletedAt.Time.Before(todos[j].DeletedAt.Time)
+		}
+		return todos[i].ID < todos[j].ID
+	})
+	if len(todos) > limit {
+		todos = todos[:limit]
+	}
+	return todos, nil
+}
+
+func (r *memoryTodoRepository) ListSeries(ctx context.Context, seriesID uint) ([]model.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todos := []model.Todo{}
+	for _, todo := range r.todos {
+		if todo.DeletedAt.Valid || todo.SeriesID == nil || *todo.SeriesID != seriesID {
+			continue
+		}
+		if err := r.loadLabels(ctx, &todo); err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+	sort.Slice(todos, func(i, j int) bool {
+		if !todos[i].DueAt.Equal(*todos[j].DueAt) {
+			return todos[i].DueAt.Before(*todos[j].DueAt)
+		}
+		return todos[i].ID < todos[j].ID
+	})
+	return todos, nil
+}
+
+func (r *memoryTodoRepository) ListChildren(ctx context.Context, parentIDs []uint) ([]model.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	parents := map[uint]bool{}
+	for _, id := range parentIDs {
+		parents[id] = true
+	}
+
+	todos := []model.Todo{}
+	for _, todo := range r.todos {
+		if todo.DeletedAt.Valid || todo.ParentID == nil || !parents[*todo.ParentID] {
+			continue
+		}
+		if err := r.loadLabels(ctx, &todo); err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+	sort.Slice(todos, func(i, j int) bool { return todos[i].ID < todos[j].ID })
+	return todos, nil
+}
+
+func (r *memoryTodoRepository) SetDone(ctx context.Context, ids []uint, done bool, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range ids {
+		if todo, ok := r.todos[id]; ok && !todo.DeletedAt.Valid && todo.Done != done {
+			todo.SetDone(done, at)
+			todo.UpdatedAt = time.Now()
+			r.todos[id] = todo
+		}
+	}
+	return nil
+}
+
+func (r *memoryTodoRepository) SetList(ctx context.Context, ids []uint, listID *uint, ownerID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range ids {
+		todo, ok := r.todos[id]
+		if !ok || todo.DeletedAt.Valid {
+			continue
+		}
+		todo.ListID = listID
+		if ownerID != 0 {
+			todo.OwnerID = ownerID
+		}
+		todo.UpdatedAt = time.Now()
+		r.todos[id] = todo
+	}
+	return nil
+}
+
+func (r *memoryTodoRepository) AttachLabel(ctx context.Context, todoID uint, labelID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.todoLabels[todoID] == nil {
+		r.todoLabels[todoID] = map[uint]bool{}
+	}
+	r.todoLabels[todoID][labelID] = true
+	return nil
+}
+
+func (r *memoryTodoRepository) DetachLabel(ctx context.Context, todoID uint, labelID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.todoLabels[todoID], labelID)
+	return nil
+}
+
+// inScope reports whether todo belongs to the lists, or is an unlisted todo
+// of the owner, selected by filter
+func inScope(todo model.Todo, filter ListTodoFilter) bool {
+	if todo.ListID == nil {
+		return filter.IncludeUnlisted && todo.OwnerID == filter.OwnerID
+	}
+	for _, listID := range filter.ListIDs {
+		if *todo.ListID == listID {
+			return true
+		}
+	}
+	return false
+}
+
+// loadLabels sets the labels attached to todo, sorted by name
+func (r *memoryTodoRepository) loadLabels(ctx context.Context, todo *model.Todo) error {
+	ids := make([]uint, 0, len(r.todoLabels[todo.ID]))
+	for labelID := range r.todoLabels[todo.ID] {
+		ids = append(ids, labelID)
+	}
+	labels, err := r.labelRepository.FindByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	todo.Labels = labels
+	return nil
+}
+
+// matchLabels reports whether labels of the owner contain any, or all, of the
+// wanted names
+func matchLabels(labels []model.Label, ownerID uint, wanted []string, matchAll bool) bool {
+	names := map[string]bool{}
+	for _, label := range labels {
+		if label.OwnerID == ownerID {
+			names[label.Name] = true
+		}
+	}
+
+	matched := 0
+	for _, name := range wanted {
+		if names[name] {
+			matched++
+		}
+	}
+	if matchAll {
+		return matched == len(wanted)
+	}
+	return matched > 0
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+// TodoSeriesRepository persists recurring todo series. Lookups of missing
+// series return gorm.ErrRecordNotFound regardless of the implementation.
+type TodoSeriesRepository interface {
+	Create(ctx context.Context, series *model.TodoSeries) error
+	FindByID(ctx context.Context, id uint) (*model.TodoSeries, error)
+	// FindForUpdate is FindByID locking the series until the transaction of
+	// ctx ends, so concurrent callers move it forward one at a time.
+	FindForUpdate(ctx context.Context, id uint) (*model.TodoSeries, error)
+	// Update loads the series, applies fn and saves the result atomically.
+	Update(ctx context.Context, id uint, fn func(series *model.TodoSeries) error) (*model.TodoSeries, error)
+	// ListDue returns up to limit active series whose latest occurrence was
+	// due at or before now, oldest first.
+	ListDue(ctx context.Context, now time.Time, limit int) ([]model.TodoSeries, error)
+}
+
+type gormTodoSeriesRepository struct {
+	db *gorm.DB
+}
+
+func NewGormTodoSeriesRepository(db *gorm.DB) TodoSeriesRepository {
+	return &gormTodoSeriesRepository{
+		db: db,
+	}
+}
+
+func (r *gormTodoSeriesRepository) Create(ctx context.Context, series *model.TodoSeries) error {
+	return txn.DB(ctx, r.db).Create(series).Error
+}
+
+func (r *gormTodoSeriesRepository) FindByID(ctx context.Context, id uint) (*model.TodoSeries, error) {
+	series := model.TodoSeries{}
+	err := txn.DB(ctx, r.db).First(&series, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+func (r *gormTodoSeriesRepository) FindForUpdate(ctx context.Context, id uint) (*model.TodoSeries, error) {
+	// SQLite ignores the locking clause, its transactions already take the
+	// write lock when they begin
+	series := model.TodoSeries{}
+	err := txn.DB(ctx, r.db).Clauses(clause.Locking{Strength: "UPDATE"}).First(&series, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+func (r *gormTodoSeriesRepository) Update(ctx context.Context, id uint, fn func(series *model.TodoSeries) error) (*model.TodoSeries, error) {
+	series := model.TodoSeries{}
+	err := txn.DB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&series, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := fn(&series); err != nil {
+			return err
+		}
+		return tx.Save(&series).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+func (r *gormTodoSeriesRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]model.TodoSeries, error) {
+	series := []model.TodoSeries{}
+	err := txn.DB(ctx, r.db).
+		Where("rrule <> '' AND next_due_at IS NOT NULL AND last_due_at <= ?", now).
+		Order("last_due_at, id").
+		Limit(limit).
+		Find(&series).Error
+	if err != nil {
+		return nil, err
+	}
+	return series, nil
+}
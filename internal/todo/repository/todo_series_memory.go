@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+)
+
+type memoryTodoSeriesRepository struct {
+	mu     sync.RWMutex
+	nextID uint
+	series map[uint]model.TodoSeries
+}
+
+// NewMemoryTodoSeriesRepository creates a TodoSeriesRepository backed by a
+// map, for tests and demos that run without a database.
+func NewMemoryTodoSeriesRepository() TodoSeriesRepository {
+	return &memoryTodoSeriesRepository{
+		series: map[uint]model.TodoSeries{},
+	}
+}
+
+func (r *memoryTodoSeriesRepository) Create(ctx context.Context, series *model.TodoSeries) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	now := time.Now()
+	series.ID = r.nextID
+	series.CreatedAt = now
+	series.UpdatedAt = now
+	r.series[series.ID] = *series
+	return nil
+}
+
+func (r *memoryTodoSeriesRepository) FindByID(ctx context.Context, id uint) (*model.TodoSeries, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	series, ok := r.series[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &series, nil
+}
+
+// FindForUpdate is FindByID, the memory repository has no transactions
+func (r *memoryTodoSeriesRepository) FindForUpdate(ctx context.Context, id uint) (*model.TodoSeries, error) {
+	return r.FindByID(ctx, id)
+}
+
+func (r *memoryTodoSeriesRepository) Update(ctx context.Context, id uint, fn func(series *model.TodoSeries) error) (*model.TodoSeries, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	series, ok := r.series[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err := fn(&series); err != nil {
+		return nil, err
+	}
+	series.UpdatedAt = time.Now()
+	r.series[id] = series
+	return &series, nil
+}
+
+func (r *memoryTodoSeriesRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]model.TodoSeries, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	due := []model.TodoSeries{}
+	for _, series := range r.series {
+		if series.Active() && !series.LastDueAt.After(now) {
+			due = append(due, series)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].LastDueAt.Equal(due[j].LastDueAt) {
+			return due[i].LastDueAt.Before(due[j].LastDueAt)
+		}
+		return due[i].ID < due[j].ID
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
@@ -0,0 +1,229 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/internal/todo/repository"
+	"khiemle.dev/golang-api-template/pkg/database/databasetest"
+)
+
+type todoRepositories struct {
+	todos  repository.TodoRepository
+	labels repository.LabelRepository
+	series repository.TodoSeriesRepository
+}
+
+// TestTodoRepository runs the same contract against every implementation
+func TestTodoRepository(t *testing.T) {
+	implementations := map[string]func(t *testing.T) todoRepositories{
+		"gorm": func(t *testing.T) todoRepositories {
+			db := databasetest.New(t)
+			return todoRepositories{
+				todos:  repository.NewGormTodoRepository(db),
+				labels: repository.NewGormLabelRepository(db),
+				series: repository.NewGormTodoSeriesRepository(db),
+			}
+		},
+		"memory": func(t *testing.T) todoRepositories {
+			labels := repository.NewMemoryLabelRepository()
+			return todoRepositories{
+				todos:  repository.NewMemoryTodoRepository(labels),
+				labels: labels,
+				series: repository.NewMemoryTodoSeriesRepository(),
+			}
+		},
+	}
+	for name, newRepositories := range implementations {
+		t.Run(name, func(t *testing.T) {
+			testTodoRepository(t, newRepositories(t))
+		})
+	}
+}
+
+func testTodoRepository(t *testing.T, r todoRepositories) {
+	ctx := context.Background()
+	const ownerID = 1
+
+	create := func(name string, parent *model.Todo) *model.Todo {
+		t.Helper()
+		todo := &model.Todo{OwnerID: ownerID, Name: name}
+		if parent != nil {
+			todo.ParentID = &parent.ID
+		}
+		if err := r.todos.Create(ctx, todo); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		return todo
+	}
+	names := func(todos []model.Todo) []string {
+		names := []string{}
+		for _, todo := range todos {
+			names = append(names, todo.Name)
+		}
+		return names
+	}
+
+	t.Run("find and update", func(t *testing.T) {
+		todo := create("write tests", nil)
+
+		found, err := r.todos.FindByID(ctx, todo.ID)
+		if err != nil {
+			t.Fatalf("FindByID: %v", err)
+		}
+		if found.Name != "write tests" || found.Labels == nil {
+			t.Errorf("FindByID = %+v, want the todo with its labels loaded", found)
+		}
+		if _, err := r.todos.FindByID(ctx, 999); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("FindByID of a missing todo = %v, want gorm.ErrRecordNotFound", err)
+		}
+
+		updated, err := r.todos.Update(ctx, todo.ID, func(todo *model.Todo) error {
+			todo.Description = "for every repository"
+			return nil
+		})
+		if err != nil || updated.Description != "for every repository" {
+			t.Errorf("Update = %+v, %v", updated, err)
+		}
+	})
+
+	t.Run("labels", func(t *testing.T) {
+		todo := create("labelled", nil)
+		label := &model.Label{OwnerID: ownerID, Name: "work", Color: "#ff0000"}
+		if err := r.labels.Create(ctx, label); err != nil {
+			t.Fatalf("create label: %v", err)
+		}
+
+		// Attaching twice is a no-op
+		for i := 0; i < 2; i++ {
+			if err := r.todos.AttachLabel(ctx, todo.ID, label.ID); err != nil {
+				t.Fatalf("AttachLabel: %v", err)
+			}
+		}
+		found, err := r.todos.FindByID(ctx, todo.ID)
+		if err != nil || len(found.Labels) != 1 || found.Labels[0].Name != "work" {
+			t.Fatalf("FindByID after AttachLabel = %+v, %v", found, err)
+		}
+
+		todos, err := r.todos.List(ctx, repository.ListTodoFilter{OwnerID: ownerID, IncludeUnlisted: true, Labels: []string{"work"}})
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if got := names(todos); len(got) != 1 || got[0] != "labelled" {
+			t.Errorf("List by label = %v, want [labelled]", got)
+		}
+
+		if err := r.todos.DetachLabel(ctx, todo.ID, label.ID); err != nil {
+			t.Fatalf("DetachLabel: %v", err)
+		}
+		if found, _ := r.todos.FindByID(ctx, todo.ID); len(found.Labels) != 0 {
+			t.Errorf("labels after DetachLabel = %+v", found.Labels)
+		}
+	})
+
+	t.Run("series occurrences are unique per due date", func(t *testing.T) {
+		dueAt := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+		series := &model.TodoSeries{Name: "standup", RRule: "FREQ=DAILY", Timezone: "UTC", StartAt: dueAt, LastDueAt: dueAt}
+		if err := r.series.Create(ctx, series); err != nil {
+			t.Fatalf("create series: %v", err)
+		}
+
+		for i, want := range []error{nil, gorm.ErrDuplicatedKey} {
+			todo := &model.Todo{OwnerID: ownerID, Name: "standup", SeriesID: &series.ID, DueAt: &dueAt}
+			if err := r.todos.Create(ctx, todo); !errors.Is(err, want) {
+				t.Errorf("Create of occurrence %d = %v, want %v", i, err, want)
+			}
+		}
+
+		occurrences, err := r.todos.ListSeries(ctx, series.ID)
+		if err != nil || len(occurrences) != 1 {
+			t.Errorf("ListSeries = %v, %v, want one occurrence", names(occurrences), err)
+		}
+	})
+
+	t.Run("done", func(t *testing.T) {
+		todo := create("finish", nil)
+		at := time.Now()
+		if err := r.todos.SetDone(ctx, []uint{todo.ID}, true, at); err != nil {
+			t.Fatalf("SetDone: %v", err)
+		}
+		found, _ := r.todos.FindByID(ctx, todo.ID)
+		if !found.Done || found.CompletedAt == nil {
+			t.Errorf("after SetDone(true) = %+v", found)
+		}
+
+		if err := r.todos.SetDone(ctx, []uint{todo.ID}, false, at); err != nil {
+			t.Fatalf("SetDone: %v", err)
+		}
+		found, _ = r.todos.FindByID(ctx, todo.ID)
+		if found.Done || found.CompletedAt != nil {
+			t.Errorf("after SetDone(false) = %+v", found)
+		}
+	})
+
+	t.Run("trash", func(t *testing.T) {
+		parent := create("trashed parent", nil)
+		child := create("trashed child", parent)
+
+		at := time.Now().Add(-time.Hour)
+		if err := r.todos.Trash(ctx, []uint{parent.ID, child.ID}, at); err != nil {
+			t.Fatalf("Trash: %v", err)
+		}
+		if _, err := r.todos.FindByID(ctx, parent.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("FindByID of a trashed todo = %v, want gorm.ErrRecordNotFound", err)
+		}
+		if _, err := r.todos.FindTrashed(ctx, parent.ID); err != nil {
+			t.Errorf("FindTrashed: %v", err)
+		}
+
+		// Subtasks come and go with their parent
+		trash, err := r.todos.ListTrash(ctx, repository.ListTodoFilter{OwnerID: ownerID, IncludeUnlisted: true})
+		if err != nil {
+			t.Fatalf("ListTrash: %v", err)
+		}
+		if got := names(trash); len(got) != 1 || got[0] != "trashed parent" {
+			t.Errorf("ListTrash = %v, want [trashed parent]", got)
+		}
+		children, err := r.todos.ListTrashedChildren(ctx, []uint{parent.ID}, trash[0].DeletedAt.Time)
+		if err != nil || len(children) != 1 || children[0].ID != child.ID {
+			t.Errorf("ListTrashedChildren = %v, %v, want [trashed child]", names(children), err)
+		}
+
+		if err := r.todos.Restore(ctx, []uint{parent.ID, child.ID}); err != nil {
+			t.Fatalf("Restore: %v", err)
+		}
+		if _, err := r.todos.FindByID(ctx, child.ID); err != nil {
+			t.Errorf("FindByID of a restored todo: %v", err)
+		}
+
+		if err := r.todos.Trash(ctx, []uint{parent.ID, child.ID}, at); err != nil {
+			t.Fatalf("Trash: %v", err)
+		}
+		expired, err := r.todos.ListExpiredTrash(ctx, at.Add(-time.Minute), 10)
+		if err != nil || len(expired) != 0 {
+			t.Errorf("ListExpiredTrash before the todos were trashed = %v, %v, want none", names(expired), err)
+		}
+		expired, err = r.todos.ListExpiredTrash(ctx, time.Now(), 10)
+		if err != nil || len(expired) != 1 || expired[0].ID != parent.ID {
+			t.Errorf("ListExpiredTrash = %v, %v, want [trashed parent]", names(expired), err)
+		}
+	})
+
+	t.Run("delete removes subtasks", func(t *testing.T) {
+		parent := create("deleted parent", nil)
+		child := create("deleted child", parent)
+
+		if err := r.todos.Delete(ctx, parent.ID); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		for _, id := range []uint{parent.ID, child.ID} {
+			if _, err := r.todos.FindByID(ctx, id); !errors.Is(err, gorm.ErrRecordNotFound) {
+				t.Errorf("FindByID after Delete = %v, want gorm.ErrRecordNotFound", err)
+			}
+		}
+	})
+}
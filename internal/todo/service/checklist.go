@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/internal/todo/repository"
+	"khiemle.dev/golang-api-template/pkg/apperror"
+)
+
+var ErrChecklistItemNotFound = apperror.NotFound(apperror.CodeChecklistItemNotFound, "checklist item not found")
+
+// ChecklistService manages the checklist items of todos. Items follow the
+// access rules of their todo: readers see them with the todo, editors change
+// them.
+type ChecklistService interface {
+	// AddItem appends an item to the checklist of the todo.
+	AddItem(ctx context.Context, userID uint, todoID int, text string) (*model.ChecklistItem, error)
+	UpdateItem(ctx context.Context, userID uint, todoID int, id int, text string, done *bool) (*model.ChecklistItem, error)
+	DeleteItem(ctx context.Context, userID uint, todoID int, id int) error
+}
+
+type checklistService struct {
+	checklistItemRepository repository.ChecklistItemRepository
+	todos                   todoAccess
+}
+
+func NewChecklistService(
+	checklistItemRepository repository.ChecklistItemRepository,
+	todoRepository repository.TodoRepository,
+	todoListRepository repository.TodoListRepository,
+	listMemberRepository repository.ListMemberRepository,
+) ChecklistService {
+	return &checklistService{
+		checklistItemRepository: checklistItemRepository,
+		todos: todoAccess{
+			todoRepository: todoRepository,
+			lists: listAccess{
+				todoListRepository:   todoListRepository,
+				listMemberRepository: listMemberRepository,
+			},
+		},
+	}
+}
+
+func (s *checklistService) AddItem(ctx context.Context, userID uint, todoID int, text string) (*model.ChecklistItem, error) {
+	if _, err := s.todos.load(ctx, userID, uint(todoID), model.ListRoleEditor); err != nil {
+		return nil, err
+	}
+
+	items, err := s.checklistItemRepository.List(ctx, uint(todoID))
+	if err != nil {
+		return nil, err
+	}
+	item := model.ChecklistItem{
+		TodoID: uint(todoID),
+		Text:   text,
+	}
+	if len(items) > 0 {
+		item.Position = items[len(items)-1].Position + 1
+	}
+
+	if err := s.checklistItemRepository.Create(ctx, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (s *checklistService) UpdateItem(ctx context.Context, userID uint, todoID int, id int, text string, done *bool) (*model.ChecklistItem, error) {
+	if _, err := s.todos.load(ctx, userID, uint(todoID), model.ListRoleEditor); err != nil {
+		return nil, err
+	}
+
+	item, err := s.checklistItemRepository.Update(ctx, uint(todoID), uint(id), func(item *model.ChecklistItem) error {
+		if text != "" {
+			item.Text = text
+		}
+		if done != nil {
+			item.Done = *done
+		}
+		return nil
+	})
+	return item, translateChecklistItemError(err)
+}
+
+func (s *checklistService) DeleteItem(ctx context.Context, userID uint, todoID int, id int) error {
+	if _, err := s.todos.load(ctx, userID, uint(todoID), model.ListRoleEditor); err != nil {
+		return err
+	}
+	return translateChecklistItemError(s.checklistItemRepository.Delete(ctx, uint(todoID), uint(id)))
+}
+
+// translateChecklistItemError turns repository errors into domain errors
+func translateChecklistItemError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrChecklistItemNotFound
+	}
+	return err
+}
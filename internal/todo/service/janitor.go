@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/metric"
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/internal/todo/repository"
+	"khiemle.dev/golang-api-template/pkg/metrics"
+	"khiemle.dev/golang-api-template/pkg/outbox"
+	"khiemle.dev/golang-api-template/pkg/pubsub"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+// expiredTrashBatchSize caps how many trashed todos are listed at once
+const expiredTrashBatchSize = 100
+
+// TrashJanitor deletes todos for good once they spent the retention window in
+// the trash, recording their todo.purged events as when users purge them. It
+// is run periodically by the scheduler.
+type TrashJanitor struct {
+	todoRepository repository.TodoRepository
+	transactor     txn.Transactor
+	trash          trash
+	retention      time.Duration
+	purged         metric.Int64Counter
+}
+
+func NewTrashJanitor(
+	todoRepository repository.TodoRepository,
+	todoListRepository repository.TodoListRepository,
+	listMemberRepository repository.ListMemberRepository,
+	todoEventRepository repository.TodoEventRepository,
+	ps pubsub.PubSub,
+	transactor txn.Transactor,
+	recorder outbox.Recorder,
+	retention time.Duration,
+) (*TrashJanitor, error) {
+	purged, err := metrics.Meter().Int64Counter(
+		"todos.trash.purged",
+		metric.WithDescription("Number of trashed todos deleted by the janitor"),
+		metric.WithUnit("{todo}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TrashJanitor{
+		todoRepository: todoRepository,
+		transactor:     transactor,
+		trash: trash{
+			todoRepository: todoRepository,
+			events: todoEvents{
+				todoEventRepository: todoEventRepository,
+				lists: listAccess{
+					todoListRepository:   todoListRepository,
+					listMemberRepository: listMemberRepository,
+				},
+				pubsub: ps,
+				outbox: recorder,
+			},
+		},
+		retention: retention,
+		purged:    purged,
+	}, nil
+}
+
+// Run purges todos trashed before the retention window, each in its own
+// transaction.
+func (j *TrashJanitor) Run(ctx context.Context) error {
+	before := time.Now().Add(-j.retention)
+
+	// A failing todo must not hold back the others
+	var purged int64
+	var errs []error
+	for {
+		expired, err := j.todoRepository.ListExpiredTrash(ctx, before, expiredTrashBatchSize)
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+		for i := range expired {
+			todos, err := txn.Run(ctx, j.transactor, func(ctx context.Context) ([]model.Todo, error) {
+				// Restored or purged in the meantime
+				todo, err := j.todoRepository.FindTrashed(ctx, expired[i].ID)
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, nil
+				}
+				if err != nil {
+					return nil, err
+				}
+				if !todo.DeletedAt.Time.Before(before) {
+					// Restored and trashed again
+					return nil, nil
+				}
+				return j.trash.purge(ctx, todo)
+			})
+			if err != nil {
+				errs = append(errs, fmt.Errorf("todo %d: %w", expired[i].ID, err))
+				continue
+			}
+			purged += int64(len(todos))
+		}
+		// Failed todos would be listed again
+		if len(expired) < expiredTrashBatchSize || len(errs) > 0 {
+			break
+		}
+	}
+	j.purged.Add(ctx, purged)
+
+	event := log.Debug()
+	if purged > 0 {
+		event = log.Info()
+	}
+	event.Int64("purged", purged).Msg("Purged trashed todos")
+	return errors.Join(errs...)
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/internal/todo/repository"
+	"khiemle.dev/golang-api-template/pkg/apperror"
+)
+
+// DefaultLabelColor is used for labels created without a colour
+const DefaultLabelColor = "#9e9e9e"
+
+var (
+	ErrLabelNotFound  = apperror.NotFound(apperror.CodeLabelNotFound, "label not found")
+	ErrLabelNameTaken = apperror.Conflict(apperror.CodeLabelNameTaken, "label name is already used")
+)
+
+// LabelService manages the labels of an owner. Labels of other owners are
+// reported as not found.
+type LabelService interface {
+	CreateLabel(ctx context.Context, ownerID uint, name string, color string) (*model.Label, error)
+	GetLabel(ctx context.Context, ownerID uint, id int) (*model.Label, error)
+	ListLabels(ctx context.Context, ownerID uint) ([]model.Label, error)
+	UpdateLabel(ctx context.Context, ownerID uint, id int, name string, color string) (*model.Label, error)
+	DeleteLabel(ctx context.Context, ownerID uint, id int) error
+}
+
+type labelService struct {
+	labelRepository repository.LabelRepository
+}
+
+func NewLabelService(labelRepository repository.LabelRepository) LabelService {
+	return &labelService{
+		labelRepository: labelRepository,
+	}
+}
+
+func (s *labelService) CreateLabel(ctx context.Context, ownerID uint, name string, color string) (*model.Label, error) {
+	if color == "" {
+		color = DefaultLabelColor
+	}
+	label := model.Label{
+		OwnerID: ownerID,
+		Name:    name,
+		Color:   color,
+	}
+
+	if err := s.labelRepository.Create(ctx, &label); err != nil {
+		return nil, translateLabelError(err)
+	}
+	return &label, nil
+}
+
+func (s *labelService) GetLabel(ctx context.Context, ownerID uint, id int) (*model.Label, error) {
+	label, err := s.labelRepository.FindByID(ctx, ownerID, uint(id))
+	return label, translateLabelError(err)
+}
+
+func (s *labelService) ListLabels(ctx context.Context, ownerID uint) ([]model.Label, error) {
+	return s.labelRepository.List(ctx, ownerID)
+}
+
+func (s *labelService) UpdateLabel(ctx context.Context, ownerID uint, id int, name string, color string) (*model.Label, error) {
+	label, err := s.labelRepository.Update(ctx, ownerID, uint(id), func(label *model.Label) error {
+		if name != "" {
+			label.Name = name
+		}
+		if color != "" {
+			label.Color = color
+		}
+		return nil
+	})
+	return label, translateLabelError(err)
+}
+
+func (s *labelService) DeleteLabel(ctx context.Context, ownerID uint, id int) error {
+	return translateLabelError(s.labelRepository.Delete(ctx, ownerID, uint(id)))
+}
+
+// translateLabelError turns repository errors into domain errors
+func translateLabelError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrLabelNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrLabelNameTaken
+	}
+	return err
+}
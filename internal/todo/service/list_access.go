@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/internal/todo/repository"
+	"khiemle.dev/golang-api-template/pkg/apperror"
+)
+
+var ErrTodoListForbidden = apperror.New(apperror.CodeTodoListForbidden, http.StatusForbidden, "your role on this todo list does not allow this")
+
+// listAccess resolves the role of users on todo lists. Users without a role
+// are told the list does not exist.
+type listAccess struct {
+	todoListRepository   repository.TodoListRepository
+	listMemberRepository repository.ListMemberRepository
+}
+
+// load returns the list with Role set to the role of the user on it.
+func (a listAccess) load(ctx context.Context, userID uint, listID uint) (*model.TodoList, error) {
+	list, err := a.todoListRepository.FindByID(ctx, listID)
+	return a.withRole(ctx, userID, list, err)
+}
+
+// lock is load locking the list until the transaction of ctx ends
+func (a listAccess) lock(ctx context.Context, userID uint, listID uint) (*model.TodoList, error) {
+	list, err := a.todoListRepository.FindForUpdate(ctx, listID)
+	return a.withRole(ctx, userID, list, err)
+}
+
+// withRole sets the role of the user on the list found, or translates the
+// error finding it
+func (a listAccess) withRole(ctx context.Context, userID uint, list *model.TodoList, err error) (*model.TodoList, error) {
+	if err != nil {
+		return nil, translateTodoListError(err)
+	}
+	if list.OwnerID == userID {
+		list.Role = model.ListRoleOwner
+		return list, nil
+	}
+
+	member, err := a.listMemberRepository.Find(ctx, list.ID, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTodoListNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	list.Role = member.Role
+	return list, nil
+}
+
+// require loads the list like load and checks the user has at least role.
+func (a listAccess) require(ctx context.Context, userID uint, listID uint, role model.ListRole) (*model.TodoList, error) {
+	list, err := a.load(ctx, userID, listID)
+	if err != nil {
+		return nil, err
+	}
+	if !list.Role.AtLeast(role) {
+		return nil, ErrTodoListForbidden
+	}
+	return list, nil
+}
+
+// setRoles sets the role of the user on lists they loaded
+func (a listAccess) setRoles(ctx context.Context, userID uint, lists []model.TodoList) error {
+	members, err := a.listMemberRepository.ListByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	roles := map[uint]model.ListRole{}
+	for _, member := range members {
+		roles[member.ListID] = member.Role
+	}
+
+	for i := range lists {
+		if lists[i].OwnerID == userID {
+			lists[i].Role = model.ListRoleOwner
+		} else {
+			lists[i].Role = roles[lists[i].ID]
+		}
+	}
+	return nil
+}
+
+// todoAccess checks what users may do on todos. Owners of todos outside of
+// lists may do everything, todos in a list follow the role of the user on
+// the list. Users who cannot read a todo are told it does not exist.
+type todoAccess struct {
+	todoRepository repository.TodoRepository
+	lists          listAccess
+}
+
+// load returns the todo if the user has at least role on it.
+func (a todoAccess) load(ctx context.Context, userID uint, id uint, role model.ListRole) (*model.Todo, error) {
+	todo, err := a.todoRepository.FindByID(ctx, id)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	if err := a.require(ctx, userID, todo, role); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// loadTrashed returns the todo of the trash if the user has at least role on
+// it.
+func (a todoAccess) loadTrashed(ctx context.Context, userID uint, id uint, role model.ListRole) (*model.Todo, error) {
+	todo, err := a.todoRepository.FindTrashed(ctx, id)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	if err := a.require(ctx, userID, todo, role); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// require makes sure the user has at least role on the todo
+func (a todoAccess) require(ctx context.Context, userID uint, todo *model.Todo, role model.ListRole) error {
+	if todo.ListID == nil {
+		if todo.OwnerID != userID {
+			return ErrTodoNotFound
+		}
+		return nil
+	}
+
+	_, err := a.lists.require(ctx, userID, *todo.ListID, role)
+	if errors.Is(err, ErrTodoListNotFound) {
+		return ErrTodoNotFound
+	}
+	return err
+}
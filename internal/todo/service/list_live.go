@@ -0,0 +1,362 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/internal/todo/repository"
+	_userModel "khiemle.dev/golang-api-template/internal/user/model"
+	_userService "khiemle.dev/golang-api-template/internal/user/service"
+	"khiemle.dev/golang-api-template/pkg/pubsub"
+)
+
+// ListPresenceChannel is the pubsub channel on which sessions announce the
+// list they view, so viewers connected to other replicas show up too.
+const ListPresenceChannel = "list_presence"
+
+// presenceAnnouncement is published when a session opens, on every refresh
+// and when it closes.
+type presenceAnnouncement struct {
+	ListID    uint   `json:"list_id"`
+	UserID    uint   `json:"user_id"`
+	SessionID string `json:"session_id"`
+	Gone      bool   `json:"gone"`
+}
+
+// ListChange is a change of the todos of a list as seen by its viewers. Todos
+// moved into the list are created and todos moved out of it are deleted. Todo
+// is nil for deletions.
+type ListChange struct {
+	Type   string
+	TodoID uint
+	Todo   *model.Todo
+}
+
+// ListSession follows a list on behalf of a user: changes of its todos and
+// who else views it.
+type ListSession struct {
+	// List has Role set to the role of the user on it and Todos holds its
+	// todos when the session opened.
+	List  *model.TodoList
+	Todos []model.Todo
+
+	id       string
+	userID   uint
+	service  *listLiveService
+	stream   *TodoStream
+	todoIDs  map[uint]bool
+	presence chan struct{}
+}
+
+// Wake is signalled when the todos of the list may have changed.
+func (s *ListSession) Wake() <-chan struct{} {
+	return s.stream.Wake()
+}
+
+// Next returns the changes of the todos of the list since the previous call,
+// oldest first.
+func (s *ListSession) Next(ctx context.Context) ([]ListChange, error) {
+	events, err := s.stream.Next(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []ListChange{}
+	for _, event := range events {
+		known := s.todoIDs[event.TodoID]
+		if event.Type == model.TodoEventDeleted {
+			if known {
+				delete(s.todoIDs, event.TodoID)
+				changes = append(changes, ListChange{Type: model.TodoEventDeleted, TodoID: event.TodoID})
+			}
+			continue
+		}
+
+		todo := model.Todo{}
+		if err := json.Unmarshal([]byte(event.Data), &todo); err != nil {
+			return nil, err
+		}
+		switch {
+		case todo.ListID != nil && *todo.ListID == s.List.ID:
+			changeType := event.Type
+			if !known {
+				changeType = model.TodoEventCreated
+			}
+			s.todoIDs[todo.ID] = true
+			changes = append(changes, ListChange{Type: changeType, TodoID: todo.ID, Todo: &todo})
+		case known:
+			// Moved to another list
+			delete(s.todoIDs, todo.ID)
+			changes = append(changes, ListChange{Type: model.TodoEventDeleted, TodoID: todo.ID})
+		}
+	}
+	return changes, nil
+}
+
+// PresenceChanged is signalled when users start or stop viewing the list.
+func (s *ListSession) PresenceChanged() <-chan struct{} {
+	return s.presence
+}
+
+// Viewers returns the users viewing the list, the user of the session
+// included, by id.
+func (s *ListSession) Viewers(ctx context.Context) ([]_userModel.User, error) {
+	return s.service.listViewers(ctx, s.List.ID)
+}
+
+// Refresh tells other sessions the user still views the list, forgets
+// viewers that stopped doing so and updates the role of the user. It returns
+// ErrTodoListNotFound once the user may no longer read the list.
+func (s *ListSession) Refresh(ctx context.Context) error {
+	list, err := s.service.lists.load(ctx, s.userID, s.List.ID)
+	if err != nil {
+		return err
+	}
+	s.List.Role = list.Role
+
+	s.service.prune(s.List.ID, time.Now())
+	s.service.announce(ctx, s, false)
+	return nil
+}
+
+// Close stops following the list and tells other sessions the user left.
+func (s *ListSession) Close(ctx context.Context) {
+	s.stream.Close()
+	s.service.leave(s)
+	s.service.announce(ctx, s, true)
+}
+
+func (s *ListSession) signalPresence() {
+	select {
+	case s.presence <- struct{}{}:
+	default:
+	}
+}
+
+// ListLiveService lets users follow a list live, e.g. to edit it together.
+type ListLiveService interface {
+	// Join opens a session on the list if the user may read it. Sessions
+	// must be refreshed more often than the heartbeat given to
+	// NewListLiveService, or their user stops being listed as a viewer.
+	Join(ctx context.Context, userID uint, listID int) (*ListSession, error)
+}
+
+// viewer is a session viewing a list, on any replica
+type viewer struct {
+	userID uint
+	seen   time.Time
+}
+
+type listLiveService struct {
+	todoRepository   repository.TodoRepository
+	lists            listAccess
+	todoEventService TodoEventService
+	userService      _userService.UserService
+	pubsub           pubsub.PubSub
+	// ttl is how long viewers are listed without announcing themselves again
+	ttl time.Duration
+
+	mu sync.Mutex
+	// viewers are the sessions viewing each list by session id
+	viewers map[uint]map[string]viewer
+	// sessions are the sessions of this replica on each list
+	sessions map[uint]map[*ListSession]struct{}
+}
+
+// NewListLiveService creates the service. Viewers that have not refreshed
+// their session for three heartbeats, e.g. because their replica stopped, are
+// no longer listed.
+func NewListLiveService(
+	todoRepository repository.TodoRepository,
+	todoListRepository repository.TodoListRepository,
+	listMemberRepository repository.ListMemberRepository,
+	todoEventService TodoEventService,
+	userService _userService.UserService,
+	ps pubsub.PubSub,
+	heartbeat time.Duration,
+) ListLiveService {
+	s := &listLiveService{
+		todoRepository: todoRepository,
+		lists: listAccess{
+			todoListRepository:   todoListRepository,
+			listMemberRepository: listMemberRepository,
+		},
+		todoEventService: todoEventService,
+		userService:      userService,
+		pubsub:           ps,
+		ttl:              3 * heartbeat,
+		viewers:          map[uint]map[string]viewer{},
+		sessions:         map[uint]map[*ListSession]struct{}{},
+	}
+	ps.Subscribe(ListPresenceChannel, s.receive)
+	return s
+}
+
+func (s *listLiveService) Join(ctx context.Context, userID uint, listID int) (*ListSession, error) {
+	list, err := s.lists.load(ctx, userID, uint(listID))
+	if err != nil {
+		return nil, err
+	}
+
+	// Follow events before loading the todos so no change is missed in
+	// between, changes already in the snapshot are sent again
+	stream, err := s.todoEventService.Subscribe(ctx, userID, nil)
+	if err != nil {
+		return nil, err
+	}
+	todos, err := s.todoRepository.List(ctx, repository.ListTodoFilter{ListIDs: []uint{list.ID}})
+	if err != nil {
+		stream.Close()
+		return nil, err
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		stream.Close()
+		return nil, err
+	}
+	session := &ListSession{
+		List:     list,
+		Todos:    withLabelsOfAll(userID, todos),
+		id:       hex.EncodeToString(id),
+		userID:   userID,
+		service:  s,
+		stream:   stream,
+		todoIDs:  map[uint]bool{},
+		presence: make(chan struct{}, 1),
+	}
+	for _, todo := range todos {
+		session.todoIDs[todo.ID] = true
+	}
+
+	s.mu.Lock()
+	if s.sessions[list.ID] == nil {
+		s.sessions[list.ID] = map[*ListSession]struct{}{}
+	}
+	s.sessions[list.ID][session] = struct{}{}
+	s.mu.Unlock()
+
+	// Send the viewers once even if they did not change
+	session.signalPresence()
+	s.announce(ctx, session, false)
+	return session, nil
+}
+
+// announce publishes that the session views the list, or left it
+func (s *listLiveService) announce(ctx context.Context, session *ListSession, gone bool) {
+	payload, _ := json.Marshal(presenceAnnouncement{
+		ListID:    session.List.ID,
+		UserID:    session.userID,
+		SessionID: session.id,
+		Gone:      gone,
+	})
+	if err := s.pubsub.Publish(ctx, ListPresenceChannel, string(payload)); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Could not announce list presence")
+	}
+}
+
+// receive records an announcement of a session on any replica
+func (s *listLiveService) receive(payload string) {
+	announcement := presenceAnnouncement{}
+	if err := json.Unmarshal([]byte(payload), &announcement); err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	listID := announcement.ListID
+	before := s.userIDs(listID)
+	if announcement.Gone {
+		delete(s.viewers[listID], announcement.SessionID)
+		if len(s.viewers[listID]) == 0 {
+			delete(s.viewers, listID)
+		}
+	} else {
+		if s.viewers[listID] == nil {
+			s.viewers[listID] = map[string]viewer{}
+		}
+		s.viewers[listID][announcement.SessionID] = viewer{userID: announcement.UserID, seen: time.Now()}
+	}
+	s.presenceChanged(listID, before)
+}
+
+// prune forgets the viewers of the list that stopped announcing themselves
+func (s *listLiveService) prune(listID uint, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before := s.userIDs(listID)
+	for id, viewer := range s.viewers[listID] {
+		if now.Sub(viewer.seen) > s.ttl {
+			delete(s.viewers[listID], id)
+		}
+	}
+	if len(s.viewers[listID]) == 0 {
+		delete(s.viewers, listID)
+	}
+	s.presenceChanged(listID, before)
+}
+
+// leave forgets the session of this replica
+func (s *listLiveService) leave(session *ListSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	listID := session.List.ID
+	delete(s.sessions[listID], session)
+	if len(s.sessions[listID]) == 0 {
+		delete(s.sessions, listID)
+	}
+}
+
+// presenceChanged signals the sessions of this replica on the list when its
+// viewers are no longer before. The caller holds mu.
+func (s *listLiveService) presenceChanged(listID uint, before []uint) {
+	if slices.Equal(before, s.userIDs(listID)) {
+		return
+	}
+	for session := range s.sessions[listID] {
+		session.signalPresence()
+	}
+}
+
+// userIDs returns the ids of the users viewing the list, sorted. The caller
+// holds mu.
+func (s *listLiveService) userIDs(listID uint) []uint {
+	userIDs := []uint{}
+	for _, viewer := range s.viewers[listID] {
+		if !slices.Contains(userIDs, viewer.userID) {
+			userIDs = append(userIDs, viewer.userID)
+		}
+	}
+	slices.Sort(userIDs)
+	return userIDs
+}
+
+func (s *listLiveService) listViewers(ctx context.Context, listID uint) ([]_userModel.User, error) {
+	s.mu.Lock()
+	userIDs := s.userIDs(listID)
+	s.mu.Unlock()
+
+	users := make([]_userModel.User, 0, len(userIDs))
+	for _, userID := range userIDs {
+		user, err := s.userService.GetUserById(ctx, userID)
+		if errors.Is(err, _userService.ErrUserNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+	return users, nil
+}
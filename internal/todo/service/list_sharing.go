@@ -0,0 +1,357 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/internal/todo/repository"
+	_userModel "khiemle.dev/golang-api-template/internal/user/model"
+	_userService "khiemle.dev/golang-api-template/internal/user/service"
+	"khiemle.dev/golang-api-template/pkg/apperror"
+	"khiemle.dev/golang-api-template/pkg/jobqueue"
+)
+
+var (
+	ErrListMemberNotFound = apperror.NotFound(apperror.CodeListMemberNotFound, "list member not found")
+	ErrListCreator        = apperror.Conflict(apperror.CodeListCreator, "the creator of a list always stays its owner")
+	ErrAlreadyListMember  = apperror.Conflict(apperror.CodeAlreadyListMember, "user is already a member of the list")
+	ErrInvitationNotFound = apperror.NotFound(apperror.CodeInvitationNotFound, "invitation not found")
+	ErrInvitationExists   = apperror.Conflict(apperror.CodeInvitationExists, "user is already invited to the list")
+	ErrInvitationAnswered = apperror.Conflict(apperror.CodeInvitationAnswered, "invitation is no longer pending")
+)
+
+// ListCollaborator is a user with a role on a list.
+type ListCollaborator struct {
+	User *_userModel.User
+	Role model.ListRole
+	// Creator is set for the user who created the list
+	Creator  bool
+	JoinedAt time.Time
+}
+
+// ListSharingService shares todo lists. Owners invite users, who are notified
+// and become members once they accept, and manage the roles of members. Members may
+// leave a list, except its creator.
+type ListSharingService interface {
+	// Invite invites the user with the given username or email. Nobody is
+	// invited when no user has them, without telling the caller, so lists
+	// cannot be used to find out who has an account.
+	Invite(ctx context.Context, userID uint, listID int, invitee string, role model.ListRole) error
+	// ListInvitations returns the pending invitations to the list.
+	ListInvitations(ctx context.Context, userID uint, listID int) ([]model.ListInvitation, error)
+	RevokeInvitation(ctx context.Context, userID uint, listID int, invitationID int) error
+	// ListReceivedInvitations returns the pending invitations of the user.
+	ListReceivedInvitations(ctx context.Context, userID uint) ([]model.ListInvitation, error)
+	AcceptInvitation(ctx context.Context, userID uint, invitationID int) (*model.TodoList, error)
+	DeclineInvitation(ctx context.Context, userID uint, invitationID int) error
+	// ListMembers returns the creator of the list followed by its members.
+	ListMembers(ctx context.Context, userID uint, listID int) ([]ListCollaborator, error)
+	UpdateMember(ctx context.Context, userID uint, listID int, memberID int, role model.ListRole) (*ListCollaborator, error)
+	// RemoveMember removes a member from the list. Removing oneself leaves
+	// the list.
+	RemoveMember(ctx context.Context, userID uint, listID int, memberID int) error
+	LeaveList(ctx context.Context, userID uint, listID int) error
+}
+
+type listSharingService struct {
+	listMemberRepository     repository.ListMemberRepository
+	listInvitationRepository repository.ListInvitationRepository
+	userService              _userService.UserService
+	jobs                     jobqueue.Client
+	access                   listAccess
+}
+
+func NewListSharingService(
+	todoListRepository repository.TodoListRepository,
+	listMemberRepository repository.ListMemberRepository,
+	listInvitationRepository repository.ListInvitationRepository,
+	userService _userService.UserService,
+	jobs jobqueue.Client,
+) ListSharingService {
+	return &listSharingService{
+		listMemberRepository:     listMemberRepository,
+		listInvitationRepository: listInvitationRepository,
+		userService:              userService,
+		jobs:                     jobs,
+		access: listAccess{
+			todoListRepository:   todoListRepository,
+			listMemberRepository: listMemberRepository,
+		},
+	}
+}
+
+func (s *listSharingService) Invite(ctx context.Context, userID uint, listID int, invitee string, role model.ListRole) error {
+	list, err := s.access.require(ctx, userID, uint(listID), model.ListRoleOwner)
+	if err != nil {
+		return err
+	}
+
+	var user *_userModel.User
+	if strings.Contains(invitee, "@") {
+		user, err = s.userService.GetUserByEmail(ctx, invitee)
+	} else {
+		user, err = s.userService.GetUserByUsername(ctx, invitee)
+	}
+	if errors.Is(err, _userService.ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if user.ID == list.OwnerID {
+		return ErrAlreadyListMember
+	}
+	if _, err := s.listMemberRepository.Find(ctx, list.ID, user.ID); err == nil {
+		return ErrAlreadyListMember
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	pending, err := s.listInvitationRepository.List(ctx, repository.ListInvitationFilter{
+		ListID:    list.ID,
+		InviteeID: user.ID,
+		Status:    model.InvitationPending,
+	})
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return ErrInvitationExists
+	}
+
+	invitation := model.ListInvitation{
+		ListID:    list.ID,
+		InviterID: userID,
+		InviteeID: user.ID,
+		Role:      role,
+		Status:    model.InvitationPending,
+	}
+	if err := s.listInvitationRepository.Create(ctx, &invitation); err != nil {
+		return err
+	}
+
+	_, err = s.jobs.Enqueue(ctx, JobNotifyListActivity, ListActivity{
+		Kind:       ListActivityInvited,
+		ListID:     list.ID,
+		ActorID:    userID,
+		InviteeID:  user.ID,
+		Role:       role,
+		OccurredAt: invitation.CreatedAt,
+	})
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Could not enqueue invitation notification")
+	}
+	return nil
+}
+
+func (s *listSharingService) ListInvitations(ctx context.Context, userID uint, listID int) ([]model.ListInvitation, error) {
+	if _, err := s.access.require(ctx, userID, uint(listID), model.ListRoleOwner); err != nil {
+		return nil, err
+	}
+	return s.listInvitationRepository.List(ctx, repository.ListInvitationFilter{
+		ListID: uint(listID),
+		Status: model.InvitationPending,
+	})
+}
+
+func (s *listSharingService) RevokeInvitation(ctx context.Context, userID uint, listID int, invitationID int) error {
+	if _, err := s.access.require(ctx, userID, uint(listID), model.ListRoleOwner); err != nil {
+		return err
+	}
+	return s.answer(ctx, uint(invitationID), model.InvitationRevoked, func(invitation *model.ListInvitation) bool {
+		return invitation.ListID == uint(listID)
+	})
+}
+
+func (s *listSharingService) ListReceivedInvitations(ctx context.Context, userID uint) ([]model.ListInvitation, error) {
+	return s.listInvitationRepository.List(ctx, repository.ListInvitationFilter{
+		InviteeID: userID,
+		Status:    model.InvitationPending,
+	})
+}
+
+func (s *listSharingService) AcceptInvitation(ctx context.Context, userID uint, invitationID int) (*model.TodoList, error) {
+	invitation, err := s.received(ctx, userID, uint(invitationID))
+	if err != nil {
+		return nil, err
+	}
+
+	// Join before marking the invitation accepted, so accepting again after
+	// a failure only repeats the join
+	member := model.ListMember{
+		ListID: invitation.ListID,
+		UserID: userID,
+		Role:   invitation.Role,
+	}
+	if err := s.listMemberRepository.Save(ctx, &member); err != nil {
+		return nil, err
+	}
+
+	err = s.answer(ctx, invitation.ID, model.InvitationAccepted, func(*model.ListInvitation) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+	return s.access.load(ctx, userID, invitation.ListID)
+}
+
+func (s *listSharingService) DeclineInvitation(ctx context.Context, userID uint, invitationID int) error {
+	invitation, err := s.received(ctx, userID, uint(invitationID))
+	if err != nil {
+		return err
+	}
+	return s.answer(ctx, invitation.ID, model.InvitationDeclined, func(*model.ListInvitation) bool { return true })
+}
+
+func (s *listSharingService) ListMembers(ctx context.Context, userID uint, listID int) ([]ListCollaborator, error) {
+	list, err := s.access.load(ctx, userID, uint(listID))
+	if err != nil {
+		return nil, err
+	}
+
+	creator, err := s.userService.GetUserById(ctx, list.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+	collaborators := []ListCollaborator{{
+		User:     creator,
+		Role:     model.ListRoleOwner,
+		Creator:  true,
+		JoinedAt: list.CreatedAt,
+	}}
+
+	members, err := s.listMemberRepository.List(ctx, list.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		collaborator, err := s.collaborator(ctx, &member)
+		if errors.Is(err, _userService.ErrUserNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		collaborators = append(collaborators, *collaborator)
+	}
+	return collaborators, nil
+}
+
+func (s *listSharingService) UpdateMember(ctx context.Context, userID uint, listID int, memberID int, role model.ListRole) (*ListCollaborator, error) {
+	list, err := s.access.require(ctx, userID, uint(listID), model.ListRoleOwner)
+	if err != nil {
+		return nil, err
+	}
+	if uint(memberID) == list.OwnerID {
+		return nil, ErrListCreator
+	}
+
+	member, err := s.listMemberRepository.Find(ctx, list.ID, uint(memberID))
+	if err != nil {
+		return nil, translateListMemberError(err)
+	}
+	member.Role = role
+	if err := s.listMemberRepository.Save(ctx, member); err != nil {
+		return nil, err
+	}
+	return s.collaborator(ctx, member)
+}
+
+func (s *listSharingService) RemoveMember(ctx context.Context, userID uint, listID int, memberID int) error {
+	if uint(memberID) == userID {
+		return s.LeaveList(ctx, userID, listID)
+	}
+
+	list, err := s.access.require(ctx, userID, uint(listID), model.ListRoleOwner)
+	if err != nil {
+		return err
+	}
+	if uint(memberID) == list.OwnerID {
+		return ErrListCreator
+	}
+	return translateListMemberError(s.listMemberRepository.Delete(ctx, list.ID, uint(memberID)))
+}
+
+func (s *listSharingService) LeaveList(ctx context.Context, userID uint, listID int) error {
+	list, err := s.access.load(ctx, userID, uint(listID))
+	if err != nil {
+		return err
+	}
+	if list.OwnerID == userID {
+		return ErrListCreator
+	}
+	return translateListMemberError(s.listMemberRepository.Delete(ctx, list.ID, userID))
+}
+
+// received returns a pending invitation sent to the user for a list that
+// still exists
+func (s *listSharingService) received(ctx context.Context, userID uint, invitationID uint) (*model.ListInvitation, error) {
+	invitation, err := s.listInvitationRepository.FindByID(ctx, invitationID)
+	if err != nil {
+		return nil, translateInvitationError(err)
+	}
+	if invitation.InviteeID != userID {
+		return nil, ErrInvitationNotFound
+	}
+	if invitation.Status != model.InvitationPending {
+		return nil, ErrInvitationAnswered
+	}
+	if _, err := s.access.todoListRepository.FindByID(ctx, invitation.ListID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvitationNotFound
+		}
+		return nil, err
+	}
+	return invitation, nil
+}
+
+// answer sets the status of a pending invitation. Invitations rejected by
+// belongs are reported as not found.
+func (s *listSharingService) answer(ctx context.Context, invitationID uint, status string, belongs func(*model.ListInvitation) bool) error {
+	_, err := s.listInvitationRepository.Update(ctx, invitationID, func(invitation *model.ListInvitation) error {
+		if !belongs(invitation) {
+			return ErrInvitationNotFound
+		}
+		if invitation.Status != model.InvitationPending {
+			return ErrInvitationAnswered
+		}
+		now := time.Now()
+		invitation.Status = status
+		invitation.RespondedAt = &now
+		return nil
+	})
+	return translateInvitationError(err)
+}
+
+func (s *listSharingService) collaborator(ctx context.Context, member *model.ListMember) (*ListCollaborator, error) {
+	user, err := s.userService.GetUserById(ctx, member.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return &ListCollaborator{
+		User:     user,
+		Role:     member.Role,
+		JoinedAt: member.CreatedAt,
+	}, nil
+}
+
+// translateListMemberError turns repository errors into domain errors
+func translateListMemberError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrListMemberNotFound
+	}
+	return err
+}
+
+// translateInvitationError turns repository errors into domain errors
+func translateInvitationError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrInvitationNotFound
+	}
+	return err
+}
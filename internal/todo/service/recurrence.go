@@ -0,0 +1,404 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/teambition/rrule-go"
+	"go.opentelemetry.io/otel/metric"
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/internal/todo/repository"
+	"khiemle.dev/golang-api-template/pkg/apperror"
+	"khiemle.dev/golang-api-template/pkg/metrics"
+	"khiemle.dev/golang-api-template/pkg/outbox"
+	"khiemle.dev/golang-api-template/pkg/pubsub"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+// DefaultTimezone is used to expand recurrence rules given without a timezone
+const DefaultTimezone = "UTC"
+
+// dueSeriesBatchSize caps how many series a generator run handles
+const dueSeriesBatchSize = 100
+
+var (
+	ErrInvalidRecurrence = apperror.Validation(apperror.FieldError{
+		Field:   "recurrence",
+		Code:    "rrule",
+		Message: "must be an RFC 5545 RRULE repeating at most hourly, without DTSTART",
+	})
+	ErrInvalidTimezone = apperror.Validation(apperror.FieldError{
+		Field:   "timezone",
+		Code:    "timezone",
+		Message: "must be an IANA time zone such as Europe/Paris",
+	})
+	ErrRecurrenceDueAt = apperror.Validation(apperror.FieldError{
+		Field:   "due_at",
+		Code:    "required_with",
+		Message: "is required for recurring todos",
+	})
+	ErrRecurringSubtask = apperror.Conflict(apperror.CodeRecurringSubtask, "subtasks cannot repeat")
+)
+
+// recurrence creates the occurrences of recurring todos. The next occurrence
+// copies the list, owner, labels, checklist and reminders of the latest one,
+// with its name and description taken from the series.
+type recurrence struct {
+	todoRepository          repository.TodoRepository
+	todoSeriesRepository    repository.TodoSeriesRepository
+	checklistItemRepository repository.ChecklistItemRepository
+	reminderRepository      repository.ReminderRepository
+	transactor              txn.Transactor
+	events                  todoEvents
+}
+
+// start creates a series repeating the todo, whose due date is the first
+// occurrence.
+func (r recurrence) start(ctx context.Context, todo *model.Todo, rule string, timezone string) (*model.TodoSeries, error) {
+	if todo.ParentID != nil {
+		return nil, ErrRecurringSubtask
+	}
+	if todo.DueAt == nil {
+		return nil, ErrRecurrenceDueAt
+	}
+	if timezone == "" {
+		timezone = DefaultTimezone
+	}
+	rule, parsed, err := parseRule(rule, timezone, *todo.DueAt)
+	if err != nil {
+		return nil, err
+	}
+
+	series := model.TodoSeries{
+		Name:        todo.Name,
+		Description: todo.Description,
+		RRule:       rule,
+		Timezone:    timezone,
+		StartAt:     *todo.DueAt,
+		LastDueAt:   *todo.DueAt,
+		NextDueAt:   nextOccurrence(parsed, *todo.DueAt),
+	}
+	if err := r.todoSeriesRepository.Create(ctx, &series); err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+// change replaces the rule of the series, which starts over from its latest
+// occurrence. An empty rule stops the series.
+func (r recurrence) change(ctx context.Context, seriesID uint, rule string, timezone string) error {
+	_, err := r.todoSeriesRepository.Update(ctx, seriesID, func(series *model.TodoSeries) error {
+		if rule == "" {
+			series.RRule = ""
+			series.NextDueAt = nil
+			return nil
+		}
+		if timezone == "" {
+			timezone = series.Timezone
+		}
+		rule, parsed, err := parseRule(rule, timezone, series.LastDueAt)
+		if err != nil {
+			return err
+		}
+		series.RRule = rule
+		series.Timezone = timezone
+		series.StartAt = series.LastDueAt
+		series.NextDueAt = nextOccurrence(parsed, series.LastDueAt)
+		return nil
+	})
+	return err
+}
+
+// next creates the next occurrence of the series, which must be locked with
+// TodoSeriesRepository.FindForUpdate in the transaction of ctx. Occurrences
+// due before skipUntil are skipped but the latest of them, so a series that
+// fell behind gets a single overdue occurrence. It returns nil when the series
+// is over or the occurrence already exists.
+func (r recurrence) next(ctx context.Context, series *model.TodoSeries, skipUntil time.Time) (*model.Todo, error) {
+	if !series.Active() {
+		return nil, nil
+	}
+	_, rule, err := parseRule(series.RRule, series.Timezone, series.StartAt)
+	if err != nil {
+		return nil, err
+	}
+
+	occurrences, err := r.todoRepository.ListSeries(ctx, series.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(occurrences) == 0 {
+		// Every occurrence was deleted, nothing is left to repeat
+		_, err := r.todoSeriesRepository.Update(ctx, series.ID, func(series *model.TodoSeries) error {
+			series.NextDueAt = nil
+			return nil
+		})
+		return nil, err
+	}
+	latest := occurrences[len(occurrences)-1]
+
+	dueAt := *series.NextDueAt
+	for following := nextOccurrence(rule, dueAt); following != nil && !following.After(skipUntil); following = nextOccurrence(rule, dueAt) {
+		dueAt = *following
+	}
+	todo := model.Todo{
+		OwnerID:     latest.OwnerID,
+		ListID:      latest.ListID,
+		Name:        series.Name,
+		Description: series.Description,
+		DueAt:       &dueAt,
+		SeriesID:    &series.ID,
+		Labels:      []model.Label{},
+	}
+	// A failed insert aborts the transaction on PostgreSQL, unless it is
+	// rolled back to a savepoint
+	created := true
+	err = r.transactor.Transaction(ctx, func(ctx context.Context) error {
+		return r.todoRepository.Create(ctx, &todo)
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		created = false
+	} else if err != nil {
+		return nil, err
+	}
+
+	if created {
+		if err := r.copyDetails(ctx, &latest, &todo); err != nil {
+			return nil, err
+		}
+		todo.Labels = latest.Labels
+		if err := r.events.created(ctx, &todo); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = r.todoSeriesRepository.Update(ctx, series.ID, func(series *model.TodoSeries) error {
+		series.LastDueAt = dueAt
+		series.NextDueAt = nextOccurrence(rule, dueAt)
+		return nil
+	})
+	if err != nil || !created {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+// restored resumes the series of the restored todo when it is the latest
+// occurrence and the series stopped, as it does once every occurrence is
+// deleted. The series continues after the latest occurrence it created.
+func (r recurrence) restored(ctx context.Context, todo *model.Todo) error {
+	if todo.SeriesID == nil || todo.DueAt == nil {
+		return nil
+	}
+	series, err := r.todoSeriesRepository.FindForUpdate(ctx, *todo.SeriesID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if series.RRule == "" || series.NextDueAt != nil {
+		return nil
+	}
+
+	occurrences, err := r.todoRepository.ListSeries(ctx, series.ID)
+	if err != nil {
+		return err
+	}
+	if len(occurrences) == 0 || occurrences[len(occurrences)-1].ID != todo.ID {
+		return nil
+	}
+	_, rule, err := parseRule(series.RRule, series.Timezone, series.StartAt)
+	if err != nil {
+		return err
+	}
+	lastDueAt := series.LastDueAt
+	if todo.DueAt.After(lastDueAt) {
+		lastDueAt = *todo.DueAt
+	}
+
+	_, err = r.todoSeriesRepository.Update(ctx, series.ID, func(series *model.TodoSeries) error {
+		series.LastDueAt = lastDueAt
+		series.NextDueAt = nextOccurrence(rule, lastDueAt)
+		return nil
+	})
+	return err
+}
+
+// copyDetails gives the occurrence the labels, the unchecked checklist and
+// the reminders of the previous one
+func (r recurrence) copyDetails(ctx context.Context, previous *model.Todo, todo *model.Todo) error {
+	for _, label := range previous.Labels {
+		if err := r.todoRepository.AttachLabel(ctx, todo.ID, label.ID); err != nil {
+			return err
+		}
+	}
+	todo.Labels = previous.Labels
+
+	items, err := r.checklistItemRepository.List(ctx, previous.ID)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		copied := model.ChecklistItem{
+			TodoID:   todo.ID,
+			Text:     item.Text,
+			Position: item.Position,
+		}
+		if err := r.checklistItemRepository.Create(ctx, &copied); err != nil {
+			return err
+		}
+	}
+
+	reminders, err := r.reminderRepository.List(ctx, previous.ID)
+	if err != nil {
+		return err
+	}
+	for _, reminder := range reminders {
+		copied := model.Reminder{
+			TodoID:        todo.ID,
+			UserID:        reminder.UserID,
+			MinutesBefore: reminder.MinutesBefore,
+			Channel:       reminder.Channel,
+		}
+		copied.Schedule(*todo.DueAt)
+		if err := r.reminderRepository.Create(ctx, &copied); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseRule validates the rule and expands it from start in the timezone. It
+// also returns the rule in its canonical form.
+func parseRule(rule string, timezone string, start time.Time) (string, *rrule.RRule, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil || timezone == "Local" {
+		return "", nil, ErrInvalidTimezone
+	}
+
+	option, err := rrule.StrToROption(rule)
+	if err != nil || !option.Dtstart.IsZero() || option.Freq > rrule.HOURLY {
+		return "", nil, ErrInvalidRecurrence
+	}
+	canonical := option.RRuleString()
+
+	option.Dtstart = start.In(location)
+	parsed, err := rrule.NewRRule(*option)
+	if err != nil {
+		return "", nil, ErrInvalidRecurrence
+	}
+	return canonical, parsed, nil
+}
+
+// nextOccurrence returns the first occurrence of the rule after t, or nil
+// when there is none
+func nextOccurrence(rule *rrule.RRule, t time.Time) *time.Time {
+	next := rule.After(t, false)
+	if next.IsZero() {
+		return nil
+	}
+	next = next.UTC()
+	return &next
+}
+
+// RecurringTodoGenerator creates the next occurrence of recurring todos whose
+// latest occurrence is due, so they show up even when the previous one was
+// not completed. It is run periodically by the scheduler.
+type RecurringTodoGenerator struct {
+	todoSeriesRepository repository.TodoSeriesRepository
+	recurrence           recurrence
+	created              metric.Int64Counter
+}
+
+func NewRecurringTodoGenerator(
+	todoRepository repository.TodoRepository,
+	todoSeriesRepository repository.TodoSeriesRepository,
+	checklistItemRepository repository.ChecklistItemRepository,
+	reminderRepository repository.ReminderRepository,
+	todoListRepository repository.TodoListRepository,
+	listMemberRepository repository.ListMemberRepository,
+	todoEventRepository repository.TodoEventRepository,
+	ps pubsub.PubSub,
+	transactor txn.Transactor,
+	recorder outbox.Recorder,
+) (*RecurringTodoGenerator, error) {
+	created, err := metrics.Meter().Int64Counter(
+		"todos.occurrences.created",
+		metric.WithDescription("Number of recurring todo occurrences created on schedule"),
+		metric.WithUnit("{todo}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RecurringTodoGenerator{
+		todoSeriesRepository: todoSeriesRepository,
+		recurrence: recurrence{
+			todoRepository:          todoRepository,
+			todoSeriesRepository:    todoSeriesRepository,
+			checklistItemRepository: checklistItemRepository,
+			reminderRepository:      reminderRepository,
+			transactor:              transactor,
+			events: todoEvents{
+				todoEventRepository: todoEventRepository,
+				lists: listAccess{
+					todoListRepository:   todoListRepository,
+					listMemberRepository: listMemberRepository,
+				},
+				pubsub: ps,
+				outbox: recorder,
+			},
+		},
+		created: created,
+	}, nil
+}
+
+// Run creates one occurrence for each due series. Series that missed several
+// occurrences only get the latest of them.
+func (g *RecurringTodoGenerator) Run(ctx context.Context) error {
+	now := time.Now()
+	due, err := g.todoSeriesRepository.ListDue(ctx, now, dueSeriesBatchSize)
+	if err != nil {
+		return err
+	}
+
+	// A failing series must not hold back the others
+	var created int64
+	var errs []error
+	for i := range due {
+		todo, err := txn.Run(ctx, g.recurrence.transactor, func(ctx context.Context) (*model.Todo, error) {
+			series, err := g.todoSeriesRepository.FindForUpdate(ctx, due[i].ID)
+			if err != nil {
+				return nil, err
+			}
+			// Another run may have moved the series forward in the meantime
+			if series.LastDueAt.After(now) {
+				return nil, nil
+			}
+			return g.recurrence.next(ctx, series, now)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("series %d: %w", due[i].ID, err))
+			continue
+		}
+		if todo != nil {
+			created++
+		}
+	}
+	g.created.Add(ctx, created)
+
+	event := log.Debug()
+	if created > 0 {
+		event = log.Info()
+	}
+	event.
+		Int("due", len(due)).
+		Int64("created", created).
+		Msg("Created recurring todo occurrences")
+	return errors.Join(errs...)
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/internal/todo/repository"
+	_userService "khiemle.dev/golang-api-template/internal/user/service"
+	"khiemle.dev/golang-api-template/pkg/apperror"
+	"khiemle.dev/golang-api-template/pkg/jobqueue"
+	"khiemle.dev/golang-api-template/pkg/notify"
+)
+
+// JobDeliverReminder is the job type delivering a reminder, with a
+// DeliverReminder payload
+const JobDeliverReminder = "deliver_reminder"
+
+// dueRemindersBatchSize caps how many reminders an EnqueueDue call enqueues
+const dueRemindersBatchSize = 500
+
+var (
+	ErrReminderNotFound   = apperror.NotFound(apperror.CodeReminderNotFound, "reminder not found")
+	ErrReminderExists     = apperror.Conflict(apperror.CodeReminderExists, "the same reminder is already set on this todo")
+	ErrTodoWithoutDueDate = apperror.Conflict(apperror.CodeTodoWithoutDueDate, "reminders need a todo with a due date")
+	ErrUnknownChannel     = apperror.Validation(apperror.FieldError{
+		Field:   "channel",
+		Code:    "oneof",
+		Message: "must be one of the enabled notification channels",
+	})
+)
+
+// DeliverReminder is the payload of JobDeliverReminder jobs. RemindAt tells
+// jobs of rescheduled reminders apart.
+type DeliverReminder struct {
+	ReminderID uint      `json:"reminder_id"`
+	RemindAt   time.Time `json:"remind_at"`
+}
+
+// ReminderService manages the reminders users set on todos they can read and
+// delivers them. Delivery is at least once: a reminder is enqueued once, and
+// retried until its channel accepts it. Channels get a stable message id to
+// drop duplicates.
+type ReminderService interface {
+	// AddReminder reminds the user through channel, in-app by default,
+	// minutesBefore the todo is due.
+	AddReminder(ctx context.Context, userID uint, todoID int, minutesBefore int, channel string) (*model.Reminder, error)
+	// ListReminders returns the reminders of the user on the todo.
+	ListReminders(ctx context.Context, userID uint, todoID int) ([]model.Reminder, error)
+	DeleteReminder(ctx context.Context, userID uint, todoID int, id int) error
+	// EnqueueDue enqueues a delivery job for every due reminder.
+	EnqueueDue(ctx context.Context) error
+	// Deliver sends a reminder through its channel. Reminders already sent or
+	// rescheduled, of completed todos or of users who lost access to the todo
+	// are skipped.
+	Deliver(ctx context.Context, job DeliverReminder) error
+}
+
+type reminderService struct {
+	reminderRepository repository.ReminderRepository
+	userService        _userService.UserService
+	notifier           *notify.Notifier
+	todos              todoAccess
+}
+
+func NewReminderService(
+	reminderRepository repository.ReminderRepository,
+	todoRepository repository.TodoRepository,
+	todoListRepository repository.TodoListRepository,
+	listMemberRepository repository.ListMemberRepository,
+	userService _userService.UserService,
+	notifier *notify.Notifier,
+) ReminderService {
+	return &reminderService{
+		reminderRepository: reminderRepository,
+		userService:        userService,
+		notifier:           notifier,
+		todos: todoAccess{
+			todoRepository: todoRepository,
+			lists: listAccess{
+				todoListRepository:   todoListRepository,
+				listMemberRepository: listMemberRepository,
+			},
+		},
+	}
+}
+
+func (s *reminderService) AddReminder(ctx context.Context, userID uint, todoID int, minutesBefore int, channel string) (*model.Reminder, error) {
+	if channel == "" {
+		channel = notify.ChannelInApp
+	}
+	if !s.notifier.Has(channel) {
+		return nil, ErrUnknownChannel
+	}
+
+	todo, err := s.todos.load(ctx, userID, uint(todoID), model.ListRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	if todo.DueAt == nil {
+		return nil, ErrTodoWithoutDueDate
+	}
+
+	reminder := model.Reminder{
+		TodoID:        todo.ID,
+		UserID:        userID,
+		MinutesBefore: minutesBefore,
+		Channel:       channel,
+	}
+	reminder.Schedule(*todo.DueAt)
+	if err := s.reminderRepository.Create(ctx, &reminder); err != nil {
+		return nil, translateReminderError(err)
+	}
+	return &reminder, nil
+}
+
+func (s *reminderService) ListReminders(ctx context.Context, userID uint, todoID int) ([]model.Reminder, error) {
+	if _, err := s.todos.load(ctx, userID, uint(todoID), model.ListRoleViewer); err != nil {
+		return nil, err
+	}
+
+	all, err := s.reminderRepository.List(ctx, uint(todoID))
+	if err != nil {
+		return nil, err
+	}
+	reminders := []model.Reminder{}
+	for _, reminder := range all {
+		if reminder.UserID == userID {
+			reminders = append(reminders, reminder)
+		}
+	}
+	return reminders, nil
+}
+
+func (s *reminderService) DeleteReminder(ctx context.Context, userID uint, todoID int, id int) error {
+	if _, err := s.todos.load(ctx, userID, uint(todoID), model.ListRoleViewer); err != nil {
+		return err
+	}
+	return translateReminderError(s.reminderRepository.Delete(ctx, uint(todoID), userID, uint(id)))
+}
+
+func (s *reminderService) EnqueueDue(ctx context.Context) error {
+	enqueued, err := s.reminderRepository.EnqueueDue(ctx, time.Now(), dueRemindersBatchSize,
+		func(ctx context.Context, jobs jobqueue.Client, reminder *model.Reminder) error {
+			_, err := jobs.Enqueue(ctx, JobDeliverReminder, DeliverReminder{
+				ReminderID: reminder.ID,
+				RemindAt:   reminder.RemindAt,
+			})
+			return err
+		})
+
+	event := log.Debug()
+	if enqueued > 0 {
+		event = log.Info()
+	}
+	event.Int("enqueued", enqueued).Msg("Enqueued due reminders")
+	return err
+}
+
+func (s *reminderService) Deliver(ctx context.Context, job DeliverReminder) error {
+	reminder, err := s.reminderRepository.FindByID(ctx, job.ReminderID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if reminder.SentAt != nil || !reminder.RemindAt.Equal(job.RemindAt) {
+		return nil
+	}
+
+	todo, err := s.todos.load(ctx, reminder.UserID, reminder.TodoID, model.ListRoleViewer)
+	if errors.Is(err, ErrTodoNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if todo.Done || todo.DueAt == nil {
+		return nil
+	}
+
+	user, err := s.userService.GetUserById(ctx, reminder.UserID)
+	if errors.Is(err, _userService.ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = s.notifier.Send(ctx, reminder.Channel, notify.Message{
+		ID:      fmt.Sprintf("reminder-%d-%d", reminder.ID, reminder.RemindAt.Unix()),
+		UserID:  user.ID,
+		Email:   user.Email,
+		Kind:    "todo.reminder",
+		Subject: fmt.Sprintf("Reminder: %s", todo.Name),
+		Body:    fmt.Sprintf("%s is due at %s.", todo.Name, todo.DueAt.UTC().Format("Mon, 02 Jan 2006 15:04 MST")),
+		Data: map[string]any{
+			"todo_id": todo.ID,
+			"list_id": todo.ListID,
+			"due_at":  todo.DueAt,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.reminderRepository.Update(ctx, reminder.ID, func(reminder *model.Reminder) error {
+		if reminder.RemindAt.Equal(job.RemindAt) {
+			now := time.Now()
+			reminder.SentAt = &now
+		}
+		return nil
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	return err
+}
+
+// translateReminderError turns repository errors into domain errors
+func translateReminderError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrReminderNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrReminderExists
+	}
+	return err
+}
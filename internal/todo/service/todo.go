@@ -1,113 +1,1169 @@
 package service
 
 import (
-	"github.com/gin-gonic/gin"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
 	"gorm.io/gorm"
 	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/internal/todo/repository"
+	"khiemle.dev/golang-api-template/pkg/apperror"
+	"khiemle.dev/golang-api-template/pkg/jobqueue"
+	"khiemle.dev/golang-api-template/pkg/outbox"
+	"khiemle.dev/golang-api-template/pkg/pubsub"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+var (
+	ErrTodoNotFound = apperror.NotFound(apperror.CodeTodoNotFound, "todo not found")
+	ErrTodoTooDeep  = apperror.Conflict(apperror.CodeTodoTooDeep, fmt.Sprintf("todos can be nested at most %d levels deep", model.MaxTodoDepth))
+	ErrTodoCycle    = apperror.Conflict(apperror.CodeTodoCycle, "a todo cannot be moved under itself or its subtasks")
+	ErrSubtaskList  = apperror.Conflict(apperror.CodeSubtaskList, "subtasks are always in the list of their parent")
+	// ErrParentTrashed and ErrParentForbidden keep subtasks in the trash
+	// when they cannot go back under their parent
+	ErrParentTrashed   = apperror.Conflict(apperror.CodeParentTrashed, "the parent of the todo is in the trash, restore it first")
+	ErrParentForbidden = apperror.Conflict(apperror.CodeParentForbidden, "you can no longer edit the parent of the todo")
 )
 
+type CreateTodoArgs struct {
+	Name        string
+	Description string
+	// ListID adds the todo to a list, ParentID makes it a subtask. Subtasks
+	// are added to the list of their parent.
+	ListID   *int
+	ParentID *int
+	DueAt    *time.Time
+	// Recurrence is an RRULE repeating the todo from its due date, expanded in
+	// Timezone (UTC by default).
+	Recurrence string
+	Timezone   string
+}
+
+type UpdateTodoArgs struct {
+	// Empty Name and Description and nil fields are left unchanged
+	Name        string
+	Description string
+	Done        *bool
+	DueAt       *time.Time
+	// Recurrence replaces the rule of the series of the todo, or makes it
+	// recurring. An empty rule stops the series.
+	Recurrence *string
+	Timezone   string
+	// Series applies Name and Description to every open occurrence of the
+	// series and the ones to come, instead of this occurrence only.
+	Series bool
+}
+
+type ListTodoArgs struct {
+	// ListID and Done narrow down todos when set
+	ListID *int
+	Done   *bool
+	// Labels keeps todos having any of the named labels, or all of them when
+	// MatchAll is set.
+	Labels   []string
+	MatchAll bool
+}
+
+// TodoProgress counts the done subtasks, at every level below a todo, and
+// the done checklist items of the todo.
+type TodoProgress struct {
+	SubtasksDone   int
+	SubtasksTotal  int
+	ChecklistDone  int
+	ChecklistTotal int
+}
+
+// TodoDetails is a todo with its direct subtasks, checklist and progress,
+// and its series when it repeats.
+type TodoDetails struct {
+	Todo      *model.Todo
+	Subtasks  []model.Todo
+	Checklist []model.ChecklistItem
+	Progress  TodoProgress
+	Series    *model.TodoSeries
+}
+
+// TodoService manages todos on behalf of a user. Todos outside of lists are
+// only accessible to their owner. Todos in a list can be read by every member
+// of the list and changed by its editors and owners. Todos the user cannot
+// read are reported as not found.
+//
+// Todos form trees of at most model.MaxTodoDepth levels. Completing a todo
+// completes its subtasks and reopening one reopens its parents, so a done
+// todo never has open subtasks.
+//
+// Recurring todos are top-level todos with a due date and an RRULE. Completing
+// the latest occurrence, or its due date passing, creates the next one.
+//
+// Deleted todos go to the trash with their subtasks, where they can be
+// restored or deleted for good until they are purged after
+// TODO_TRASH_RETENTION. Todos of the trash are left out everywhere else.
 type TodoService interface {
-	CreateTodo(c *gin.Context, name string, description string) (*model.Todo, error)
-	GetById(c *gin.Context, id int) (*model.Todo, error)
-	UpdateTodo(c *gin.Context, id int, name string, description string) (*model.Todo, error)
-	ListTodo(c *gin.Context) ([]model.Todo, error)
-	DeleteTodo(c *gin.Context, id int) error
+	CreateTodo(ctx context.Context, userID uint, args CreateTodoArgs) (*model.Todo, error)
+	GetById(ctx context.Context, userID uint, id int) (*model.Todo, error)
+	GetDetails(ctx context.Context, userID uint, id int) (*TodoDetails, error)
+	UpdateTodo(ctx context.Context, userID uint, id int, args UpdateTodoArgs) (*model.Todo, error)
+	// ListTodo returns the todos of the user outside of lists and the todos of
+	// every list they can access.
+	ListTodo(ctx context.Context, userID uint, args ListTodoArgs) ([]model.Todo, error)
+	// DeleteTodo moves the todo with its subtasks to the trash.
+	DeleteTodo(ctx context.Context, userID uint, id int) error
+	// ListTrash returns the trashed todos of the user outside of lists and of
+	// every list they can access, most recently deleted first.
+	ListTrash(ctx context.Context, userID uint) ([]model.Todo, error)
+	// RestoreTodo takes the todo out of the trash with the subtasks deleted
+	// along with it.
+	RestoreTodo(ctx context.Context, userID uint, id int) (*model.Todo, error)
+	// PurgeTodo deletes the todo of the trash for good, with its subtasks.
+	PurgeTodo(ctx context.Context, userID uint, id int) error
+	// MoveTodo moves the todo with its subtasks to the list, or out of its
+	// list when listID is nil. A subtask moved to another list becomes a
+	// top-level todo.
+	MoveTodo(ctx context.Context, userID uint, id int, listID *int) (*model.Todo, error)
+	// SetParent moves the todo with its subtasks under another todo, or to the
+	// top level when parentID is nil. They follow the parent to its list.
+	SetParent(ctx context.Context, userID uint, id int, parentID *int) (*model.Todo, error)
+	AttachLabel(ctx context.Context, userID uint, id int, labelID int) (*model.Todo, error)
+	DetachLabel(ctx context.Context, userID uint, id int, labelID int) (*model.Todo, error)
 }
 
 type todoService struct {
-	db *gorm.DB
+	todoRepository          repository.TodoRepository
+	labelRepository         repository.LabelRepository
+	todoListRepository      repository.TodoListRepository
+	checklistItemRepository repository.ChecklistItemRepository
+	todoSeriesRepository    repository.TodoSeriesRepository
+	reminderRepository      repository.ReminderRepository
+	jobs                    jobqueue.Client
+	transactor              txn.Transactor
+	todos                   todoAccess
+	recurrences             recurrence
+	trash                   trash
+	events                  todoEvents
 }
 
-func NewTodoService(db *gorm.DB) TodoService {
+func NewTodoService(
+	todoRepository repository.TodoRepository,
+	labelRepository repository.LabelRepository,
+	todoListRepository repository.TodoListRepository,
+	listMemberRepository repository.ListMemberRepository,
+	checklistItemRepository repository.ChecklistItemRepository,
+	todoSeriesRepository repository.TodoSeriesRepository,
+	reminderRepository repository.ReminderRepository,
+	todoEventRepository repository.TodoEventRepository,
+	jobs jobqueue.Client,
+	ps pubsub.PubSub,
+	transactor txn.Transactor,
+	recorder outbox.Recorder,
+) TodoService {
+	lists := listAccess{
+		todoListRepository:   todoListRepository,
+		listMemberRepository: listMemberRepository,
+	}
+	events := todoEvents{
+		todoEventRepository: todoEventRepository,
+		lists:               lists,
+		pubsub:              ps,
+		outbox:              recorder,
+	}
 	return &todoService{
-		db: db,
+		todoRepository:          todoRepository,
+		labelRepository:         labelRepository,
+		todoListRepository:      todoListRepository,
+		checklistItemRepository: checklistItemRepository,
+		todoSeriesRepository:    todoSeriesRepository,
+		reminderRepository:      reminderRepository,
+		jobs:                    jobs,
+		transactor:              transactor,
+		todos: todoAccess{
+			todoRepository: todoRepository,
+			lists:          lists,
+		},
+		recurrences: recurrence{
+			todoRepository:          todoRepository,
+			todoSeriesRepository:    todoSeriesRepository,
+			checklistItemRepository: checklistItemRepository,
+			reminderRepository:      reminderRepository,
+			transactor:              transactor,
+			events:                  events,
+		},
+		trash: trash{
+			todoRepository: todoRepository,
+			events:         events,
+		},
+		events: events,
 	}
 }
 
 // CreateTodo
-func (s *todoService) CreateTodo(c *gin.Context, name string, description string) (*model.Todo, error) {
+func (s *todoService) CreateTodo(ctx context.Context, userID uint, args CreateTodoArgs) (*model.Todo, error) {
+	todo, err := txn.Run(ctx, s.transactor, func(ctx context.Context) (*model.Todo, error) {
+		return s.createTodo(ctx, userID, args)
+	})
+	return withLabelsOf(userID, todo), err
+}
+
+func (s *todoService) createTodo(ctx context.Context, userID uint, args CreateTodoArgs) (*model.Todo, error) {
 	todo := model.Todo{
-		Name:        name,
-		Description: description,
+		OwnerID:     userID,
+		ListID:      toUintPtr(args.ListID),
+		Name:        args.Name,
+		Description: args.Description,
+		DueAt:       args.DueAt,
+		Labels:      []model.Label{},
 	}
 
-	err := s.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Create(&todo).Error; err != nil {
-			return err
+	var parents []model.Todo
+	if args.ParentID != nil {
+		parent, err := s.todos.load(ctx, userID, uint(*args.ParentID), model.ListRoleEditor)
+		if err != nil {
+			return nil, err
 		}
-		return nil
-	})
-	if err != nil {
+		if args.ListID != nil && !sameList(parent.ListID, todo.ListID) {
+			return nil, ErrSubtaskList
+		}
+
+		parents, err = s.ancestors(ctx, parent)
+		if err != nil {
+			return nil, err
+		}
+		parents = append([]model.Todo{*parent}, parents...)
+		if len(parents)+1 > model.MaxTodoDepth {
+			return nil, ErrTodoTooDeep
+		}
+
+		todo.ParentID = &parent.ID
+		todo.ListID = parent.ListID
+	}
+
+	if err := s.checkListWritable(ctx, userID, todo.ListID); err != nil {
+		return nil, err
+	}
+	if args.Recurrence != "" {
+		series, err := s.recurrences.start(ctx, &todo, args.Recurrence, args.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		todo.SeriesID = &series.ID
+	}
+	if err := s.todoRepository.Create(ctx, &todo); err != nil {
 		return nil, err
 	}
 
+	// A new open subtask reopens its done parents
+	now := time.Now()
+	if err := s.todoRepository.SetDone(ctx, todoIDs(parents), false, now); err != nil {
+		return nil, err
+	}
+
+	if err := s.events.created(ctx, &todo); err != nil {
+		return nil, err
+	}
+	if err := s.doneChanged(ctx, parents, false, now); err != nil {
+		return nil, err
+	}
+	s.notifyActivity(ctx, ListActivityTodoCreated, userID, &todo, todo.ListID)
 	return &todo, nil
 }
 
 // GetById
-func (s *todoService) GetById(c *gin.Context, id int) (*model.Todo, error) {
-	todo := model.Todo{}
-	tx := s.db.First(&todo, "id = ?", id)
-	return &todo, tx.Error
+func (s *todoService) GetById(ctx context.Context, userID uint, id int) (*model.Todo, error) {
+	todo, err := s.todos.load(ctx, userID, uint(id), model.ListRoleViewer)
+	return withLabelsOf(userID, todo), err
 }
 
-// UpdateTodo
-func (s *todoService) UpdateTodo(c *gin.Context, id int, name string, description string) (*model.Todo, error) {
-	todo := model.Todo{}
-	err := s.db.Transaction(func(tx *gorm.DB) error {
-		err := s.db.First(&todo, "id = ?", id).Error
+// GetDetails returns the todo with its subtasks, checklist and progress
+func (s *todoService) GetDetails(ctx context.Context, userID uint, id int) (*TodoDetails, error) {
+	todo, err := s.todos.load(ctx, userID, uint(id), model.ListRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	descendants, err := s.descendants(ctx, todo)
+	if err != nil {
+		return nil, err
+	}
+	checklist, err := s.checklistItemRepository.List(ctx, todo.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	details := TodoDetails{
+		Todo:      withLabelsOf(userID, todo),
+		Subtasks:  []model.Todo{},
+		Checklist: checklist,
+	}
+	for _, descendant := range descendants {
+		if *descendant.ParentID == todo.ID {
+			descendant.Labels = descendant.LabelsOf(userID)
+			details.Subtasks = append(details.Subtasks, descendant)
+		}
+		details.Progress.SubtasksTotal++
+		if descendant.Done {
+			details.Progress.SubtasksDone++
+		}
+	}
+	for _, item := range checklist {
+		details.Progress.ChecklistTotal++
+		if item.Done {
+			details.Progress.ChecklistDone++
+		}
+	}
+
+	if todo.SeriesID != nil {
+		details.Series, err = s.todoSeriesRepository.FindByID(ctx, *todo.SeriesID)
 		if err != nil {
+			return nil, err
+		}
+	}
+	return &details, nil
+}
+
+// UpdateTodo
+func (s *todoService) UpdateTodo(ctx context.Context, userID uint, id int, args UpdateTodoArgs) (*model.Todo, error) {
+	todo, err := txn.Run(ctx, s.transactor, func(ctx context.Context) (*model.Todo, error) {
+		return s.updateTodo(ctx, userID, id, args)
+	})
+	return withLabelsOf(userID, todo), err
+}
+
+func (s *todoService) updateTodo(ctx context.Context, userID uint, id int, args UpdateTodoArgs) (*model.Todo, error) {
+	before, err := s.todos.load(ctx, userID, uint(id), model.ListRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Start, change or stop repeating the todo
+	seriesID := before.SeriesID
+	if args.Recurrence != nil {
+		if seriesID == nil && *args.Recurrence != "" {
+			todo := *before
+			if args.DueAt != nil {
+				todo.DueAt = args.DueAt
+			}
+			if args.Name != "" {
+				todo.Name = args.Name
+			}
+			if args.Description != "" {
+				todo.Description = args.Description
+			}
+			series, err := s.recurrences.start(ctx, &todo, *args.Recurrence, args.Timezone)
+			if err != nil {
+				return nil, err
+			}
+			seriesID = &series.ID
+		} else if seriesID != nil {
+			if err := s.recurrences.change(ctx, *seriesID, *args.Recurrence, args.Timezone); err != nil {
+				return nil, translateError(err)
+			}
+		}
+	}
+
+	now := time.Now()
+	todo, err := s.todoRepository.Update(ctx, uint(id), func(todo *model.Todo) error {
+		if args.Name != "" {
+			todo.Name = args.Name
+		}
+		if args.Description != "" {
+			todo.Description = args.Description
+		}
+		if args.DueAt != nil {
+			todo.DueAt = args.DueAt
+		}
+		if args.Done != nil {
+			todo.SetDone(*args.Done, now)
+		}
+		todo.SeriesID = seriesID
+		return nil
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	if args.DueAt != nil && (before.DueAt == nil || !before.DueAt.Equal(*args.DueAt)) {
+		if err := s.reminderRepository.Reschedule(ctx, todo.ID, *todo.DueAt); err != nil {
+			return nil, err
+		}
+	}
+
+	if args.Series && seriesID != nil && (args.Name != "" || args.Description != "") {
+		if err := s.updateSeries(ctx, todo, args.Name, args.Description); err != nil {
+			return nil, err
+		}
+	}
+
+	if args.Done != nil && *args.Done != before.Done {
+		if err := s.cascadeDone(ctx, todo, now); err != nil {
+			return nil, err
+		}
+		if todo.Done && todo.SeriesID != nil {
+			if err := s.completeOccurrence(ctx, todo); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := s.events.updated(ctx, before, todo); err != nil {
+		return nil, err
+	}
+	s.notifyUpdate(ctx, userID, before, todo)
+	return todo, nil
+}
+
+// notifyUpdate tells the other members of a shared list about the update.
+// Notifications must not fail the update, so failures are only logged.
+func (s *todoService) notifyUpdate(ctx context.Context, userID uint, before *model.Todo, after *model.Todo) {
+	changes := todoChanges(before, after)
+	if after.ListID == nil || len(changes) == 0 {
+		return
+	}
+
+	s.notify(ctx, *after.ListID, JobNotifyTodoUpdated, TodoUpdated{
+		TodoID:    after.ID,
+		ListID:    *after.ListID,
+		ActorID:   userID,
+		Changes:   changes,
+		UpdatedAt: after.UpdatedAt,
+	})
+}
+
+// notifyMove tells the other members of the shared lists a todo left and
+// entered that it moved
+func (s *todoService) notifyMove(ctx context.Context, userID uint, before *model.Todo, after *model.Todo) {
+	if sameList(before.ListID, after.ListID) {
+		return
+	}
+	s.notifyActivity(ctx, ListActivityTodoMovedOut, userID, after, before.ListID)
+	s.notifyActivity(ctx, ListActivityTodoMovedIn, userID, after, after.ListID)
+}
+
+// notifyActivity tells the other members of a shared list about the activity
+// of the user on the todo. Todos outside lists are nobody else's business.
+func (s *todoService) notifyActivity(ctx context.Context, kind string, userID uint, todo *model.Todo, listID *uint) {
+	if listID == nil {
+		return
+	}
+	s.notify(ctx, *listID, JobNotifyListActivity, ListActivity{
+		Kind:       kind,
+		ListID:     *listID,
+		ActorID:    userID,
+		TodoID:     todo.ID,
+		OccurredAt: time.Now(),
+	})
+}
+
+// notify enqueues the notification job when the list is shared. Failures are
+// logged, notifications do not fail the change: the job is enqueued in a
+// savepoint, as a failed statement would abort the whole transaction on
+// PostgreSQL.
+func (s *todoService) notify(ctx context.Context, listID uint, jobType string, payload any) {
+	err := s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		members, err := s.todos.lists.listMemberRepository.List(ctx, listID)
+		if err != nil || len(members) == 0 {
 			return err
 		}
+		_, err = s.jobs.Enqueue(ctx, jobType, payload)
+		return err
+	})
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Str("job_type", jobType).Msg("Could not enqueue notification")
+	}
+}
 
+// updateSeries renames the series of the todo and its other open occurrences
+func (s *todoService) updateSeries(ctx context.Context, todo *model.Todo, name string, description string) error {
+	rename := func(oldName *string, oldDescription *string) {
 		if name != "" {
-			todo.Name = name
+			*oldName = name
 		}
 		if description != "" {
-			todo.Description = description
+			*oldDescription = description
 		}
+	}
+
+	_, err := s.todoSeriesRepository.Update(ctx, *todo.SeriesID, func(series *model.TodoSeries) error {
+		rename(&series.Name, &series.Description)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-		if err := tx.Save(&todo).Error; err != nil {
+	occurrences, err := s.todoRepository.ListSeries(ctx, *todo.SeriesID)
+	if err != nil {
+		return err
+	}
+	for _, occurrence := range occurrences {
+		if occurrence.Done || occurrence.ID == todo.ID {
+			continue
+		}
+		renamed, err := s.todoRepository.Update(ctx, occurrence.ID, func(occurrence *model.Todo) error {
+			rename(&occurrence.Name, &occurrence.Description)
+			return nil
+		})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.events.updated(ctx, &occurrence, renamed); err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
+// completeOccurrence creates the next occurrence when the latest occurrence
+// of a series is completed
+func (s *todoService) completeOccurrence(ctx context.Context, todo *model.Todo) error {
+	series, err := s.todoSeriesRepository.FindForUpdate(ctx, *todo.SeriesID)
+	if err != nil {
+		return err
+	}
+	if todo.DueAt == nil || !todo.DueAt.Equal(series.LastDueAt) {
 		return nil
-	})
+	}
+	// The user asked for the very next occurrence, even if it is overdue
+	_, err = s.recurrences.next(ctx, series, time.Time{})
+	return err
+}
+
+// cascadeDone completes the subtasks of a todo that was completed, or reopens
+// the parents of a todo that was reopened
+func (s *todoService) cascadeDone(ctx context.Context, todo *model.Todo, now time.Time) error {
+	var related []model.Todo
+	var err error
+	if todo.Done {
+		related, err = s.descendants(ctx, todo)
+	} else {
+		related, err = s.ancestors(ctx, todo)
+	}
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if err := s.todoRepository.SetDone(ctx, todoIDs(related), todo.Done, now); err != nil {
+		return err
 	}
+	return s.doneChanged(ctx, related, todo.Done, now)
+}
 
-	return &todo, nil
+// doneChanged records the update of the todos that SetDone completed or
+// reopened
+func (s *todoService) doneChanged(ctx context.Context, todos []model.Todo, done bool, now time.Time) error {
+	for i := range todos {
+		if todos[i].Done == done {
+			continue
+		}
+		after := todos[i]
+		after.SetDone(done, now)
+		if err := s.events.updated(ctx, &todos[i], &after); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // ListTodo
-func (s *todoService) ListTodo(c *gin.Context) ([]model.Todo, error) {
-	todos := []model.Todo{}
-	tx := s.db.Find(&todos)
-	if tx.Error != nil {
-		return nil, tx.Error
+func (s *todoService) ListTodo(ctx context.Context, userID uint, args ListTodoArgs) ([]model.Todo, error) {
+	filter := repository.ListTodoFilter{
+		OwnerID:  userID,
+		Done:     args.Done,
+		Labels:   args.Labels,
+		MatchAll: args.MatchAll,
 	}
-	return todos, nil
+
+	if args.ListID != nil {
+		if _, err := s.todos.lists.load(ctx, userID, uint(*args.ListID)); err != nil {
+			return nil, err
+		}
+		filter.ListIDs = []uint{uint(*args.ListID)}
+	} else {
+		listIDs, err := s.todoListRepository.AccessibleIDs(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		filter.ListIDs = listIDs
+		filter.IncludeUnlisted = true
+	}
+
+	todos, err := s.todoRepository.List(ctx, filter)
+	return withLabelsOfAll(userID, todos), err
 }
 
 // DeleteTodo
-func (s *todoService) DeleteTodo(c *gin.Context, id int) error {
-	todo := model.Todo{}
-	err := s.db.Transaction(func(tx *gorm.DB) error {
-		err := s.db.First(&todo, "id = ?", id).Error
+func (s *todoService) DeleteTodo(ctx context.Context, userID uint, id int) error {
+	return s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		return s.deleteTodo(ctx, userID, id)
+	})
+}
+
+func (s *todoService) deleteTodo(ctx context.Context, userID uint, id int) error {
+	todo, err := s.todos.load(ctx, userID, uint(id), model.ListRoleEditor)
+	if err != nil {
+		return err
+	}
+	descendants, err := s.descendants(ctx, todo)
+	if err != nil {
+		return err
+	}
+	tree := append([]model.Todo{*todo}, descendants...)
+	if err := s.todoRepository.Trash(ctx, todoIDs(tree), time.Now()); err != nil {
+		return err
+	}
+
+	return s.events.deleted(ctx, tree)
+}
+
+// ListTrash
+func (s *todoService) ListTrash(ctx context.Context, userID uint) ([]model.Todo, error) {
+	listIDs, err := s.todoListRepository.AccessibleIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	todos, err := s.todoRepository.ListTrash(ctx, repository.ListTodoFilter{
+		OwnerID:         userID,
+		ListIDs:         listIDs,
+		IncludeUnlisted: true,
+	})
+	return withLabelsOfAll(userID, todos), err
+}
+
+// RestoreTodo restores the todo where it was deleted from. The user must be
+// able to edit todos of its list, which cannot be archived. Subtasks go back
+// under their parent, which must be out of the trash and editable by the
+// user, joining its list if it moved since, and reopen it when they are open.
+// Restoring the latest occurrence of a recurring todo resumes its series if it
+// stopped because every occurrence was deleted.
+func (s *todoService) RestoreTodo(ctx context.Context, userID uint, id int) (*model.Todo, error) {
+	todo, err := txn.Run(ctx, s.transactor, func(ctx context.Context) (*model.Todo, error) {
+		return s.restoreTodo(ctx, userID, id)
+	})
+	return withLabelsOf(userID, todo), err
+}
+
+func (s *todoService) restoreTodo(ctx context.Context, userID uint, id int) (*model.Todo, error) {
+	todo, err := s.todos.loadTrashed(ctx, userID, uint(id), model.ListRoleEditor)
+	if errors.Is(err, ErrTodoNotFound) {
+		err = s.checkParentTrashed(ctx, userID, uint(id))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkListWritable(ctx, userID, todo.ListID); err != nil {
+		return nil, err
+	}
+	descendants, err := s.trash.descendants(ctx, todo)
+	if err != nil {
+		return nil, err
+	}
+	ids := append([]uint{todo.ID}, todoIDs(descendants)...)
+
+	var parents []model.Todo
+	listID := todo.ListID
+	if todo.ParentID != nil {
+		parent, err := s.restoredParent(ctx, userID, *todo.ParentID)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		ancestors, err := s.ancestors(ctx, parent)
+		if err != nil {
+			return nil, err
 		}
+		parents = append([]model.Todo{*parent}, ancestors...)
+		if len(parents)+height(todo, descendants) > model.MaxTodoDepth {
+			return nil, ErrTodoTooDeep
+		}
+		if !sameList(todo.ListID, parent.ListID) {
+			if err := s.checkListLeavable(ctx, userID, todo); err != nil {
+				return nil, err
+			}
+			if err := s.checkListWritable(ctx, userID, parent.ListID); err != nil {
+				return nil, err
+			}
+		}
+		listID = parent.ListID
+	}
 
-		if err := tx.Delete(&todo).Error; err != nil {
-			return err
+	if err := s.todoRepository.Restore(ctx, ids); err != nil {
+		return nil, err
+	}
+	if !sameList(todo.ListID, listID) {
+		// Todos taken out of lists belong to the user restoring them, who
+		// owns the parent
+		var ownerID uint
+		if listID == nil {
+			ownerID = userID
+		}
+		if err := s.todoRepository.SetList(ctx, ids, listID, ownerID); err != nil {
+			return nil, err
 		}
+	}
+
+	restored, err := s.todoRepository.FindByID(ctx, todo.ID)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	descendants, err = s.descendants(ctx, restored)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.events.restored(ctx, append([]model.Todo{*restored}, descendants...)); err != nil {
+		return nil, err
+	}
+	if err := s.recurrences.restored(ctx, restored); err != nil {
+		return nil, err
+	}
+
+	// Done parents cannot have open subtasks, which a done todo never has
+	if !restored.Done {
+		now := time.Now()
+		if err := s.todoRepository.SetDone(ctx, todoIDs(parents), false, now); err != nil {
+			return nil, err
+		}
+		if err := s.doneChanged(ctx, parents, false, now); err != nil {
+			return nil, err
+		}
+	}
+	return restored, nil
+}
+
+// restoredParent returns the parent a subtask of the trash goes back under,
+// which the user must be able to edit
+func (s *todoService) restoredParent(ctx context.Context, userID uint, parentID uint) (*model.Todo, error) {
+	parent, err := s.todos.load(ctx, userID, parentID, model.ListRoleEditor)
+	if err == nil {
+		return parent, nil
+	}
+	if errors.Is(err, ErrTodoNotFound) || errors.Is(err, ErrTodoListForbidden) {
+		return nil, ErrParentForbidden
+	}
+	return nil, err
+}
+
+// checkParentTrashed reports a trashed subtask the user could restore if its
+// parent was not trashed too, or ErrTodoNotFound
+func (s *todoService) checkParentTrashed(ctx context.Context, userID uint, id uint) error {
+	todo, err := s.todoRepository.FindTrashedSubtask(ctx, id)
+	if err != nil {
+		return translateError(err)
+	}
+	if err := s.todos.require(ctx, userID, todo, model.ListRoleEditor); err != nil {
+		return ErrTodoNotFound
+	}
+	return ErrParentTrashed
+}
+
+// PurgeTodo
+func (s *todoService) PurgeTodo(ctx context.Context, userID uint, id int) error {
+	return s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		return s.purgeTodo(ctx, userID, id)
+	})
+}
 
+func (s *todoService) purgeTodo(ctx context.Context, userID uint, id int) error {
+	todo, err := s.todos.loadTrashed(ctx, userID, uint(id), model.ListRoleEditor)
+	if err != nil {
+		return err
+	}
+	_, err = s.trash.purge(ctx, todo)
+	return err
+}
+
+// MoveTodo moves the todo between lists. The user must be able to edit todos
+// of both lists, and own the todo or the list it leaves. Todos cannot be moved
+// into an archived list, but can be moved out of one.
+func (s *todoService) MoveTodo(ctx context.Context, userID uint, id int, listID *int) (*model.Todo, error) {
+	todo, err := txn.Run(ctx, s.transactor, func(ctx context.Context) (*model.Todo, error) {
+		return s.moveTodo(ctx, userID, id, listID)
+	})
+	return withLabelsOf(userID, todo), err
+}
+
+func (s *todoService) moveTodo(ctx context.Context, userID uint, id int, listID *int) (*model.Todo, error) {
+	todo, err := s.todos.load(ctx, userID, uint(id), model.ListRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	target := toUintPtr(listID)
+	if sameList(todo.ListID, target) {
+		return todo, nil
+	}
+	if err := s.checkListLeavable(ctx, userID, todo); err != nil {
+		return nil, err
+	}
+	if err := s.checkListWritable(ctx, userID, target); err != nil {
+		return nil, err
+	}
+
+	if err := s.moveTree(ctx, userID, todo, target); err != nil {
+		return nil, err
+	}
+	moved, err := s.todoRepository.Update(ctx, todo.ID, func(todo *model.Todo) error {
+		todo.ParentID = nil
 		return nil
 	})
 	if err != nil {
+		return nil, translateError(err)
+	}
+
+	if err := s.events.updated(ctx, todo, moved); err != nil {
+		return nil, err
+	}
+	s.notifyMove(ctx, userID, todo, moved)
+	return moved, nil
+}
+
+// SetParent moves the todo under another todo. Both must be editable by the
+// user and the tree must stay within model.MaxTodoDepth levels. Following the
+// parent to another list is a move, see MoveTodo.
+func (s *todoService) SetParent(ctx context.Context, userID uint, id int, parentID *int) (*model.Todo, error) {
+	todo, err := txn.Run(ctx, s.transactor, func(ctx context.Context) (*model.Todo, error) {
+		return s.setParent(ctx, userID, id, parentID)
+	})
+	return withLabelsOf(userID, todo), err
+}
+
+func (s *todoService) setParent(ctx context.Context, userID uint, id int, parentID *int) (*model.Todo, error) {
+	todo, err := s.todos.load(ctx, userID, uint(id), model.ListRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	if parentID == nil {
+		updated, err := s.todoRepository.Update(ctx, todo.ID, func(todo *model.Todo) error {
+			todo.ParentID = nil
+			return nil
+		})
+		if err != nil {
+			return nil, translateError(err)
+		}
+		if err := s.events.updated(ctx, todo, updated); err != nil {
+			return nil, err
+		}
+		return updated, nil
+	}
+
+	parent, err := s.todos.load(ctx, userID, uint(*parentID), model.ListRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	if todo.SeriesID != nil {
+		series, err := s.todoSeriesRepository.FindByID(ctx, *todo.SeriesID)
+		if err != nil {
+			return nil, err
+		}
+		if series.Active() {
+			return nil, ErrRecurringSubtask
+		}
+	}
+	descendants, err := s.descendants(ctx, todo)
+	if err != nil {
+		return nil, err
+	}
+	if parent.ID == todo.ID || containsTodo(descendants, parent.ID) {
+		return nil, ErrTodoCycle
+	}
+
+	parents, err := s.ancestors(ctx, parent)
+	if err != nil {
+		return nil, err
+	}
+	parents = append([]model.Todo{*parent}, parents...)
+	if len(parents)+height(todo, descendants) > model.MaxTodoDepth {
+		return nil, ErrTodoTooDeep
+	}
+
+	if !sameList(todo.ListID, parent.ListID) {
+		if err := s.checkListLeavable(ctx, userID, todo); err != nil {
+			return nil, err
+		}
+		if err := s.checkListWritable(ctx, userID, parent.ListID); err != nil {
+			return nil, err
+		}
+		if err := s.moveTree(ctx, userID, todo, parent.ListID); err != nil {
+			return nil, err
+		}
+	}
+
+	updated, err := s.todoRepository.Update(ctx, todo.ID, func(todo *model.Todo) error {
+		todo.ParentID = &parent.ID
+		return nil
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	if err := s.events.updated(ctx, todo, updated); err != nil {
+		return nil, err
+	}
+	s.notifyMove(ctx, userID, todo, updated)
+
+	// Done parents cannot have open subtasks
+	tree := append([]model.Todo{*updated}, descendants...)
+	for _, t := range tree {
+		if !t.Done {
+			now := time.Now()
+			if err := s.todoRepository.SetDone(ctx, todoIDs(parents), false, now); err != nil {
+				return nil, err
+			}
+			if err := s.doneChanged(ctx, parents, false, now); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	return updated, nil
+}
+
+// moveTree moves the todo and its subtasks to the list. Todos taken out of
+// lists now belong to the user moving them.
+func (s *todoService) moveTree(ctx context.Context, userID uint, todo *model.Todo, listID *uint) error {
+	descendants, err := s.descendants(ctx, todo)
+	if err != nil {
+		return err
+	}
+
+	var ownerID uint
+	if listID == nil {
+		ownerID = userID
+	}
+	ids := append([]uint{todo.ID}, todoIDs(descendants)...)
+	if err := s.todoRepository.SetList(ctx, ids, listID, ownerID); err != nil {
+		return err
+	}
+
+	// The todo itself is recorded by the caller once fully updated
+	for i := range descendants {
+		after := descendants[i]
+		after.ListID = listID
+		if ownerID != 0 {
+			after.OwnerID = ownerID
+		}
+		if err := s.events.updated(ctx, &descendants[i], &after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AttachLabel adds a label of the user to the todo
+func (s *todoService) AttachLabel(ctx context.Context, userID uint, id int, labelID int) (*model.Todo, error) {
+	todo, err := txn.Run(ctx, s.transactor, func(ctx context.Context) (*model.Todo, error) {
+		if err := s.checkTodoAndLabel(ctx, userID, id, labelID); err != nil {
+			return nil, err
+		}
+		if err := s.todoRepository.AttachLabel(ctx, uint(id), uint(labelID)); err != nil {
+			return nil, err
+		}
+		return s.labelsChanged(ctx, userID, id)
+	})
+	return withLabelsOf(userID, todo), err
+}
+
+// DetachLabel removes a label of the user from the todo
+func (s *todoService) DetachLabel(ctx context.Context, userID uint, id int, labelID int) (*model.Todo, error) {
+	todo, err := txn.Run(ctx, s.transactor, func(ctx context.Context) (*model.Todo, error) {
+		if err := s.checkTodoAndLabel(ctx, userID, id, labelID); err != nil {
+			return nil, err
+		}
+		if err := s.todoRepository.DetachLabel(ctx, uint(id), uint(labelID)); err != nil {
+			return nil, err
+		}
+		return s.labelsChanged(ctx, userID, id)
+	})
+	return withLabelsOf(userID, todo), err
+}
+
+// labelsChanged reloads the todo and records its update
+func (s *todoService) labelsChanged(ctx context.Context, userID uint, id int) (*model.Todo, error) {
+	todo, err := s.todos.load(ctx, userID, uint(id), model.ListRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.events.updated(ctx, todo, todo); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// checkTodoAndLabel makes sure the user can edit the todo and owns the label
+func (s *todoService) checkTodoAndLabel(ctx context.Context, userID uint, id int, labelID int) error {
+	if _, err := s.todos.load(ctx, userID, uint(id), model.ListRoleEditor); err != nil {
 		return err
 	}
+	if _, err := s.labelRepository.FindByID(ctx, userID, uint(labelID)); err != nil {
+		return translateLabelError(err)
+	}
+	return nil
+}
 
+// checkListWritable makes sure the user can add todos to the list. A nil
+// listID means no list and is always allowed. It must run in the transaction
+// adding the todos: the list stays locked until it ends so it cannot be
+// archived or deleted before they are added.
+func (s *todoService) checkListWritable(ctx context.Context, userID uint, listID *uint) error {
+	if listID == nil {
+		return nil
+	}
+	list, err := s.todos.lists.lock(ctx, userID, *listID)
+	if err != nil {
+		return err
+	}
+	if !list.Role.AtLeast(model.ListRoleEditor) {
+		return ErrTodoListForbidden
+	}
+	if list.Archived() {
+		return ErrTodoListArchived
+	}
 	return nil
 }
+
+// withLabelsOf drops the labels of other users from the todo returned to the
+// user, see model.Todo.LabelsOf. todo may be nil.
+func withLabelsOf(userID uint, todo *model.Todo) *model.Todo {
+	if todo != nil {
+		todo.Labels = todo.LabelsOf(userID)
+	}
+	return todo
+}
+
+// withLabelsOfAll is withLabelsOf for each todo
+func withLabelsOfAll(userID uint, todos []model.Todo) []model.Todo {
+	for i := range todos {
+		withLabelsOf(userID, &todos[i])
+	}
+	return todos
+}
+
+// checkListLeavable makes sure the user may take the todo out of its list.
+// Editors may only take their own todos, owners of the list any of them.
+func (s *todoService) checkListLeavable(ctx context.Context, userID uint, todo *model.Todo) error {
+	if todo.ListID == nil || todo.OwnerID == userID {
+		return nil
+	}
+	_, err := s.todos.lists.require(ctx, userID, *todo.ListID, model.ListRoleOwner)
+	return err
+}
+
+// ancestors returns the parent of the todo, its parent and so on
+func (s *todoService) ancestors(ctx context.Context, todo *model.Todo) ([]model.Todo, error) {
+	ancestors := []model.Todo{}
+	for parentID := todo.ParentID; parentID != nil && len(ancestors) < model.MaxTodoDepth; {
+		parent, err := s.todoRepository.FindByID(ctx, *parentID)
+		if err != nil {
+			return nil, err
+		}
+		ancestors = append(ancestors, *parent)
+		parentID = parent.ParentID
+	}
+	return ancestors, nil
+}
+
+// descendants returns the subtasks of the todo at every level, level by level
+func (s *todoService) descendants(ctx context.Context, todo *model.Todo) ([]model.Todo, error) {
+	descendants := []model.Todo{}
+	level := []uint{todo.ID}
+	for depth := 1; len(level) > 0 && depth < model.MaxTodoDepth; depth++ {
+		children, err := s.todoRepository.ListChildren(ctx, level)
+		if err != nil {
+			return nil, err
+		}
+		descendants = append(descendants, children...)
+		level = todoIDs(children)
+	}
+	return descendants, nil
+}
+
+// trash deletes trashed todos for good, for their users and the janitor
+type trash struct {
+	todoRepository repository.TodoRepository
+	events         todoEvents
+}
+
+// descendants returns the subtasks of the trashed todo that were deleted
+// along with it, level by level
+func (t trash) descendants(ctx context.Context, todo *model.Todo) ([]model.Todo, error) {
+	descendants := []model.Todo{}
+	level := []uint{todo.ID}
+	for depth := 1; len(level) > 0 && depth < model.MaxTodoDepth; depth++ {
+		children, err := t.todoRepository.ListTrashedChildren(ctx, level, todo.DeletedAt.Time)
+		if err != nil {
+			return nil, err
+		}
+		descendants = append(descendants, children...)
+		level = todoIDs(children)
+	}
+	return descendants, nil
+}
+
+// purge deletes the trashed todo for good with the subtasks deleted along with
+// it, and returns them all
+func (t trash) purge(ctx context.Context, todo *model.Todo) ([]model.Todo, error) {
+	descendants, err := t.descendants(ctx, todo)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.todoRepository.Delete(ctx, todo.ID); err != nil {
+		return nil, translateError(err)
+	}
+
+	purged := append([]model.Todo{*todo}, descendants...)
+	return purged, t.events.purged(ctx, purged)
+}
+
+// height returns how many levels the tree of todo has
+func height(todo *model.Todo, descendants []model.Todo) int {
+	depths := map[uint]int{todo.ID: 1}
+	max := 1
+	// descendants are ordered level by level, parents come first
+	for _, descendant := range descendants {
+		depth := depths[*descendant.ParentID] + 1
+		depths[descendant.ID] = depth
+		if depth > max {
+			max = depth
+		}
+	}
+	return max
+}
+
+func containsTodo(todos []model.Todo, id uint) bool {
+	for _, todo := range todos {
+		if todo.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func todoIDs(todos []model.Todo) []uint {
+	ids := make([]uint, 0, len(todos))
+	for _, todo := range todos {
+		ids = append(ids, todo.ID)
+	}
+	return ids
+}
+
+func sameList(a *uint, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func toUintPtr(value *int) *uint {
+	if value == nil {
+		return nil
+	}
+	v := uint(*value)
+	return &v
+}
+
+// translateError turns repository errors into domain errors
+func translateError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrTodoNotFound
+	}
+	return err
+}
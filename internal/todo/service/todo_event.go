@@ -0,0 +1,529 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/metric"
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/internal/todo/repository"
+	"khiemle.dev/golang-api-template/pkg/metrics"
+	"khiemle.dev/golang-api-template/pkg/outbox"
+	"khiemle.dev/golang-api-template/pkg/pubsub"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+// TodoEventsChannel is the pubsub channel waking up the todo event streams of
+// the users listed in the payload, or of every user when it is empty.
+const TodoEventsChannel = "todo_events"
+
+const (
+	// todoEventBatchSize bounds how many events a stream reads at once
+	todoEventBatchSize = 100
+	// maxTodoEventPayload keeps wake-up payloads under the 8000 bytes
+	// PostgreSQL allows for notifications
+	maxTodoEventPayload = 7000
+)
+
+// todoEvents records changes of todos for every user who can see them, wakes
+// up their streams once committed and records the domain events of the
+// changes in the outbox. Changes are recorded in their transaction, so
+// failures fail the change.
+type todoEvents struct {
+	todoEventRepository repository.TodoEventRepository
+	lists               listAccess
+	pubsub              pubsub.PubSub
+	outbox              outbox.Recorder
+}
+
+// created records todos that were created
+func (e todoEvents) created(ctx context.Context, todos ...*model.Todo) error {
+	events := []model.TodoEvent{}
+	changes := []outbox.Event{}
+	for _, todo := range todos {
+		audience, err := e.audience(ctx, todo)
+		if err != nil {
+			return err
+		}
+		events = append(events, e.events(model.TodoEventCreated, todo, audience)...)
+
+		change, err := outbox.NewEvent(model.TodoEventCreated, model.AggregateTodo, todo.ID, model.TodoChange{
+			Todo:    todo,
+			UserIDs: audience,
+		})
+		if err != nil {
+			return err
+		}
+		changes = append(changes, change)
+	}
+	return e.record(ctx, events, changes)
+}
+
+// updated records an update of a todo. Users who could only see the todo
+// before, because it moved to another list, are told it was deleted.
+func (e todoEvents) updated(ctx context.Context, before *model.Todo, after *model.Todo) error {
+	audience, err := e.audience(ctx, after)
+	if err != nil {
+		return err
+	}
+	events := e.events(model.TodoEventUpdated, after, audience)
+
+	removed := []uint{}
+	if !sameList(before.ListID, after.ListID) {
+		previous, err := e.audience(ctx, before)
+		if err != nil {
+			return err
+		}
+		removed = subtractIDs(previous, audience)
+		events = append(events, e.events(model.TodoEventDeleted, after, removed)...)
+	}
+
+	change, err := outbox.NewEvent(model.TodoEventUpdated, model.AggregateTodo, after.ID, model.TodoChange{
+		Todo:           after,
+		Before:         before,
+		UserIDs:        audience,
+		RemovedUserIDs: removed,
+	})
+	if err != nil {
+		return err
+	}
+	return e.record(ctx, events, []outbox.Event{change})
+}
+
+// deleted records todos that were deleted
+func (e todoEvents) deleted(ctx context.Context, todos []model.Todo) error {
+	events := []model.TodoEvent{}
+	changes := []outbox.Event{}
+	for i := range todos {
+		audience, err := e.audience(ctx, &todos[i])
+		if err != nil {
+			return err
+		}
+		events = append(events, e.events(model.TodoEventDeleted, &todos[i], audience)...)
+
+		change, err := outbox.NewEvent(model.TodoEventDeleted, model.AggregateTodo, todos[i].ID, model.TodoChange{
+			Todo:    &todos[i],
+			UserIDs: audience,
+		})
+		if err != nil {
+			return err
+		}
+		changes = append(changes, change)
+	}
+	return e.record(ctx, events, changes)
+}
+
+// restored records todos that were taken out of the trash. Streams see them
+// created again.
+func (e todoEvents) restored(ctx context.Context, todos []model.Todo) error {
+	return e.changed(ctx, model.TodoEventRestored, model.TodoEventCreated, todos)
+}
+
+// purged records todos of the trash that were deleted for good. Streams
+// already saw them deleted.
+func (e todoEvents) purged(ctx context.Context, todos []model.Todo) error {
+	return e.changed(ctx, model.TodoEventPurged, "", todos)
+}
+
+// changed records the domain event of each todo, and the stream event of
+// streamType for each user who can see it unless streamType is empty
+func (e todoEvents) changed(ctx context.Context, eventType string, streamType string, todos []model.Todo) error {
+	events := []model.TodoEvent{}
+	changes := []outbox.Event{}
+	for i := range todos {
+		audience, err := e.audience(ctx, &todos[i])
+		if err != nil {
+			return err
+		}
+		if streamType != "" {
+			events = append(events, e.events(streamType, &todos[i], audience)...)
+		}
+
+		change, err := outbox.NewEvent(eventType, model.AggregateTodo, todos[i].ID, model.TodoChange{
+			Todo:    &todos[i],
+			UserIDs: audience,
+		})
+		if err != nil {
+			return err
+		}
+		changes = append(changes, change)
+	}
+	return e.record(ctx, events, changes)
+}
+
+// audience returns the users who can see the todo: the members of its list,
+// or its owner outside of lists
+func (e todoEvents) audience(ctx context.Context, todo *model.Todo) ([]uint, error) {
+	if todo.ListID == nil {
+		return []uint{todo.OwnerID}, nil
+	}
+	list, err := e.lists.todoListRepository.FindByID(ctx, *todo.ListID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return []uint{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	members, err := e.lists.listMemberRepository.List(ctx, list.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	audience := []uint{list.OwnerID}
+	for _, member := range members {
+		audience = append(audience, member.UserID)
+	}
+	return audience, nil
+}
+
+// events builds an event of the todo for each user, with the labels of the
+// user only
+func (e todoEvents) events(eventType string, todo *model.Todo, userIDs []uint) []model.TodoEvent {
+	events := make([]model.TodoEvent, 0, len(userIDs))
+	for _, userID := range userIDs {
+		var data []byte
+		if eventType == model.TodoEventDeleted {
+			data, _ = json.Marshal(map[string]uint{"id": todo.ID})
+		} else {
+			seen := *todo
+			seen.Labels = todo.LabelsOf(userID)
+			data, _ = json.Marshal(&seen)
+		}
+		events = append(events, model.TodoEvent{
+			UserID: userID,
+			Type:   eventType,
+			TodoID: todo.ID,
+			Data:   string(data),
+		})
+	}
+	return events
+}
+
+// record saves the events of the users and the domain events, and wakes up
+// the streams of the users once they are committed
+func (e todoEvents) record(ctx context.Context, events []model.TodoEvent, changes []outbox.Event) error {
+	if err := e.outbox.Record(ctx, changes...); err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	if err := e.todoEventRepository.Create(ctx, events); err != nil {
+		return err
+	}
+
+	seen := map[uint]bool{}
+	userIDs := []string{}
+	for _, event := range events {
+		if !seen[event.UserID] {
+			seen[event.UserID] = true
+			userIDs = append(userIDs, strconv.FormatUint(uint64(event.UserID), 10))
+		}
+	}
+	payload := strings.Join(userIDs, ",")
+	if len(payload) > maxTodoEventPayload {
+		payload = ""
+	}
+
+	// Streams also poll, a lost wake-up only delays the events
+	txn.AfterCommit(ctx, func() {
+		if err := e.pubsub.Publish(ctx, TodoEventsChannel, payload); err != nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("Could not wake up todo event streams")
+		}
+	})
+	return nil
+}
+
+// subtractIDs returns the ids of a that are not in b
+func subtractIDs(a []uint, b []uint) []uint {
+	excluded := map[uint]bool{}
+	for _, id := range b {
+		excluded[id] = true
+	}
+	ids := []uint{}
+	for _, id := range a {
+		if !excluded[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// TodoStream delivers the todo events of a user as they are recorded.
+//
+// Ids of events are only in commit order within the gap timeout of the
+// service: an event may commit after events with higher ids, as long as its
+// transaction is shorter. Streams deliver such events late, rather than skip
+// them.
+type TodoStream struct {
+	todoEventRepository repository.TodoEventRepository
+	userID              uint
+	gapTimeout          time.Duration
+	// cursor is the id up to which every event of the user is settled: it was
+	// delivered, or before the stream started, and no event with a lower id
+	// can commit any more.
+	cursor uint
+	// sent holds the events after the cursor that were delivered, or
+	// committed before the stream started, with their creation time
+	sent  map[uint]time.Time
+	wake  chan struct{}
+	close func()
+
+	// Reset is set when the stream could not resume after the requested
+	// event, because it was purged or never existed. Clients should reload
+	// their todos.
+	Reset bool
+}
+
+// Wake is signalled when new events may be available.
+func (s *TodoStream) Wake() <-chan struct{} {
+	return s.wake
+}
+
+// Next returns the events of the user that were not delivered yet, by id,
+// and wakes the stream up again when more are waiting.
+func (s *TodoStream) Next(ctx context.Context) ([]model.TodoEvent, error) {
+	// Events created before settled were committed before the events are read
+	settled := time.Now().Add(-s.gapTimeout)
+
+	events := []model.TodoEvent{}
+	after := s.cursor
+	for len(events) < todoEventBatchSize {
+		page, err := s.todoEventRepository.ListAfter(ctx, s.userID, after, todoEventBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range page {
+			if _, ok := s.sent[event.ID]; !ok {
+				s.sent[event.ID] = event.CreatedAt
+				events = append(events, event)
+			}
+		}
+		if len(page) < todoEventBatchSize {
+			break
+		}
+		after = page[len(page)-1].ID
+	}
+	if len(events) >= todoEventBatchSize {
+		s.signal()
+	}
+
+	s.settle(settled)
+	return events, nil
+}
+
+// settle moves the cursor past the sent events created before settled, as
+// events with lower ids were committed before them
+func (s *TodoStream) settle(settled time.Time) {
+	for id, createdAt := range s.sent {
+		if id > s.cursor && createdAt.Before(settled) {
+			s.cursor = id
+		}
+	}
+	for id := range s.sent {
+		if id <= s.cursor {
+			delete(s.sent, id)
+		}
+	}
+}
+
+// start positions the stream after the event with the given id. Events up to
+// it that are not settled yet are taken as sent, the ones committing later
+// are delivered.
+func (s *TodoStream) start(ctx context.Context, position uint) error {
+	settled := time.Now().Add(-s.gapTimeout)
+	cursor, err := s.todoEventRepository.LastIDBefore(ctx, settled)
+	if err != nil {
+		return err
+	}
+	s.cursor = min(cursor, position)
+
+	after := s.cursor
+	for {
+		page, err := s.todoEventRepository.ListAfter(ctx, s.userID, after, todoEventBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, event := range page {
+			if event.ID > position {
+				return nil
+			}
+			s.sent[event.ID] = event.CreatedAt
+		}
+		if len(page) < todoEventBatchSize {
+			return nil
+		}
+		after = page[len(page)-1].ID
+	}
+}
+
+// Close stops waking the stream up.
+func (s *TodoStream) Close() {
+	s.close()
+}
+
+func (s *TodoStream) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+type TodoEventService interface {
+	// Subscribe opens a stream of the todo events of the user, starting after
+	// lastEventID when set or with the next event otherwise. Events committed
+	// late are delivered after events with higher ids, so resuming from the
+	// id of one may deliver some of those again.
+	Subscribe(ctx context.Context, userID uint, lastEventID *uint) (*TodoStream, error)
+}
+
+type todoEventService struct {
+	todoEventRepository repository.TodoEventRepository
+	gapTimeout          time.Duration
+
+	mu      sync.Mutex
+	streams map[uint]map[*TodoStream]struct{}
+}
+
+// NewTodoEventService creates the service and starts waking up streams of
+// this replica when events are recorded on any replica. Transactions
+// recording events must be shorter than gapTimeout, or streams miss the
+// events committing later.
+func NewTodoEventService(todoEventRepository repository.TodoEventRepository, ps pubsub.PubSub, gapTimeout time.Duration) TodoEventService {
+	s := &todoEventService{
+		todoEventRepository: todoEventRepository,
+		gapTimeout:          gapTimeout,
+		streams:             map[uint]map[*TodoStream]struct{}{},
+	}
+	ps.Subscribe(TodoEventsChannel, s.wake)
+	return s
+}
+
+func (s *todoEventService) Subscribe(ctx context.Context, userID uint, lastEventID *uint) (*TodoStream, error) {
+	stream := &TodoStream{
+		todoEventRepository: s.todoEventRepository,
+		userID:              userID,
+		gapTimeout:          s.gapTimeout,
+		sent:                map[uint]time.Time{},
+		wake:                make(chan struct{}, 1),
+	}
+
+	// Register before reading the cursor so no event is missed in between
+	s.mu.Lock()
+	if s.streams[userID] == nil {
+		s.streams[userID] = map[*TodoStream]struct{}{}
+	}
+	s.streams[userID][stream] = struct{}{}
+	s.mu.Unlock()
+	stream.close = func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.streams[userID], stream)
+		if len(s.streams[userID]) == 0 {
+			delete(s.streams, userID)
+		}
+	}
+
+	position, err := s.position(ctx, stream, lastEventID)
+	if err == nil {
+		err = stream.start(ctx, position)
+	}
+	if err != nil {
+		stream.Close()
+		return nil, err
+	}
+	if lastEventID != nil && !stream.Reset {
+		stream.signal()
+	}
+	return stream, nil
+}
+
+// position returns the id of the event the stream starts after: lastEventID
+// when the user has it, the newest event otherwise
+func (s *todoEventService) position(ctx context.Context, stream *TodoStream, lastEventID *uint) (uint, error) {
+	if lastEventID != nil {
+		_, err := s.todoEventRepository.Find(ctx, stream.userID, *lastEventID)
+		if err == nil {
+			return *lastEventID, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, err
+		}
+		stream.Reset = true
+	}
+	return s.todoEventRepository.LastID(ctx)
+}
+
+// wake signals the streams of the users listed in the payload
+func (s *todoEventService) wake(payload string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if payload == "" {
+		for _, streams := range s.streams {
+			for stream := range streams {
+				stream.signal()
+			}
+		}
+		return
+	}
+	for _, field := range strings.Split(payload, ",") {
+		userID, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			continue
+		}
+		for stream := range s.streams[uint(userID)] {
+			stream.signal()
+		}
+	}
+}
+
+// TodoEventJanitor deletes todo events older than the retention window,
+// after which streams can no longer resume from them. It is run periodically
+// by the scheduler.
+type TodoEventJanitor struct {
+	todoEventRepository repository.TodoEventRepository
+	retention           time.Duration
+	purged              metric.Int64Counter
+}
+
+func NewTodoEventJanitor(todoEventRepository repository.TodoEventRepository, retention time.Duration) (*TodoEventJanitor, error) {
+	purged, err := metrics.Meter().Int64Counter(
+		"todos.events.purged",
+		metric.WithDescription("Number of todo events deleted by the janitor"),
+		metric.WithUnit("{event}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TodoEventJanitor{
+		todoEventRepository: todoEventRepository,
+		retention:           retention,
+		purged:              purged,
+	}, nil
+}
+
+// Run purges events older than the retention window.
+func (j *TodoEventJanitor) Run(ctx context.Context) error {
+	purged, err := j.todoEventRepository.PurgeBefore(ctx, time.Now().Add(-j.retention))
+	j.purged.Add(ctx, purged)
+	if err != nil {
+		return err
+	}
+
+	event := log.Debug()
+	if purged > 0 {
+		event = log.Info()
+	}
+	event.Int64("purged", purged).Msg("Purged todo events")
+	return nil
+}
@@ -0,0 +1,110 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/internal/todo/repository"
+	"khiemle.dev/golang-api-template/internal/todo/service"
+	"khiemle.dev/golang-api-template/pkg/database/databasetest"
+	"khiemle.dev/golang-api-template/pkg/pubsub"
+)
+
+func TestTodoStreamDeliversEventsCommittedLate(t *testing.T) {
+	ctx := context.Background()
+	db := databasetest.New(t)
+	events := repository.NewGormTodoEventRepository(db)
+	svc := service.NewTodoEventService(events, pubsub.NewLocal(), time.Minute)
+
+	const alice, bob = 1, 2
+	// commit records an event as if its transaction committed now, ids being
+	// taken when the transaction began
+	commit := func(id uint, userID uint, createdAt time.Time) {
+		t.Helper()
+		event := model.TodoEvent{ID: id, UserID: userID, Type: model.TodoEventUpdated, TodoID: 1, Data: "{}", CreatedAt: createdAt}
+		if err := db.Create(&event).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	next := func(stream *service.TodoStream) string {
+		t.Helper()
+		got, err := stream.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []uint{}
+		for _, event := range got {
+			ids = append(ids, event.ID)
+		}
+		return fmt.Sprint(ids)
+	}
+
+	now := time.Now()
+	old := now.Add(-time.Hour)
+	commit(1, alice, old)
+	commit(3, alice, now)
+	commit(4, bob, now)
+
+	// Events committed before the stream started are not delivered, the ones
+	// still being committed are, even with lower ids
+	stream, err := svc.Subscribe(ctx, alice, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	if got := next(stream); got != "[]" {
+		t.Errorf("first events = %s, want []", got)
+	}
+
+	// Resumed streams deliver the events committed late too
+	lastEventID := uint(3)
+	resumed, err := svc.Subscribe(ctx, alice, &lastEventID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resumed.Close()
+	if resumed.Reset {
+		t.Fatal("stream could not resume")
+	}
+
+	commit(2, alice, now)
+	commit(5, alice, now)
+	for name, stream := range map[string]*service.TodoStream{"new": stream, "resumed": resumed} {
+		if got := next(stream); got != "[2 5]" {
+			t.Errorf("events of the %s stream = %s, want [2 5]", name, got)
+		}
+		if got := next(stream); got != "[]" {
+			t.Errorf("events of the %s stream after delivering = %s, want []", name, got)
+		}
+	}
+}
+
+func TestTodoStreamSettlesOldEvents(t *testing.T) {
+	ctx := context.Background()
+	db := databasetest.New(t)
+	events := repository.NewGormTodoEventRepository(db)
+	svc := service.NewTodoEventService(events, pubsub.NewLocal(), time.Millisecond)
+
+	stream, err := svc.Subscribe(ctx, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := events.Create(ctx, []model.TodoEvent{{UserID: 1, Type: model.TodoEventCreated, TodoID: 1, Data: "{}"}}); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * time.Millisecond)
+		got, err := stream.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0].ID != uint(i+1) {
+			t.Fatalf("events = %+v, want event %d", got, i+1)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/internal/todo/repository"
+	"khiemle.dev/golang-api-template/pkg/apperror"
+)
+
+var (
+	ErrTodoListNotFound = apperror.NotFound(apperror.CodeTodoListNotFound, "todo list not found")
+	ErrTodoListArchived = apperror.Conflict(apperror.CodeTodoListArchived, "todo list is archived")
+)
+
+// TodoListService manages the todo lists a user owns or is a member of. Any
+// member may read a list, only owners may change it. Lists the user cannot
+// access are reported as not found.
+type TodoListService interface {
+	CreateList(ctx context.Context, userID uint, name string, description string) (*model.TodoList, error)
+	GetList(ctx context.Context, userID uint, id int) (*model.TodoList, error)
+	ListLists(ctx context.Context, userID uint, includeArchived bool) ([]model.TodoList, error)
+	UpdateList(ctx context.Context, userID uint, id int, name string, description string) (*model.TodoList, error)
+	// ArchiveList and UnarchiveList are idempotent.
+	ArchiveList(ctx context.Context, userID uint, id int) (*model.TodoList, error)
+	UnarchiveList(ctx context.Context, userID uint, id int) (*model.TodoList, error)
+	// DeleteList deletes the list with its todos.
+	DeleteList(ctx context.Context, userID uint, id int) error
+}
+
+type todoListService struct {
+	todoListRepository repository.TodoListRepository
+	access             listAccess
+}
+
+func NewTodoListService(todoListRepository repository.TodoListRepository, listMemberRepository repository.ListMemberRepository) TodoListService {
+	return &todoListService{
+		todoListRepository: todoListRepository,
+		access: listAccess{
+			todoListRepository:   todoListRepository,
+			listMemberRepository: listMemberRepository,
+		},
+	}
+}
+
+func (s *todoListService) CreateList(ctx context.Context, userID uint, name string, description string) (*model.TodoList, error) {
+	list := model.TodoList{
+		OwnerID:     userID,
+		Name:        name,
+		Description: description,
+	}
+
+	if err := s.todoListRepository.Create(ctx, &list); err != nil {
+		return nil, err
+	}
+	list.Role = model.ListRoleOwner
+	return &list, nil
+}
+
+func (s *todoListService) GetList(ctx context.Context, userID uint, id int) (*model.TodoList, error) {
+	return s.access.load(ctx, userID, uint(id))
+}
+
+func (s *todoListService) ListLists(ctx context.Context, userID uint, includeArchived bool) ([]model.TodoList, error) {
+	lists, err := s.todoListRepository.List(ctx, userID, includeArchived)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.access.setRoles(ctx, userID, lists); err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+func (s *todoListService) UpdateList(ctx context.Context, userID uint, id int, name string, description string) (*model.TodoList, error) {
+	return s.update(ctx, userID, id, func(list *model.TodoList) {
+		if name != "" {
+			list.Name = name
+		}
+		if description != "" {
+			list.Description = description
+		}
+	})
+}
+
+func (s *todoListService) ArchiveList(ctx context.Context, userID uint, id int) (*model.TodoList, error) {
+	return s.update(ctx, userID, id, func(list *model.TodoList) {
+		if list.ArchivedAt == nil {
+			now := time.Now()
+			list.ArchivedAt = &now
+		}
+	})
+}
+
+func (s *todoListService) UnarchiveList(ctx context.Context, userID uint, id int) (*model.TodoList, error) {
+	return s.update(ctx, userID, id, func(list *model.TodoList) {
+		list.ArchivedAt = nil
+	})
+}
+
+func (s *todoListService) DeleteList(ctx context.Context, userID uint, id int) error {
+	if _, err := s.access.require(ctx, userID, uint(id), model.ListRoleOwner); err != nil {
+		return err
+	}
+	return translateTodoListError(s.todoListRepository.Delete(ctx, uint(id)))
+}
+
+// update applies fn to the list if the user owns it
+func (s *todoListService) update(ctx context.Context, userID uint, id int, fn func(list *model.TodoList)) (*model.TodoList, error) {
+	if _, err := s.access.require(ctx, userID, uint(id), model.ListRoleOwner); err != nil {
+		return nil, err
+	}
+
+	list, err := s.todoListRepository.Update(ctx, uint(id), func(list *model.TodoList) error {
+		fn(list)
+		return nil
+	})
+	if err != nil {
+		return nil, translateTodoListError(err)
+	}
+	list.Role = model.ListRoleOwner
+	return list, nil
+}
+
+// translateTodoListError turns repository errors into domain errors
+func translateTodoListError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrTodoListNotFound
+	}
+	return err
+}
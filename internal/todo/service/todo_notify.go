@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/internal/todo/repository"
+	_userService "khiemle.dev/golang-api-template/internal/user/service"
+	"khiemle.dev/golang-api-template/pkg/notify"
+)
+
+// Jobs telling the members of shared lists what others did
+const (
+	// JobNotifyTodoUpdated tells the members of a shared list about an update
+	JobNotifyTodoUpdated = "notify_todo_updated"
+	// JobNotifyListActivity tells users about a ListActivity
+	JobNotifyListActivity = "notify_list_activity"
+)
+
+// Kinds of ListActivity
+const (
+	// ListActivityTodoCreated and ListActivityTodoMovedIn tell the other
+	// members of the list a todo was added to it, ListActivityTodoMovedOut
+	// that one left it.
+	ListActivityTodoCreated  = "todo.created"
+	ListActivityTodoMovedIn  = "todo.moved_in"
+	ListActivityTodoMovedOut = "todo.moved_out"
+	// ListActivityInvited tells the invitee they were invited to the list
+	ListActivityInvited = "list.invited"
+)
+
+// Fields reported by TodoUpdated.Changes
+const (
+	TodoChangeName        = "name"
+	TodoChangeDescription = "description"
+	TodoChangeDueAt       = "due_at"
+	TodoChangeDone        = "done"
+	TodoChangeReopened    = "reopened"
+)
+
+// TodoUpdated is the payload of JobNotifyTodoUpdated.
+type TodoUpdated struct {
+	TodoID    uint      `json:"todo_id"`
+	ListID    uint      `json:"list_id"`
+	ActorID   uint      `json:"actor_id"`
+	Changes   []string  `json:"changes"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ListActivity is the payload of JobNotifyListActivity.
+type ListActivity struct {
+	Kind    string `json:"kind"`
+	ListID  uint   `json:"list_id"`
+	ActorID uint   `json:"actor_id"`
+	// TodoID is set for the activities of todos
+	TodoID uint `json:"todo_id,omitempty"`
+	// InviteeID and Role are set for invitations
+	InviteeID  uint           `json:"invitee_id,omitempty"`
+	Role       model.ListRole `json:"role,omitempty"`
+	OccurredAt time.Time      `json:"occurred_at"`
+}
+
+// todoChanges lists what an update changed on the todo
+func todoChanges(before *model.Todo, after *model.Todo) []string {
+	changes := []string{}
+	if before.Name != after.Name {
+		changes = append(changes, TodoChangeName)
+	}
+	if before.Description != after.Description {
+		changes = append(changes, TodoChangeDescription)
+	}
+	if (before.DueAt == nil) != (after.DueAt == nil) || (before.DueAt != nil && !before.DueAt.Equal(*after.DueAt)) {
+		changes = append(changes, TodoChangeDueAt)
+	}
+	if before.Done != after.Done {
+		if after.Done {
+			changes = append(changes, TodoChangeDone)
+		} else {
+			changes = append(changes, TodoChangeReopened)
+		}
+	}
+	return changes
+}
+
+// SharedTodoNotifier tells the owner and members of a list, except whoever
+// made the change, that one of its todos was updated, added or taken out,
+// and invitees that they were invited.
+type SharedTodoNotifier struct {
+	todoRepository       repository.TodoRepository
+	todoListRepository   repository.TodoListRepository
+	listMemberRepository repository.ListMemberRepository
+	userService          _userService.UserService
+	notifier             *notify.Notifier
+}
+
+func NewSharedTodoNotifier(
+	todoRepository repository.TodoRepository,
+	todoListRepository repository.TodoListRepository,
+	listMemberRepository repository.ListMemberRepository,
+	userService _userService.UserService,
+	notifier *notify.Notifier,
+) *SharedTodoNotifier {
+	return &SharedTodoNotifier{
+		todoRepository:       todoRepository,
+		todoListRepository:   todoListRepository,
+		listMemberRepository: listMemberRepository,
+		userService:          userService,
+		notifier:             notifier,
+	}
+}
+
+// Notify sends an in-app notification to every other member of the list.
+// Todos deleted or moved out of the list since are skipped.
+func (n *SharedTodoNotifier) Notify(ctx context.Context, job TodoUpdated) error {
+	todo, err := n.todoRepository.FindByID(ctx, job.TodoID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if todo.ListID == nil || *todo.ListID != job.ListID {
+		return nil
+	}
+
+	list, err := n.todoListRepository.FindByID(ctx, job.ListID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	recipients, err := n.members(ctx, list)
+	if err != nil {
+		return err
+	}
+	actor, err := n.actorName(ctx, job.ActorID)
+	if err != nil {
+		return err
+	}
+
+	return n.send(ctx, recipients, job.ActorID, notify.Message{
+		ID:      fmt.Sprintf("todo-%d-updated-%d", todo.ID, job.UpdatedAt.UnixNano()),
+		Kind:    "todo.updated",
+		Subject: fmt.Sprintf("%s was updated", todo.Name),
+		Body:    fmt.Sprintf("%s %s %q in %s.", actor, describeChanges(job.Changes), todo.Name, list.Name),
+		Data: map[string]any{
+			"todo_id":  todo.ID,
+			"list_id":  list.ID,
+			"actor_id": job.ActorID,
+			"changes":  job.Changes,
+		},
+	})
+}
+
+// NotifyActivity sends an in-app notification about the activity to the
+// other members of the list, or to the invitee. Activities of todos or lists
+// deleted since are skipped.
+func (n *SharedTodoNotifier) NotifyActivity(ctx context.Context, job ListActivity) error {
+	list, err := n.todoListRepository.FindByID(ctx, job.ListID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	actor, err := n.actorName(ctx, job.ActorID)
+	if err != nil {
+		return err
+	}
+
+	if job.Kind == ListActivityInvited {
+		return n.send(ctx, []uint{job.InviteeID}, job.ActorID, notify.Message{
+			ID:      fmt.Sprintf("list-%d-invited-%d-%d", list.ID, job.InviteeID, job.OccurredAt.UnixNano()),
+			Kind:    job.Kind,
+			Subject: fmt.Sprintf("You are invited to %s", list.Name),
+			Body:    fmt.Sprintf("%s invited you to %s as %s.", actor, list.Name, job.Role),
+			Data: map[string]any{
+				"list_id":  list.ID,
+				"actor_id": job.ActorID,
+				"role":     job.Role,
+			},
+		})
+	}
+
+	todo, err := n.todoRepository.FindByID(ctx, job.TodoID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var subject, body string
+	switch job.Kind {
+	case ListActivityTodoCreated:
+		subject = fmt.Sprintf("%s was added to %s", todo.Name, list.Name)
+		body = fmt.Sprintf("%s added %q to %s.", actor, todo.Name, list.Name)
+	case ListActivityTodoMovedIn:
+		subject = fmt.Sprintf("%s was moved to %s", todo.Name, list.Name)
+		body = fmt.Sprintf("%s moved %q to %s.", actor, todo.Name, list.Name)
+	case ListActivityTodoMovedOut:
+		subject = fmt.Sprintf("%s was moved out of %s", todo.Name, list.Name)
+		body = fmt.Sprintf("%s moved %q out of %s.", actor, todo.Name, list.Name)
+	default:
+		return fmt.Errorf("unknown list activity %q", job.Kind)
+	}
+
+	recipients, err := n.members(ctx, list)
+	if err != nil {
+		return err
+	}
+	return n.send(ctx, recipients, job.ActorID, notify.Message{
+		ID:      fmt.Sprintf("todo-%d-%s-%d", todo.ID, job.Kind, job.OccurredAt.UnixNano()),
+		Kind:    job.Kind,
+		Subject: subject,
+		Body:    body,
+		Data: map[string]any{
+			"todo_id":  todo.ID,
+			"list_id":  list.ID,
+			"actor_id": job.ActorID,
+		},
+	})
+}
+
+// members returns the owner and members of the list
+func (n *SharedTodoNotifier) members(ctx context.Context, list *model.TodoList) ([]uint, error) {
+	members, err := n.listMemberRepository.List(ctx, list.ID)
+	if err != nil {
+		return nil, err
+	}
+	userIDs := []uint{list.OwnerID}
+	for _, member := range members {
+		userIDs = append(userIDs, member.UserID)
+	}
+	return userIDs, nil
+}
+
+// actorName returns the name of the user who did something, "Someone" once
+// they deleted their account
+func (n *SharedTodoNotifier) actorName(ctx context.Context, actorID uint) (string, error) {
+	user, err := n.userService.GetUserById(ctx, actorID)
+	if errors.Is(err, _userService.ErrUserNotFound) {
+		return "Someone", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return user.Name, nil
+}
+
+// send sends the message in-app to the recipients but the actor
+func (n *SharedTodoNotifier) send(ctx context.Context, recipients []uint, actorID uint, msg notify.Message) error {
+	errs := []error{}
+	for _, userID := range recipients {
+		if userID == actorID {
+			continue
+		}
+		msg.UserID = userID
+		errs = append(errs, n.notifier.Send(ctx, notify.ChannelInApp, msg))
+	}
+	return errors.Join(errs...)
+}
+
+// describeChanges phrases the changes of an update, e.g. "completed" or
+// "changed the name and due date of"
+func describeChanges(changes []string) string {
+	fields := []string{}
+	verb := ""
+	for _, change := range changes {
+		switch change {
+		case TodoChangeDone:
+			verb = "completed"
+		case TodoChangeReopened:
+			verb = "reopened"
+		case TodoChangeDueAt:
+			fields = append(fields, "due date")
+		default:
+			fields = append(fields, change)
+		}
+	}
+
+	if len(fields) == 0 {
+		return verb
+	}
+	described := "changed the " + fields[0]
+	if len(fields) > 1 {
+		described = "changed the " + strings.Join(fields[:len(fields)-1], ", ") + " and " + fields[len(fields)-1]
+	}
+	if verb != "" {
+		return verb + " and " + described + " of"
+	}
+	return described + " of"
+}
@@ -0,0 +1,134 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/internal/todo/repository"
+	"khiemle.dev/golang-api-template/internal/todo/service"
+	"khiemle.dev/golang-api-template/pkg/database/databasetest"
+	"khiemle.dev/golang-api-template/pkg/jobqueue"
+	"khiemle.dev/golang-api-template/pkg/outbox"
+	"khiemle.dev/golang-api-template/pkg/pubsub"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+// newTodoService creates the todo service on a temporary database
+func newTodoService(t *testing.T) (service.TodoService, *gorm.DB) {
+	t.Helper()
+	db := databasetest.New(t)
+	ps := pubsub.NewLocal()
+	svc := service.NewTodoService(
+		repository.NewGormTodoRepository(db),
+		repository.NewGormLabelRepository(db),
+		repository.NewGormTodoListRepository(db),
+		repository.NewGormListMemberRepository(db),
+		repository.NewGormChecklistItemRepository(db),
+		repository.NewGormTodoSeriesRepository(db),
+		repository.NewGormReminderRepository(db),
+		repository.NewGormTodoEventRepository(db),
+		jobqueue.NewClient(db),
+		ps,
+		txn.NewGormTransactor(db),
+		outbox.NewRecorder(db, ps),
+	)
+	return svc, db
+}
+
+func TestRestoreSubtaskOfTrashedParent(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := newTodoService(t)
+
+	const alice = 1
+	parent, err := svc.CreateTodo(ctx, alice, service.CreateTodoArgs{Name: "parent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentID := int(parent.ID)
+	subtask, err := svc.CreateTodo(ctx, alice, service.CreateTodoArgs{Name: "subtask", ParentID: &parentID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.DeleteTodo(ctx, alice, int(subtask.ID)); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.DeleteTodo(ctx, alice, parentID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.RestoreTodo(ctx, alice, int(subtask.ID)); !errors.Is(err, service.ErrParentTrashed) {
+		t.Fatalf("restore subtask = %v, want %v", err, service.ErrParentTrashed)
+	}
+
+	// It goes back under its parent once the parent is restored
+	if _, err := svc.RestoreTodo(ctx, alice, parentID); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := svc.RestoreTodo(ctx, alice, int(subtask.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.ParentID == nil || *restored.ParentID != parent.ID {
+		t.Errorf("parent = %v, want %d", restored.ParentID, parent.ID)
+	}
+}
+
+func TestRestoreSubtaskOfParentNoLongerEditable(t *testing.T) {
+	ctx := context.Background()
+	svc, db := newTodoService(t)
+
+	// Bob edits the list of Alice
+	const alice, bob = 1, 2
+	list := model.TodoList{OwnerID: alice, Name: "shared"}
+	if err := db.Create(&list).Error; err != nil {
+		t.Fatal(err)
+	}
+	members := []model.ListMember{
+		{ListID: list.ID, UserID: alice, Role: model.ListRoleOwner},
+		{ListID: list.ID, UserID: bob, Role: model.ListRoleEditor},
+	}
+	if err := db.Create(&members).Error; err != nil {
+		t.Fatal(err)
+	}
+	listID := int(list.ID)
+	parent, err := svc.CreateTodo(ctx, alice, service.CreateTodoArgs{Name: "parent", ListID: &listID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentID := int(parent.ID)
+	subtask, err := svc.CreateTodo(ctx, bob, service.CreateTodoArgs{Name: "subtask", ParentID: &parentID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.DeleteTodo(ctx, bob, int(subtask.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Alice takes the parent out of the list
+	if _, err := svc.MoveTodo(ctx, alice, parentID, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.RestoreTodo(ctx, bob, int(subtask.ID)); !errors.Is(err, service.ErrParentForbidden) {
+		t.Fatalf("restore subtask = %v, want %v", err, service.ErrParentForbidden)
+	}
+	trash, err := svc.ListTrash(ctx, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 1 || trash[0].ID != subtask.ID {
+		t.Errorf("trash = %+v, want the subtask", trash)
+	}
+
+	// Alice can restore it under her parent
+	restored, err := svc.RestoreTodo(ctx, alice, int(subtask.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.ListID != nil || restored.OwnerID != alice {
+		t.Errorf("restored into list %v owned by %d, want no list owned by %d", restored.ListID, restored.OwnerID, alice)
+	}
+}
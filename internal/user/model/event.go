@@ -0,0 +1,18 @@
+package model
+
+// Domain events of users, recorded in the outbox with the user as aggregate
+const (
+	AggregateUser = "user"
+
+	EventUserRegistered      = "user.registered"
+	EventUserUpdated         = "user.updated"
+	EventUserPasswordChanged = "user.password_changed"
+	EventUserDeleted         = "user.deleted"
+)
+
+// UserChange is the payload of the events of users. Before is nil when the
+// user registered and After once it was deleted.
+type UserChange struct {
+	Before *User `json:"before"`
+	After  *User `json:"after"`
+}
@@ -7,7 +7,8 @@ type User struct {
 	Username       string    `json:"username" gorm:"size:255;uniqueIndex;not null"`
 	Email          string    `json:"email" gorm:"size:255;uniqueIndex;not null"`
 	Name           string    `json:"name" gorm:"size:255;not null"`
-	HashedPassword string    `json:"hashed_password" gorm:"not null"`
+	HashedPassword string    `json:"-" gorm:"not null"`
+	IsAdmin        bool      `json:"is_admin" gorm:"not null;default:false"`
 	CreatedAt      time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt      time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 }
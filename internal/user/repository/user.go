@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/user/model"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+// UserRepository persists users. Lookups of missing users return
+// gorm.ErrRecordNotFound and a taken username or email returns
+// gorm.ErrDuplicatedKey regardless of the implementation.
+type UserRepository interface {
+	Create(ctx context.Context, user *model.User) error
+	FindByID(ctx context.Context, id uint) (*model.User, error)
+	FindByUsername(ctx context.Context, username string) (*model.User, error)
+	FindByEmail(ctx context.Context, email string) (*model.User, error)
+	List(ctx context.Context) ([]model.User, error)
+	// Update loads the user, applies fn and saves the result atomically.
+	Update(ctx context.Context, id uint, fn func(user *model.User) error) (*model.User, error)
+	Delete(ctx context.Context, id uint) error
+}
+
+type gormUserRepository struct {
+	db *gorm.DB
+}
+
+func NewGormUserRepository(db *gorm.DB) UserRepository {
+	return &gormUserRepository{
+		db: db,
+	}
+}
+
+func (r *gormUserRepository) Create(ctx context.Context, user *model.User) error {
+	return txn.DB(ctx, r.db).Create(user).Error
+}
+
+func (r *gormUserRepository) FindByID(ctx context.Context, id uint) (*model.User, error) {
+	return r.findBy(ctx, "id = ?", id)
+}
+
+func (r *gormUserRepository) FindByUsername(ctx context.Context, username string) (*model.User, error) {
+	return r.findBy(ctx, "username = ?", username)
+}
+
+func (r *gormUserRepository) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	return r.findBy(ctx, "email = ?", email)
+}
+
+func (r *gormUserRepository) findBy(ctx context.Context, query string, args ...any) (*model.User, error) {
+	user := model.User{}
+	err := txn.DB(ctx, r.db).Where(query, args...).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) List(ctx context.Context) ([]model.User, error) {
+	users := []model.User{}
+	err := txn.DB(ctx, r.db).Order("id").Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *gormUserRepository) Update(ctx context.Context, id uint, fn func(user *model.User) error) (*model.User, error) {
+	user := model.User{}
+	err := txn.DB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := fn(&user); err != nil {
+			return err
+		}
+		return tx.Save(&user).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) Delete(ctx context.Context, id uint) error {
+	tx := txn.DB(ctx, r.db).Delete(&model.User{}, "id = ?", id)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/user/model"
+)
+
+type memoryUserRepository struct {
+	mu     sync.RWMutex
+	nextID uint
+	users  map[uint]model.User
+}
+
+// NewMemoryUserRepository creates a UserRepository backed by a map, for tests
+// and demos that run without a database. Usernames and emails are unique as
+// with the database indexes.
+func NewMemoryUserRepository() UserRepository {
+	return &memoryUserRepository{
+		users: map[uint]model.User{},
+	}
+}
+
+func (r *memoryUserRepository) Create(ctx context.Context, user *model.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conflicts(0, user) {
+		return gorm.ErrDuplicatedKey
+	}
+
+	r.nextID++
+	now := time.Now()
+	user.ID = r.nextID
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	r.users[user.ID] = *user
+	return nil
+}
+
+func (r *memoryUserRepository) FindByID(ctx context.Context, id uint) (*model.User, error) {
+	return r.findBy(func(user *model.User) bool { return user.ID == id })
+}
+
+func (r *memoryUserRepository) FindByUsername(ctx context.Context, username string) (*model.User, error) {
+	return r.findBy(func(user *model.User) bool { return user.Username == username })
+}
+
+func (r *memoryUserRepository) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	return r.findBy(func(user *model.User) bool { return user.Email == email })
+}
+
+func (r *memoryUserRepository) findBy(match func(user *model.User) bool) (*model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if match(&user) {
+			return &user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryUserRepository) List(ctx context.Context) ([]model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := make([]model.User, 0, len(r.users))
+	for _, user := range r.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+func (r *memoryUserRepository) Update(ctx context.Context, id uint, fn func(user *model.User) error) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err := fn(&user); err != nil {
+		return nil, err
+	}
+	if r.conflicts(id, &user) {
+		return nil, gorm.ErrDuplicatedKey
+	}
+	user.UpdatedAt = time.Now()
+	r.users[id] = user
+	return &user, nil
+}
+
+func (r *memoryUserRepository) Delete(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[id]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.users, id)
+	return nil
+}
+
+// conflicts reports whether another user than id already has the username or
+// email of user. Must be called with the lock held.
+func (r *memoryUserRepository) conflicts(id uint, user *model.User) bool {
+	for _, other := range r.users {
+		if other.ID == id {
+			continue
+		}
+		if other.Username == user.Username || other.Email == user.Email {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/user/model"
+	"khiemle.dev/golang-api-template/internal/user/repository"
+	"khiemle.dev/golang-api-template/pkg/database/databasetest"
+)
+
+// TestUserRepository runs the same contract against every implementation
+func TestUserRepository(t *testing.T) {
+	implementations := map[string]func(t *testing.T) repository.UserRepository{
+		"gorm": func(t *testing.T) repository.UserRepository {
+			return repository.NewGormUserRepository(databasetest.New(t))
+		},
+		"memory": func(t *testing.T) repository.UserRepository {
+			return repository.NewMemoryUserRepository()
+		},
+	}
+	for name, newRepository := range implementations {
+		t.Run(name, func(t *testing.T) {
+			testUserRepository(t, newRepository(t))
+		})
+	}
+}
+
+func testUserRepository(t *testing.T, r repository.UserRepository) {
+	ctx := context.Background()
+
+	alice := &model.User{Username: "alice", Email: "alice@example.com", Name: "Alice", HashedPassword: "x"}
+	if err := r.Create(ctx, alice); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if alice.ID == 0 || alice.CreatedAt.IsZero() {
+		t.Fatalf("Create did not set the id and timestamps: %+v", alice)
+	}
+
+	t.Run("duplicates", func(t *testing.T) {
+		sameUsername := &model.User{Username: "alice", Email: "other@example.com", Name: "A", HashedPassword: "x"}
+		if err := r.Create(ctx, sameUsername); !errors.Is(err, gorm.ErrDuplicatedKey) {
+			t.Errorf("Create with a taken username = %v, want gorm.ErrDuplicatedKey", err)
+		}
+		sameEmail := &model.User{Username: "other", Email: "alice@example.com", Name: "A", HashedPassword: "x"}
+		if err := r.Create(ctx, sameEmail); !errors.Is(err, gorm.ErrDuplicatedKey) {
+			t.Errorf("Create with a taken email = %v, want gorm.ErrDuplicatedKey", err)
+		}
+	})
+
+	t.Run("find", func(t *testing.T) {
+		for name, find := range map[string]func() (*model.User, error){
+			"id":       func() (*model.User, error) { return r.FindByID(ctx, alice.ID) },
+			"username": func() (*model.User, error) { return r.FindByUsername(ctx, "alice") },
+			"email":    func() (*model.User, error) { return r.FindByEmail(ctx, "alice@example.com") },
+		} {
+			user, err := find()
+			if err != nil {
+				t.Fatalf("find by %s: %v", name, err)
+			}
+			if user.ID != alice.ID || user.Name != "Alice" {
+				t.Errorf("find by %s = %+v, want %+v", name, user, alice)
+			}
+		}
+		if _, err := r.FindByUsername(ctx, "nobody"); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("FindByUsername of a missing user = %v, want gorm.ErrRecordNotFound", err)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		bob := &model.User{Username: "bob", Email: "bob@example.com", Name: "Bob", HashedPassword: "x"}
+		if err := r.Create(ctx, bob); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		user, err := r.Update(ctx, alice.ID, func(user *model.User) error {
+			user.Name = "Alice A."
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if user.Name != "Alice A." {
+			t.Errorf("Update returned name %q", user.Name)
+		}
+
+		_, err = r.Update(ctx, alice.ID, func(user *model.User) error {
+			user.Email = "bob@example.com"
+			return nil
+		})
+		if !errors.Is(err, gorm.ErrDuplicatedKey) {
+			t.Errorf("Update to a taken email = %v, want gorm.ErrDuplicatedKey", err)
+		}
+
+		failed := errors.New("failed")
+		_, err = r.Update(ctx, alice.ID, func(user *model.User) error {
+			user.Name = "lost"
+			return failed
+		})
+		if !errors.Is(err, failed) {
+			t.Errorf("Update = %v, want the error of fn", err)
+		}
+		if user, _ := r.FindByID(ctx, alice.ID); user.Name != "Alice A." {
+			t.Errorf("a failed Update saved name %q", user.Name)
+		}
+
+		if _, err := r.Update(ctx, 999, func(*model.User) error { return nil }); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Update of a missing user = %v, want gorm.ErrRecordNotFound", err)
+		}
+	})
+
+	t.Run("list and delete", func(t *testing.T) {
+		users, err := r.List(ctx)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(users) != 2 || users[0].ID > users[1].ID {
+			t.Fatalf("List = %+v, want two users by id", users)
+		}
+
+		if err := r.Delete(ctx, alice.ID); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if _, err := r.FindByID(ctx, alice.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("FindByID after Delete = %v, want gorm.ErrRecordNotFound", err)
+		}
+		if err := r.Delete(ctx, alice.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("second Delete = %v, want gorm.ErrRecordNotFound", err)
+		}
+	})
+}
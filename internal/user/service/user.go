@@ -1,35 +1,76 @@
 package service
 
 import (
-	"github.com/gin-gonic/gin"
+	"context"
+	"errors"
+	"net/http"
+
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 	"khiemle.dev/golang-api-template/internal/user/model"
+	"khiemle.dev/golang-api-template/internal/user/repository"
+	"khiemle.dev/golang-api-template/pkg/apperror"
+	"khiemle.dev/golang-api-template/pkg/outbox"
+	"khiemle.dev/golang-api-template/pkg/tracing"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+var (
+	ErrUserNotFound    = apperror.NotFound(apperror.CodeUserNotFound, "user not found")
+	ErrUsernameTaken   = apperror.Conflict(apperror.CodeUsernameTaken, "username is already taken")
+	ErrEmailTaken      = apperror.Conflict(apperror.CodeEmailTaken, "email is already registered")
+	ErrWrongPassword   = apperror.New(apperror.CodeInvalidCredentials, http.StatusUnauthorized, "current password is incorrect")
+	ErrPasswordConfirm = apperror.Validation(apperror.FieldError{
+		Field:   "confirm_password",
+		Code:    "eqfield",
+		Message: "must match the password",
+	})
 )
 
+// UserService manages users. Changes are recorded in the outbox as domain
+// events of the user, in the transaction of the change.
 type UserService interface {
-	CreateUser(ctx *gin.Context, username string, email string, name string, password string) (*model.User, error)
-	GetUserById(ctx *gin.Context, id uint) (*model.User, error)
-	GetUserByUsername(ctx *gin.Context, username string) (*model.User, error)
-	GetUserByEmail(ctx *gin.Context, email string) (*model.User, error)
-	ListUser(ctx *gin.Context) ([]model.User, error)
-	UpdateUser(ctx *gin.Context, id uint, name string, email string) (*model.User, error)
-	UpdatePassword(ctx *gin.Context, id uint, password string, newPassword string, confirmPassword string) error
-	DeleteUser(ctx *gin.Context, id uint) error
+	CreateUser(ctx context.Context, username string, email string, name string, password string) (*model.User, error)
+	GetUserById(ctx context.Context, id uint) (*model.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*model.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*model.User, error)
+	ListUser(ctx context.Context) ([]model.User, error)
+	UpdateUser(ctx context.Context, id uint, name string, email string) (*model.User, error)
+	UpdatePassword(ctx context.Context, id uint, password string, newPassword string, confirmPassword string) error
+	DeleteUser(ctx context.Context, id uint) error
 }
 
 type userService struct {
-	db *gorm.DB
+	userRepository repository.UserRepository
+	transactor     txn.Transactor
+	outbox         outbox.Recorder
 }
 
-func NewUserService(db *gorm.DB) UserService {
+func NewUserService(userRepository repository.UserRepository, transactor txn.Transactor, recorder outbox.Recorder) UserService {
 	return &userService{
-		db: db,
+		userRepository: userRepository,
+		transactor:     transactor,
+		outbox:         recorder,
 	}
 }
 
-func (s *userService) CreateUser(ctx *gin.Context, username string, email string, name string, password string) (*model.User, error) {
+func (s *userService) CreateUser(ctx context.Context, username string, email string, name string, password string) (*model.User, error) {
+	// Report which field is taken, the unique indexes remain the source of
+	// truth for concurrent registrations
+	if _, err := s.GetUserByUsername(ctx, username); err == nil {
+		return nil, ErrUsernameTaken
+	} else if !errors.Is(err, ErrUserNotFound) {
+		return nil, err
+	}
+	if _, err := s.GetUserByEmail(ctx, email); err == nil {
+		return nil, ErrEmailTaken
+	} else if !errors.Is(err, ErrUserNotFound) {
+		return nil, err
+	}
+
+	_, span := tracing.Tracer().Start(ctx, "bcrypt.GenerateFromPassword")
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	tracing.End(span, err)
 	if err != nil {
 		return nil, err
 	}
@@ -41,9 +82,15 @@ func (s *userService) CreateUser(ctx *gin.Context, username string, email string
 		HashedPassword: string(hashedPassword),
 	}
 
-	err = s.db.Transaction(func(tx *gorm.DB) error {
-		return tx.Create(&user).Error
+	err = s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		if err := s.userRepository.Create(ctx, user); err != nil {
+			return err
+		}
+		return s.record(ctx, model.EventUserRegistered, user.ID, nil, user)
 	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, apperror.Wrap(err, apperror.CodeConflict, http.StatusConflict, "username or email is already registered")
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -51,94 +98,119 @@ func (s *userService) CreateUser(ctx *gin.Context, username string, email string
 	return user, nil
 }
 
-func (s *userService) GetUserById(ctx *gin.Context, id uint) (*model.User, error) {
-	user := &model.User{}
-	tx := s.db.First(&user, "id = ?", id)
-	if tx.Error != nil {
-		return nil, tx.Error
-	}
-	return user, nil
+func (s *userService) GetUserById(ctx context.Context, id uint) (*model.User, error) {
+	user, err := s.userRepository.FindByID(ctx, id)
+	return user, translateError(err)
 }
 
-func (s *userService) GetUserByUsername(ctx *gin.Context, username string) (*model.User, error) {
-	user := &model.User{}
-	tx := s.db.First(&user, "username = ?", username)
-	if tx.Error != nil {
-		return nil, tx.Error
-	}
-	return user, nil
+func (s *userService) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
+	user, err := s.userRepository.FindByUsername(ctx, username)
+	return user, translateError(err)
 }
 
-func (s *userService) GetUserByEmail(ctx *gin.Context, email string) (*model.User, error) {
-	user := &model.User{}
-	tx := s.db.First(&user, "email = ?", email)
-	if tx.Error != nil {
-		return nil, tx.Error
-	}
-	return user, nil
+func (s *userService) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	user, err := s.userRepository.FindByEmail(ctx, email)
+	return user, translateError(err)
 }
 
-func (s *userService) ListUser(ctx *gin.Context) ([]model.User, error) {
-	users := []model.User{}
-	tx := s.db.Find(&users)
-	if tx.Error != nil {
-		return nil, tx.Error
-	}
-	return users, nil
+func (s *userService) ListUser(ctx context.Context) ([]model.User, error) {
+	return s.userRepository.List(ctx)
 }
 
-func (s *userService) UpdateUser(ctx *gin.Context, id uint, name string, email string) (*model.User, error) {
-	user := &model.User{}
-	err := s.db.Transaction(func(tx *gorm.DB) error {
-		err := tx.Find(user, "id = ?", id).Error
+func (s *userService) UpdateUser(ctx context.Context, id uint, name string, email string) (*model.User, error) {
+	user, err := txn.Run(ctx, s.transactor, func(ctx context.Context) (*model.User, error) {
+		var before model.User
+		user, err := s.userRepository.Update(ctx, id, func(user *model.User) error {
+			before = *user
+			if name != "" {
+				user.Name = name
+			}
+			if email != "" {
+				user.Email = email
+			}
+			return nil
+		})
 		if err != nil {
-			return err
-		}
-
-		if name != "" {
-			user.Name = name
+			return nil, err
 		}
-		if email != "" {
-			user.Email = email
-		}
-
-		return tx.Save(user).Error
+		return user, s.record(ctx, model.EventUserUpdated, id, &before, user)
 	})
-	return user, err
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, ErrEmailTaken
+	}
+	return user, translateError(err)
 }
 
-func (s *userService) UpdatePassword(ctx *gin.Context, id uint, currentPassword string, newPassword string, confirmPassword string) error {
+func (s *userService) UpdatePassword(ctx context.Context, id uint, currentPassword string, newPassword string, confirmPassword string) error {
 	if newPassword != confirmPassword {
-		return nil
+		return ErrPasswordConfirm
 	}
 
-	user := &model.User{}
-	err := s.db.First(user, "id = ?", id).Error
+	user, err := s.GetUserById(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(currentPassword)); err != nil {
+	_, span := tracing.Tracer().Start(ctx, "bcrypt.CompareHashAndPassword")
+	err = bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(currentPassword))
+	tracing.End(span, err)
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrWrongPassword
+	}
+	if err != nil {
 		return err
 	}
 
+	_, span = tracing.Tracer().Start(ctx, "bcrypt.GenerateFromPassword")
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	tracing.End(span, err)
 	if err != nil {
 		return err
 	}
 
-	err = s.db.Transaction(func(tx *gorm.DB) error {
-		user.HashedPassword = string(hashedPassword)
-		return tx.Save(user).Error
+	err = s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		user, err := s.userRepository.Update(ctx, id, func(user *model.User) error {
+			user.HashedPassword = string(hashedPassword)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return s.record(ctx, model.EventUserPasswordChanged, id, user, user)
 	})
-	return err
+	return translateError(err)
 }
 
-func (s *userService) DeleteUser(ctx *gin.Context, id uint) error {
-	return s.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Delete(&model.User{}, "id = ?", id).Error; err != nil {
+func (s *userService) DeleteUser(ctx context.Context, id uint) error {
+	err := s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		user, err := s.userRepository.FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := s.userRepository.Delete(ctx, id); err != nil {
 			return err
 		}
-		return nil
+		return s.record(ctx, model.EventUserDeleted, id, user, nil)
+	})
+	return translateError(err)
+}
+
+// record records a domain event of the user in the outbox
+func (s *userService) record(ctx context.Context, eventType string, id uint, before *model.User, after *model.User) error {
+	event, err := outbox.NewEvent(eventType, model.AggregateUser, id, model.UserChange{
+		Before: before,
+		After:  after,
 	})
+	if err != nil {
+		return err
+	}
+	return s.outbox.Record(ctx, event)
+}
+
+// translateError turns repository errors into domain errors
+func translateError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrUserNotFound
+	}
+	return err
 }
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"khiemle.dev/golang-api-template/internal/user/model"
+	"khiemle.dev/golang-api-template/pkg/cache"
+)
+
+const userCacheSize = 10000
+
+type cachedUserService struct {
+	UserService
+	byID *cache.TTLCache[uint, model.User]
+}
+
+// NewCachedUserService caches GetUserById lookups of next for ttl. Entries are
+// invalidated when the user is changed through this service; other replicas
+// see the change once their entry expires.
+func NewCachedUserService(next UserService, ttl time.Duration) UserService {
+	return &cachedUserService{
+		UserService: next,
+		byID:        cache.NewTTLCache[uint, model.User](ttl, userCacheSize),
+	}
+}
+
+func (s *cachedUserService) GetUserById(ctx context.Context, id uint) (*model.User, error) {
+	if user, ok := s.byID.Get(id); ok {
+		return &user, nil
+	}
+
+	user, err := s.UserService.GetUserById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.byID.Set(id, *user)
+	return user, nil
+}
+
+func (s *cachedUserService) UpdateUser(ctx context.Context, id uint, name string, email string) (*model.User, error) {
+	defer s.byID.Delete(id)
+	return s.UserService.UpdateUser(ctx, id, name, email)
+}
+
+func (s *cachedUserService) UpdatePassword(ctx context.Context, id uint, password string, newPassword string, confirmPassword string) error {
+	defer s.byID.Delete(id)
+	return s.UserService.UpdatePassword(ctx, id, password, newPassword, confirmPassword)
+}
+
+func (s *cachedUserService) DeleteUser(ctx context.Context, id uint) error {
+	defer s.byID.Delete(id)
+	return s.UserService.DeleteUser(ctx, id)
+}
@@ -0,0 +1,149 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/user/model"
+	"khiemle.dev/golang-api-template/internal/user/repository"
+	"khiemle.dev/golang-api-template/internal/user/service"
+	"khiemle.dev/golang-api-template/pkg/database/databasetest"
+	"khiemle.dev/golang-api-template/pkg/outbox"
+	"khiemle.dev/golang-api-template/pkg/pubsub"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+const password = "Passw0rd!"
+
+func newUserService(t *testing.T) (service.UserService, *gorm.DB) {
+	db := databasetest.New(t)
+	userService := service.NewUserService(
+		repository.NewGormUserRepository(db),
+		txn.NewGormTransactor(db),
+		outbox.NewRecorder(db, pubsub.NewLocal()),
+	)
+	return userService, db
+}
+
+// eventTypes returns the types of the events recorded for the user
+func eventTypes(t *testing.T, db *gorm.DB, userID uint) []string {
+	t.Helper()
+	types := []string{}
+	err := db.Model(&outbox.Event{}).
+		Where("aggregate_type = ? AND aggregate_id = ?", model.AggregateUser, userID).
+		Order("id").
+		Pluck("type", &types).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	return types
+}
+
+func TestCreateUser(t *testing.T) {
+	ctx := context.Background()
+	userService, db := newUserService(t)
+
+	user, err := userService.CreateUser(ctx, "alice", "alice@example.com", "Alice", password)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if user.HashedPassword == "" || user.HashedPassword == password {
+		t.Errorf("CreateUser stored password %q, want a hash", user.HashedPassword)
+	}
+	if got := eventTypes(t, db, user.ID); len(got) != 1 || got[0] != model.EventUserRegistered {
+		t.Errorf("events = %v, want [%s]", got, model.EventUserRegistered)
+	}
+
+	_, err = userService.CreateUser(ctx, "alice", "other@example.com", "Alice", password)
+	if !errors.Is(err, service.ErrUsernameTaken) {
+		t.Errorf("CreateUser with a taken username = %v, want ErrUsernameTaken", err)
+	}
+	_, err = userService.CreateUser(ctx, "other", "alice@example.com", "Alice", password)
+	if !errors.Is(err, service.ErrEmailTaken) {
+		t.Errorf("CreateUser with a taken email = %v, want ErrEmailTaken", err)
+	}
+}
+
+func TestUpdatePassword(t *testing.T) {
+	ctx := context.Background()
+	userService, db := newUserService(t)
+
+	user, err := userService.CreateUser(ctx, "alice", "alice@example.com", "Alice", password)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		current         string
+		newPassword     string
+		confirmPassword string
+		want            error
+	}{
+		{"confirmation differs", password, "N3wPassw0rd!", "other", service.ErrPasswordConfirm},
+		{"wrong current password", "wrong", "N3wPassw0rd!", "N3wPassw0rd!", service.ErrWrongPassword},
+		{"changed", password, "N3wPassw0rd!", "N3wPassw0rd!", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := userService.UpdatePassword(ctx, user.ID, tt.current, tt.newPassword, tt.confirmPassword)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("UpdatePassword = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	want := []string{model.EventUserRegistered, model.EventUserPasswordChanged}
+	if got := eventTypes(t, db, user.ID); len(got) != len(want) || got[1] != want[1] {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestUpdateAndDeleteUser(t *testing.T) {
+	ctx := context.Background()
+	userService, db := newUserService(t)
+
+	alice, err := userService.CreateUser(ctx, "alice", "alice@example.com", "Alice", password)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if _, err := userService.CreateUser(ctx, "bob", "bob@example.com", "Bob", password); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	// Empty fields are left unchanged
+	user, err := userService.UpdateUser(ctx, alice.ID, "Alice A.", "")
+	if err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if user.Name != "Alice A." || user.Email != "alice@example.com" {
+		t.Errorf("UpdateUser = %+v", user)
+	}
+	if _, err := userService.UpdateUser(ctx, alice.ID, "", "bob@example.com"); !errors.Is(err, service.ErrEmailTaken) {
+		t.Errorf("UpdateUser to a taken email = %v, want ErrEmailTaken", err)
+	}
+
+	if err := userService.DeleteUser(ctx, alice.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if _, err := userService.GetUserById(ctx, alice.ID); !errors.Is(err, service.ErrUserNotFound) {
+		t.Errorf("GetUserById after DeleteUser = %v, want ErrUserNotFound", err)
+	}
+	if err := userService.DeleteUser(ctx, alice.ID); !errors.Is(err, service.ErrUserNotFound) {
+		t.Errorf("second DeleteUser = %v, want ErrUserNotFound", err)
+	}
+
+	want := []string{model.EventUserRegistered, model.EventUserUpdated, model.EventUserDeleted}
+	got := eventTypes(t, db, alice.ID)
+	if len(got) != len(want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("events = %v, want %v", got, want)
+			break
+		}
+	}
+}
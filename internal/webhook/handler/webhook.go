@@ -0,0 +1,425 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"khiemle.dev/golang-api-template/internal/schemas"
+	"khiemle.dev/golang-api-template/internal/webhook/service"
+	"khiemle.dev/golang-api-template/pkg/apperror"
+	"khiemle.dev/golang-api-template/pkg/middleware"
+)
+
+type WebhookHandler interface {
+	CreateWebhookHandler(c *gin.Context)
+	ListWebhookHandler(c *gin.Context)
+	GetWebhookHandler(c *gin.Context)
+	UpdateWebhookHandler(c *gin.Context)
+	DeleteWebhookHandler(c *gin.Context)
+	RotateSecretHandler(c *gin.Context)
+	PingHandler(c *gin.Context)
+	ListDeliveryHandler(c *gin.Context)
+	GetDeliveryHandler(c *gin.Context)
+	ReplayDeliveryHandler(c *gin.Context)
+}
+
+type webhookHandler struct {
+	webhookService service.WebhookService
+}
+
+func NewWebhookHandler(webhookService service.WebhookService) WebhookHandler {
+	return &webhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+// CreateWebhookHandler godoc
+
+// @Summary		Create webhook
+// @Description	Register an endpoint receiving the selected events of the current user, or of every user for administrators. The signing secret is only returned here and when it is rotated.
+// @Tags			webhooks
+// @Accept			json
+// @Produce		json
+// @Param			request	body		schemas.CreateWebhookRequest	true	"Webhook"
+// @Success		200		{object}	schemas.APIResponse{data=schemas.WebhookSecretResponse}
+// @Failure		401		{object}	schemas.ProblemDetails
+// @Failure		403		{object}	schemas.ProblemDetails
+// @Failure		422		{object}	schemas.ProblemDetails
+// @Failure		500		{object}	schemas.ProblemDetails
+// @Router			/webhooks/ [post]
+// @Security		BearerAuth
+func (h *webhookHandler) CreateWebhookHandler(c *gin.Context) {
+	req := schemas.CreateWebhookRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	endpoint, err := h.webhookService.CreateWebhook(c.Request.Context(), middleware.CurrentUser(c), service.CreateWebhookArgs{
+		URL:         req.URL,
+		Description: req.Description,
+		Events:      req.Events,
+		AllUsers:    req.AllUsers,
+	})
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    schemas.NewWebhookSecretResponse(endpoint),
+	})
+}
+
+// ListWebhookHandler godoc
+
+// @Summary		List webhooks
+// @Description	List the webhooks of the current user
+// @Tags			webhooks
+// @Accept			json
+// @Produce		json
+// @Success		200	{object}	schemas.ListWebhookResponse
+// @Failure		401	{object}	schemas.ProblemDetails
+// @Failure		500	{object}	schemas.ProblemDetails
+// @Router			/webhooks/ [get]
+// @Security		BearerAuth
+func (h *webhookHandler) ListWebhookHandler(c *gin.Context) {
+	endpoints, err := h.webhookService.ListWebhooks(c.Request.Context(), middleware.CurrentUser(c).ID)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.ListWebhookResponse{
+		Status:   http.StatusOK,
+		Message:  http.StatusText(http.StatusOK),
+		Webhooks: schemas.NewWebhookResponses(endpoints),
+	})
+}
+
+// GetWebhookHandler godoc
+
+// @Summary		Get webhook
+// @Description	Get a webhook of the current user
+// @Tags			webhooks
+// @Accept			json
+// @Produce		json
+// @Param			id	path		int	true	"Webhook id"
+// @Success		200	{object}	schemas.APIResponse{data=schemas.WebhookResponse}
+// @Failure		401	{object}	schemas.ProblemDetails
+// @Failure		404	{object}	schemas.ProblemDetails
+// @Failure		500	{object}	schemas.ProblemDetails
+// @Router			/webhooks/{id} [get]
+// @Security		BearerAuth
+func (h *webhookHandler) GetWebhookHandler(c *gin.Context) {
+	id, err := parseIntParam(c, "id")
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	endpoint, err := h.webhookService.GetWebhook(c.Request.Context(), middleware.CurrentUser(c).ID, id)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    schemas.NewWebhookResponse(endpoint),
+	})
+}
+
+// UpdateWebhookHandler godoc
+
+// @Summary		Update webhook
+// @Description	Update a webhook of the current user. Set enabled to true to enable it again after it was disabled.
+// @Tags			webhooks
+// @Accept			json
+// @Produce		json
+// @Param			id		path		int								true	"Webhook id"
+// @Param			request	body		schemas.UpdateWebhookRequest	true	"Changes"
+// @Success		200		{object}	schemas.APIResponse{data=schemas.WebhookResponse}
+// @Failure		401		{object}	schemas.ProblemDetails
+// @Failure		403		{object}	schemas.ProblemDetails
+// @Failure		404		{object}	schemas.ProblemDetails
+// @Failure		422		{object}	schemas.ProblemDetails
+// @Failure		500		{object}	schemas.ProblemDetails
+// @Router			/webhooks/{id} [patch]
+// @Security		BearerAuth
+func (h *webhookHandler) UpdateWebhookHandler(c *gin.Context) {
+	req := schemas.UpdateWebhookRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	id, err := parseIntParam(c, "id")
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	endpoint, err := h.webhookService.UpdateWebhook(c.Request.Context(), middleware.CurrentUser(c), id, service.UpdateWebhookArgs{
+		URL:         req.URL,
+		Description: req.Description,
+		Events:      req.Events,
+		AllUsers:    req.AllUsers,
+		Enabled:     req.Enabled,
+	})
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    schemas.NewWebhookResponse(endpoint),
+	})
+}
+
+// DeleteWebhookHandler godoc
+
+// @Summary		Delete webhook
+// @Description	Delete a webhook of the current user with its deliveries
+// @Tags			webhooks
+// @Accept			json
+// @Produce		json
+// @Param			id	path		int	true	"Webhook id"
+// @Success		200	{object}	schemas.APIResponse
+// @Failure		401	{object}	schemas.ProblemDetails
+// @Failure		404	{object}	schemas.ProblemDetails
+// @Failure		500	{object}	schemas.ProblemDetails
+// @Router			/webhooks/{id} [delete]
+// @Security		BearerAuth
+func (h *webhookHandler) DeleteWebhookHandler(c *gin.Context) {
+	id, err := parseIntParam(c, "id")
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	if err := h.webhookService.DeleteWebhook(c.Request.Context(), middleware.CurrentUser(c).ID, id); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    nil,
+	})
+}
+
+// RotateSecretHandler godoc
+
+// @Summary		Rotate webhook secret
+// @Description	Replace the signing secret of a webhook. Deliveries are signed with the new secret right away.
+// @Tags			webhooks
+// @Accept			json
+// @Produce		json
+// @Param			id	path		int	true	"Webhook id"
+// @Success		200	{object}	schemas.APIResponse{data=schemas.WebhookSecretResponse}
+// @Failure		401	{object}	schemas.ProblemDetails
+// @Failure		404	{object}	schemas.ProblemDetails
+// @Failure		500	{object}	schemas.ProblemDetails
+// @Router			/webhooks/{id}/rotate-secret [post]
+// @Security		BearerAuth
+func (h *webhookHandler) RotateSecretHandler(c *gin.Context) {
+	id, err := parseIntParam(c, "id")
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	endpoint, err := h.webhookService.RotateSecret(c.Request.Context(), middleware.CurrentUser(c).ID, id)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    schemas.NewWebhookSecretResponse(endpoint),
+	})
+}
+
+// PingHandler godoc
+
+// @Summary		Ping webhook
+// @Description	Send a ping event to a webhook right away, even when it is disabled, and return the delivery. The delivery failed when the endpoint did not answer with a 2xx status.
+// @Tags			webhooks
+// @Accept			json
+// @Produce		json
+// @Param			id	path		int	true	"Webhook id"
+// @Success		200	{object}	schemas.APIResponse{data=schemas.DeliveryResponse}
+// @Failure		401	{object}	schemas.ProblemDetails
+// @Failure		404	{object}	schemas.ProblemDetails
+// @Failure		500	{object}	schemas.ProblemDetails
+// @Router			/webhooks/{id}/ping [post]
+// @Security		BearerAuth
+func (h *webhookHandler) PingHandler(c *gin.Context) {
+	id, err := parseIntParam(c, "id")
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	delivery, err := h.webhookService.Ping(c.Request.Context(), middleware.CurrentUser(c).ID, id)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    schemas.NewDeliveryResponse(delivery),
+	})
+}
+
+// ListDeliveryHandler godoc
+
+// @Summary		List webhook deliveries
+// @Description	List the deliveries of a webhook, newest first
+// @Tags			webhooks
+// @Accept			json
+// @Produce		json
+// @Param			id		path		int		true	"Webhook id"
+// @Param			status	query		string	false	"pending, succeeded or failed"
+// @Param			before	query		int		false	"next_before of the previous page"
+// @Param			limit	query		int		false	"page size, 20 by default and at most 100"
+// @Success		200		{object}	schemas.ListDeliveryResponse
+// @Failure		401		{object}	schemas.ProblemDetails
+// @Failure		404		{object}	schemas.ProblemDetails
+// @Failure		422		{object}	schemas.ProblemDetails
+// @Failure		500		{object}	schemas.ProblemDetails
+// @Router			/webhooks/{id}/deliveries [get]
+// @Security		BearerAuth
+func (h *webhookHandler) ListDeliveryHandler(c *gin.Context) {
+	req := schemas.ListDeliveryRequest{}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	id, err := parseIntParam(c, "id")
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	page, err := h.webhookService.ListDeliveries(c.Request.Context(), middleware.CurrentUser(c).ID, id, service.ListDeliveriesArgs{
+		Status: req.Status,
+		Before: req.Before,
+		Limit:  req.Limit,
+	})
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.NewListDeliveryResponse(http.StatusOK, http.StatusText(http.StatusOK), page))
+}
+
+// GetDeliveryHandler godoc
+
+// @Summary		Get webhook delivery
+// @Description	Get a delivery of a webhook with the outcome of its last attempt
+// @Tags			webhooks
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int	true	"Webhook id"
+// @Param			deliveryId	path		int	true	"Delivery id"
+// @Success		200			{object}	schemas.APIResponse{data=schemas.DeliveryResponse}
+// @Failure		401			{object}	schemas.ProblemDetails
+// @Failure		404			{object}	schemas.ProblemDetails
+// @Failure		500			{object}	schemas.ProblemDetails
+// @Router			/webhooks/{id}/deliveries/{deliveryId} [get]
+// @Security		BearerAuth
+func (h *webhookHandler) GetDeliveryHandler(c *gin.Context) {
+	id, deliveryID, err := parseDeliveryIDs(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	delivery, err := h.webhookService.GetDelivery(c.Request.Context(), middleware.CurrentUser(c).ID, id, deliveryID)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    schemas.NewDeliveryResponse(delivery),
+	})
+}
+
+// ReplayDeliveryHandler godoc
+
+// @Summary		Replay webhook delivery
+// @Description	Send the event of a delivery again, with the same event id, as a new delivery
+// @Tags			webhooks
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int	true	"Webhook id"
+// @Param			deliveryId	path		int	true	"Delivery id"
+// @Success		200			{object}	schemas.APIResponse{data=schemas.DeliveryResponse}
+// @Failure		401			{object}	schemas.ProblemDetails
+// @Failure		404			{object}	schemas.ProblemDetails
+// @Failure		409			{object}	schemas.ProblemDetails
+// @Failure		500			{object}	schemas.ProblemDetails
+// @Router			/webhooks/{id}/deliveries/{deliveryId}/replay [post]
+// @Security		BearerAuth
+func (h *webhookHandler) ReplayDeliveryHandler(c *gin.Context) {
+	id, deliveryID, err := parseDeliveryIDs(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	delivery, err := h.webhookService.ReplayDelivery(c.Request.Context(), middleware.CurrentUser(c).ID, id, deliveryID)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schemas.APIResponse{
+		Status:  http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Data:    schemas.NewDeliveryResponse(delivery),
+	})
+}
+
+// parseDeliveryIDs reads the id and deliveryId path parameters
+func parseDeliveryIDs(c *gin.Context) (int, int, error) {
+	id, err := parseIntParam(c, "id")
+	if err != nil {
+		return 0, 0, err
+	}
+	deliveryID, err := parseIntParam(c, "deliveryId")
+	if err != nil {
+		return 0, 0, err
+	}
+	return id, deliveryID, nil
+}
+
+func parseIntParam(c *gin.Context, name string) (int, error) {
+	value, err := strconv.Atoi(c.Param(name))
+	if err != nil {
+		return 0, apperror.Validation(apperror.FieldError{
+			Field:   name,
+			Code:    "integer",
+			Message: "must be an integer",
+		})
+	}
+	return value, nil
+}
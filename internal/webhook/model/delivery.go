@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// Statuses of deliveries
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	// DeliveryFailed marks deliveries that ran out of attempts or whose
+	// endpoint was disabled or deleted before they succeeded
+	DeliveryFailed = "failed"
+)
+
+// Delivery is an event sent, or to be sent, to an endpoint. Replays of a
+// delivery are new deliveries of the same event.
+type Delivery struct {
+	ID         uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	EndpointID uint      `json:"endpoint_id" gorm:"not null;index"`
+	Endpoint   *Endpoint `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	EventID    string    `json:"event_id" gorm:"size:36;not null;index"`
+	EventType  string    `json:"event_type" gorm:"size:100;not null"`
+	// Payload is the exact body posted to the endpoint
+	Payload        string `json:"payload" gorm:"type:text;not null"`
+	Status         string `json:"status" gorm:"size:20;not null"`
+	Attempts       int    `json:"attempts" gorm:"not null;default:0"`
+	MaxAttempts    int    `json:"max_attempts" gorm:"not null"`
+	ResponseStatus int    `json:"response_status" gorm:"not null;default:0"`
+	// ResponseBody is the beginning of the body of the last response
+	ResponseBody string `json:"response_body" gorm:"type:text;not null;default:''"`
+	Error        string `json:"error" gorm:"type:text;not null;default:''"`
+	// DurationMs is how long the last attempt took
+	DurationMs    int64      `json:"duration_ms" gorm:"not null;default:0"`
+	LastAttemptAt *time.Time `json:"last_attempt_at"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"autoCreateTime;index"`
+}
+
+func (Delivery) TableName() string {
+	return "webhook_deliveries"
+}
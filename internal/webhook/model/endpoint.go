@@ -0,0 +1,65 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// Types of the events sent to webhook endpoints. Todo events use the same
+// types as todo event streams.
+const (
+	EventTodoCreated = "todo.created"
+	EventTodoUpdated = "todo.updated"
+	EventTodoDeleted = "todo.deleted"
+	EventUserCreated = "user.created"
+	EventUserLogin   = "user.login"
+	// EventPing is only sent by test pings, whatever the endpoint subscribed to
+	EventPing = "ping"
+)
+
+// Endpoint is a URL events are posted to on behalf of its owner.
+type Endpoint struct {
+	ID          uint   `json:"id" gorm:"primaryKey;autoIncrement"`
+	OwnerID     uint   `json:"owner_id" gorm:"not null;index"`
+	URL         string `json:"url" gorm:"size:2048;not null"`
+	Description string `json:"description" gorm:"size:255;not null"`
+	// Secret signs the deliveries, it is only shown when it is generated
+	Secret string `json:"-" gorm:"size:100;not null"`
+	// Events is a comma separated list of event types, "todo.*" matches
+	// every todo event and "*" every event
+	Events string `json:"events" gorm:"size:500;not null"`
+	// AllUsers receives the events of every user instead of those of the
+	// owner only. Only administrators may set it.
+	AllUsers bool `json:"all_users" gorm:"not null;default:false;index"`
+	// FailureCount is the number of attempts that failed in a row
+	FailureCount   int        `json:"failure_count" gorm:"not null;default:0"`
+	DisabledAt     *time.Time `json:"disabled_at"`
+	DisabledReason string     `json:"disabled_reason" gorm:"size:255;not null;default:''"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (Endpoint) TableName() string {
+	return "webhook_endpoints"
+}
+
+// EventTypes returns the event types of Events.
+func (e *Endpoint) EventTypes() []string {
+	if e.Events == "" {
+		return []string{}
+	}
+	return strings.Split(e.Events, ",")
+}
+
+// Subscribed reports whether the endpoint receives events of the type.
+func (e *Endpoint) Subscribed(eventType string) bool {
+	for _, pattern := range e.EventTypes() {
+		if pattern == "*" || pattern == eventType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(eventType, prefix) {
+			return true
+		}
+	}
+	return false
+}
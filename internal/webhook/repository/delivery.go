@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/webhook/model"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+// ListDeliveryFilter selects a page of the deliveries of EndpointID, newest
+// first.
+type ListDeliveryFilter struct {
+	EndpointID uint
+	// Status keeps deliveries with the given status, empty means all.
+	Status string
+	// BeforeID keeps deliveries older than the given one, 0 starts from the
+	// newest.
+	BeforeID uint
+	Limit    int
+}
+
+// DeliveryRepository persists the log of webhook deliveries. Lookups of
+// missing deliveries return gorm.ErrRecordNotFound regardless of the
+// implementation.
+type DeliveryRepository interface {
+	Create(ctx context.Context, delivery *model.Delivery) error
+	FindByID(ctx context.Context, id uint) (*model.Delivery, error)
+	List(ctx context.Context, filter ListDeliveryFilter) ([]model.Delivery, error)
+	// Update saves every field of the delivery.
+	Update(ctx context.Context, delivery *model.Delivery) error
+	// PurgeBefore deletes deliveries created before the given time.
+	PurgeBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type gormDeliveryRepository struct {
+	db *gorm.DB
+}
+
+func NewGormDeliveryRepository(db *gorm.DB) DeliveryRepository {
+	return &gormDeliveryRepository{
+		db: db,
+	}
+}
+
+func (r *gormDeliveryRepository) Create(ctx context.Context, delivery *model.Delivery) error {
+	return txn.DB(ctx, r.db).Create(delivery).Error
+}
+
+func (r *gormDeliveryRepository) FindByID(ctx context.Context, id uint) (*model.Delivery, error) {
+	delivery := model.Delivery{}
+	if err := txn.DB(ctx, r.db).First(&delivery, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (r *gormDeliveryRepository) List(ctx context.Context, filter ListDeliveryFilter) ([]model.Delivery, error) {
+	query := txn.DB(ctx, r.db).Where("endpoint_id = ?", filter.EndpointID)
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.BeforeID != 0 {
+		query = query.Where("id < ?", filter.BeforeID)
+	}
+
+	deliveries := []model.Delivery{}
+	err := query.Order("id DESC").Limit(filter.Limit).Find(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *gormDeliveryRepository) Update(ctx context.Context, delivery *model.Delivery) error {
+	return txn.DB(ctx, r.db).Save(delivery).Error
+}
+
+func (r *gormDeliveryRepository) PurgeBefore(ctx context.Context, before time.Time) (int64, error) {
+	tx := txn.DB(ctx, r.db).Where("created_at < ?", before).Delete(&model.Delivery{})
+	return tx.RowsAffected, tx.Error
+}
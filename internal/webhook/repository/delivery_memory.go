@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/webhook/model"
+)
+
+type memoryDeliveryRepository struct {
+	mu         sync.RWMutex
+	nextID     uint
+	deliveries map[uint]model.Delivery
+}
+
+// NewMemoryDeliveryRepository creates a DeliveryRepository backed by a map,
+// for tests and demos that run without a database.
+func NewMemoryDeliveryRepository() DeliveryRepository {
+	return &memoryDeliveryRepository{
+		deliveries: map[uint]model.Delivery{},
+	}
+}
+
+func (r *memoryDeliveryRepository) Create(ctx context.Context, delivery *model.Delivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	delivery.ID = r.nextID
+	delivery.CreatedAt = time.Now()
+	r.deliveries[delivery.ID] = *delivery
+	return nil
+}
+
+func (r *memoryDeliveryRepository) FindByID(ctx context.Context, id uint) (*model.Delivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	delivery, ok := r.deliveries[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &delivery, nil
+}
+
+func (r *memoryDeliveryRepository) List(ctx context.Context, filter ListDeliveryFilter) ([]model.Delivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	deliveries := []model.Delivery{}
+	for _, delivery := range r.deliveries {
+		if delivery.EndpointID != filter.EndpointID {
+			continue
+		}
+		if filter.Status != "" && delivery.Status != filter.Status {
+			continue
+		}
+		if filter.BeforeID != 0 && delivery.ID >= filter.BeforeID {
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].ID > deliveries[j].ID
+	})
+	if len(deliveries) > filter.Limit {
+		deliveries = deliveries[:filter.Limit]
+	}
+	return deliveries, nil
+}
+
+func (r *memoryDeliveryRepository) Update(ctx context.Context, delivery *model.Delivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.deliveries[delivery.ID]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	r.deliveries[delivery.ID] = *delivery
+	return nil
+}
+
+func (r *memoryDeliveryRepository) PurgeBefore(ctx context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged int64
+	for id, delivery := range r.deliveries {
+		if delivery.CreatedAt.Before(before) {
+			delete(r.deliveries, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
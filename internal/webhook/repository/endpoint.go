@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/webhook/model"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+// EndpointRepository persists webhook endpoints. Lookups of missing
+// endpoints return gorm.ErrRecordNotFound regardless of the implementation.
+type EndpointRepository interface {
+	Create(ctx context.Context, endpoint *model.Endpoint) error
+	FindByID(ctx context.Context, id uint) (*model.Endpoint, error)
+	// List returns the endpoints of the owner, oldest first.
+	List(ctx context.Context, ownerID uint) ([]model.Endpoint, error)
+	// ListSubscribers returns the enabled endpoints receiving events of the
+	// users: theirs and those receiving events of every user.
+	ListSubscribers(ctx context.Context, userIDs []uint) ([]model.Endpoint, error)
+	// Update loads the endpoint, applies fn and saves the result atomically.
+	Update(ctx context.Context, id uint, fn func(endpoint *model.Endpoint) error) (*model.Endpoint, error)
+	// Delete removes the endpoint with its deliveries.
+	Delete(ctx context.Context, id uint) error
+	// RecordFailure counts a failed attempt and returns how many failed in a
+	// row.
+	RecordFailure(ctx context.Context, id uint) (int, error)
+	// RecordSuccess resets the count of failed attempts.
+	RecordSuccess(ctx context.Context, id uint) error
+	// Disable disables the endpoint and reports whether it was enabled.
+	Disable(ctx context.Context, id uint, reason string, at time.Time) (bool, error)
+}
+
+type gormEndpointRepository struct {
+	db *gorm.DB
+}
+
+func NewGormEndpointRepository(db *gorm.DB) EndpointRepository {
+	return &gormEndpointRepository{
+		db: db,
+	}
+}
+
+func (r *gormEndpointRepository) Create(ctx context.Context, endpoint *model.Endpoint) error {
+	return txn.DB(ctx, r.db).Create(endpoint).Error
+}
+
+func (r *gormEndpointRepository) FindByID(ctx context.Context, id uint) (*model.Endpoint, error) {
+	endpoint := model.Endpoint{}
+	if err := txn.DB(ctx, r.db).First(&endpoint, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+func (r *gormEndpointRepository) List(ctx context.Context, ownerID uint) ([]model.Endpoint, error) {
+	endpoints := []model.Endpoint{}
+	err := txn.DB(ctx, r.db).Where("owner_id = ?", ownerID).Order("id").Find(&endpoints).Error
+	if err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+func (r *gormEndpointRepository) ListSubscribers(ctx context.Context, userIDs []uint) ([]model.Endpoint, error) {
+	query := txn.DB(ctx, r.db).Where("disabled_at IS NULL")
+	if len(userIDs) > 0 {
+		query = query.Where("all_users = ? OR owner_id IN ?", true, userIDs)
+	} else {
+		query = query.Where("all_users = ?", true)
+	}
+
+	endpoints := []model.Endpoint{}
+	if err := query.Order("id").Find(&endpoints).Error; err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+func (r *gormEndpointRepository) Update(ctx context.Context, id uint, fn func(endpoint *model.Endpoint) error) (*model.Endpoint, error) {
+	endpoint := model.Endpoint{}
+	err := txn.DB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&endpoint, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := fn(&endpoint); err != nil {
+			return err
+		}
+		return tx.Save(&endpoint).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+func (r *gormEndpointRepository) Delete(ctx context.Context, id uint) error {
+	// Deliveries are removed by the foreign key cascade
+	tx := txn.DB(ctx, r.db).Delete(&model.Endpoint{}, "id = ?", id)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *gormEndpointRepository) RecordFailure(ctx context.Context, id uint) (int, error) {
+	endpoint := model.Endpoint{}
+	err := txn.DB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.Endpoint{}).
+			Where("id = ?", id).
+			Update("failure_count", gorm.Expr("failure_count + 1")).Error
+		if err != nil {
+			return err
+		}
+		return tx.Select("failure_count").First(&endpoint, "id = ?", id).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return endpoint.FailureCount, nil
+}
+
+func (r *gormEndpointRepository) RecordSuccess(ctx context.Context, id uint) error {
+	return txn.DB(ctx, r.db).Model(&model.Endpoint{}).
+		Where("id = ? AND failure_count <> 0", id).
+		Update("failure_count", 0).Error
+}
+
+func (r *gormEndpointRepository) Disable(ctx context.Context, id uint, reason string, at time.Time) (bool, error) {
+	tx := txn.DB(ctx, r.db).Model(&model.Endpoint{}).
+		Where("id = ? AND disabled_at IS NULL", id).
+		Updates(map[string]any{
+			"disabled_at":     at,
+			"disabled_reason": reason,
+		})
+	return tx.RowsAffected > 0, tx.Error
+}
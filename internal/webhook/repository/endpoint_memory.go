@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/webhook/model"
+)
+
+type memoryEndpointRepository struct {
+	mu        sync.RWMutex
+	nextID    uint
+	endpoints map[uint]model.Endpoint
+}
+
+// NewMemoryEndpointRepository creates an EndpointRepository backed by a map,
+// for tests and demos that run without a database. Deliveries of deleted
+// endpoints are kept.
+func NewMemoryEndpointRepository() EndpointRepository {
+	return &memoryEndpointRepository{
+		endpoints: map[uint]model.Endpoint{},
+	}
+}
+
+func (r *memoryEndpointRepository) Create(ctx context.Context, endpoint *model.Endpoint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	endpoint.ID = r.nextID
+	endpoint.CreatedAt = time.Now()
+	endpoint.UpdatedAt = endpoint.CreatedAt
+	r.endpoints[endpoint.ID] = *endpoint
+	return nil
+}
+
+func (r *memoryEndpointRepository) FindByID(ctx context.Context, id uint) (*model.Endpoint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	endpoint, ok := r.endpoints[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &endpoint, nil
+}
+
+func (r *memoryEndpointRepository) List(ctx context.Context, ownerID uint) ([]model.Endpoint, error) {
+	return r.list(func(endpoint *model.Endpoint) bool {
+		return endpoint.OwnerID == ownerID
+	}), nil
+}
+
+func (r *memoryEndpointRepository) ListSubscribers(ctx context.Context, userIDs []uint) ([]model.Endpoint, error) {
+	return r.list(func(endpoint *model.Endpoint) bool {
+		return endpoint.DisabledAt == nil && (endpoint.AllUsers || slices.Contains(userIDs, endpoint.OwnerID))
+	}), nil
+}
+
+// list returns the endpoints matching keep, oldest first
+func (r *memoryEndpointRepository) list(keep func(endpoint *model.Endpoint) bool) []model.Endpoint {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	endpoints := []model.Endpoint{}
+	for _, endpoint := range r.endpoints {
+		if keep(&endpoint) {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].ID < endpoints[j].ID
+	})
+	return endpoints
+}
+
+func (r *memoryEndpointRepository) Update(ctx context.Context, id uint, fn func(endpoint *model.Endpoint) error) (*model.Endpoint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	endpoint, ok := r.endpoints[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err := fn(&endpoint); err != nil {
+		return nil, err
+	}
+	endpoint.UpdatedAt = time.Now()
+	r.endpoints[id] = endpoint
+	return &endpoint, nil
+}
+
+func (r *memoryEndpointRepository) Delete(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.endpoints[id]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.endpoints, id)
+	return nil
+}
+
+func (r *memoryEndpointRepository) RecordFailure(ctx context.Context, id uint) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	endpoint, ok := r.endpoints[id]
+	if !ok {
+		return 0, gorm.ErrRecordNotFound
+	}
+	endpoint.FailureCount++
+	r.endpoints[id] = endpoint
+	return endpoint.FailureCount, nil
+}
+
+func (r *memoryEndpointRepository) RecordSuccess(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if endpoint, ok := r.endpoints[id]; ok {
+		endpoint.FailureCount = 0
+		r.endpoints[id] = endpoint
+	}
+	return nil
+}
+
+func (r *memoryEndpointRepository) Disable(ctx context.Context, id uint, reason string, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	endpoint, ok := r.endpoints[id]
+	if !ok || endpoint.DisabledAt != nil {
+		return false, nil
+	}
+	endpoint.DisabledAt = &at
+	endpoint.DisabledReason = reason
+	r.endpoints[id] = endpoint
+	return true, nil
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrAddressNotAllowed is the error of attempts to endpoints resolving to an
+// address that is not public, which webhooks must not reach. The address is
+// left out, so owners cannot map the private network.
+var ErrAddressNotAllowed = errors.New("endpoint address is not allowed")
+
+// NewHTTPClient creates the client posting deliveries. It gives up after
+// timeout, does not follow redirects and only connects to public addresses,
+// or to the ones in allowedNetworks. The address is checked once resolved,
+// right before connecting, so a name resolving to a public address when the
+// endpoint is saved and to a private one later is still refused.
+func NewHTTPClient(timeout time.Duration, allowedNetworks []netip.Prefix) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network string, address string, c syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return ErrAddressNotAllowed
+			}
+			if !addressAllowed(addrPort.Addr().Unmap(), allowedNetworks) {
+				return ErrAddressNotAllowed
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// Proxies would connect on our behalf, past the check
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+		// A redirected POST would be sent again as a GET
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// addressAllowed reports whether the address is public or in one of the
+// allowed networks
+func addressAllowed(addr netip.Addr, allowedNetworks []netip.Prefix) bool {
+	for _, network := range allowedNetworks {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+// sharedAddressSpace is used by carrier-grade NATs, see RFC 6598
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/internal/webhook/model"
+	"khiemle.dev/golang-api-template/internal/webhook/repository"
+	"khiemle.dev/golang-api-template/pkg/notify"
+)
+
+// maxResponseBody bounds how much of a response body is kept in the log
+const maxResponseBody = 4096
+
+// sender posts deliveries to endpoints with a client from NewHTTPClient
+type sender struct {
+	client *http.Client
+}
+
+// send posts the payload of the delivery to the endpoint, signed with its
+// secret, and records the attempt on the delivery. Only 2xx responses
+// succeed.
+func (s sender) send(ctx context.Context, endpoint *model.Endpoint, delivery *model.Delivery) error {
+	start := time.Now()
+	err := s.post(ctx, endpoint, delivery)
+
+	delivery.Attempts++
+	delivery.LastAttemptAt = &start
+	delivery.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		delivery.Error = err.Error()
+		return err
+	}
+	delivery.Status = model.DeliverySucceeded
+	delivery.Error = ""
+	delivery.DeliveredAt = &start
+	return nil
+}
+
+func (s sender) post(ctx context.Context, endpoint *model.Endpoint, delivery *model.Delivery) error {
+	delivery.ResponseStatus = 0
+	delivery.ResponseBody = ""
+
+	req, err := notify.NewWebhookRequest(ctx, endpoint.URL, endpoint.Secret, delivery.EventID, []byte(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(notify.HeaderWebhookEvent, delivery.EventType)
+	req.Header.Set("User-Agent", "golang-api-template-webhooks")
+
+	resp, err := s.client.Do(req)
+	if errors.Is(err, ErrAddressNotAllowed) {
+		// Leave out the address the error names
+		return ErrAddressNotAllowed
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	_, _ = io.Copy(io.Discard, resp.Body)
+	delivery.ResponseStatus = resp.StatusCode
+	delivery.ResponseBody = string(body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("endpoint responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// Deliverer posts deliveries to their endpoint for the worker. Failed
+// attempts are retried by the job queue with exponential backoff, and
+// endpoints failing too many attempts in a row are disabled.
+type Deliverer struct {
+	endpointRepository repository.EndpointRepository
+	deliveryRepository repository.DeliveryRepository
+	notifier           *notify.Notifier
+	sender             sender
+	// disableAfter is how many attempts may fail in a row, 0 for no limit
+	disableAfter int
+}
+
+// NewDeliverer creates a deliverer posting with client, see NewHTTPClient.
+// Owners of disabled endpoints are told with an in-app notification.
+func NewDeliverer(
+	endpointRepository repository.EndpointRepository,
+	deliveryRepository repository.DeliveryRepository,
+	notifier *notify.Notifier,
+	client *http.Client,
+	disableAfter int,
+) *Deliverer {
+	return &Deliverer{
+		endpointRepository: endpointRepository,
+		deliveryRepository: deliveryRepository,
+		notifier:           notifier,
+		sender:             sender{client: client},
+		disableAfter:       disableAfter,
+	}
+}
+
+// Deliver makes an attempt to deliver the event. It fails, so the job is
+// retried, while the delivery has attempts left.
+func (d *Deliverer) Deliver(ctx context.Context, job DeliverWebhook) error {
+	delivery, err := d.deliveryRepository.FindByID(ctx, job.DeliveryID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Purged, or its endpoint was deleted
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if delivery.Status != model.DeliveryPending {
+		return nil
+	}
+
+	endpoint, err := d.endpointRepository.FindByID(ctx, delivery.EndpointID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if endpoint.DisabledAt != nil {
+		delivery.Status = model.DeliveryFailed
+		delivery.Error = "endpoint is disabled"
+		return d.deliveryRepository.Update(ctx, delivery)
+	}
+
+	sendErr := d.sender.send(ctx, endpoint, delivery)
+	if sendErr == nil {
+		if err := d.endpointRepository.RecordSuccess(ctx, endpoint.ID); err != nil {
+			return err
+		}
+		return d.deliveryRepository.Update(ctx, delivery)
+	}
+
+	failures, err := d.endpointRepository.RecordFailure(ctx, endpoint.ID)
+	if err != nil {
+		return err
+	}
+	disabled := false
+	if d.disableAfter > 0 && failures >= d.disableAfter {
+		disabled = true
+		d.disable(ctx, endpoint, failures)
+	}
+	if disabled || delivery.Attempts >= delivery.MaxAttempts {
+		delivery.Status = model.DeliveryFailed
+	}
+	if err := d.deliveryRepository.Update(ctx, delivery); err != nil {
+		return err
+	}
+	if disabled {
+		return nil
+	}
+	return sendErr
+}
+
+// disable disables the endpoint and tells its owner, once
+func (d *Deliverer) disable(ctx context.Context, endpoint *model.Endpoint, failures int) {
+	reason := fmt.Sprintf("%d delivery attempts failed in a row", failures)
+	now := time.Now()
+	disabled, err := d.endpointRepository.Disable(ctx, endpoint.ID, reason, now)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Uint("webhook_id", endpoint.ID).Msg("Could not disable webhook endpoint")
+		return
+	}
+	if !disabled {
+		return
+	}
+	zerolog.Ctx(ctx).Warn().Uint("webhook_id", endpoint.ID).Str("reason", reason).Msg("Disabled webhook endpoint")
+
+	err = d.notifier.Send(ctx, notify.ChannelInApp, notify.Message{
+		ID:      fmt.Sprintf("webhook-disabled-%d-%d", endpoint.ID, now.Unix()),
+		UserID:  endpoint.OwnerID,
+		Kind:    "webhook.disabled",
+		Subject: "Webhook endpoint disabled",
+		Body:    fmt.Sprintf("Events are no longer sent to %s because %s. Fix the endpoint, then enable it again.", endpoint.URL, reason),
+		Data: map[string]any{
+			"webhook_id": endpoint.ID,
+			"url":        endpoint.URL,
+		},
+	})
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Uint("webhook_id", endpoint.ID).Msg("Could not notify about disabled webhook endpoint")
+	}
+}
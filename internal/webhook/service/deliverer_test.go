@@ -0,0 +1,111 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"khiemle.dev/golang-api-template/internal/webhook/model"
+	"khiemle.dev/golang-api-template/internal/webhook/repository"
+	"khiemle.dev/golang-api-template/internal/webhook/service"
+	"khiemle.dev/golang-api-template/pkg/notify"
+)
+
+// deliver makes one attempt to deliver an event to url and returns the
+// delivery afterwards
+func deliver(t *testing.T, client *http.Client, url string) (*model.Delivery, error) {
+	t.Helper()
+	ctx := context.Background()
+	endpoints := repository.NewMemoryEndpointRepository()
+	deliveries := repository.NewMemoryDeliveryRepository()
+	deliverer := service.NewDeliverer(endpoints, deliveries, notify.NewNotifier(), client, 0)
+
+	endpoint := model.Endpoint{OwnerID: 1, URL: url, Secret: "whsec_test", Events: "*"}
+	if err := endpoints.Create(ctx, &endpoint); err != nil {
+		t.Fatal(err)
+	}
+	delivery := model.Delivery{
+		EndpointID:  endpoint.ID,
+		EventID:     "8a1e0a54-8f5b-4b8e-9d59-0c5d1b8f3f6e",
+		EventType:   model.EventTodoCreated,
+		Payload:     `{"type":"todo.created"}`,
+		Status:      model.DeliveryPending,
+		MaxAttempts: 3,
+	}
+	if err := deliveries.Create(ctx, &delivery); err != nil {
+		t.Fatal(err)
+	}
+
+	deliverErr := deliverer.Deliver(ctx, service.DeliverWebhook{DeliveryID: delivery.ID})
+	got, err := deliveries.FindByID(ctx, delivery.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got, deliverErr
+}
+
+func TestDelivererRefusesPrivateAddresses(t *testing.T) {
+	var received atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		fmt.Fprint(w, "internal data")
+	}))
+	defer receiver.Close()
+	port := receiver.URL[strings.LastIndex(receiver.URL, ":")+1:]
+
+	client := service.NewHTTPClient(time.Second, nil)
+	urls := []string{
+		receiver.URL,
+		"http://localhost:" + port,
+		"http://[::1]:" + port,
+		"http://10.0.0.1/hook",
+		"http://192.168.1.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://100.64.0.1/hook",
+		"http://0.0.0.0:" + port,
+		"http://[::ffff:127.0.0.1]:" + port,
+	}
+	for _, url := range urls {
+		t.Run(url, func(t *testing.T) {
+			delivery, err := deliver(t, client, url)
+			if !errors.Is(err, service.ErrAddressNotAllowed) {
+				t.Fatalf("Deliver = %v, want %v", err, service.ErrAddressNotAllowed)
+			}
+			// Neither the address nor anything the target said is kept
+			if delivery.Error != service.ErrAddressNotAllowed.Error() || delivery.ResponseBody != "" || delivery.ResponseStatus != 0 {
+				t.Errorf("delivery = %+v, want the error only", delivery)
+			}
+		})
+	}
+	if n := received.Load(); n != 0 {
+		t.Errorf("receiver got %d requests, want 0", n)
+	}
+}
+
+func TestDelivererPostsToAllowedNetworks(t *testing.T) {
+	var event string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event = r.Header.Get(notify.HeaderWebhookEvent)
+		fmt.Fprint(w, "ok")
+	}))
+	defer receiver.Close()
+
+	client := service.NewHTTPClient(time.Second, []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")})
+	delivery, err := deliver(t, client, receiver.URL)
+	if err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+	if delivery.Status != model.DeliverySucceeded || delivery.ResponseBody != "ok" {
+		t.Errorf("delivery = %+v, want a success", delivery)
+	}
+	if event != model.EventTodoCreated {
+		t.Errorf("event header = %q, want %q", event, model.EventTodoCreated)
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/metric"
+	"khiemle.dev/golang-api-template/internal/webhook/repository"
+	"khiemle.dev/golang-api-template/pkg/metrics"
+)
+
+// DeliveryJanitor deletes deliveries older than the retention window from the
+// log. It is run periodically by the scheduler.
+type DeliveryJanitor struct {
+	deliveryRepository repository.DeliveryRepository
+	retention          time.Duration
+	purged             metric.Int64Counter
+}
+
+func NewDeliveryJanitor(deliveryRepository repository.DeliveryRepository, retention time.Duration) (*DeliveryJanitor, error) {
+	purged, err := metrics.Meter().Int64Counter(
+		"webhooks.deliveries.purged",
+		metric.WithDescription("Number of webhook deliveries deleted by the janitor"),
+		metric.WithUnit("{delivery}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeliveryJanitor{
+		deliveryRepository: deliveryRepository,
+		retention:          retention,
+		purged:             purged,
+	}, nil
+}
+
+// Run purges deliveries older than the retention window.
+func (j *DeliveryJanitor) Run(ctx context.Context) error {
+	purged, err := j.deliveryRepository.PurgeBefore(ctx, time.Now().Add(-j.retention))
+	j.purged.Add(ctx, purged)
+	if err != nil {
+		return err
+	}
+
+	event := log.Debug()
+	if purged > 0 {
+		event = log.Info()
+	}
+	event.Int64("purged", purged).Msg("Purged webhook deliveries")
+	return nil
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"khiemle.dev/golang-api-template/internal/webhook/model"
+	"khiemle.dev/golang-api-template/internal/webhook/repository"
+	"khiemle.dev/golang-api-template/pkg/jobqueue"
+)
+
+// JobDeliverWebhook posts an event to a webhook endpoint
+const JobDeliverWebhook = "deliver_webhook"
+
+// DeliverWebhook is the payload of JobDeliverWebhook.
+type DeliverWebhook struct {
+	DeliveryID uint `json:"delivery_id"`
+}
+
+// Event is something that happened to users, sent to the endpoints
+// subscribed to its type.
+type Event struct {
+	// ID is the same whenever the event is published again, so receivers
+	// can deduplicate it
+	ID        string
+	CreatedAt time.Time
+	Type      string
+	// UserIDs are the users the event is about. Endpoints of other users
+	// only receive it when they receive the events of every user.
+	UserIDs []uint
+	// Data is marshalled to JSON as the data of the payload, see UserData
+	Data any
+}
+
+// UserData is event data that depends on who receives it. Endpoints get the
+// data for their owner.
+type UserData interface {
+	ForUser(userID uint) any
+}
+
+// eventPayload is the JSON body posted to endpoints
+type eventPayload struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// Publisher hands events over to the worker, which delivers them to the
+// subscribed endpoints. Deliveries are enqueued in the transaction of the
+// context, see txn.
+type Publisher interface {
+	Publish(ctx context.Context, events ...Event) error
+}
+
+type publisher struct {
+	endpointRepository repository.EndpointRepository
+	deliveryRepository repository.DeliveryRepository
+	jobs               jobqueue.Client
+	maxAttempts        int
+}
+
+// NewPublisher creates a publisher whose deliveries are tried maxAttempts
+// times before they fail.
+func NewPublisher(
+	endpointRepository repository.EndpointRepository,
+	deliveryRepository repository.DeliveryRepository,
+	jobs jobqueue.Client,
+	maxAttempts int,
+) Publisher {
+	return &publisher{
+		endpointRepository: endpointRepository,
+		deliveryRepository: deliveryRepository,
+		jobs:               jobs,
+		maxAttempts:        maxAttempts,
+	}
+}
+
+func (p *publisher) Publish(ctx context.Context, events ...Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	// Look the endpoints of every event up at once
+	userIDs := []uint{}
+	for _, event := range events {
+		for _, userID := range event.UserIDs {
+			if !slices.Contains(userIDs, userID) {
+				userIDs = append(userIDs, userID)
+			}
+		}
+	}
+	endpoints, err := p.endpointRepository.ListSubscribers(ctx, userIDs)
+	if err != nil {
+		return err
+	}
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	for _, event := range events {
+		userData, perUser := event.Data.(UserData)
+		// Bodies are built once needed, once per owner for UserData
+		payloads := map[uint][]byte{}
+		for i := range endpoints {
+			endpoint := &endpoints[i]
+			if !endpoint.Subscribed(event.Type) || (!endpoint.AllUsers && !slices.Contains(event.UserIDs, endpoint.OwnerID)) {
+				continue
+			}
+
+			var key uint
+			data := event.Data
+			if perUser {
+				key = endpoint.OwnerID
+				data = userData.ForUser(endpoint.OwnerID)
+			}
+			payload, ok := payloads[key]
+			if !ok {
+				payload, err = json.Marshal(eventPayload{
+					ID:        event.ID,
+					Type:      event.Type,
+					CreatedAt: event.CreatedAt.UTC(),
+					Data:      data,
+				})
+				if err != nil {
+					return err
+				}
+				payloads[key] = payload
+			}
+			delivery := model.Delivery{
+				EndpointID:  endpoint.ID,
+				EventID:     event.ID,
+				EventType:   event.Type,
+				Payload:     string(payload),
+				MaxAttempts: p.maxAttempts,
+			}
+			if err := enqueue(ctx, p.deliveryRepository, p.jobs, &delivery); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// enqueue saves the delivery as pending and hands it over to the worker
+func enqueue(ctx context.Context, deliveryRepository repository.DeliveryRepository, jobs jobqueue.Client, delivery *model.Delivery) error {
+	delivery.Status = model.DeliveryPending
+	if err := deliveryRepository.Create(ctx, delivery); err != nil {
+		return err
+	}
+
+	_, err := jobs.Enqueue(ctx, JobDeliverWebhook, DeliverWebhook{DeliveryID: delivery.ID}, jobqueue.WithMaxAttempts(delivery.MaxAttempts))
+	return err
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	_authModel "khiemle.dev/golang-api-template/internal/auth/model"
+	_todoModel "khiemle.dev/golang-api-template/internal/todo/model"
+	_userModel "khiemle.dev/golang-api-template/internal/user/model"
+	"khiemle.dev/golang-api-template/internal/webhook/model"
+	"khiemle.dev/golang-api-template/pkg/outbox"
+)
+
+// OutboxSubscriber is the name of the outbox subscriber publishing domain
+// events to webhooks.
+const OutboxSubscriber = "webhooks"
+
+// TodoEncoder returns the public representation of a todo sent to endpoints,
+// the one of the API.
+type TodoEncoder func(todo *_todoModel.Todo) any
+
+// NewOutboxHandler creates the outbox handler turning domain events into
+// webhook events, with todos encoded by encodeTodo. Deliveries are enqueued in
+// the transaction advancing the cursor of the subscriber, so each event is
+// delivered once per endpoint.
+func NewOutboxHandler(publisher Publisher, encodeTodo TodoEncoder) outbox.Handler {
+	return func(ctx context.Context, event *outbox.Event) error {
+		events, err := webhookEvents(event, encodeTodo)
+		if err != nil {
+			return err
+		}
+		return publisher.Publish(ctx, events...)
+	}
+}
+
+// webhookEvents returns the webhook events of a domain event, none when
+// webhooks do not expose it
+func webhookEvents(event *outbox.Event, encodeTodo TodoEncoder) ([]Event, error) {
+	// Ids are derived from the domain event, so they do not change when the
+	// event is handled again
+	newEvent := func(eventType string, userIDs []uint, data any) Event {
+		return Event{
+			ID:        uuid.NewSHA1(uuid.NameSpaceOID, []byte(event.EventID+"/"+eventType)).String(),
+			CreatedAt: event.OccurredAt,
+			Type:      eventType,
+			UserIDs:   userIDs,
+			Data:      data,
+		}
+	}
+	deletedTodo := func(todo *_todoModel.Todo) map[string]uint {
+		return map[string]uint{"id": todo.ID}
+	}
+
+	switch event.Type {
+	case _todoModel.TodoEventCreated, _todoModel.TodoEventUpdated, _todoModel.TodoEventDeleted, _todoModel.TodoEventRestored:
+		change := _todoModel.TodoChange{}
+		if err := event.Decode(&change); err != nil {
+			return nil, err
+		}
+		switch event.Type {
+		// Todos restored from the trash are created again, as for streams
+		case _todoModel.TodoEventCreated, _todoModel.TodoEventRestored:
+			return []Event{newEvent(model.EventTodoCreated, change.UserIDs, todoData{change.Todo, encodeTodo})}, nil
+		case _todoModel.TodoEventDeleted:
+			return []Event{newEvent(model.EventTodoDeleted, change.UserIDs, deletedTodo(change.Todo))}, nil
+		}
+
+		// Users who can no longer see the todo are told it was deleted
+		events := []Event{newEvent(model.EventTodoUpdated, change.UserIDs, todoData{change.Todo, encodeTodo})}
+		if len(change.RemovedUserIDs) > 0 {
+			events = append(events, newEvent(model.EventTodoDeleted, change.RemovedUserIDs, deletedTodo(change.Todo)))
+		}
+		return events, nil
+
+	case _userModel.EventUserRegistered:
+		change := _userModel.UserChange{}
+		if err := event.Decode(&change); err != nil {
+			return nil, err
+		}
+		return []Event{newEvent(model.EventUserCreated, []uint{event.AggregateID}, change.After)}, nil
+
+	case _authModel.EventSessionCreated:
+		change := _authModel.SessionChange{}
+		if err := event.Decode(&change); err != nil {
+			return nil, err
+		}
+		return []Event{newEvent(model.EventUserLogin, []uint{change.UserID}, map[string]any{
+			"user_id":          change.UserID,
+			"login_session_id": change.LoginSessionID,
+			"user_agent":       change.UserAgent,
+			"client_ip":        change.ClientIP,
+			"logged_in_at":     change.OccurredAt,
+		})}, nil
+	}
+	return nil, nil
+}
+
+// todoData is a todo as seen by each user, with their labels only
+type todoData struct {
+	todo   *_todoModel.Todo
+	encode TodoEncoder
+}
+
+func (d todoData) ForUser(userID uint) any {
+	seen := *d.todo
+	seen.Labels = d.todo.LabelsOf(userID)
+	return d.encode(&seen)
+}
@@ -0,0 +1,84 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	_todoModel "khiemle.dev/golang-api-template/internal/todo/model"
+	"khiemle.dev/golang-api-template/internal/webhook/model"
+	"khiemle.dev/golang-api-template/internal/webhook/repository"
+	"khiemle.dev/golang-api-template/internal/webhook/service"
+	"khiemle.dev/golang-api-template/pkg/database/databasetest"
+	"khiemle.dev/golang-api-template/pkg/jobqueue"
+	"khiemle.dev/golang-api-template/pkg/outbox"
+)
+
+// todoResponse stands for schemas.TodoResponse, which imports this package
+type todoResponse struct {
+	ID     uint     `json:"id"`
+	Labels []string `json:"labels"`
+}
+
+func encodeTodo(todo *_todoModel.Todo) any {
+	res := todoResponse{ID: todo.ID, Labels: []string{}}
+	for _, label := range todo.Labels {
+		res.Labels = append(res.Labels, label.Name)
+	}
+	return res
+}
+
+func TestOutboxHandlerSendsTheLabelsOfEachOwner(t *testing.T) {
+	ctx := context.Background()
+	db := databasetest.New(t)
+	endpoints := repository.NewMemoryEndpointRepository()
+	deliveries := repository.NewMemoryDeliveryRepository()
+	handle := service.NewOutboxHandler(service.NewPublisher(endpoints, deliveries, jobqueue.NewClient(db), 3), encodeTodo)
+
+	const alice, bob = 1, 2
+	for _, ownerID := range []uint{alice, bob} {
+		err := endpoints.Create(ctx, &model.Endpoint{OwnerID: ownerID, URL: "https://example.com/hook", Events: "todo.*"})
+		if err != nil {
+			t.Fatalf("Create endpoint: %v", err)
+		}
+	}
+
+	todo := &_todoModel.Todo{ID: 7, OwnerID: alice, Labels: []_todoModel.Label{
+		{ID: 1, OwnerID: alice, Name: "work"},
+		{ID: 2, OwnerID: bob, Name: "secret"},
+	}}
+	event, err := outbox.NewEvent(_todoModel.TodoEventCreated, _todoModel.AggregateTodo, todo.ID, _todoModel.TodoChange{
+		Todo:    todo,
+		UserIDs: []uint{alice, bob},
+	})
+	if err != nil {
+		t.Fatalf("NewEvent: %v", err)
+	}
+	if err := handle(ctx, &event); err != nil {
+		t.Fatalf("handle: %v", err)
+	}
+
+	// Endpoints were created in owner order
+	for endpointID, want := range map[uint]string{1: "work", 2: "secret"} {
+		sent, err := deliveries.List(ctx, repository.ListDeliveryFilter{EndpointID: endpointID, Limit: 10})
+		if err != nil {
+			t.Fatalf("List deliveries: %v", err)
+		}
+		if len(sent) != 1 {
+			t.Fatalf("endpoint %d got %d deliveries, want 1", endpointID, len(sent))
+		}
+		payload := struct {
+			Type string       `json:"type"`
+			Data todoResponse `json:"data"`
+		}{}
+		if err := json.Unmarshal([]byte(sent[0].Payload), &payload); err != nil {
+			t.Fatalf("payload %q: %v", sent[0].Payload, err)
+		}
+		if payload.Type != model.EventTodoCreated || payload.Data.ID != todo.ID {
+			t.Errorf("payload = %+v, want the created todo", payload)
+		}
+		if len(payload.Data.Labels) != 1 || payload.Data.Labels[0] != want {
+			t.Errorf("endpoint %d got labels %v, want [%s]", endpointID, payload.Data.Labels, want)
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	_userModel "khiemle.dev/golang-api-template/internal/user/model"
+	"khiemle.dev/golang-api-template/internal/webhook/model"
+	"khiemle.dev/golang-api-template/internal/webhook/repository"
+	"khiemle.dev/golang-api-template/pkg/apperror"
+	"khiemle.dev/golang-api-template/pkg/jobqueue"
+)
+
+// Page sizes of ListDeliveries
+const (
+	DefaultDeliveryPageSize = 20
+	MaxDeliveryPageSize     = 100
+)
+
+// SecretPrefix starts every endpoint secret, so leaked ones are easy to spot
+const SecretPrefix = "whsec_"
+
+var (
+	ErrWebhookNotFound   = apperror.NotFound(apperror.CodeWebhookNotFound, "webhook not found")
+	ErrWebhookDisabled   = apperror.Conflict(apperror.CodeWebhookDisabled, "webhook is disabled")
+	ErrDeliveryNotFound  = apperror.NotFound(apperror.CodeDeliveryNotFound, "delivery not found")
+	ErrAllUsersForbidden = apperror.Forbidden("only administrators may receive the events of every user")
+)
+
+type CreateWebhookArgs struct {
+	URL         string
+	Description string
+	Events      []string
+	AllUsers    bool
+}
+
+// UpdateWebhookArgs changes the fields that are not nil. Enabling an endpoint
+// forgets its failed attempts.
+type UpdateWebhookArgs struct {
+	URL         *string
+	Description *string
+	Events      []string
+	AllUsers    *bool
+	Enabled     *bool
+}
+
+type ListDeliveriesArgs struct {
+	// Status keeps deliveries with the given status, empty means all.
+	Status string
+	// Before is the NextBefore cursor of the previous page, 0 for the first.
+	Before uint
+	Limit  int
+}
+
+// DeliveryPage is a page of the deliveries of an endpoint, newest first.
+type DeliveryPage struct {
+	Deliveries []model.Delivery
+	// NextBefore is the cursor of the next page, nil on the last one.
+	NextBefore *uint
+}
+
+// WebhookService manages the webhook endpoints of users. Endpoints of other
+// users are not found.
+type WebhookService interface {
+	CreateWebhook(ctx context.Context, user *_userModel.User, args CreateWebhookArgs) (*model.Endpoint, error)
+	ListWebhooks(ctx context.Context, userID uint) ([]model.Endpoint, error)
+	GetWebhook(ctx context.Context, userID uint, id int) (*model.Endpoint, error)
+	UpdateWebhook(ctx context.Context, user *_userModel.User, id int, args UpdateWebhookArgs) (*model.Endpoint, error)
+	DeleteWebhook(ctx context.Context, userID uint, id int) error
+	// RotateSecret replaces the secret of the endpoint, deliveries are signed
+	// with the new one right away.
+	RotateSecret(ctx context.Context, userID uint, id int) (*model.Endpoint, error)
+	ListDeliveries(ctx context.Context, userID uint, id int, args ListDeliveriesArgs) (*DeliveryPage, error)
+	GetDelivery(ctx context.Context, userID uint, id int, deliveryID int) (*model.Delivery, error)
+	// ReplayDelivery sends the event of a delivery again, as a new delivery.
+	ReplayDelivery(ctx context.Context, userID uint, id int, deliveryID int) (*model.Delivery, error)
+	// Ping sends a ping event to the endpoint right away, even when it is
+	// disabled, and returns the outcome. Pings do not count towards
+	// disabling the endpoint.
+	Ping(ctx context.Context, userID uint, id int) (*model.Delivery, error)
+}
+
+type webhookService struct {
+	endpointRepository repository.EndpointRepository
+	deliveryRepository repository.DeliveryRepository
+	jobs               jobqueue.Client
+	sender             sender
+	maxAttempts        int
+}
+
+// NewWebhookService creates the service. Replays are tried maxAttempts times
+// and pings are posted with client, see NewHTTPClient.
+func NewWebhookService(
+	endpointRepository repository.EndpointRepository,
+	deliveryRepository repository.DeliveryRepository,
+	jobs jobqueue.Client,
+	client *http.Client,
+	maxAttempts int,
+) WebhookService {
+	return &webhookService{
+		endpointRepository: endpointRepository,
+		deliveryRepository: deliveryRepository,
+		jobs:               jobs,
+		sender:             sender{client: client},
+		maxAttempts:        maxAttempts,
+	}
+}
+
+func (s *webhookService) CreateWebhook(ctx context.Context, user *_userModel.User, args CreateWebhookArgs) (*model.Endpoint, error) {
+	if args.AllUsers && !user.IsAdmin {
+		return nil, ErrAllUsersForbidden
+	}
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := model.Endpoint{
+		OwnerID:     user.ID,
+		URL:         args.URL,
+		Description: args.Description,
+		Secret:      secret,
+		Events:      strings.Join(args.Events, ","),
+		AllUsers:    args.AllUsers,
+	}
+	if err := s.endpointRepository.Create(ctx, &endpoint); err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+func (s *webhookService) ListWebhooks(ctx context.Context, userID uint) ([]model.Endpoint, error) {
+	return s.endpointRepository.List(ctx, userID)
+}
+
+func (s *webhookService) GetWebhook(ctx context.Context, userID uint, id int) (*model.Endpoint, error) {
+	return s.load(ctx, userID, id)
+}
+
+func (s *webhookService) UpdateWebhook(ctx context.Context, user *_userModel.User, id int, args UpdateWebhookArgs) (*model.Endpoint, error) {
+	if args.AllUsers != nil && *args.AllUsers && !user.IsAdmin {
+		return nil, ErrAllUsersForbidden
+	}
+	return s.update(ctx, user.ID, id, func(endpoint *model.Endpoint) error {
+		if args.URL != nil {
+			endpoint.URL = *args.URL
+		}
+		if args.Description != nil {
+			endpoint.Description = *args.Description
+		}
+		if args.Events != nil {
+			endpoint.Events = strings.Join(args.Events, ",")
+		}
+		if args.AllUsers != nil {
+			endpoint.AllUsers = *args.AllUsers
+		}
+		if args.Enabled != nil {
+			switch {
+			case *args.Enabled:
+				endpoint.DisabledAt = nil
+				endpoint.DisabledReason = ""
+				endpoint.FailureCount = 0
+			case endpoint.DisabledAt == nil:
+				now := time.Now()
+				endpoint.DisabledAt = &now
+				endpoint.DisabledReason = "disabled by its owner"
+			}
+		}
+		return nil
+	})
+}
+
+func (s *webhookService) DeleteWebhook(ctx context.Context, userID uint, id int) error {
+	endpoint, err := s.load(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	return translateError(s.endpointRepository.Delete(ctx, endpoint.ID), ErrWebhookNotFound)
+}
+
+func (s *webhookService) RotateSecret(ctx context.Context, userID uint, id int) (*model.Endpoint, error) {
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+	return s.update(ctx, userID, id, func(endpoint *model.Endpoint) error {
+		endpoint.Secret = secret
+		return nil
+	})
+}
+
+func (s *webhookService) ListDeliveries(ctx context.Context, userID uint, id int, args ListDeliveriesArgs) (*DeliveryPage, error) {
+	endpoint, err := s.load(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := args.Limit
+	if limit <= 0 {
+		limit = DefaultDeliveryPageSize
+	}
+	limit = min(limit, MaxDeliveryPageSize)
+
+	// Fetch one more to know whether there is a next page
+	deliveries, err := s.deliveryRepository.List(ctx, repository.ListDeliveryFilter{
+		EndpointID: endpoint.ID,
+		Status:     args.Status,
+		BeforeID:   args.Before,
+		Limit:      limit + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &DeliveryPage{Deliveries: deliveries}
+	if len(deliveries) > limit {
+		page.Deliveries = deliveries[:limit]
+		next := page.Deliveries[limit-1].ID
+		page.NextBefore = &next
+	}
+	return page, nil
+}
+
+func (s *webhookService) GetDelivery(ctx context.Context, userID uint, id int, deliveryID int) (*model.Delivery, error) {
+	endpoint, err := s.load(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.loadDelivery(ctx, endpoint, deliveryID)
+}
+
+func (s *webhookService) ReplayDelivery(ctx context.Context, userID uint, id int, deliveryID int) (*model.Delivery, error) {
+	endpoint, err := s.load(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	original, err := s.loadDelivery(ctx, endpoint, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.DisabledAt != nil {
+		return nil, ErrWebhookDisabled
+	}
+
+	// Receivers recognize the event by its id
+	delivery := model.Delivery{
+		EndpointID:  endpoint.ID,
+		EventID:     original.EventID,
+		EventType:   original.EventType,
+		Payload:     original.Payload,
+		MaxAttempts: s.maxAttempts,
+	}
+	if err := enqueue(ctx, s.deliveryRepository, s.jobs, &delivery); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (s *webhookService) Ping(ctx context.Context, userID uint, id int) (*model.Delivery, error) {
+	endpoint, err := s.load(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	eventID := uuid.NewString()
+	payload, err := json.Marshal(eventPayload{
+		ID:        eventID,
+		Type:      model.EventPing,
+		CreatedAt: time.Now().UTC(),
+		Data:      map[string]uint{"webhook_id": endpoint.ID},
+	})
+	if err != nil {
+		return nil, err
+	}
+	delivery := model.Delivery{
+		EndpointID:  endpoint.ID,
+		EventID:     eventID,
+		EventType:   model.EventPing,
+		Payload:     string(payload),
+		Status:      model.DeliveryPending,
+		MaxAttempts: 1,
+	}
+	if err := s.deliveryRepository.Create(ctx, &delivery); err != nil {
+		return nil, err
+	}
+
+	// The outcome is recorded on the delivery
+	if err := s.sender.send(ctx, endpoint, &delivery); err != nil {
+		delivery.Status = model.DeliveryFailed
+	}
+	if err := s.deliveryRepository.Update(ctx, &delivery); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// load returns the endpoint if the user owns it
+func (s *webhookService) load(ctx context.Context, userID uint, id int) (*model.Endpoint, error) {
+	endpoint, err := s.endpointRepository.FindByID(ctx, uint(id))
+	if err != nil {
+		return nil, translateError(err, ErrWebhookNotFound)
+	}
+	if endpoint.OwnerID != userID {
+		return nil, ErrWebhookNotFound
+	}
+	return endpoint, nil
+}
+
+// update applies fn to the endpoint if the user owns it
+func (s *webhookService) update(ctx context.Context, userID uint, id int, fn func(endpoint *model.Endpoint) error) (*model.Endpoint, error) {
+	endpoint, err := s.endpointRepository.Update(ctx, uint(id), func(endpoint *model.Endpoint) error {
+		if endpoint.OwnerID != userID {
+			return ErrWebhookNotFound
+		}
+		return fn(endpoint)
+	})
+	return endpoint, translateError(err, ErrWebhookNotFound)
+}
+
+// loadDelivery returns the delivery if it was made to the endpoint
+func (s *webhookService) loadDelivery(ctx context.Context, endpoint *model.Endpoint, id int) (*model.Delivery, error) {
+	delivery, err := s.deliveryRepository.FindByID(ctx, uint(id))
+	if err != nil {
+		return nil, translateError(err, ErrDeliveryNotFound)
+	}
+	if delivery.EndpointID != endpoint.ID {
+		return nil, ErrDeliveryNotFound
+	}
+	return delivery, nil
+}
+
+// newSecret returns a random endpoint secret
+func newSecret() (string, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return SecretPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// translateError turns a missing record into notFound
+func translateError(err error, notFound error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return notFound
+	}
+	return err
+}
@@ -0,0 +1,29 @@
+// Package actor carries who performs a request through contexts, so the
+// changes made on their behalf can be attributed to them.
+package actor
+
+import "context"
+
+type contextKey struct{}
+
+// Actor is the client of a request. UserID and LoginSessionID are 0 until
+// the client is authenticated, and for work not done on behalf of a client
+// such as scheduled tasks.
+type Actor struct {
+	UserID         uint   `json:"user_id" gorm:"not null;default:0"`
+	LoginSessionID uint   `json:"login_session_id" gorm:"not null;default:0"`
+	ClientIP       string `json:"client_ip" gorm:"size:64;not null;default:''"`
+	UserAgent      string `json:"user_agent" gorm:"size:512;not null;default:''"`
+	RequestID      string `json:"request_id" gorm:"size:128;not null;default:''"`
+}
+
+// NewContext returns a copy of ctx carrying a.
+func NewContext(ctx context.Context, a Actor) context.Context {
+	return context.WithValue(ctx, contextKey{}, a)
+}
+
+// FromContext returns the actor of ctx, the zero Actor if there is none.
+func FromContext(ctx context.Context) Actor {
+	a, _ := ctx.Value(contextKey{}).(Actor)
+	return a
+}
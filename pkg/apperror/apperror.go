@@ -0,0 +1,202 @@
+package apperror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// StatusClientClosedRequest is used when the client went away before the
+// response was ready.
+const StatusClientClosedRequest = 499
+
+// Code is a stable, machine-readable error identifier. Clients may rely on
+// codes, never on messages.
+type Code string
+
+const (
+	CodeInternal              Code = "internal_error"
+	CodeBadRequest            Code = "bad_request"
+	CodeValidation            Code = "validation_failed"
+	CodeNotFound              Code = "not_found"
+	CodeConflict              Code = "conflict"
+	CodeUnauthorized          Code = "unauthorized"
+	CodeForbidden             Code = "forbidden"
+	CodeTimeout               Code = "timeout"
+	CodeCanceled              Code = "request_canceled"
+	CodeInvalidCredentials    Code = "invalid_credentials"
+	CodeInvalidToken          Code = "invalid_token"
+	CodeSessionNotFound       Code = "session_not_found"
+	CodeUserNotFound          Code = "user_not_found"
+	CodeUsernameTaken         Code = "username_taken"
+	CodeEmailTaken            Code = "email_taken"
+	CodeTodoNotFound          Code = "todo_not_found"
+	CodeLabelNotFound         Code = "label_not_found"
+	CodeLabelNameTaken        Code = "label_name_taken"
+	CodeTodoListNotFound      Code = "todo_list_not_found"
+	CodeTodoListArchived      Code = "todo_list_archived"
+	CodeTodoListForbidden     Code = "todo_list_forbidden"
+	CodeListMemberNotFound    Code = "list_member_not_found"
+	CodeListCreator           Code = "list_creator"
+	CodeAlreadyListMember     Code = "already_list_member"
+	CodeInvitationNotFound    Code = "invitation_not_found"
+	CodeInvitationExists      Code = "invitation_exists"
+	CodeInvitationAnswered    Code = "invitation_answered"
+	CodeTodoTooDeep           Code = "todo_too_deep"
+	CodeTodoCycle             Code = "todo_cycle"
+	CodeSubtaskList           Code = "subtask_list"
+	CodeParentTrashed         Code = "parent_trashed"
+	CodeParentForbidden       Code = "parent_forbidden"
+	CodeChecklistItemNotFound Code = "checklist_item_not_found"
+	CodeRecurringSubtask      Code = "recurring_subtask"
+	CodeReminderNotFound      Code = "reminder_not_found"
+	CodeReminderExists        Code = "reminder_exists"
+	CodeTodoWithoutDueDate    Code = "todo_without_due_date"
+	CodeNotificationNotFound  Code = "notification_not_found"
+	CodeRateLimited           Code = "rate_limited"
+	CodeWebhookNotFound       Code = "webhook_not_found"
+	CodeWebhookDisabled       Code = "webhook_disabled"
+	CodeDeliveryNotFound      Code = "delivery_not_found"
+)
+
+// FieldError describes why a single request field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error is a domain error carrying everything needed to render a response.
+// Message is safe to show to clients, the wrapped Err is only logged.
+type Error struct {
+	Code    Code
+	Status  int
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithCause returns a copy of e that wraps err.
+func (e *Error) WithCause(err error) *Error {
+	copied := *e
+	copied.Err = err
+	return &copied
+}
+
+// New creates an error with the given code, HTTP status and client message.
+func New(code Code, status int, message string) *Error {
+	return &Error{Code: code, Status: status, Message: message}
+}
+
+// Wrap creates an error like New that keeps err as its cause.
+func Wrap(err error, code Code, status int, message string) *Error {
+	return &Error{Code: code, Status: status, Message: message, Err: err}
+}
+
+func BadRequest(message string) *Error {
+	return New(CodeBadRequest, http.StatusBadRequest, message)
+}
+
+func NotFound(code Code, message string) *Error {
+	return New(code, http.StatusNotFound, message)
+}
+
+func Conflict(code Code, message string) *Error {
+	return New(code, http.StatusConflict, message)
+}
+
+func Unauthorized(code Code, message string) *Error {
+	return New(code, http.StatusUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(CodeForbidden, http.StatusForbidden, message)
+}
+
+// Validation creates an error listing every rejected field.
+func Validation(fields ...FieldError) *Error {
+	return &Error{
+		Code:    CodeValidation,
+		Status:  http.StatusUnprocessableEntity,
+		Message: "request validation failed",
+		Fields:  fields,
+	}
+}
+
+func Internal(err error) *Error {
+	return Wrap(err, CodeInternal, http.StatusInternalServerError, "internal server error")
+}
+
+// From converts any error into an *Error, translating well-known errors from
+// GORM, bcrypt, the validator and the JSON decoder. Unknown errors become
+// internal errors so their message never reaches the client.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]FieldError, 0, len(validationErrs))
+		for _, fieldErr := range validationErrs {
+			fields = append(fields, FieldError{
+				Field:   fieldErr.Field(),
+				Code:    fieldErr.Tag(),
+				Message: fmt.Sprintf("failed on the '%s' rule", fieldErr.Tag()),
+			})
+		}
+		appErr = Validation(fields...)
+		appErr.Err = err
+		return appErr
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var timeErr *time.ParseError
+	switch {
+	case errors.As(err, &typeErr):
+		appErr = Validation(FieldError{
+			Field:   typeErr.Field,
+			Code:    "type",
+			Message: fmt.Sprintf("must be of type %s", typeErr.Type),
+		})
+		appErr.Err = err
+		return appErr
+	case errors.As(err, &timeErr):
+		return Wrap(err, CodeBadRequest, http.StatusBadRequest, "time must be formatted as RFC 3339")
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return Wrap(err, CodeBadRequest, http.StatusBadRequest, "request body is not valid JSON")
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return Wrap(err, CodeNotFound, http.StatusNotFound, "resource not found")
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return Wrap(err, CodeConflict, http.StatusConflict, "resource already exists")
+	case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+		return Wrap(err, CodeInvalidCredentials, http.StatusUnauthorized, "invalid username or password")
+	case errors.Is(err, context.DeadlineExceeded):
+		return Wrap(err, CodeTimeout, http.StatusGatewayTimeout, "request timed out")
+	case errors.Is(err, context.Canceled):
+		return Wrap(err, CodeCanceled, StatusClientClosedRequest, "request was canceled")
+	}
+
+	return Internal(err)
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+type entry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// TTLCache is a concurrency-safe in-memory cache whose entries expire after a
+// fixed time to live. It holds at most maxSize entries.
+type TTLCache[K comparable, V any] struct {
+	mu      sync.RWMutex
+	ttl     time.Duration
+	maxSize int
+	items   map[K]entry[V]
+}
+
+func NewTTLCache[K comparable, V any](ttl time.Duration, maxSize int) *TTLCache[K, V] {
+	return &TTLCache[K, V]{
+		ttl:     ttl,
+		maxSize: maxSize,
+		items:   map[K]entry[V]{},
+	}
+}
+
+// Get returns the value cached under key, if it has not expired.
+func (c *TTLCache[K, V]) Get(key K) (V, bool) {
+	c.mu.RLock()
+	item, ok := c.items[key]
+	c.mu.RUnlock()
+
+	if !ok || time.Now().After(item.expiresAt) {
+		var zero V
+		return zero, false
+	}
+	return item.value, true
+}
+
+// Set caches value under key for the cache TTL.
+func (c *TTLCache[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.items[key]; !ok && len(c.items) >= c.maxSize {
+		c.evict()
+	}
+	c.items[key] = entry[V]{
+		value:     value,
+		expiresAt: time.Now().Add(c.ttl),
+	}
+}
+
+// Delete removes key from the cache.
+func (c *TTLCache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.items, key)
+}
+
+// evict drops expired entries, or an arbitrary one if none has expired. Must
+// be called with the lock held.
+func (c *TTLCache[K, V]) evict() {
+	now := time.Now()
+	for key, item := range c.items {
+		if now.After(item.expiresAt) {
+			delete(c.items, key)
+		}
+	}
+	if len(c.items) < c.maxSize {
+		return
+	}
+	for key := range c.items {
+		delete(c.items, key)
+		return
+	}
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	_todoModel "khiemle.dev/golang-api-template/internal/todo/model"
+	_userModel "khiemle.dev/golang-api-template/internal/user/model"
+)
+
+// ClaimUnownedTodos gives the todos created before todos had owners, which
+// have owner 0 and are visible to nobody, to the user with the given
+// username. Nothing is claimed when username is empty, unowned todos are only
+// reported.
+func ClaimUnownedTodos(ctx context.Context, db *gorm.DB, username string) (int64, error) {
+	db = db.WithContext(ctx)
+
+	if username == "" {
+		var count int64
+		if err := db.Model(&_todoModel.Todo{}).Unscoped().Where("owner_id = 0").Count(&count).Error; err != nil {
+			return 0, err
+		}
+		if count > 0 {
+			log.Warn().Int64("count", count).Msg("Todos without owner are not visible to anyone, set TODO_UNOWNED_OWNER to the username that should own them")
+		}
+		return 0, nil
+	}
+
+	user := _userModel.User{}
+	err := db.Where("username = ?", username).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, fmt.Errorf("TODO_UNOWNED_OWNER: user %q not found", username)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	result := db.Model(&_todoModel.Todo{}).Unscoped().Where("owner_id = 0").Update("owner_id", user.ID)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Info().Int64("count", result.RowsAffected).Str("username", username).Msg("Claimed todos without owner")
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,49 @@
+package database_test
+
+import (
+	"context"
+	"testing"
+
+	_todoModel "khiemle.dev/golang-api-template/internal/todo/model"
+	_userModel "khiemle.dev/golang-api-template/internal/user/model"
+	"khiemle.dev/golang-api-template/pkg/database"
+	"khiemle.dev/golang-api-template/pkg/database/databasetest"
+)
+
+func TestClaimUnownedTodos(t *testing.T) {
+	ctx := context.Background()
+	db := databasetest.New(t)
+
+	admin := _userModel.User{Username: "admin", Email: "admin@example.com", Name: "Admin", HashedPassword: "x"}
+	if err := db.Create(&admin).Error; err != nil {
+		t.Fatal(err)
+	}
+	unowned := _todoModel.Todo{Name: "legacy"}
+	owned := _todoModel.Todo{Name: "owned", OwnerID: admin.ID + 1}
+	for _, todo := range []*_todoModel.Todo{&unowned, &owned} {
+		if err := db.Create(todo).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Unowned todos are only reported without a username
+	if claimed, err := database.ClaimUnownedTodos(ctx, db, ""); err != nil || claimed != 0 {
+		t.Errorf("ClaimUnownedTodos without username = %d, %v", claimed, err)
+	}
+	if _, err := database.ClaimUnownedTodos(ctx, db, "nobody"); err == nil {
+		t.Error("ClaimUnownedTodos for a missing user succeeded")
+	}
+
+	claimed, err := database.ClaimUnownedTodos(ctx, db, "admin")
+	if err != nil || claimed != 1 {
+		t.Fatalf("ClaimUnownedTodos = %d, %v, want 1", claimed, err)
+	}
+	for todo, ownerID := range map[*_todoModel.Todo]uint{&unowned: admin.ID, &owned: admin.ID + 1} {
+		if err := db.First(todo, todo.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if todo.OwnerID != ownerID {
+			t.Errorf("todo %q has owner %d, want %d", todo.Name, todo.OwnerID, ownerID)
+		}
+	}
+}
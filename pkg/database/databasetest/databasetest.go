@@ -0,0 +1,36 @@
+// Package databasetest opens migrated databases for tests.
+package databasetest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/pkg/database"
+	"khiemle.dev/golang-api-template/pkg/util"
+)
+
+// New opens a migrated SQLite database in a temporary file that is removed
+// with the test. A file is used instead of :memory: so that transactions
+// and the queries running next to them get their own connections.
+func New(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	db, err := database.NewGormDB(util.Config{
+		DBDriver: database.DriverSQLite,
+		DBPath:   filepath.Join(t.TempDir(), "test.db"),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := database.DBMigration(db); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
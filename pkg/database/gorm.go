@@ -1,37 +1,70 @@
 package database
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net/url"
+	"os"
 
-	"github.com/rs/zerolog/log"
+	"github.com/glebarez/sqlite"
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	gormTracing "gorm.io/plugin/opentelemetry/tracing"
+	_auditModel "khiemle.dev/golang-api-template/internal/audit/model"
 	_authModel "khiemle.dev/golang-api-template/internal/auth/model"
+	_notificationModel "khiemle.dev/golang-api-template/internal/notification/model"
 	_todoModel "khiemle.dev/golang-api-template/internal/todo/model"
 	_userModel "khiemle.dev/golang-api-template/internal/user/model"
+	_webhookModel "khiemle.dev/golang-api-template/internal/webhook/model"
+	"khiemle.dev/golang-api-template/pkg/jobqueue"
+	"khiemle.dev/golang-api-template/pkg/outbox"
+	"khiemle.dev/golang-api-template/pkg/scheduler"
 	util "khiemle.dev/golang-api-template/pkg/util"
 )
 
-// NewGormDB initializes a new GORM DB instance with the provided database URL.
+// Supported values of the DB_DRIVER config
+const (
+	DriverPostgres = "postgres"
+	DriverMySQL    = "mysql"
+	DriverSQLite   = "sqlite"
+)
+
+// SQLiteInMemory is the DB_PATH value that keeps a SQLite database in memory.
+const SQLiteInMemory = ":memory:"
+
+const mysqlCustomTLSConfig = "custom"
+
+// NewGormDB initializes a new GORM DB instance for the configured driver.
 func NewGormDB(cfg util.Config) (*gorm.DB, error) {
-	// Connect to the PostgreSQL database
-	psqlconn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=disable",
-		cfg.DBHost,
-		cfg.DBUser,
-		cfg.DBPassword,
-		cfg.DBName,
-		cfg.DBPort,
-	) // noqa
-	db, err := gorm.Open(postgres.Open(psqlconn), &gorm.Config{
+	dialector, err := newDialector(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{
 		SkipDefaultTransaction: true,
+		TranslateError:         true, // return gorm.ErrDuplicatedKey on unique violations
 	})
 	if err != nil {
-		log.Fatal().Err(err).Msg("Could not connect to database")
+		return nil, fmt.Errorf("could not connect to database: %w", err)
+	}
+
+	// Create a span for every query
+	err = db.Use(gormTracing.NewPlugin(
+		gormTracing.WithDBName(cfg.DBName),
+		gormTracing.WithoutMetrics(),
+		gormTracing.WithoutQueryVariables(), // keep hashed passwords and tokens out of spans
+	))
+	if err != nil {
+		return nil, err
 	}
 
 	sqlDB, err := db.DB()
 	if err != nil {
-		log.Fatal().Err(err).Msg("Error getting SQL DB")
+		return nil, err
 	}
 
 	// SetMaxIdleConns sets the maximum number of connections in the idle connection pool.
@@ -40,12 +73,136 @@ func NewGormDB(cfg util.Config) (*gorm.DB, error) {
 	// SetMaxOpenConns sets the maximum number of open connections to the database.
 	sqlDB.SetMaxOpenConns(100)
 
+	// Every connection to an in-memory SQLite database opens a new, empty
+	// database, so keep a single one
+	if cfg.DBDriver == DriverSQLite && cfg.DBPath == SQLiteInMemory {
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
+	}
+
 	return db, nil
 }
 
+// newDialector builds the GORM dialector for cfg.DBDriver.
+func newDialector(cfg util.Config) (gorm.Dialector, error) {
+	switch cfg.DBDriver {
+	case DriverPostgres, "":
+		return postgres.Open(postgresDSN(cfg)), nil
+	case DriverMySQL:
+		dsn, err := mysqlDSN(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return mysql.Open(dsn), nil
+	case DriverSQLite:
+		return sqlite.Open(sqliteDSN(cfg)), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.DBDriver)
+	}
+}
+
+func postgresDSN(cfg util.Config) string {
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s",
+		cfg.DBHost,
+		cfg.DBUser,
+		cfg.DBPassword,
+		cfg.DBName,
+		cfg.DBPort,
+		cfg.DBSSLMode,
+	) // noqa
+	if cfg.DBSSLRootCert != "" {
+		dsn += fmt.Sprintf(" sslrootcert=%s", cfg.DBSSLRootCert)
+	}
+	return dsn
+}
+
+// mysqlDSN maps DB_SSL_MODE onto the tls parameter of the MySQL driver:
+// disable uses plain connections, require encrypts without verifying the
+// server and verify-ca/verify-full verify it, against DB_SSL_ROOT_CERT if set.
+func mysqlDSN(cfg util.Config) (string, error) {
+	tlsParam := "false"
+	switch cfg.DBSSLMode {
+	case "disable", "":
+	case "require":
+		tlsParam = "skip-verify"
+	case "verify-ca", "verify-full":
+		tlsParam = "true"
+		if cfg.DBSSLRootCert != "" {
+			if err := registerMySQLRootCert(cfg.DBSSLRootCert, cfg.DBHost); err != nil {
+				return "", err
+			}
+			tlsParam = mysqlCustomTLSConfig
+		}
+	default:
+		return "", fmt.Errorf("unsupported ssl mode %q for mysql", cfg.DBSSLMode)
+	}
+
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=UTC&tls=%s",
+		cfg.DBUser,
+		cfg.DBPassword,
+		cfg.DBHost,
+		cfg.DBPort,
+		cfg.DBName,
+		tlsParam,
+	), nil
+}
+
+func registerMySQLRootCert(path string, serverName string) error {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	rootCertPool := x509.NewCertPool()
+	if ok := rootCertPool.AppendCertsFromPEM(pem); !ok {
+		return fmt.Errorf("no certificate found in %s", path)
+	}
+
+	return mysqlDriver.RegisterTLSConfig(mysqlCustomTLSConfig, &tls.Config{
+		RootCAs:    rootCertPool,
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	})
+}
+
+func sqliteDSN(cfg util.Config) string {
+	pragmas := url.Values{}
+	pragmas.Add("_pragma", "foreign_keys(1)")
+	pragmas.Add("_pragma", "busy_timeout(5000)")
+	// Take the write lock when transactions begin, a transaction that read
+	// first fails instead of waiting when another one wrote in the meantime
+	pragmas.Set("_txlock", "immediate")
+	if cfg.DBPath == SQLiteInMemory {
+		return "file::memory:?" + pragmas.Encode()
+	}
+
+	// Let readers run while a write is in progress
+	pragmas.Add("_pragma", "journal_mode(WAL)")
+	return "file:" + cfg.DBPath + "?" + pragmas.Encode()
+}
+
 func DBMigration(db *gorm.DB) error {
+	// Use InnoDB and full unicode on MySQL, other dialects ignore table options
+	if db.Dialector.Name() == DriverMySQL {
+		db = db.Set("gorm:table_options", "ENGINE=InnoDB DEFAULT CHARSET=utf8mb4")
+	}
+
 	var err error
-	err = db.AutoMigrate(&_todoModel.Todo{})
+	// Lists, labels and series first, todos and the todo_labels join table
+	// reference them
+	err = db.AutoMigrate(
+		&_todoModel.TodoList{},
+		&_todoModel.Label{},
+		&_todoModel.TodoSeries{},
+		&_todoModel.Todo{},
+		&_todoModel.ChecklistItem{},
+		&_todoModel.Reminder{},
+		&_todoModel.TodoEvent{},
+		&_todoModel.ListMember{},
+		&_todoModel.ListInvitation{},
+	)
 	if err != nil {
 		return err
 	}
@@ -55,10 +212,41 @@ func DBMigration(db *gorm.DB) error {
 		return err
 	}
 
+	err = db.AutoMigrate(&_notificationModel.Notification{})
+	if err != nil {
+		return err
+	}
+
 	err = db.AutoMigrate(&_authModel.LoginSession{})
 	if err != nil {
 		return err
 	}
 
+	// Endpoints first, deliveries reference them
+	err = db.AutoMigrate(&_webhookModel.Endpoint{}, &_webhookModel.Delivery{})
+	if err != nil {
+		return err
+	}
+
+	err = db.AutoMigrate(&jobqueue.Job{})
+	if err != nil {
+		return err
+	}
+
+	err = db.AutoMigrate(&scheduler.Run{})
+	if err != nil {
+		return err
+	}
+
+	err = db.AutoMigrate(&outbox.Event{}, &outbox.Cursor{}, &outbox.Gap{}, &outbox.DeadLetter{})
+	if err != nil {
+		return err
+	}
+
+	err = db.AutoMigrate(&_auditModel.AuditLog{})
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -0,0 +1,106 @@
+package jobqueue
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	// StatusDead marks jobs that failed MaxAttempts times. They are kept for
+	// inspection and are not retried.
+	StatusDead = "dead"
+)
+
+// DefaultMaxAttempts is how many times a job is tried unless WithMaxAttempts
+// is passed to Enqueue.
+const DefaultMaxAttempts = 5
+
+// Job is a unit of work stored in the database until a worker runs it.
+type Job struct {
+	ID          uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	Type        string     `json:"type" gorm:"size:100;not null"`
+	Payload     string     `json:"payload" gorm:"type:text;not null"`
+	Status      string     `json:"status" gorm:"size:20;not null;index:idx_jobs_status_run_at"`
+	RunAt       time.Time  `json:"run_at" gorm:"not null;index:idx_jobs_status_run_at"`
+	Attempts    int        `json:"attempts" gorm:"not null;default:0"`
+	MaxAttempts int        `json:"max_attempts" gorm:"not null"`
+	LastError   string     `json:"last_error" gorm:"type:text"`
+	LockedBy    string     `json:"locked_by" gorm:"size:255"`
+	LockedAt    *time.Time `json:"locked_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// EnqueueOption customizes a job before it is stored.
+type EnqueueOption func(job *Job)
+
+// WithRunAt delays the job until the given time.
+func WithRunAt(runAt time.Time) EnqueueOption {
+	return func(job *Job) {
+		job.RunAt = runAt
+	}
+}
+
+// WithDelay delays the job by d.
+func WithDelay(d time.Duration) EnqueueOption {
+	return func(job *Job) {
+		job.RunAt = time.Now().Add(d)
+	}
+}
+
+// WithMaxAttempts overrides how many times the job is tried before it is
+// marked dead.
+func WithMaxAttempts(n int) EnqueueOption {
+	return func(job *Job) {
+		job.MaxAttempts = n
+	}
+}
+
+// Client enqueues jobs. Enqueue joins the transaction of the context, see
+// txn, so jobs are stored atomically with other writes.
+type Client interface {
+	Enqueue(ctx context.Context, jobType string, payload any, opts ...EnqueueOption) (*Job, error)
+}
+
+type gormClient struct {
+	db *gorm.DB
+}
+
+func NewClient(db *gorm.DB) Client {
+	return &gormClient{
+		db: db,
+	}
+}
+
+// Enqueue stores a job of the given type. The payload is encoded as JSON and
+// decoded into the type the handler was registered with.
+func (c *gormClient) Enqueue(ctx context.Context, jobType string, payload any, opts ...EnqueueOption) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	job := Job{
+		Type:        jobType,
+		Payload:     string(data),
+		Status:      StatusPending,
+		RunAt:       time.Now(),
+		MaxAttempts: DefaultMaxAttempts,
+	}
+	for _, opt := range opts {
+		opt(&job)
+	}
+
+	if err := txn.DB(ctx, c.db).Create(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
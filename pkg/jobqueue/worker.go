@@ -0,0 +1,383 @@
+package jobqueue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"khiemle.dev/golang-api-template/pkg/tracing"
+)
+
+const (
+	// retryBaseDelay is the delay before the first retry, doubled on every
+	// following attempt up to retryMaxDelay.
+	retryBaseDelay = 10 * time.Second
+	retryMaxDelay  = time.Hour
+)
+
+// HandlerFunc runs a job. Returning an error schedules a retry.
+type HandlerFunc func(ctx context.Context, job *Job) error
+
+// HandlerOption configures how jobs of a type are run.
+type HandlerOption func(h *handler)
+
+// WithConcurrency limits how many jobs of the type a worker runs at once.
+func WithConcurrency(n int) HandlerOption {
+	return func(h *handler) {
+		h.concurrency = n
+	}
+}
+
+// WithTimeout bounds how long a single run may take. It defaults to the lock
+// timeout of the worker.
+func WithTimeout(d time.Duration) HandlerOption {
+	return func(h *handler) {
+		h.timeout = d
+	}
+}
+
+type handler struct {
+	fn          HandlerFunc
+	concurrency int
+	timeout     time.Duration
+	running     int
+}
+
+// Handle registers a typed handler for jobType. The job payload is decoded
+// into T before fn is called.
+func Handle[T any](w *Worker, jobType string, fn func(ctx context.Context, payload T) error, opts ...HandlerOption) {
+	w.HandleFunc(jobType, func(ctx context.Context, job *Job) error {
+		var payload T
+		if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+			return fmt.Errorf("decode payload: %w", err)
+		}
+		return fn(ctx, payload)
+	}, opts...)
+}
+
+// Worker polls the jobs table and runs due jobs with the registered handlers.
+// Several workers, in the same or other processes, can share the table; each
+// job is claimed by exactly one of them.
+type Worker struct {
+	db           *gorm.DB
+	id           string
+	concurrency  int
+	pollInterval time.Duration
+	lockTimeout  time.Duration
+
+	mu       sync.Mutex
+	handlers map[string]*handler
+	running  int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wake   chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+	jobs   sync.WaitGroup
+}
+
+// NewWorker creates a worker identified by id that runs at most concurrency
+// jobs at once. Jobs still running after lockTimeout are considered abandoned
+// and handed to another worker.
+func NewWorker(db *gorm.DB, id string, concurrency int, pollInterval time.Duration, lockTimeout time.Duration) *Worker {
+	return &Worker{
+		db:           db,
+		id:           id,
+		concurrency:  concurrency,
+		pollInterval: pollInterval,
+		lockTimeout:  lockTimeout,
+		handlers:     map[string]*handler{},
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// HandleFunc registers fn for jobType. It must be called before Start.
+func (w *Worker) HandleFunc(jobType string, fn HandlerFunc, opts ...HandlerOption) {
+	h := &handler{
+		fn:      fn,
+		timeout: w.lockTimeout,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers[jobType] = h
+}
+
+// Start polls for jobs until Close is called.
+func (w *Worker) Start() {
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+
+	go func() {
+		defer close(w.done)
+
+		ticker := time.NewTicker(w.pollInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := w.rescue(w.ctx); err != nil {
+				log.Error().Err(err).Msg("Could not rescue abandoned jobs")
+			}
+			if err := w.poll(w.ctx); err != nil {
+				log.Error().Err(err).Msg("Could not claim jobs")
+			}
+
+			select {
+			case <-ticker.C:
+			case <-w.wake:
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops claiming jobs and waits for running ones. If ctx ends first,
+// running jobs are canceled and will be retried.
+func (w *Worker) Close(ctx context.Context) error {
+	if w.stop == nil {
+		return nil
+	}
+
+	close(w.stop)
+	<-w.done
+
+	finished := make(chan struct{})
+	go func() {
+		w.jobs.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		w.cancel()
+		return nil
+	case <-ctx.Done():
+		w.cancel()
+		return ctx.Err()
+	}
+}
+
+// poll claims as many due jobs as there are free slots and runs them.
+func (w *Worker) poll(ctx context.Context) error {
+	w.mu.Lock()
+	free := w.concurrency - w.running
+	limits := map[string]int{}
+	for jobType, h := range w.handlers {
+		limit := free
+		if h.concurrency > 0 && h.concurrency-h.running < limit {
+			limit = h.concurrency - h.running
+		}
+		if limit > 0 {
+			limits[jobType] = limit
+		}
+	}
+	w.mu.Unlock()
+
+	for jobType, limit := range limits {
+		if free <= 0 {
+			break
+		}
+		if limit > free {
+			limit = free
+		}
+
+		jobs, err := w.claim(ctx, jobType, limit)
+		if err != nil {
+			return err
+		}
+		free -= len(jobs)
+
+		for i := range jobs {
+			w.run(&jobs[i])
+		}
+	}
+	return nil
+}
+
+// claim marks up to limit due jobs of jobType as running by this worker. Rows
+// locked by other workers are skipped; SQLite has no row locks so a
+// conditional update decides which worker wins.
+func (w *Worker) claim(ctx context.Context, jobType string, limit int) ([]Job, error) {
+	claimed := []Job{}
+	err := w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		query := tx.Where("type = ? AND status = ? AND run_at <= ?", jobType, StatusPending, now).
+			Order("run_at, id").
+			Limit(limit)
+		if tx.Dialector.Name() != "sqlite" {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+
+		candidates := []Job{}
+		if err := query.Find(&candidates).Error; err != nil {
+			return err
+		}
+
+		for _, job := range candidates {
+			result := tx.Model(&Job{}).
+				Where("id = ? AND status = ?", job.ID, StatusPending).
+				Updates(map[string]any{
+					"status":    StatusRunning,
+					"attempts":  gorm.Expr("attempts + 1"),
+					"locked_by": w.id,
+					"locked_at": now,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+
+			job.Status = StatusRunning
+			job.Attempts++
+			job.LockedBy = w.id
+			job.LockedAt = &now
+			claimed = append(claimed, job)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+func (w *Worker) run(job *Job) {
+	w.mu.Lock()
+	h := w.handlers[job.Type]
+	h.running++
+	w.running++
+	w.mu.Unlock()
+
+	w.jobs.Add(1)
+	go func() {
+		defer w.jobs.Done()
+		defer func() {
+			w.mu.Lock()
+			h.running--
+			w.running--
+			w.mu.Unlock()
+
+			select {
+			case w.wake <- struct{}{}:
+			default:
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(w.ctx, h.timeout)
+		defer cancel()
+
+		ctx, span := tracing.Tracer().Start(ctx, "job "+job.Type, trace.WithAttributes(
+			attribute.Int("job.id", int(job.ID)),
+			attribute.Int("job.attempt", job.Attempts),
+		))
+		err := safeRun(ctx, h.fn, job)
+		tracing.End(span, err)
+
+		if err := w.finish(job, err); err != nil {
+			log.Error().Err(err).Uint("job_id", job.ID).Msg("Could not save job result")
+		}
+	}()
+}
+
+// safeRun turns a panicking handler into a failed attempt.
+func safeRun(ctx context.Context, fn HandlerFunc, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx, job)
+}
+
+// finish records the outcome of a run. Failed jobs are retried with
+// exponential backoff until they run out of attempts and are marked dead.
+func (w *Worker) finish(job *Job, runErr error) error {
+	now := time.Now()
+	logger := log.With().Uint("job_id", job.ID).Str("job_type", job.Type).Int("attempt", job.Attempts).Logger()
+
+	updates := map[string]any{
+		"locked_by": "",
+		"locked_at": nil,
+	}
+	switch {
+	case runErr == nil:
+		updates["status"] = StatusSucceeded
+		updates["finished_at"] = now
+		updates["last_error"] = ""
+		logger.Debug().Msg("Job succeeded")
+	case job.Attempts >= job.MaxAttempts:
+		updates["status"] = StatusDead
+		updates["finished_at"] = now
+		updates["last_error"] = runErr.Error()
+		logger.Error().Err(runErr).Msg("Job failed permanently")
+	default:
+		updates["status"] = StatusPending
+		updates["run_at"] = now.Add(retryDelay(job.Attempts))
+		updates["last_error"] = runErr.Error()
+		logger.Warn().Err(runErr).Msg("Job failed, will retry")
+	}
+
+	// The job may have been rescued by another worker in the meantime
+	return w.db.Model(&Job{}).
+		Where("id = ? AND status = ? AND locked_by = ?", job.ID, StatusRunning, w.id).
+		Updates(updates).Error
+}
+
+// rescue releases jobs whose worker did not finish them within the lock
+// timeout, most likely because it crashed. They count as a failed attempt.
+func (w *Worker) rescue(ctx context.Context) error {
+	staleBefore := time.Now().Add(-w.lockTimeout)
+	db := w.db.WithContext(ctx).Model(&Job{}).
+		Where("status = ? AND locked_at < ?", StatusRunning, staleBefore).
+		Session(&gorm.Session{})
+
+	err := db.
+		Where("attempts >= max_attempts").
+		Updates(map[string]any{
+			"status":      StatusDead,
+			"finished_at": time.Now(),
+			"last_error":  "abandoned by worker",
+			"locked_by":   "",
+			"locked_at":   nil,
+		}).Error
+	if err != nil {
+		return err
+	}
+
+	return db.
+		Updates(map[string]any{
+			"status":     StatusPending,
+			"last_error": "abandoned by worker",
+			"locked_by":  "",
+			"locked_at":  nil,
+		}).Error
+}
+
+// retryDelay returns the backoff before the next attempt, with up to 20%
+// jitter so failed jobs do not retry in lockstep.
+func retryDelay(attempts int) time.Duration {
+	delay := retryMaxDelay
+	if attempts < 32 {
+		if d := retryBaseDelay << (attempts - 1); d > 0 && d < retryMaxDelay {
+			delay = d
+		}
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/5+1))
+}
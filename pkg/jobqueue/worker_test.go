@@ -0,0 +1,139 @@
+package jobqueue_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/pkg/database/databasetest"
+	"khiemle.dev/golang-api-template/pkg/jobqueue"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+type greeting struct {
+	Name string `json:"name"`
+}
+
+func startWorker(t *testing.T, db *gorm.DB, register func(w *jobqueue.Worker)) {
+	t.Helper()
+	worker := jobqueue.NewWorker(db, "test", 2, 10*time.Millisecond, time.Minute)
+	register(worker)
+	worker.Start()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := worker.Close(ctx); err != nil {
+			t.Errorf("Close: %v", err)
+		}
+	})
+}
+
+// waitForStatus waits until the job has the given status and returns it
+func waitForStatus(t *testing.T, db *gorm.DB, id uint, status string) jobqueue.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var job jobqueue.Job
+		if err := db.First(&job, id).Error; err != nil {
+			t.Fatal(err)
+		}
+		if job.Status == status {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %d is %s, want %s", id, job.Status, status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWorkerRunsJobs(t *testing.T) {
+	db := databasetest.New(t)
+	greeted := make(chan string, 1)
+	startWorker(t, db, func(w *jobqueue.Worker) {
+		jobqueue.Handle(w, "greet", func(ctx context.Context, payload greeting) error {
+			greeted <- payload.Name
+			return nil
+		})
+	})
+
+	job, err := jobqueue.NewClient(db).Enqueue(context.Background(), "greet", greeting{Name: "alice"})
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	select {
+	case name := <-greeted:
+		if name != "alice" {
+			t.Errorf("handler got %q, want alice", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the job did not run")
+	}
+	if job := waitForStatus(t, db, job.ID, jobqueue.StatusSucceeded); job.Attempts != 1 || job.FinishedAt == nil {
+		t.Errorf("job = %+v, want one finished attempt", job)
+	}
+}
+
+func TestWorkerMarksFailedJobsDead(t *testing.T) {
+	db := databasetest.New(t)
+	startWorker(t, db, func(w *jobqueue.Worker) {
+		w.HandleFunc("fail", func(ctx context.Context, job *jobqueue.Job) error {
+			return errors.New("boom")
+		})
+	})
+
+	job, err := jobqueue.NewClient(db).Enqueue(context.Background(), "fail", nil, jobqueue.WithMaxAttempts(1))
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if job := waitForStatus(t, db, job.ID, jobqueue.StatusDead); job.LastError != "boom" {
+		t.Errorf("LastError = %q, want boom", job.LastError)
+	}
+}
+
+func TestEnqueueJoinsTransaction(t *testing.T) {
+	db := databasetest.New(t)
+	client := jobqueue.NewClient(db)
+	rollback := errors.New("rollback")
+
+	err := txn.NewGormTransactor(db).Transaction(context.Background(), func(ctx context.Context) error {
+		if _, err := client.Enqueue(ctx, "greet", greeting{Name: "alice"}); err != nil {
+			return err
+		}
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("Transaction = %v", err)
+	}
+
+	var count int64
+	if err := db.Model(&jobqueue.Job{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("%d jobs stored by a rolled back transaction", count)
+	}
+}
+
+func TestDelayedJobsWait(t *testing.T) {
+	db := databasetest.New(t)
+	ran := make(chan struct{}, 1)
+	startWorker(t, db, func(w *jobqueue.Worker) {
+		w.HandleFunc("later", func(ctx context.Context, job *jobqueue.Job) error {
+			ran <- struct{}{}
+			return nil
+		})
+	})
+
+	if _, err := jobqueue.NewClient(db).Enqueue(context.Background(), "later", nil, jobqueue.WithDelay(time.Hour)); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	select {
+	case <-ran:
+		t.Error("a delayed job ran early")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"khiemle.dev/golang-api-template/pkg/util"
+)
+
+// MeterName is the instrumentation name used for instruments created by this application.
+const MeterName = "khiemle.dev/golang-api-template"
+
+// ShutdownFunc exports pending measurements and releases the exporter.
+type ShutdownFunc func(ctx context.Context) error
+
+// Meter returns the application meter from the global meter provider.
+// Instruments created before Setup are forwarded once it ran.
+func Meter() metric.Meter {
+	return otel.Meter(MeterName)
+}
+
+// Setup installs the global meter provider, which exports to the OTLP/HTTP
+// collector configured for tracing. When metrics are disabled measurements
+// are dropped.
+func Setup(ctx context.Context, cfg util.Config) (ShutdownFunc, error) {
+	if !cfg.MetricsEnabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlpmetrichttp.Option{
+		otlpmetrichttp.WithEndpoint(cfg.TracingOTLPEndpoint),
+	}
+	if cfg.TracingOTLPInsecure {
+		opts = append(opts, otlpmetrichttp.WithInsecure())
+	}
+	exporter, err := otlpmetrichttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	mp := sdkmetric.NewMeterProvider(
+		sdkmetric.WithResource(resource.NewSchemaless(semconv.ServiceName(cfg.TracingServiceName))),
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(cfg.MetricsExportInterval))),
+	)
+	otel.SetMeterProvider(mp)
+
+	return mp.Shutdown, nil
+}
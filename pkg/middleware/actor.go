@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"khiemle.dev/golang-api-template/pkg/actor"
+)
+
+// maxUserAgentLength bounds the user agent kept for attributing changes
+const maxUserAgentLength = 512
+
+// ActorMiddleware stores the client of the request in the request context,
+// see actor.FromContext. The auth middlewares add the user and the login
+// session once the access token was verified. It must run after
+// RequestIDMiddleware.
+func ActorMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userAgent := ctx.Request.UserAgent()
+		if len(userAgent) > maxUserAgentLength {
+			userAgent = userAgent[:maxUserAgentLength]
+		}
+
+		ctx.Request = ctx.Request.WithContext(actor.NewContext(ctx.Request.Context(), actor.Actor{
+			ClientIP:  ctx.ClientIP(),
+			UserAgent: userAgent,
+			RequestID: ctx.GetString(RequestIDKey),
+		}))
+		ctx.Next()
+	}
+}
+
+// setActor updates the actor of the request.
+func setActor(ctx *gin.Context, update func(a *actor.Actor)) {
+	a := actor.FromContext(ctx.Request.Context())
+	update(&a)
+	ctx.Request = ctx.Request.WithContext(actor.NewContext(ctx.Request.Context(), a))
+}
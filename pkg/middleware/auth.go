@@ -3,16 +3,17 @@ package middleware
 import (
 	"errors"
 	"fmt"
-	"net/http"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/rs/zerolog/log"
-	"gorm.io/gorm"
+	"github.com/rs/zerolog"
 	"khiemle.dev/golang-api-template/internal/auth/service"
-	"khiemle.dev/golang-api-template/internal/schemas"
+	_userModel "khiemle.dev/golang-api-template/internal/user/model"
 	_userService "khiemle.dev/golang-api-template/internal/user/service"
+	"khiemle.dev/golang-api-template/pkg/actor"
+	"khiemle.dev/golang-api-template/pkg/apperror"
+	"khiemle.dev/golang-api-template/pkg/tracing"
 	"khiemle.dev/golang-api-template/pkg/util/token"
 )
 
@@ -22,40 +23,29 @@ const (
 	AuthorizationTypeBearer  = "bearer"
 	AuthorizationPayloadKey  = "authorization_payload"
 	AuthorizationCurrentUser = "current_user"
+	AccessTokenQueryKey      = "access_token"
 )
 
 func GetBearerTokenMiddleware() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		authorizationHeader := ctx.GetHeader(AuthorizationHeaderKey)
 		if len(authorizationHeader) == 0 {
-			err := errors.New("authorization is not provided")
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, schemas.APIResponse{
-				Status:  http.StatusUnauthorized,
-				Message: err.Error(),
-				Data:    nil,
-			})
+			AbortWithError(ctx, apperror.Unauthorized(apperror.CodeUnauthorized, "authorization is not provided"))
 			return
 		}
 
 		fields := strings.Fields(authorizationHeader)
 		if len(fields) != 2 {
-			err := errors.New("invalid authorization header format")
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, schemas.APIResponse{
-				Status:  http.StatusUnauthorized,
-				Message: err.Error(),
-				Data:    nil,
-			})
+			AbortWithError(ctx, apperror.Unauthorized(apperror.CodeUnauthorized, "invalid authorization header format"))
 			return
 		}
 
 		authorizationHeaderType := strings.ToLower(fields[0])
 		if authorizationHeaderType != AuthorizationTypeBearer {
-			err := fmt.Errorf("unsupported authorization type %s", authorizationHeaderType)
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, schemas.APIResponse{
-				Status:  http.StatusUnauthorized,
-				Message: err.Error(),
-				Data:    nil,
-			})
+			AbortWithError(ctx, apperror.Unauthorized(
+				apperror.CodeUnauthorized,
+				fmt.Sprintf("unsupported authorization type %s", authorizationHeaderType),
+			))
 			return
 		}
 
@@ -64,79 +54,150 @@ func GetBearerTokenMiddleware() gin.HandlerFunc {
 	}
 }
 
+// GetSocketTokenMiddleware reads the access token like
+// GetBearerTokenMiddleware or, as browsers cannot set headers on WebSocket
+// handshakes, from the access_token query parameter. The parameter is removed
+// from the request, so the URLs logged afterwards, e.g. by the recovery
+// middleware, do not hold the token.
+func GetSocketTokenMiddleware() gin.HandlerFunc {
+	getBearerToken := GetBearerTokenMiddleware()
+	return func(ctx *gin.Context) {
+		token := ctx.Query(AccessTokenQueryKey)
+		if ctx.Request.URL.Query().Has(AccessTokenQueryKey) {
+			query := ctx.Request.URL.Query()
+			query.Del(AccessTokenQueryKey)
+			ctx.Request.URL.RawQuery = query.Encode()
+			ctx.Request.RequestURI = ctx.Request.URL.RequestURI()
+		}
+
+		if token != "" && ctx.GetHeader(AuthorizationHeaderKey) == "" {
+			ctx.Set(AuthorizationHeaderToken, token)
+			return
+		}
+		getBearerToken(ctx)
+	}
+}
+
 func VerifyTokenMiddleware(tokenMaker token.TokenMaker, loginSessionService service.LoginSessionService, userService _userService.UserService) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		token := ctx.MustGet(AuthorizationHeaderToken).(string)
+		accessToken := ctx.MustGet(AuthorizationHeaderToken).(string)
 
 		// Verify token
-		payload, err := tokenMaker.VerifyToken(token)
+		_, span := tracing.Tracer().Start(ctx.Request.Context(), "token.Verify")
+		payload, err := tokenMaker.VerifyToken(accessToken)
+		tracing.End(span, err)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, schemas.APIResponse{
-				Status:  http.StatusUnauthorized,
-				Message: err.Error(),
-				Data:    nil,
-			})
+			AbortWithError(ctx, service.ErrInvalidToken.WithCause(err))
+			return
+		}
+		if payload.TokenType == token.TokenTypeRefresh {
+			AbortWithError(ctx, service.ErrInvalidToken)
 			return
 		}
 
 		// Check login session
-		loginSession, err := loginSessionService.FindByTokenID(ctx, payload.TokenID)
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, schemas.APIResponse{
-				Status:  http.StatusUnauthorized,
-				Message: "login session not found",
-				Data:    nil,
-			})
+		loginSession, err := loginSessionService.FindByTokenID(ctx.Request.Context(), payload.TokenID)
+		if err != nil {
+			AbortWithError(ctx, err)
+			return
+		}
+		zerolog.Ctx(ctx).Debug().Msgf("Login session found: %d", loginSession.ID)
+
+		// update last login time of login session, written in batches
+		loginSessionService.MarkUsed(loginSession.ID, time.Now())
+
+		// Get current logged in user
+		user, err := userService.GetUserById(ctx.Request.Context(), loginSession.UserId)
+		if errors.Is(err, _userService.ErrUserNotFound) {
+			AbortWithError(ctx, apperror.Unauthorized(apperror.CodeUserNotFound, "user not found"))
 			return
 		}
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, schemas.APIResponse{
-				Status:  http.StatusUnauthorized,
-				Message: err.Error(),
-				Data:    nil,
-			})
+			AbortWithError(ctx, err)
 			return
 		}
-		log.Info().Msgf("Login session found: %d", loginSession.ID)
+		zerolog.Ctx(ctx).Debug().Msgf("User found: %d", user.ID)
 
-		// update last login time of login session
-		lastUsedAt := time.Now()
-		loginSession, err = loginSessionService.UpdateSession(ctx, loginSession.ID, service.UpdateLoginSessionArgs{
-			LastUsedAt: &lastUsedAt,
+		setLoggerField(ctx, func(c zerolog.Context) zerolog.Context {
+			return c.Uint("user_id", user.ID).Uint("login_session_id", loginSession.ID)
+		})
+		setActor(ctx, func(a *actor.Actor) {
+			a.UserID = user.ID
+			a.LoginSessionID = loginSession.ID
 		})
+
+		ctx.Set(AuthorizationHeaderToken, accessToken)
+		ctx.Set(AuthorizationPayloadKey, *payload)
+		ctx.Set(AuthorizationCurrentUser, user)
+		ctx.Next()
+	}
+}
+
+// VerifyStatelessTokenMiddleware trusts access tokens on signature and expiry
+// alone and only rejects the ones in revocationList, so no login session is
+// read. The current user is still loaded, through the cache when enabled.
+func VerifyStatelessTokenMiddleware(tokenMaker token.TokenMaker, revocationList *service.RevocationList, userService _userService.UserService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		accessToken := ctx.MustGet(AuthorizationHeaderToken).(string)
+
+		// Verify token
+		_, span := tracing.Tracer().Start(ctx.Request.Context(), "token.Verify")
+		payload, err := tokenMaker.VerifyToken(accessToken)
+		tracing.End(span, err)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, schemas.APIResponse{
-				Status:  http.StatusUnauthorized,
-				Message: err.Error(),
-				Data:    nil,
-			})
+			AbortWithError(ctx, service.ErrInvalidToken.WithCause(err))
+			return
+		}
+		if payload.TokenType != token.TokenTypeAccess || revocationList.IsRevoked(payload.TokenID) {
+			AbortWithError(ctx, service.ErrInvalidToken)
 			return
 		}
-		log.Info().Msgf("Login session updated: %d", loginSession.ID)
 
 		// Get current logged in user
-		user, err := userService.GetUserById(ctx, loginSession.UserId)
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, schemas.APIResponse{
-				Status:  http.StatusUnauthorized,
-				Message: "user not found",
-				Data:    nil,
-			})
+		user, err := userService.GetUserById(ctx.Request.Context(), payload.UserId)
+		if errors.Is(err, _userService.ErrUserNotFound) {
+			AbortWithError(ctx, apperror.Unauthorized(apperror.CodeUserNotFound, "user not found"))
 			return
 		}
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, schemas.APIResponse{
-				Status:  http.StatusUnauthorized,
-				Message: err.Error(),
-				Data:    nil,
-			})
+			AbortWithError(ctx, err)
 			return
 		}
-		log.Info().Msgf("User found: %d", user.ID)
 
-		ctx.Set(AuthorizationHeaderToken, token)
+		setLoggerField(ctx, func(c zerolog.Context) zerolog.Context {
+			return c.Uint("user_id", user.ID)
+		})
+		// The login session is not read, only the user is known
+		setActor(ctx, func(a *actor.Actor) {
+			a.UserID = user.ID
+		})
+
+		ctx.Set(AuthorizationHeaderToken, accessToken)
 		ctx.Set(AuthorizationPayloadKey, *payload)
 		ctx.Set(AuthorizationCurrentUser, user)
 		ctx.Next()
 	}
 }
+
+// CurrentUser returns the user set by the auth middleware. It must run after
+// the access token was verified.
+func CurrentUser(ctx *gin.Context) *_userModel.User {
+	return ctx.MustGet(AuthorizationCurrentUser).(*_userModel.User)
+}
+
+// CurrentUserID returns the id of CurrentUser.
+func CurrentUserID(ctx *gin.Context) uint {
+	return CurrentUser(ctx).ID
+}
+
+// RequireAdminMiddleware rejects users that are not administrators. It must
+// run after the access token was verified.
+func RequireAdminMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !CurrentUser(ctx).IsAdmin {
+			AbortWithError(ctx, apperror.Forbidden("admin privileges are required"))
+			return
+		}
+		ctx.Next()
+	}
+}
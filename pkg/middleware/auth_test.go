@@ -0,0 +1,45 @@
+package middleware_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"khiemle.dev/golang-api-template/pkg/middleware"
+)
+
+func TestSocketTokenIsRemovedFromTheURL(t *testing.T) {
+	const token = "v4.local.secret-token"
+
+	// The recovery middleware logs the request of panicking handlers in debug
+	// mode
+	gin.SetMode(gin.DebugMode)
+	defer gin.SetMode(gin.TestMode)
+	logs := &bytes.Buffer{}
+	router := gin.New()
+	router.Use(gin.RecoveryWithWriter(logs))
+	var got string
+	router.GET("/ws", middleware.GetSocketTokenMiddleware(), func(c *gin.Context) {
+		got = c.GetString(middleware.AuthorizationHeaderToken)
+		panic("boom")
+	})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ws?access_token="+token+"&since=1", nil))
+
+	if got != token {
+		t.Errorf("token = %q, want %q", got, token)
+	}
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if strings.Contains(logs.String(), token) {
+		t.Errorf("logs contain the token: %s", logs)
+	}
+	if !strings.Contains(logs.String(), "/ws?since=1") {
+		t.Errorf("logs miss the request: %s", logs)
+	}
+}
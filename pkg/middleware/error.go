@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"khiemle.dev/golang-api-template/internal/schemas"
+	"khiemle.dev/golang-api-template/pkg/apperror"
+)
+
+const ProblemJSONContentType = "application/problem+json"
+
+// AbortWithError records err for ErrorHandlerMiddleware and stops the chain.
+func AbortWithError(ctx *gin.Context, err error) {
+	_ = ctx.Error(err)
+	ctx.Abort()
+}
+
+// ErrorHandlerMiddleware renders the last error recorded with AbortWithError
+// as an RFC 7807 problem+json response.
+func ErrorHandlerMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Next()
+
+		if len(ctx.Errors) == 0 || ctx.Writer.Written() {
+			return
+		}
+
+		appErr := apperror.From(ctx.Errors.Last().Err)
+		if appErr.Status >= http.StatusInternalServerError {
+			zerolog.Ctx(ctx).Error().Err(appErr).Msg("Request failed")
+		}
+
+		ctx.Header("Content-Type", ProblemJSONContentType)
+		ctx.AbortWithStatusJSON(appErr.Status, NewProblemDetails(ctx, appErr))
+	}
+}
+
+// NewProblemDetails describes appErr for the client of the request.
+func NewProblemDetails(ctx *gin.Context, appErr *apperror.Error) schemas.ProblemDetails {
+	title := http.StatusText(appErr.Status)
+	if title == "" {
+		title = appErr.Message
+	}
+
+	return schemas.ProblemDetails{
+		Type:      "about:blank",
+		Title:     title,
+		Status:    appErr.Status,
+		Detail:    appErr.Message,
+		Instance:  ctx.Request.URL.Path,
+		Code:      string(appErr.Code),
+		RequestID: ctx.GetString(RequestIDKey),
+		Errors:    appErr.Fields,
+	}
+}
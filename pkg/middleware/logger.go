@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// LoggerMiddleware stores a request-scoped logger in the request context and
+// logs every request once it completes. Handlers retrieve the logger with
+// zerolog.Ctx(ctx).
+func LoggerMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+
+		logCtx := log.Logger.With().
+			Str("request_id", ctx.GetString(RequestIDKey)).
+			Str("method", ctx.Request.Method).
+			Str("route", ctx.FullPath())
+		if spanCtx := trace.SpanContextFromContext(ctx.Request.Context()); spanCtx.HasTraceID() {
+			logCtx = logCtx.Str("trace_id", spanCtx.TraceID().String())
+		}
+		logger := logCtx.Logger()
+		ctx.Request = ctx.Request.WithContext(logger.WithContext(ctx.Request.Context()))
+
+		ctx.Next()
+
+		// Fetch the logger again as later middlewares may have enriched it
+		logger = *zerolog.Ctx(ctx.Request.Context())
+
+		status := ctx.Writer.Status()
+		var event *zerolog.Event
+		switch {
+		case status >= 500:
+			event = logger.Error()
+		case status >= 400:
+			event = logger.Warn()
+		default:
+			event = logger.Info()
+		}
+		if len(ctx.Errors) > 0 {
+			event = event.Str("errors", ctx.Errors.String())
+		}
+
+		event.
+			Str("path", ctx.Request.URL.Path).
+			Int("status", status).
+			Int("size", ctx.Writer.Size()).
+			Str("client_ip", ctx.ClientIP()).
+			Dur("latency", time.Since(start)).
+			Msg("Request completed")
+	}
+}
+
+// setLoggerField adds a field to the request-scoped logger.
+func setLoggerField(ctx *gin.Context, update func(c zerolog.Context) zerolog.Context) {
+	logger := update(zerolog.Ctx(ctx.Request.Context()).With()).Logger()
+	ctx.Request = ctx.Request.WithContext(logger.WithContext(ctx.Request.Context()))
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TimeoutMiddleware sets a deadline on the request context so database
+// queries and other context-aware work stop once it passes. The context is
+// also cancelled when the client goes away. Long-lived streaming routes, given
+// as registered paths such as /v1/todos/events, get no deadline.
+func TimeoutMiddleware(timeout time.Duration, streamingRoutes ...string) gin.HandlerFunc {
+	streaming := map[string]bool{}
+	for _, route := range streamingRoutes {
+		streaming[route] = true
+	}
+
+	return func(ctx *gin.Context) {
+		if timeout <= 0 || streaming[ctx.FullPath()] {
+			ctx.Next()
+			return
+		}
+
+		timeoutCtx, cancel := context.WithTimeout(ctx.Request.Context(), timeout)
+		defer cancel()
+
+		ctx.Request = ctx.Request.WithContext(timeoutCtx)
+		ctx.Next()
+	}
+}
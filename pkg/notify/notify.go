@@ -0,0 +1,76 @@
+// Package notify delivers user-facing messages through pluggable channels
+// such as email, outbound webhooks or the in-app inbox.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Names of the built-in channels
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+	ChannelInApp   = "in_app"
+)
+
+// Message is a notification for a single user.
+type Message struct {
+	// ID identifies the message across retries. Channels pass it on so
+	// receivers can drop duplicates of an at-least-once delivery.
+	ID      string
+	UserID  uint
+	Email   string
+	Kind    string // e.g. todo.reminder
+	Subject string
+	Body    string
+	Data    map[string]any
+}
+
+// Channel delivers messages. Send returns an error when the message may not
+// have been delivered, so the caller can retry it.
+type Channel interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Notifier sends messages through the channels registered by name.
+type Notifier struct {
+	channels map[string]Channel
+}
+
+func NewNotifier() *Notifier {
+	return &Notifier{
+		channels: map[string]Channel{},
+	}
+}
+
+// Register adds a channel. It must be called before the notifier is used.
+func (n *Notifier) Register(name string, channel Channel) {
+	n.channels[name] = channel
+}
+
+// Has reports whether a channel is registered under name.
+func (n *Notifier) Has(name string) bool {
+	_, ok := n.channels[name]
+	return ok
+}
+
+// Channels returns the names of the registered channels, sorted.
+func (n *Notifier) Channels() []string {
+	names := make([]string, 0, len(n.channels))
+	for name := range n.channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Send delivers msg through the named channel.
+func (n *Notifier) Send(ctx context.Context, channel string, msg Message) error {
+	ch, ok := n.channels[channel]
+	if !ok {
+		return fmt.Errorf("notification channel %q is not enabled", channel)
+	}
+	return ch.Send(ctx, msg)
+}
@@ -0,0 +1,71 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"khiemle.dev/golang-api-template/pkg/notify"
+)
+
+// brokerMessage is the JSON body of the requests of the HTTP broker
+type brokerMessage struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   uint            `json:"aggregate_id"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Data          json.RawMessage `json:"data"`
+}
+
+type httpBroker struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewHTTPBroker creates a Broker posting events as JSON to url, e.g. a bridge
+// to a message broker. Requests are signed with secret like outbound
+// webhooks and carry the event id in the X-Webhook-Id header.
+func NewHTTPBroker(url string, secret string, timeout time.Duration) Broker {
+	return &httpBroker{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (b *httpBroker) Publish(ctx context.Context, event *Event) error {
+	body, err := json.Marshal(brokerMessage{
+		ID:            event.EventID,
+		Type:          event.Type,
+		AggregateType: event.AggregateType,
+		AggregateID:   event.AggregateID,
+		OccurredAt:    event.OccurredAt,
+		Data:          json.RawMessage(event.Payload),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := notify.NewWebhookRequest(ctx, b.url, b.secret, event.EventID, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(notify.HeaderWebhookEvent, event.Type)
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("broker responded with %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,509 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"khiemle.dev/golang-api-template/pkg/metrics"
+	"khiemle.dev/golang-api-template/pkg/pubsub"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+const (
+	// batchSize bounds how many events a subscriber handles per transaction
+	batchSize = 100
+	// retryBaseDelay is the delay before retrying a failed event, doubled on
+	// every following failure up to retryMaxDelay.
+	retryBaseDelay = time.Second
+	retryMaxDelay  = 5 * time.Minute
+)
+
+// errCursorMoved is returned when another dispatcher advanced the cursor of a
+// subscriber first, SQLite has no row locks to prevent it
+var errCursorMoved = errors.New("cursor moved by another dispatcher")
+
+// handlerError is returned when a subscriber failed to handle an event
+type handlerError struct {
+	event *Event
+	err   error
+}
+
+func (e *handlerError) Error() string {
+	return fmt.Sprintf("handle %s event %s: %s", e.event.Type, e.event.EventID, e.err)
+}
+
+func (e *handlerError) Unwrap() error {
+	return e.err
+}
+
+// permanentError is an error retrying the event cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks an error of a handler as one retrying cannot fix, e.g. an
+// event that cannot be decoded. The event is moved to the dead letters right
+// away instead of holding back the later events.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Handler handles an event. It runs in the transaction advancing the cursor
+// of its subscriber, so writes made through txn are committed exactly once
+// along with the cursor. An error rolls the writes back and the event is
+// retried with backoff, later events wait for it. After the max attempts of
+// the dispatcher, or right away for errors marked Permanent, the event is
+// moved to the dead letters.
+type Handler func(ctx context.Context, event *Event) error
+
+// Broker publishes events outside of the application, e.g. to a message
+// broker. Deliveries are at least once: receivers deduplicate by event id.
+type Broker interface {
+	Publish(ctx context.Context, event *Event) error
+}
+
+type subscriber struct {
+	name       string
+	fn         Handler
+	wake       chan struct{}
+	fromOldest bool
+}
+
+// SubscribeOption configures a subscriber.
+type SubscribeOption func(sub *subscriber)
+
+// FromOldest starts a new subscriber with the oldest event kept, instead of
+// the events recorded after it first ran.
+func FromOldest() SubscribeOption {
+	return func(sub *subscriber) {
+		sub.fromOldest = true
+	}
+}
+
+// Dispatcher hands the recorded events to every subscriber, in order. Each
+// subscriber has its own cursor, so a failing one does not hold back the
+// others, and subscribers added later start with the events recorded after
+// they first ran, unless subscribed with FromOldest. Several dispatchers, in the same or other processes, can
+// share the tables; each event is handled by one of them per subscriber.
+type Dispatcher struct {
+	db           *gorm.DB
+	transactor   txn.Transactor
+	pubsub       pubsub.PubSub
+	pollInterval time.Duration
+	gapTimeout   time.Duration
+	maxAttempts  int
+	subscribers  []*subscriber
+	dispatched   metric.Int64Counter
+	failed       metric.Int64Counter
+	deadLettered metric.Int64Counter
+
+	ctx         context.Context
+	cancel      context.CancelFunc
+	unsubscribe func()
+	stop        chan struct{}
+	running     sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher polling for events every pollInterval
+// and woken up through ps as soon as events are committed. Subscribers try
+// each event maxAttempts times before moving it to the dead letters.
+//
+// Ids of events are only in commit order within gapTimeout: an event whose
+// predecessor id is missing waits that long for it to commit, in case its
+// transaction is still running, and rolled back ones delay the following
+// events by as much. Events committed later are handled out of order once
+// they show up, until the Janitor forgets about them.
+func NewDispatcher(db *gorm.DB, ps pubsub.PubSub, pollInterval time.Duration, gapTimeout time.Duration, maxAttempts int) (*Dispatcher, error) {
+	dispatched, err := metrics.Meter().Int64Counter(
+		"outbox.events.dispatched",
+		metric.WithDescription("Number of outbox events handled by subscribers"),
+		metric.WithUnit("{event}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	failed, err := metrics.Meter().Int64Counter(
+		"outbox.events.failed",
+		metric.WithDescription("Number of times subscribers failed to handle an outbox event"),
+		metric.WithUnit("{event}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	deadLettered, err := metrics.Meter().Int64Counter(
+		"outbox.events.dead_lettered",
+		metric.WithDescription("Number of outbox events subscribers gave up on"),
+		metric.WithUnit("{event}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Dispatcher{
+		db:           db,
+		transactor:   txn.NewGormTransactor(db),
+		pubsub:       ps,
+		pollInterval: pollInterval,
+		gapTimeout:   gapTimeout,
+		maxAttempts:  maxAttempts,
+		dispatched:   dispatched,
+		failed:       failed,
+		deadLettered: deadLettered,
+	}, nil
+}
+
+// Subscribe registers fn under name, which identifies its cursor and must
+// not change. It must be called before Start.
+func (d *Dispatcher) Subscribe(name string, fn Handler, opts ...SubscribeOption) {
+	sub := &subscriber{
+		name: name,
+		fn:   fn,
+		wake: make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(sub)
+	}
+	d.subscribers = append(d.subscribers, sub)
+}
+
+// SubscribeBroker publishes every event to broker.
+func (d *Dispatcher) SubscribeBroker(name string, broker Broker) {
+	d.Subscribe(name, broker.Publish)
+}
+
+// Start dispatches events until Close is called.
+func (d *Dispatcher) Start() {
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	d.stop = make(chan struct{})
+	d.unsubscribe = d.pubsub.Subscribe(Channel, func(string) {
+		for _, sub := range d.subscribers {
+			signal(sub.wake)
+		}
+	})
+
+	for _, sub := range d.subscribers {
+		d.running.Add(1)
+		go func() {
+			defer d.running.Done()
+			d.run(d.ctx, sub)
+		}()
+	}
+}
+
+// Close stops dispatching and waits for running handlers. If ctx ends first
+// they are canceled and their events will be handled again.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	if d.stop == nil {
+		return nil
+	}
+
+	close(d.stop)
+	d.unsubscribe()
+
+	finished := make(chan struct{})
+	go func() {
+		d.running.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-finished
+		return ctx.Err()
+	}
+}
+
+// run dispatches the events of the subscriber, backing off while its
+// handler fails
+func (d *Dispatcher) run(ctx context.Context, sub *subscriber) {
+	logger := log.With().Str("subscriber", sub.name).Logger()
+	failures := 0
+	for {
+		delay := d.pollInterval
+		wake := sub.wake
+		more, err := d.dispatch(ctx, sub)
+		var handleErr *handlerError
+		switch {
+		case ctx.Err() != nil:
+			return
+		case errors.As(err, &handleErr):
+			failures++
+			delay = retryDelay(failures)
+			// Do not retry before the backoff passed
+			wake = nil
+			logger.Warn().Err(err).Int("failures", failures).Msg("Could not handle outbox event, will retry")
+		case err != nil:
+			logger.Error().Err(err).Msg("Could not dispatch outbox events")
+		case more:
+			failures = 0
+			delay = 0
+		default:
+			failures = 0
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-wake:
+			timer.Stop()
+		case <-d.stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// dispatch hands the subscriber the events committed after it moved past
+// their id, then the events after its cursor, in one transaction, and
+// reports whether more are waiting. Progress made before a failing event is
+// kept, along with the failed attempt.
+func (d *Dispatcher) dispatch(ctx context.Context, sub *subscriber) (bool, error) {
+	attrs := metric.WithAttributes(attribute.String("subscriber", sub.name))
+	var handled, deadLettered int64
+	var handleErr *handlerError
+	more := false
+
+	err := d.transactor.Transaction(ctx, func(ctx context.Context) error {
+		db := txn.DB(ctx, d.db)
+
+		// Skip subscribers another dispatcher is busy with
+		query := db.Where("subscriber = ?", sub.name).Limit(1)
+		if db.Dialector.Name() != "sqlite" {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+		cursors := []Cursor{}
+		if err := query.Find(&cursors).Error; err != nil {
+			return err
+		}
+		if len(cursors) == 0 {
+			return d.createCursor(db, sub)
+		}
+		cursor := cursors[0]
+
+		gaps := []Gap{}
+		err := db.Where("subscriber = ?", sub.name).Order("event_id").Limit(batchSize).Find(&gaps).Error
+		if err != nil {
+			return err
+		}
+		gapsChanged := false
+		if len(gaps) > 0 {
+			ids := make([]uint, len(gaps))
+			gapAttempts := map[uint]int{}
+			for i, gap := range gaps {
+				ids[i] = gap.EventID
+				gapAttempts[gap.EventID] = gap.Attempts
+			}
+			late := []Event{}
+			if err := db.Where("id IN ?", ids).Order("id").Find(&late).Error; err != nil {
+				return err
+			}
+			for i := range late {
+				event := &late[i]
+				attempts, dead, err := d.handle(ctx, db, sub, event, gapAttempts[event.ID])
+				gap := db.Where("subscriber = ? AND event_id = ?", sub.name, event.ID)
+				if errors.As(err, &handleErr) {
+					gapsChanged = true
+					return gap.Model(&Gap{}).Update("attempts", attempts).Error
+				}
+				if err != nil {
+					return err
+				}
+				if err := gap.Delete(&Gap{}).Error; err != nil {
+					return err
+				}
+				gapsChanged = true
+				if dead {
+					deadLettered++
+				} else {
+					handled++
+				}
+			}
+		}
+
+		events := []Event{}
+		err = db.Where("id > ?", cursor.LastEventID).Order("id").Limit(batchSize).Find(&events).Error
+		if err != nil {
+			return err
+		}
+
+		last := cursor.LastEventID
+		attempts := cursor.Attempts
+		for i := range events {
+			event := &events[i]
+			if event.ID != last+1 && time.Since(event.OccurredAt) < d.gapTimeout {
+				// The missing events may not be committed yet
+				break
+			}
+
+			var dead bool
+			var err error
+			attempts, dead, err = d.handle(ctx, db, sub, event, attempts)
+			if errors.As(err, &handleErr) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if dead {
+				deadLettered++
+			} else {
+				handled++
+			}
+
+			// Handle the missing events whenever they get committed
+			if event.ID != last+1 {
+				if err := d.createGaps(db, sub.name, last+1, event.ID); err != nil {
+					return err
+				}
+				gapsChanged = true
+			}
+			last = event.ID
+		}
+		more = handleErr == nil && len(events) == batchSize && last == events[len(events)-1].ID
+
+		if last == cursor.LastEventID && attempts == cursor.Attempts && !gapsChanged {
+			return nil
+		}
+		result := db.Model(&Cursor{}).
+			Where("subscriber = ? AND last_event_id = ? AND attempts = ?", sub.name, cursor.LastEventID, cursor.Attempts).
+			Updates(map[string]any{"last_event_id": last, "attempts": attempts})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errCursorMoved
+		}
+		return nil
+	})
+	if errors.Is(err, errCursorMoved) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	d.dispatched.Add(ctx, handled, attrs)
+	d.deadLettered.Add(ctx, deadLettered, attrs)
+	if handleErr != nil {
+		d.failed.Add(ctx, 1, attrs)
+		return false, handleErr
+	}
+	return more, nil
+}
+
+// handle hands the event to the subscriber, which failed to handle it
+// attempts times so far. It returns a handlerError along with the new count
+// of attempts when the event is to be retried. Otherwise the event is done
+// with, handled or moved to the dead letters as reported by dead.
+func (d *Dispatcher) handle(ctx context.Context, db *gorm.DB, sub *subscriber, event *Event, attempts int) (int, bool, error) {
+	// Roll back the writes of a failing handler only
+	err := d.transactor.Transaction(ctx, func(ctx context.Context) error {
+		return sub.fn(ctx, event)
+	})
+	if err == nil {
+		return 0, false, nil
+	}
+	if ctx.Err() != nil {
+		// Canceled on shutdown, not a failed attempt
+		return attempts, false, ctx.Err()
+	}
+
+	attempts++
+	var permanent *permanentError
+	if attempts < d.maxAttempts && !errors.As(err, &permanent) {
+		return attempts, false, &handlerError{event: event, err: err}
+	}
+	if err := d.deadLetter(db, sub.name, event, attempts, err); err != nil {
+		return attempts, false, err
+	}
+	return 0, true, nil
+}
+
+// createGaps remembers the ids from first up to before end, which the
+// subscriber moves past while they are missing
+func (d *Dispatcher) createGaps(db *gorm.DB, name string, first uint, end uint) error {
+	gaps := make([]Gap, 0, end-first)
+	for id := first; id < end; id++ {
+		gaps = append(gaps, Gap{Subscriber: name, EventID: id})
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(gaps, batchSize).Error
+}
+
+// deadLetter gives up on the event for the subscriber
+func (d *Dispatcher) deadLetter(db *gorm.DB, name string, event *Event, attempts int, err error) error {
+	log.Error().Err(err).
+		Str("subscriber", name).
+		Str("event_id", event.EventID).
+		Str("event_type", event.Type).
+		Int("attempts", attempts).
+		Msg("Gave up on outbox event, moved it to the dead letters")
+	return db.Create(&DeadLetter{
+		Subscriber: name,
+		EventID:    event.EventID,
+		EventType:  event.Type,
+		Payload:    event.Payload,
+		Attempts:   attempts,
+		Error:      err.Error(),
+	}).Error
+}
+
+// createCursor starts a new subscriber after the latest event, or before the
+// oldest one with FromOldest. Nothing is created when another dispatcher
+// holds the cursor.
+func (d *Dispatcher) createCursor(db *gorm.DB, sub *subscriber) error {
+	position := "COALESCE(MAX(id), 0)"
+	if sub.fromOldest {
+		position = "COALESCE(MIN(id) - 1, 0)"
+	}
+	var lastEventID uint
+	if err := db.Model(&Event{}).Select(position).Scan(&lastEventID).Error; err != nil {
+		return err
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&Cursor{
+		Subscriber:  sub.name,
+		LastEventID: lastEventID,
+	}).Error
+}
+
+// retryDelay returns the backoff before the next attempt, with up to 20%
+// jitter.
+func retryDelay(failures int) time.Duration {
+	delay := retryMaxDelay
+	if failures < 32 {
+		if d := retryBaseDelay << (failures - 1); d > 0 && d < retryMaxDelay {
+			delay = d
+		}
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/5+1))
+}
+
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,276 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/pkg/database/databasetest"
+	"khiemle.dev/golang-api-template/pkg/outbox"
+	"khiemle.dev/golang-api-template/pkg/pubsub"
+)
+
+// deadLetters waits for count dead letters to be committed and returns them
+func deadLetters(t *testing.T, db *gorm.DB, count int) []outbox.DeadLetter {
+	t.Helper()
+	deadLetters := []outbox.DeadLetter{}
+	waitFor(t, func() bool {
+		return db.Find(&deadLetters).Error == nil && len(deadLetters) >= count
+	})
+	return deadLetters
+}
+
+func TestDispatcherDeadLettersFailingEvents(t *testing.T) {
+	ctx := context.Background()
+	db := databasetest.New(t)
+	ps := pubsub.NewLocal()
+	dispatcher, err := outbox.NewDispatcher(db, ps, 10*time.Millisecond, time.Second, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	handled := []string{}
+	dispatcher.Subscribe("test", func(ctx context.Context, event *outbox.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts[event.Type]++
+		if event.Type == "poison" {
+			return errors.New("cannot handle")
+		}
+		handled = append(handled, event.Type)
+		return nil
+	})
+	dispatcher.Start()
+	defer dispatcher.Close(ctx)
+
+	// Wait for the cursor, subscribers start after the events recorded before
+	waitFor(t, func() bool {
+		var count int64
+		return db.Model(&outbox.Cursor{}).Count(&count).Error == nil && count == 1
+	})
+
+	record(t, db, ps, "first", "poison", "last")
+
+	// The poison event no longer holds back the last one once given up on
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == 2
+	})
+	mu.Lock()
+	if handled[0] != "first" || handled[1] != "last" || attempts["poison"] != 2 {
+		t.Errorf("handled %v after %d attempts of the poison event, want [first last] after 2", handled, attempts["poison"])
+	}
+	mu.Unlock()
+
+	letters := deadLetters(t, db, 1)
+	if len(letters) != 1 || letters[0].EventType != "poison" || letters[0].Subscriber != "test" ||
+		letters[0].Attempts != 2 || letters[0].Error != "cannot handle" {
+		t.Errorf("dead letters = %+v, want the poison event", letters)
+	}
+	cursor := outbox.Cursor{}
+	if err := db.First(&cursor, "subscriber = ?", "test").Error; err != nil {
+		t.Fatal(err)
+	}
+	if cursor.Attempts != 0 {
+		t.Errorf("cursor attempts = %d, want 0", cursor.Attempts)
+	}
+}
+
+func TestDispatcherDeadLettersPermanentErrorsRightAway(t *testing.T) {
+	ctx := context.Background()
+	db := databasetest.New(t)
+	ps := pubsub.NewLocal()
+	dispatcher, err := outbox.NewDispatcher(db, ps, 10*time.Millisecond, time.Second, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	handled := []string{}
+	dispatcher.Subscribe("test", func(ctx context.Context, event *outbox.Event) error {
+		if event.Type == "undecodable" {
+			return outbox.Permanent(errors.New("cannot decode"))
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, event.Type)
+		return nil
+	}, outbox.FromOldest())
+	record(t, db, ps, "undecodable", "last")
+	dispatcher.Start()
+	defer dispatcher.Close(ctx)
+
+	// Without backoff, well before the first retry would be due
+	start := time.Now()
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == 1
+	})
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("last event handled after %s, want no backoff", elapsed)
+	}
+
+	letters := deadLetters(t, db, 1)
+	if len(letters) != 1 || letters[0].EventType != "undecodable" || letters[0].Attempts != 1 {
+		t.Errorf("dead letters = %+v, want the undecodable event after 1 attempt", letters)
+	}
+}
+
+func TestDispatcherStartsFromOldestEvent(t *testing.T) {
+	ctx := context.Background()
+	db := databasetest.New(t)
+	ps := pubsub.NewLocal()
+	dispatcher, err := outbox.NewDispatcher(db, ps, 10*time.Millisecond, time.Second, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	handled := map[string][]string{}
+	handler := func(name string) outbox.Handler {
+		return func(ctx context.Context, event *outbox.Event) error {
+			mu.Lock()
+			defer mu.Unlock()
+			handled[name] = append(handled[name], event.Type)
+			return nil
+		}
+	}
+	dispatcher.Subscribe("latest", handler("latest"))
+	dispatcher.Subscribe("oldest", handler("oldest"), outbox.FromOldest())
+
+	// The oldest events are gone, the dispatcher must not wait for them
+	record(t, db, ps, "purged", "before")
+	if err := db.Where("type = ?", "purged").Delete(&outbox.Event{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	dispatcher.Start()
+	defer dispatcher.Close(ctx)
+	waitFor(t, func() bool {
+		var count int64
+		return db.Model(&outbox.Cursor{}).Count(&count).Error == nil && count == 2
+	})
+	record(t, db, ps, "after")
+
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled["oldest"]) == 2 && len(handled["latest"]) == 1
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	if got := strings.Join(handled["oldest"], ","); got != "before,after" {
+		t.Errorf("oldest subscriber handled %s, want before,after", got)
+	}
+	if got := strings.Join(handled["latest"], ","); got != "after" {
+		t.Errorf("latest subscriber handled %s, want after", got)
+	}
+}
+
+func TestDispatcherHandlesEventsCommittedLate(t *testing.T) {
+	ctx := context.Background()
+	db := databasetest.New(t)
+	dispatcher, err := outbox.NewDispatcher(db, pubsub.NewLocal(), 10*time.Millisecond, 50*time.Millisecond, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	handled := []string{}
+	dispatcher.Subscribe("test", func(ctx context.Context, event *outbox.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, event.Type)
+		return nil
+	})
+	dispatcher.Start()
+	defer dispatcher.Close(ctx)
+	waitFor(t, func() bool {
+		var count int64
+		return db.Model(&outbox.Cursor{}).Count(&count).Error == nil && count == 1
+	})
+
+	// The transaction of the second event outlasts the gap timeout
+	create(t, db, 1, "first", time.Now())
+	create(t, db, 3, "later", time.Now())
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == 2
+	})
+	gaps := []outbox.Gap{}
+	if err := db.Find(&gaps).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(gaps) != 1 || gaps[0].Subscriber != "test" || gaps[0].EventID != 2 {
+		t.Errorf("gaps = %+v, want event 2 of test", gaps)
+	}
+
+	create(t, db, 2, "late", time.Now().Add(-time.Minute))
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == 3
+	})
+	mu.Lock()
+	if got := strings.Join(handled, ","); got != "first,later,late" {
+		t.Errorf("handled %s, want first,later,late", got)
+	}
+	mu.Unlock()
+	var count int64
+	if err := db.Model(&outbox.Gap{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("%d gaps left, want 0", count)
+	}
+}
+
+// create stores an event of eventType with the given id, as committed by a
+// transaction that began at occurredAt
+func create(t *testing.T, db *gorm.DB, id uint, eventType string, occurredAt time.Time) {
+	t.Helper()
+	event, err := outbox.NewEvent(eventType, "test", 1, map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event.ID = id
+	event.OccurredAt = occurredAt
+	if err := db.Create(&event).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// record records an event of each type
+func record(t *testing.T, db *gorm.DB, ps pubsub.PubSub, eventTypes ...string) {
+	t.Helper()
+	recorder := outbox.NewRecorder(db, ps)
+	for _, eventType := range eventTypes {
+		event, err := outbox.NewEvent(eventType, "test", 1, map[string]string{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := recorder.Record(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// waitFor polls cond until it holds, for up to 10 seconds
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,66 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/metric"
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/pkg/metrics"
+)
+
+// Janitor deletes events older than the retention window once every
+// subscriber handled them. Events are kept while no subscriber ran yet, and
+// the cursor of a removed subscriber must be deleted for events to be purged
+// again. Subscribers stop waiting for the events missing since then too. It
+// is run periodically by the scheduler.
+type Janitor struct {
+	db        *gorm.DB
+	retention time.Duration
+	purged    metric.Int64Counter
+}
+
+func NewJanitor(db *gorm.DB, retention time.Duration) (*Janitor, error) {
+	purged, err := metrics.Meter().Int64Counter(
+		"outbox.events.purged",
+		metric.WithDescription("Number of outbox events deleted by the janitor"),
+		metric.WithUnit("{event}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Janitor{
+		db:        db,
+		retention: retention,
+		purged:    purged,
+	}, nil
+}
+
+// Run purges events older than the retention window that every subscriber
+// handled, and the gaps of subscribers older than it.
+func (j *Janitor) Run(ctx context.Context) error {
+	db := j.db.WithContext(ctx)
+	before := time.Now().Add(-j.retention)
+
+	// Events rolled back, or purged, never show up
+	if err := db.Where("created_at < ?", before).Delete(&Gap{}).Error; err != nil {
+		return err
+	}
+
+	handled := db.Model(&Cursor{}).Select("MIN(last_event_id)")
+	pending := db.Model(&Gap{}).Select("event_id")
+	result := db.Where("occurred_at < ? AND id <= (?) AND id NOT IN (?)", before, handled, pending).Delete(&Event{})
+	j.purged.Add(ctx, result.RowsAffected)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	event := log.Debug()
+	if result.RowsAffected > 0 {
+		event = log.Info()
+	}
+	event.Int64("purged", result.RowsAffected).Msg("Purged outbox events")
+	return nil
+}
@@ -0,0 +1,120 @@
+package outbox_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"khiemle.dev/golang-api-template/pkg/database/databasetest"
+	"khiemle.dev/golang-api-template/pkg/outbox"
+	"khiemle.dev/golang-api-template/pkg/pubsub"
+)
+
+func TestJanitorKeepsEventsSubscribersDidNotHandle(t *testing.T) {
+	ctx := context.Background()
+	db := databasetest.New(t)
+	janitor, err := outbox.NewJanitor(db, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record(t, db, pubsub.NewLocal(), "first", "second", "third", "recent")
+	events := []outbox.Event{}
+	if err := db.Order("id").Find(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+	err = db.Model(&outbox.Event{}).Where("type <> ?", "recent").Update("occurred_at", time.Now().Add(-2*time.Hour)).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remaining := func() []string {
+		t.Helper()
+		types := []string{}
+		if err := db.Model(&outbox.Event{}).Order("id").Pluck("type", &types).Error; err != nil {
+			t.Fatal(err)
+		}
+		return types
+	}
+
+	// Nothing is purged before subscribers ran
+	if err := janitor.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := remaining(); len(got) != 4 {
+		t.Errorf("events = %v, want all of them", got)
+	}
+
+	// The slowest subscriber handled the first event only
+	cursors := []outbox.Cursor{
+		{Subscriber: "fast", LastEventID: events[3].ID},
+		{Subscriber: "slow", LastEventID: events[0].ID},
+	}
+	if err := db.Create(&cursors).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := janitor.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := remaining(); len(got) != 3 || got[0] != "second" {
+		t.Errorf("events = %v, want the ones after first", got)
+	}
+
+	// Recent events are kept once handled
+	if err := db.Model(&outbox.Cursor{}).Where("subscriber = ?", "slow").Update("last_event_id", events[3].ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := janitor.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := remaining(); len(got) != 1 || got[0] != "recent" {
+		t.Errorf("events = %v, want recent", got)
+	}
+}
+
+func TestJanitorForgetsOldGaps(t *testing.T) {
+	ctx := context.Background()
+	db := databasetest.New(t)
+	janitor, err := outbox.NewJanitor(db, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The subscriber moved past the first event before it was committed
+	record(t, db, pubsub.NewLocal(), "late", "handled")
+	events := []outbox.Event{}
+	if err := db.Order("id").Find(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(&outbox.Event{}).Where("true").Update("occurred_at", time.Now().Add(-2*time.Hour)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&outbox.Cursor{Subscriber: "test", LastEventID: events[1].ID}).Error; err != nil {
+		t.Fatal(err)
+	}
+	gaps := []outbox.Gap{
+		{Subscriber: "test", EventID: events[0].ID},
+		{Subscriber: "test", EventID: events[1].ID + 1, CreatedAt: time.Now().Add(-2 * time.Hour)},
+	}
+	if err := db.Create(&gaps).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := janitor.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	types := []string{}
+	if err := db.Model(&outbox.Event{}).Pluck("type", &types).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(types) != 1 || types[0] != "late" {
+		t.Errorf("events = %v, want the one not handled yet", types)
+	}
+	left := []outbox.Gap{}
+	if err := db.Find(&left).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 1 || left[0].EventID != events[0].ID {
+		t.Errorf("gaps = %+v, want the recent one", left)
+	}
+}
@@ -0,0 +1,149 @@
+// Package outbox publishes domain events reliably. Services record events in
+// the transaction of the change they describe, so an event exists if and only
+// if its change was committed. A Dispatcher then hands them to subscribers.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/pkg/actor"
+	"khiemle.dev/golang-api-template/pkg/pubsub"
+	"khiemle.dev/golang-api-template/pkg/txn"
+)
+
+// Channel is the pubsub channel waking up dispatchers once events were
+// committed.
+const Channel = "outbox"
+
+// Event is a change of the state of an aggregate, such as a todo or a user.
+// Events of an aggregate are dispatched in the order they were recorded.
+type Event struct {
+	ID uint `json:"-" gorm:"primaryKey;autoIncrement"`
+	// EventID identifies the event for receivers deduplicating deliveries
+	EventID       string    `json:"id" gorm:"size:36;not null;uniqueIndex"`
+	Type          string    `json:"type" gorm:"size:100;not null"`
+	AggregateType string    `json:"aggregate_type" gorm:"size:50;not null"`
+	AggregateID   uint      `json:"aggregate_id" gorm:"not null"`
+	Payload       string    `json:"payload" gorm:"type:text;not null"`
+	OccurredAt    time.Time `json:"occurred_at" gorm:"not null;index"`
+	// Actor is the client whose request recorded the event
+	Actor actor.Actor `json:"actor" gorm:"embedded;embeddedPrefix:actor_"`
+}
+
+func (Event) TableName() string {
+	return "outbox_events"
+}
+
+// Decode decodes the payload of the event into v.
+func (e *Event) Decode(v any) error {
+	return json.Unmarshal([]byte(e.Payload), v)
+}
+
+// Cursor is the last event a subscriber handled.
+type Cursor struct {
+	Subscriber  string `gorm:"primaryKey;size:100"`
+	LastEventID uint   `gorm:"not null"`
+	// Attempts is how many times the subscriber failed to handle the event
+	// after LastEventID
+	Attempts  int       `gorm:"not null;default:0"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+func (Cursor) TableName() string {
+	return "outbox_cursors"
+}
+
+// Gap is the id of an event a subscriber moved past while the event was not
+// committed yet. The event is handled once it shows up, out of order.
+type Gap struct {
+	Subscriber string `gorm:"primaryKey;size:100"`
+	EventID    uint   `gorm:"primaryKey;autoIncrement:false"`
+	// Attempts is how many times the subscriber failed to handle the event
+	Attempts  int       `gorm:"not null;default:0"`
+	CreatedAt time.Time `gorm:"autoCreateTime;index"`
+}
+
+func (Gap) TableName() string {
+	return "outbox_gaps"
+}
+
+// DeadLetter is an event a subscriber gave up on, so the following events are
+// not held back any longer. It keeps a copy of the event, which may be
+// purged since, to be looked into and handled by hand.
+type DeadLetter struct {
+	ID         uint   `gorm:"primaryKey;autoIncrement"`
+	Subscriber string `gorm:"size:100;not null;index"`
+	// EventID is the id of the event for receivers, not of its row
+	EventID   string    `gorm:"size:36;not null"`
+	EventType string    `gorm:"size:100;not null"`
+	Payload   string    `gorm:"type:text;not null"`
+	Attempts  int       `gorm:"not null"`
+	Error     string    `gorm:"type:text;not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime;index"`
+}
+
+func (DeadLetter) TableName() string {
+	return "outbox_dead_letters"
+}
+
+// NewEvent creates an event of eventType about an aggregate. The payload is
+// encoded as JSON.
+func NewEvent(eventType string, aggregateType string, aggregateID uint, payload any) (Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, fmt.Errorf("encode %s event: %w", eventType, err)
+	}
+	return Event{
+		EventID:       uuid.NewString(),
+		Type:          eventType,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Payload:       string(data),
+		OccurredAt:    time.Now(),
+	}, nil
+}
+
+// Recorder records events.
+type Recorder interface {
+	// Record stores the events in the transaction of the context, see txn,
+	// attributed to the actor of the context. Outside of transactions they
+	// are stored right away.
+	Record(ctx context.Context, events ...Event) error
+}
+
+type gormRecorder struct {
+	db     *gorm.DB
+	pubsub pubsub.PubSub
+}
+
+// NewRecorder creates a Recorder waking up dispatchers through ps once the
+// events are committed.
+func NewRecorder(db *gorm.DB, ps pubsub.PubSub) Recorder {
+	return &gormRecorder{
+		db:     db,
+		pubsub: ps,
+	}
+}
+
+func (r *gormRecorder) Record(ctx context.Context, events ...Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	for i := range events {
+		events[i].Actor = actor.FromContext(ctx)
+	}
+	if err := txn.DB(ctx, r.db).Create(&events).Error; err != nil {
+		return err
+	}
+
+	// Dispatchers also poll, a lost wake-up only delays the events
+	txn.AfterCommit(ctx, func() {
+		_ = r.pubsub.Publish(context.WithoutCancel(ctx), Channel, "")
+	})
+	return nil
+}
@@ -0,0 +1,144 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// reconnectDelay is how long the listener waits before reconnecting
+const reconnectDelay = time.Second
+
+type postgresPubSub struct {
+	*registry
+	db     *gorm.DB
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// newPostgresPubSub publishes with pg_notify and listens on a connection of
+// the pool dedicated to LISTEN.
+func newPostgresPubSub(db *gorm.DB) *postgresPubSub {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &postgresPubSub{
+		registry: newRegistry(),
+		db:       db,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go p.run(ctx)
+	return p
+}
+
+func (p *postgresPubSub) Publish(ctx context.Context, channel string, payload string) error {
+	return p.db.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", channel, payload).Error
+}
+
+func (p *postgresPubSub) Subscribe(channel string, fn Handler) func() {
+	return p.subscribe(channel, fn)
+}
+
+func (p *postgresPubSub) Close(ctx context.Context) error {
+	p.cancel()
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run listens until the pubsub is closed, reconnecting after errors
+func (p *postgresPubSub) run(ctx context.Context) {
+	defer close(p.done)
+	for {
+		err := p.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			continue
+		}
+		log.Error().Err(err).Msg("Could not listen for notifications, reconnecting")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+// errChannelsChanged restarts listen so new channels are listened to
+var errChannelsChanged = errors.New("pubsub: channels changed")
+
+// listen takes a connection out of the pool, LISTENs to every channel and
+// dispatches notifications until ctx is done or a channel is subscribed to.
+func (p *postgresPubSub) listen(ctx context.Context) error {
+	// Wait for a first subscription
+	channels := p.channels()
+	if len(channels) == 0 {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-p.changed:
+			channels = p.channels()
+		}
+	}
+
+	sqlDB, err := p.db.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	listenCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-listenCtx.Done():
+		case <-p.changed:
+			cancel()
+		}
+	}()
+
+	err = conn.Raw(func(driverConn any) error {
+		stdlibConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("pubsub: unexpected driver connection %T", driverConn)
+		}
+		pgConn := stdlibConn.Conn()
+		for _, channel := range channels {
+			if _, err := pgConn.Exec(listenCtx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+				return err
+			}
+		}
+
+		for {
+			notification, err := pgConn.WaitForNotification(listenCtx)
+			if err != nil {
+				// The connection is unusable once its context was cancelled
+				_ = pgConn.Close(context.Background())
+				if ctx.Err() == nil && listenCtx.Err() != nil {
+					return errChannelsChanged
+				}
+				return err
+			}
+			p.dispatch(notification.Channel, notification.Payload)
+		}
+	})
+	if errors.Is(err, errChannelsChanged) {
+		return nil
+	}
+	return err
+}
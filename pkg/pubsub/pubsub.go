@@ -0,0 +1,118 @@
+// Package pubsub broadcasts short messages to every replica of the API, e.g.
+// to wake up streams when something they follow changed.
+package pubsub
+
+import (
+	"context"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Handler receives the payloads published on a channel. It is called from
+// the delivery goroutine and must not block.
+type Handler func(payload string)
+
+// PubSub delivers payloads at most once: subscribers must tolerate missed
+// messages, e.g. while a replica reconnects, by catching up from the database.
+type PubSub interface {
+	// Publish sends payload to the subscribers of channel on every replica.
+	Publish(ctx context.Context, channel string, payload string) error
+	// Subscribe calls fn for every payload published on channel until the
+	// returned function is called.
+	Subscribe(channel string, fn Handler) (unsubscribe func())
+	// Close stops delivering messages.
+	Close(ctx context.Context) error
+}
+
+// New returns a PubSub using LISTEN/NOTIFY on PostgreSQL. With other
+// databases messages only reach subscribers of the same process.
+func New(db *gorm.DB) PubSub {
+	if db.Dialector.Name() == "postgres" {
+		return newPostgresPubSub(db)
+	}
+	return NewLocal()
+}
+
+// registry keeps the handlers of every channel
+type registry struct {
+	mu       sync.RWMutex
+	nextID   int
+	handlers map[string]map[int]Handler
+	// changed is signalled when a channel gets its first handler
+	changed chan struct{}
+}
+
+func newRegistry() *registry {
+	return &registry{
+		handlers: map[string]map[int]Handler{},
+		changed:  make(chan struct{}, 1),
+	}
+}
+
+func (r *registry) subscribe(channel string, fn Handler) func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.handlers[channel] == nil {
+		r.handlers[channel] = map[int]Handler{}
+		select {
+		case r.changed <- struct{}{}:
+		default:
+		}
+	}
+	r.nextID++
+	id := r.nextID
+	r.handlers[channel][id] = fn
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.handlers[channel], id)
+	}
+}
+
+// channels returns the channels that ever had a handler
+func (r *registry) channels() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	channels := make([]string, 0, len(r.handlers))
+	for channel := range r.handlers {
+		channels = append(channels, channel)
+	}
+	return channels
+}
+
+func (r *registry) dispatch(channel string, payload string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, fn := range r.handlers[channel] {
+		fn(payload)
+	}
+}
+
+type localPubSub struct {
+	*registry
+}
+
+// NewLocal returns a PubSub delivering messages within the process only.
+func NewLocal() PubSub {
+	return &localPubSub{
+		registry: newRegistry(),
+	}
+}
+
+func (p *localPubSub) Publish(ctx context.Context, channel string, payload string) error {
+	p.dispatch(channel, payload)
+	return nil
+}
+
+func (p *localPubSub) Subscribe(channel string, fn Handler) func() {
+	return p.subscribe(channel, fn)
+}
+
+func (p *localPubSub) Close(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,331 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"khiemle.dev/golang-api-template/pkg/tracing"
+)
+
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Disabled is the spec that turns a schedule off.
+const Disabled = "off"
+
+// Run is one execution of a schedule. The unique name and scheduled time
+// make sure only one replica runs each occurrence.
+type Run struct {
+	ID          uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name        string     `json:"name" gorm:"size:100;not null;uniqueIndex:idx_schedule_runs_name_scheduled_at"`
+	ScheduledAt time.Time  `json:"scheduled_at" gorm:"not null;uniqueIndex:idx_schedule_runs_name_scheduled_at"`
+	Holder      string     `json:"holder" gorm:"size:255;not null"`
+	Status      string     `json:"status" gorm:"size:20;not null"`
+	Error       string     `json:"error" gorm:"type:text"`
+	StartedAt   time.Time  `json:"started_at" gorm:"not null"`
+	FinishedAt  *time.Time `json:"finished_at"`
+}
+
+func (Run) TableName() string {
+	return "schedule_runs"
+}
+
+// Func is the task run by a schedule.
+type Func func(ctx context.Context) error
+
+// Info describes a registered schedule for display.
+type Info struct {
+	Name      string
+	Spec      string
+	Enabled   bool
+	NextRunAt *time.Time
+	LastRun   *Run
+}
+
+type entry struct {
+	name     string
+	spec     string
+	schedule cron.Schedule
+	fn       Func
+}
+
+// Scheduler runs registered functions on cron schedules. Every replica runs
+// the same scheduler; each occurrence is executed by whichever replica
+// records it first in the schedule_runs table.
+type Scheduler struct {
+	db               *gorm.DB
+	holder           string
+	overrides        map[string]string
+	historyRetention time.Duration
+	runTimeout       time.Duration
+
+	entries []*entry
+
+	// stop ends the schedule loops, cancelRuns the tasks still running
+	stopCtx    context.Context
+	stop       context.CancelFunc
+	runCtx     context.Context
+	cancelRuns context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+// New creates a scheduler identified by holder. Specs in overrides replace the
+// ones passed to Register, by schedule name. Runs are canceled after
+// runTimeout, so runs still marked running after that were abandoned by a
+// process that stopped. Run history older than historyRetention is deleted.
+func New(db *gorm.DB, holder string, overrides map[string]string, historyRetention time.Duration, runTimeout time.Duration) *Scheduler {
+	return &Scheduler{
+		db:               db,
+		holder:           holder,
+		overrides:        overrides,
+		historyRetention: historyRetention,
+		runTimeout:       runTimeout,
+	}
+}
+
+// ParseOverrides parses overrides written as "name=spec;name=spec", e.g.
+// "session_cleanup=@every 30m;digest=off".
+func ParseOverrides(s string) (map[string]string, error) {
+	overrides := map[string]string{}
+	for _, item := range strings.Split(s, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid schedule override %q, expected name=spec", item)
+		}
+		overrides[strings.TrimSpace(name)] = strings.TrimSpace(spec)
+	}
+	return overrides, nil
+}
+
+// Register adds a schedule. spec is a standard five field cron expression or
+// a descriptor such as @hourly or @every 30m, optionally prefixed with
+// CRON_TZ=. It must be called before Start.
+func (s *Scheduler) Register(name string, spec string, fn Func) error {
+	if override, ok := s.overrides[name]; ok {
+		spec = override
+	}
+
+	e := &entry{
+		name: name,
+		spec: spec,
+		fn:   fn,
+	}
+	if spec != Disabled {
+		schedule, err := cron.ParseStandard(spec)
+		if err != nil {
+			return fmt.Errorf("schedule %s: %w", name, err)
+		}
+		e.schedule = schedule
+	}
+
+	s.entries = append(s.entries, e)
+	return nil
+}
+
+// Start runs every enabled schedule until Close is called. Runs abandoned by
+// processes that stopped while running them are marked failed first.
+func (s *Scheduler) Start() {
+	s.stopCtx, s.stop = context.WithCancel(context.Background())
+	s.runCtx, s.cancelRuns = context.WithCancel(context.Background())
+
+	if err := s.failAbandoned(s.runCtx, time.Now()); err != nil {
+		log.Error().Err(err).Msg("Could not fail abandoned scheduled runs")
+	}
+
+	for _, e := range s.entries {
+		if e.schedule == nil {
+			log.Info().Str("schedule", e.name).Msg("Schedule is disabled")
+			continue
+		}
+
+		s.wg.Add(1)
+		go func(e *entry) {
+			defer s.wg.Done()
+			s.loop(e)
+		}(e)
+	}
+}
+
+// Close stops the schedules and waits for running ones. If ctx ends first,
+// they are canceled.
+func (s *Scheduler) Close(ctx context.Context) error {
+	if s.stop == nil {
+		return nil
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(finished)
+	}()
+
+	s.stop()
+	defer s.cancelRuns()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// List returns the registered schedules with their last run and next run time.
+func (s *Scheduler) List(ctx context.Context) ([]Info, error) {
+	infos := []Info{}
+	now := time.Now()
+	for _, e := range s.entries {
+		info := Info{
+			Name:    e.name,
+			Spec:    e.spec,
+			Enabled: e.schedule != nil,
+		}
+		if e.schedule != nil {
+			next := nextRun(e.schedule, now)
+			info.NextRunAt = &next
+		}
+
+		runs := []Run{}
+		err := s.db.WithContext(ctx).
+			Where("name = ?", e.name).
+			Order("scheduled_at DESC").
+			Limit(1).
+			Find(&runs).Error
+		if err != nil {
+			return nil, err
+		}
+		if len(runs) > 0 {
+			info.LastRun = &runs[0]
+		}
+
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos, nil
+}
+
+func (s *Scheduler) loop(e *entry) {
+	for {
+		next := nextRun(e.schedule, time.Now())
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-timer.C:
+			s.execute(e, next)
+		case <-s.stopCtx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// execute runs the occurrence scheduled at scheduledAt unless another replica
+// already claimed it.
+func (s *Scheduler) execute(e *entry, scheduledAt time.Time) {
+	logger := log.With().Str("schedule", e.name).Time("scheduled_at", scheduledAt).Logger()
+
+	run := Run{
+		Name:        e.name,
+		ScheduledAt: scheduledAt,
+		Holder:      s.holder,
+		Status:      StatusRunning,
+		StartedAt:   time.Now(),
+	}
+	result := s.db.WithContext(s.runCtx).Clauses(clause.OnConflict{DoNothing: true}).Create(&run)
+	if result.Error != nil {
+		logger.Error().Err(result.Error).Msg("Could not claim scheduled run")
+		return
+	}
+	if result.RowsAffected == 0 {
+		logger.Debug().Msg("Scheduled run claimed by another replica")
+		return
+	}
+
+	ctx, span := tracing.Tracer().Start(s.runCtx, "schedule "+e.name)
+	runCtx, cancel := context.WithTimeout(ctx, s.runTimeout)
+	runErr := safeRun(runCtx, e.fn)
+	cancel()
+	defer tracing.End(span, runErr)
+
+	// Record the outcome even when the run was canceled by Close
+	ctx = context.WithoutCancel(ctx)
+
+	finishedAt := time.Now()
+	updates := map[string]any{
+		"status":      StatusSucceeded,
+		"finished_at": finishedAt,
+	}
+	if runErr != nil {
+		updates["status"] = StatusFailed
+		updates["error"] = runErr.Error()
+		logger.Error().Err(runErr).Msg("Scheduled run failed")
+	} else {
+		logger.Info().Dur("duration", finishedAt.Sub(run.StartedAt)).Msg("Scheduled run succeeded")
+	}
+
+	if err := s.db.WithContext(ctx).Model(&run).Updates(updates).Error; err != nil {
+		logger.Error().Err(err).Msg("Could not save scheduled run")
+	}
+
+	err := s.db.WithContext(ctx).
+		Where("name = ? AND scheduled_at < ?", e.name, finishedAt.Add(-s.historyRetention)).
+		Delete(&Run{}).Error
+	if err != nil {
+		logger.Error().Err(err).Msg("Could not prune schedule run history")
+	}
+}
+
+// failAbandoned marks runs that started more than the run timeout before now
+// and are still running as failed, the process running them stopped.
+func (s *Scheduler) failAbandoned(ctx context.Context, now time.Time) error {
+	result := s.db.WithContext(ctx).Model(&Run{}).
+		Where("status = ? AND started_at < ?", StatusRunning, now.Add(-s.runTimeout)).
+		Updates(map[string]any{
+			"status":      StatusFailed,
+			"error":       "abandoned, the process running it stopped",
+			"finished_at": now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Warn().Int64("count", result.RowsAffected).Msg("Marked abandoned scheduled runs failed")
+	}
+	return nil
+}
+
+// nextRun returns the next occurrence after now. @every schedules are aligned
+// to multiples of their interval so every replica computes the same time.
+func nextRun(schedule cron.Schedule, now time.Time) time.Time {
+	if every, ok := schedule.(cron.ConstantDelaySchedule); ok {
+		return now.Truncate(every.Delay).Add(every.Delay)
+	}
+	return schedule.Next(now)
+}
+
+// safeRun turns a panicking task into a failed run.
+func safeRun(ctx context.Context, fn Func) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
@@ -0,0 +1,158 @@
+package scheduler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"khiemle.dev/golang-api-template/pkg/database/databasetest"
+	"khiemle.dev/golang-api-template/pkg/scheduler"
+)
+
+func TestParseOverrides(t *testing.T) {
+	overrides, err := scheduler.ParseOverrides(" session_cleanup = @every 30m ;digest=off;")
+	if err != nil {
+		t.Fatalf("ParseOverrides: %v", err)
+	}
+	if len(overrides) != 2 || overrides["session_cleanup"] != "@every 30m" || overrides["digest"] != scheduler.Disabled {
+		t.Errorf("ParseOverrides = %v", overrides)
+	}
+
+	if _, err := scheduler.ParseOverrides("session_cleanup"); err == nil {
+		t.Error("ParseOverrides accepted an item without spec")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	sched := scheduler.New(nil, "test", map[string]string{"off": scheduler.Disabled}, time.Hour, time.Hour)
+	if err := sched.Register("bad", "not a spec", nil); err == nil {
+		t.Error("Register accepted an invalid spec")
+	}
+	// Overridden specs are not parsed when disabled
+	if err := sched.Register("off", "not a spec", nil); err != nil {
+		t.Errorf("Register of a disabled schedule: %v", err)
+	}
+}
+
+func startScheduler(t *testing.T, sched *scheduler.Scheduler) {
+	t.Helper()
+	sched.Start()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := sched.Close(ctx); err != nil {
+			t.Errorf("Close: %v", err)
+		}
+	})
+}
+
+// waitForRuns waits until the schedule finished count runs and returns them
+func waitForRuns(t *testing.T, db *gorm.DB, name string, count int) []scheduler.Run {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		runs := []scheduler.Run{}
+		err := db.Where("name = ? AND status <> ?", name, scheduler.StatusRunning).Order("id").Find(&runs).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(runs) >= count {
+			return runs
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d runs of %s finished, want %d", len(runs), name, count)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// TestRunsOncePerOccurrence runs the same schedule on two replicas
+func TestRunsOncePerOccurrence(t *testing.T) {
+	db := databasetest.New(t)
+	for _, holder := range []string{"a", "b"} {
+		sched := scheduler.New(db, holder, nil, time.Hour, time.Hour)
+		err := sched.Register("tick", "@every 1s", func(ctx context.Context) error { return nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+		startScheduler(t, sched)
+	}
+
+	runs := waitForRuns(t, db, "tick", 1)
+	if runs[0].Status != scheduler.StatusSucceeded || runs[0].FinishedAt == nil {
+		t.Errorf("run = %+v, want it succeeded", runs[0])
+	}
+
+	var count int64
+	if err := db.Model(&scheduler.Run{}).Where("name = ? AND scheduled_at = ?", "tick", runs[0].ScheduledAt).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("%d runs of one occurrence, want 1", count)
+	}
+}
+
+func TestFailedRuns(t *testing.T) {
+	db := databasetest.New(t)
+	sched := scheduler.New(db, "test", nil, time.Hour, time.Hour)
+	if err := sched.Register("fail", "@every 1s", func(ctx context.Context) error { return errors.New("boom") }); err != nil {
+		t.Fatal(err)
+	}
+	if err := sched.Register("panic", "@every 1s", func(ctx context.Context) error { panic("oops") }); err != nil {
+		t.Fatal(err)
+	}
+	startScheduler(t, sched)
+
+	if run := waitForRuns(t, db, "fail", 1)[0]; run.Status != scheduler.StatusFailed || run.Error != "boom" {
+		t.Errorf("run = %+v, want it failed with boom", run)
+	}
+	if run := waitForRuns(t, db, "panic", 1)[0]; run.Status != scheduler.StatusFailed || run.Error != "panic: oops" {
+		t.Errorf("run = %+v, want it failed with the panic", run)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	db := databasetest.New(t)
+	sched := scheduler.New(db, "test", nil, time.Hour, 50*time.Millisecond)
+	err := sched.Register("slow", "@every 1s", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	startScheduler(t, sched)
+
+	if run := waitForRuns(t, db, "slow", 1)[0]; run.Status != scheduler.StatusFailed {
+		t.Errorf("run = %+v, want it failed by the timeout", run)
+	}
+}
+
+func TestStartFailsAbandonedRuns(t *testing.T) {
+	db := databasetest.New(t)
+	now := time.Now()
+	abandoned := scheduler.Run{Name: "task", ScheduledAt: now.Add(-2 * time.Hour), Holder: "crashed", Status: scheduler.StatusRunning, StartedAt: now.Add(-2 * time.Hour)}
+	running := scheduler.Run{Name: "task", ScheduledAt: now.Add(-time.Minute), Holder: "alive", Status: scheduler.StatusRunning, StartedAt: now.Add(-time.Minute)}
+	for _, run := range []*scheduler.Run{&abandoned, &running} {
+		if err := db.Create(run).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	startScheduler(t, scheduler.New(db, "test", nil, 24*time.Hour, time.Hour))
+
+	if err := db.First(&abandoned, abandoned.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if abandoned.Status != scheduler.StatusFailed || abandoned.FinishedAt == nil {
+		t.Errorf("abandoned run = %+v, want it failed", abandoned)
+	}
+	if err := db.First(&running, running.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if running.Status != scheduler.StatusRunning {
+		t.Errorf("run within the timeout = %+v, want it still running", running)
+	}
+}
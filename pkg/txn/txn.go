@@ -0,0 +1,119 @@
+// Package txn runs service code in database transactions without handing
+// transactions around: the transaction travels in the context and gorm
+// repositories pick it up with DB.
+package txn
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type contextKey struct{}
+
+// state is the transaction of a context and what to run once it commits
+type state struct {
+	tx          *gorm.DB
+	afterCommit []func()
+}
+
+// commit runs the functions registered with AfterCommit, or hands them to
+// the parent transaction of a savepoint
+func (s *state) commit(parent *state) {
+	if parent != nil {
+		parent.afterCommit = append(parent.afterCommit, s.afterCommit...)
+		return
+	}
+	for _, f := range s.afterCommit {
+		f()
+	}
+}
+
+// Transactor runs functions in a transaction.
+type Transactor interface {
+	// Transaction runs fn in a transaction committed when fn returns nil and
+	// rolled back otherwise. Calls nested in fn run in a savepoint of its
+	// transaction, so a failing nested call can be recovered from.
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type gormTransactor struct {
+	db *gorm.DB
+}
+
+func NewGormTransactor(db *gorm.DB) Transactor {
+	return &gormTransactor{
+		db: db,
+	}
+}
+
+func (t *gormTransactor) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	parent, nested := ctx.Value(contextKey{}).(*state)
+	db := t.db
+	if nested && parent.tx != nil {
+		// gorm turns transactions of a transaction into savepoints
+		db = parent.tx
+	}
+
+	s := &state{}
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		s.tx = tx
+		return fn(context.WithValue(ctx, contextKey{}, s))
+	})
+	if err != nil {
+		return err
+	}
+	s.commit(parent)
+	return nil
+}
+
+type nopTransactor struct{}
+
+// NewNopTransactor creates a Transactor running functions as they are, for
+// repositories without transactions such as the memory ones. Functions
+// registered with AfterCommit run when fn returns nil.
+func NewNopTransactor() Transactor {
+	return nopTransactor{}
+}
+
+func (nopTransactor) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	parent, _ := ctx.Value(contextKey{}).(*state)
+
+	s := &state{}
+	if err := fn(context.WithValue(ctx, contextKey{}, s)); err != nil {
+		return err
+	}
+	s.commit(parent)
+	return nil
+}
+
+// Run runs fn in a transaction of t and returns its result.
+func Run[T any](ctx context.Context, t Transactor, fn func(ctx context.Context) (T, error)) (T, error) {
+	var result T
+	err := t.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = fn(ctx)
+		return err
+	})
+	return result, err
+}
+
+// DB returns the transaction of the context, or db outside of transactions,
+// bound to ctx.
+func DB(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if s, ok := ctx.Value(contextKey{}).(*state); ok && s.tx != nil {
+		return s.tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
+
+// AfterCommit runs fn once the transaction of the context commits, or right
+// away outside of transactions. Use it for side effects others must only see
+// after the change, such as waking up readers of the changed rows.
+func AfterCommit(ctx context.Context, fn func()) {
+	if s, ok := ctx.Value(contextKey{}).(*state); ok {
+		s.afterCommit = append(s.afterCommit, fn)
+		return
+	}
+	fn()
+}
@@ -1,18 +1,30 @@
 package util
 
 import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
 	"github.com/spf13/viper"
 )
 
 // Config stores all application configs loaded from file or env variables
 type Config struct {
 	// HTTP Server
-	HTTPServerAddress string `mapstructure:"HTTP_SERVER_ADDRESS"`
+	HTTPServerAddress  string        `mapstructure:"HTTP_SERVER_ADDRESS"`
+	HTTPRequestTimeout time.Duration `mapstructure:"HTTP_REQUEST_TIMEOUT"` // e.g. 30s, 0 disables it
+	// HTTPTrustedProxies are the addresses or CIDRs of the proxies whose
+	// X-Forwarded-For header gives the client IP. None by default, the client
+	// IP is then the address of the peer.
+	HTTPTrustedProxies []string `mapstructure:"HTTP_TRUSTED_PROXIES"`
 
 	// Swagger
 	SwaggerURL string `mapstructure:"SWAGGER_URL"`
 
 	// Logging
+	LogLevel      string `mapstructure:"LOG_LEVEL"`  // trace, debug, info, warn, error
+	LogFormat     string `mapstructure:"LOG_FORMAT"` // console or json
 	LogFilename   string `mapstructure:"LOG_FILENAME"`
 	LogMaxSize    int    `mapstructure:"LOG_MAX_SIZE"` // in megabytes
 	LogMaxBackups int    `mapstructure:"LOG_MAX_BACKUPS"`
@@ -20,11 +32,15 @@ type Config struct {
 	LogCompress   bool   `mapstructure:"LOG_COMPRESS"`
 
 	// Database
-	DBHost     string `mapstructure:"DB_HOST"`
-	DBUser     string `mapstructure:"DB_USER"`
-	DBPassword string `mapstructure:"DB_PASSWORD"`
-	DBName     string `mapstructure:"DB_NAME"`
-	DBPort     int    `mapstructure:"DB_PORT"`
+	DBDriver      string `mapstructure:"DB_DRIVER"` // postgres, mysql or sqlite
+	DBHost        string `mapstructure:"DB_HOST"`
+	DBUser        string `mapstructure:"DB_USER"`
+	DBPassword    string `mapstructure:"DB_PASSWORD"`
+	DBName        string `mapstructure:"DB_NAME"`
+	DBPort        int    `mapstructure:"DB_PORT"`
+	DBPath        string `mapstructure:"DB_PATH"`          // SQLite file, or :memory:
+	DBSSLMode     string `mapstructure:"DB_SSL_MODE"`      // disable, require, verify-ca or verify-full
+	DBSSLRootCert string `mapstructure:"DB_SSL_ROOT_CERT"` // CA certificate used by verify-ca and verify-full
 
 	// JWT
 	PasetoSecretHex           string `mapstructure:"JWT_SECRET_KEY"`
@@ -33,6 +49,86 @@ type Config struct {
 
 	// Cookies
 	CookieDomain string `mapstructure:"COOKIE_DOMAIN"`
+
+	// Auth
+	AuthCacheTTL              time.Duration `mapstructure:"AUTH_CACHE_TTL"`                // cache of session and user lookups, 0 disables it
+	SessionLastUsedFlushEvery time.Duration `mapstructure:"SESSION_LAST_USED_FLUSH_EVERY"` // how often last used timestamps are written
+	SessionRetention          time.Duration `mapstructure:"SESSION_RETENTION"`             // how long logged out sessions are kept
+
+	// Stateless access tokens are trusted on signature and expiry alone,
+	// revoked ones are rejected through a denylist synced from the database
+	AuthStatelessAccessTokens      bool          `mapstructure:"AUTH_STATELESS_ACCESS_TOKENS"`
+	AuthStatelessAccessTokenExpiry time.Duration `mapstructure:"AUTH_STATELESS_ACCESS_TOKEN_EXPIRY"`
+	AuthRevocationSyncEvery        time.Duration `mapstructure:"AUTH_REVOCATION_SYNC_EVERY"`
+
+	// Background jobs
+	JobsConcurrency  int           `mapstructure:"JOBS_CONCURRENCY"`   // jobs run at once per worker process
+	JobsPollInterval time.Duration `mapstructure:"JOBS_POLL_INTERVAL"` // how often due jobs are looked up
+	JobsLockTimeout  time.Duration `mapstructure:"JOBS_LOCK_TIMEOUT"`  // running jobs are retried after this
+
+	// Scheduler
+	ScheduleOverrides         string        `mapstructure:"SCHEDULE_OVERRIDES"` // e.g. session_cleanup=@every 30m;other=off
+	SchedulerHistoryRetention time.Duration `mapstructure:"SCHEDULER_HISTORY_RETENTION"`
+	SchedulerRunTimeout       time.Duration `mapstructure:"SCHEDULER_RUN_TIMEOUT"` // runs are canceled after this
+
+	// Todos
+	TodoTrashRetention time.Duration `mapstructure:"TODO_TRASH_RETENTION"` // how long deleted todos can be restored
+	TodoUnownedOwner   string        `mapstructure:"TODO_UNOWNED_OWNER"`   // username given the todos created before todos had owners
+
+	// Todo event streams
+	TodoEventsRetention   time.Duration `mapstructure:"TODO_EVENTS_RETENTION"`    // how long streams can resume from
+	TodoEventsHeartbeat   time.Duration `mapstructure:"TODO_EVENTS_HEARTBEAT"`    // how often idle streams get a comment
+	TodoEventsMaxDuration time.Duration `mapstructure:"TODO_EVENTS_MAX_DURATION"` // streams are closed after this
+	TodoEventsGapTimeout  time.Duration `mapstructure:"TODO_EVENTS_GAP_TIMEOUT"`  // how long streams wait for earlier events still being committed
+
+	// List sockets, which also use the heartbeat and maximum duration of
+	// todo event streams
+	ListSocketRateLimit float64 `mapstructure:"LIST_SOCKET_RATE_LIMIT"` // messages per second a socket may send
+	ListSocketRateBurst int     `mapstructure:"LIST_SOCKET_RATE_BURST"`
+	// Origins of the pages allowed to open sockets besides the API's own, as
+	// comma-separated scheme://host[:port]
+	ListSocketAllowedOrigins []string `mapstructure:"LIST_SOCKET_ALLOWED_ORIGINS"`
+
+	// Notifications. Email is sent when SMTP_HOST is set and webhooks when
+	// NOTIFY_WEBHOOK_URL is set, in-app notifications are always stored.
+	SMTPHost            string `mapstructure:"SMTP_HOST"`
+	SMTPPort            int    `mapstructure:"SMTP_PORT"`
+	SMTPUsername        string `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword        string `mapstructure:"SMTP_PASSWORD"`
+	SMTPFrom            string `mapstructure:"SMTP_FROM"`
+	NotifyWebhookURL    string `mapstructure:"NOTIFY_WEBHOOK_URL"`
+	NotifyWebhookSecret string `mapstructure:"NOTIFY_WEBHOOK_SECRET"` // signs webhook bodies with HMAC-SHA256
+
+	// Webhooks registered by users
+	WebhookMaxAttempts          int           `mapstructure:"WEBHOOK_MAX_ATTEMPTS"`           // attempts of a delivery before it fails
+	WebhookDisableAfterFailures int           `mapstructure:"WEBHOOK_DISABLE_AFTER_FAILURES"` // failed attempts in a row disabling an endpoint, 0 never
+	WebhookTimeout              time.Duration `mapstructure:"WEBHOOK_TIMEOUT"`                // of a single attempt
+	WebhookDeliveryRetention    time.Duration `mapstructure:"WEBHOOK_DELIVERY_RETENTION"`     // how long deliveries are logged
+	// Networks endpoints may be in besides the public internet, as
+	// comma-separated CIDRs, e.g. 10.1.0.0/16 for receivers of a private
+	// network. Loopback, private and link-local addresses are refused
+	// otherwise.
+	WebhookAllowedNetworks []string `mapstructure:"WEBHOOK_ALLOWED_NETWORKS"`
+
+	// Domain events, dispatched by the worker. Events are also posted to
+	// OUTBOX_BROKER_URL when set.
+	OutboxPollInterval time.Duration `mapstructure:"OUTBOX_POLL_INTERVAL"`
+	OutboxMaxAttempts  int           `mapstructure:"OUTBOX_MAX_ATTEMPTS"` // attempts of a subscriber before it gives up on an event
+	OutboxGapTimeout   time.Duration `mapstructure:"OUTBOX_GAP_TIMEOUT"`  // how long events wait for earlier ones still being committed
+	OutboxRetention    time.Duration `mapstructure:"OUTBOX_RETENTION"`    // how long events are kept for subscribers lagging behind
+	OutboxBrokerURL    string        `mapstructure:"OUTBOX_BROKER_URL"`
+	OutboxBrokerSecret string        `mapstructure:"OUTBOX_BROKER_SECRET"` // signs broker requests with HMAC-SHA256
+
+	// Tracing
+	TracingEnabled      bool    `mapstructure:"TRACING_ENABLED"`
+	TracingServiceName  string  `mapstructure:"TRACING_SERVICE_NAME"`
+	TracingOTLPEndpoint string  `mapstructure:"TRACING_OTLP_ENDPOINT"` // host:port of the OTLP/HTTP collector
+	TracingOTLPInsecure bool    `mapstructure:"TRACING_OTLP_INSECURE"`
+	TracingSampleRatio  float64 `mapstructure:"TRACING_SAMPLE_RATIO"`
+
+	// Metrics, exported to the same OTLP/HTTP collector as traces
+	MetricsEnabled        bool          `mapstructure:"METRICS_ENABLED"`
+	MetricsExportInterval time.Duration `mapstructure:"METRICS_EXPORT_INTERVAL"`
 }
 
 // Load config from env file and return Config
@@ -45,16 +141,26 @@ func LoadConfig(path string) (config Config, err error) {
 
 	// Set default values
 	viper.SetDefault("HTTP_SERVER_ADDRESS", ":8080")
+	viper.SetDefault("HTTP_REQUEST_TIMEOUT", "30s")
+	viper.SetDefault("HTTP_TRUSTED_PROXIES", "")
 
 	viper.SetDefault("SWAGGER_URL", "/docs")
 
 	// Configs for logger
+	viper.SetDefault("LOG_LEVEL", "info")
+	viper.SetDefault("LOG_FORMAT", "console")
 	viper.SetDefault("LOG_FILENAME", "logs/app.log")
 	viper.SetDefault("LOG_MAX_SIZE", 10)
 	viper.SetDefault("LOG_MAX_BACKUPS", 5)
 	viper.SetDefault("LOG_MAX_AGE", 28)
 	viper.SetDefault("LOG_COMPRESS", true)
 
+	// Database
+	viper.SetDefault("DB_DRIVER", "postgres")
+	viper.SetDefault("DB_PATH", "app.db")
+	viper.SetDefault("DB_SSL_MODE", "disable")
+	viper.SetDefault("DB_SSL_ROOT_CERT", "")
+
 	// JWT configs
 	viper.SetDefault("ACCESS_TOKEN_EXPIRY_IN_HOURS", 24)     // 1 day
 	viper.SetDefault("REFRESH_TOKEN_EXPIRY_IN_HOURS", 24*30) // 30 days
@@ -62,6 +168,74 @@ func LoadConfig(path string) (config Config, err error) {
 	// Cookies
 	viper.SetDefault("COOKIE_DOMAIN", "localhost")
 
+	// Auth
+	viper.SetDefault("AUTH_CACHE_TTL", "30s")
+	viper.SetDefault("SESSION_LAST_USED_FLUSH_EVERY", "30s")
+	viper.SetDefault("AUTH_STATELESS_ACCESS_TOKENS", false)
+	viper.SetDefault("AUTH_STATELESS_ACCESS_TOKEN_EXPIRY", "15m")
+	viper.SetDefault("AUTH_REVOCATION_SYNC_EVERY", "10s")
+	viper.SetDefault("SESSION_RETENTION", "168h") // 7 days
+
+	// Background jobs
+	viper.SetDefault("JOBS_CONCURRENCY", 10)
+	viper.SetDefault("JOBS_POLL_INTERVAL", "1s")
+	viper.SetDefault("JOBS_LOCK_TIMEOUT", "15m")
+
+	// Scheduler
+	viper.SetDefault("SCHEDULE_OVERRIDES", "")
+	viper.SetDefault("SCHEDULER_HISTORY_RETENTION", "720h") // 30 days
+	viper.SetDefault("SCHEDULER_RUN_TIMEOUT", "1h")
+
+	// Todos
+	viper.SetDefault("TODO_TRASH_RETENTION", "720h") // 30 days
+	viper.SetDefault("TODO_UNOWNED_OWNER", "")
+
+	// Todo event streams
+	viper.SetDefault("TODO_EVENTS_RETENTION", "24h")
+	viper.SetDefault("TODO_EVENTS_HEARTBEAT", "15s")
+	viper.SetDefault("TODO_EVENTS_MAX_DURATION", "30m")
+	viper.SetDefault("TODO_EVENTS_GAP_TIMEOUT", "5s")
+
+	// List sockets
+	viper.SetDefault("LIST_SOCKET_RATE_LIMIT", 5)
+	viper.SetDefault("LIST_SOCKET_RATE_BURST", 20)
+	viper.SetDefault("LIST_SOCKET_ALLOWED_ORIGINS", "")
+
+	// Notifications
+	viper.SetDefault("SMTP_HOST", "")
+	viper.SetDefault("SMTP_PORT", 587)
+	viper.SetDefault("SMTP_USERNAME", "")
+	viper.SetDefault("SMTP_PASSWORD", "")
+	viper.SetDefault("SMTP_FROM", "")
+	viper.SetDefault("NOTIFY_WEBHOOK_URL", "")
+	viper.SetDefault("NOTIFY_WEBHOOK_SECRET", "")
+
+	// Webhooks
+	viper.SetDefault("WEBHOOK_MAX_ATTEMPTS", 10)
+	viper.SetDefault("WEBHOOK_DISABLE_AFTER_FAILURES", 20)
+	viper.SetDefault("WEBHOOK_TIMEOUT", "10s")
+	viper.SetDefault("WEBHOOK_DELIVERY_RETENTION", "720h") // 30 days
+	viper.SetDefault("WEBHOOK_ALLOWED_NETWORKS", "")
+
+	// Outbox
+	viper.SetDefault("OUTBOX_POLL_INTERVAL", "1s")
+	viper.SetDefault("OUTBOX_MAX_ATTEMPTS", 10)
+	viper.SetDefault("OUTBOX_GAP_TIMEOUT", "5s")
+	viper.SetDefault("OUTBOX_RETENTION", "168h") // 7 days
+	viper.SetDefault("OUTBOX_BROKER_URL", "")
+	viper.SetDefault("OUTBOX_BROKER_SECRET", "")
+
+	// Tracing
+	viper.SetDefault("TRACING_ENABLED", false)
+	viper.SetDefault("TRACING_SERVICE_NAME", "golang-api-template")
+	viper.SetDefault("TRACING_OTLP_ENDPOINT", "localhost:4318")
+	viper.SetDefault("TRACING_OTLP_INSECURE", true)
+	viper.SetDefault("TRACING_SAMPLE_RATIO", 1.0)
+
+	// Metrics
+	viper.SetDefault("METRICS_ENABLED", false)
+	viper.SetDefault("METRICS_EXPORT_INTERVAL", "60s")
+
 	// Read config
 	err = viper.ReadInConfig()
 	if err != nil {
@@ -70,5 +244,104 @@ func LoadConfig(path string) (config Config, err error) {
 
 	// Unmarshal config
 	err = viper.Unmarshal(&config)
+	if err != nil {
+		return
+	}
+
+	err = config.validate()
 	return
 }
+
+// validate rejects settings the services cannot run with, such as intervals
+// of tickers, which panic when they are not positive.
+func (c *Config) validate() error {
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"SESSION_LAST_USED_FLUSH_EVERY", c.SessionLastUsedFlushEvery},
+		{"AUTH_REVOCATION_SYNC_EVERY", c.AuthRevocationSyncEvery},
+		{"JOBS_POLL_INTERVAL", c.JobsPollInterval},
+		{"JOBS_LOCK_TIMEOUT", c.JobsLockTimeout},
+		{"SCHEDULER_RUN_TIMEOUT", c.SchedulerRunTimeout},
+		{"TODO_EVENTS_HEARTBEAT", c.TodoEventsHeartbeat},
+		{"TODO_EVENTS_MAX_DURATION", c.TodoEventsMaxDuration},
+		{"TODO_EVENTS_GAP_TIMEOUT", c.TodoEventsGapTimeout},
+		{"OUTBOX_POLL_INTERVAL", c.OutboxPollInterval},
+		{"OUTBOX_GAP_TIMEOUT", c.OutboxGapTimeout},
+		// A client without timeout lets a stuck receiver hold a worker
+		{"WEBHOOK_TIMEOUT", c.WebhookTimeout},
+		// Janitors would delete everything on their next run
+		{"SESSION_RETENTION", c.SessionRetention},
+		{"SCHEDULER_HISTORY_RETENTION", c.SchedulerHistoryRetention},
+		{"TODO_TRASH_RETENTION", c.TodoTrashRetention},
+		{"TODO_EVENTS_RETENTION", c.TodoEventsRetention},
+		{"WEBHOOK_DELIVERY_RETENTION", c.WebhookDeliveryRetention},
+		{"OUTBOX_RETENTION", c.OutboxRetention},
+	}
+	for _, d := range durations {
+		if d.value <= 0 {
+			return fmt.Errorf("%s must be positive, got %s", d.name, d.value)
+		}
+	}
+	// Stateless access tokens would expire as soon as they are issued
+	if c.AuthStatelessAccessTokens && c.AuthStatelessAccessTokenExpiry <= 0 {
+		return fmt.Errorf("AUTH_STATELESS_ACCESS_TOKEN_EXPIRY must be positive, got %s", c.AuthStatelessAccessTokenExpiry)
+	}
+
+	if c.JobsConcurrency <= 0 {
+		return fmt.Errorf("JOBS_CONCURRENCY must be positive, got %d", c.JobsConcurrency)
+	}
+	if c.WebhookMaxAttempts <= 0 {
+		return fmt.Errorf("WEBHOOK_MAX_ATTEMPTS must be positive, got %d", c.WebhookMaxAttempts)
+	}
+	if c.OutboxMaxAttempts <= 0 {
+		return fmt.Errorf("OUTBOX_MAX_ATTEMPTS must be positive, got %d", c.OutboxMaxAttempts)
+	}
+	if c.ListSocketRateLimit <= 0 {
+		return fmt.Errorf("LIST_SOCKET_RATE_LIMIT must be positive, got %g", c.ListSocketRateLimit)
+	}
+	if c.ListSocketRateBurst <= 0 {
+		return fmt.Errorf("LIST_SOCKET_RATE_BURST must be positive, got %d", c.ListSocketRateBurst)
+	}
+	if _, err := c.WebhookAllowedPrefixes(); err != nil {
+		return err
+	}
+	if _, err := c.TrustedProxies(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// TrustedProxies parses HTTP_TRUSTED_PROXIES.
+func (c *Config) TrustedProxies() ([]string, error) {
+	proxies := []string{}
+	for _, proxy := range c.HTTPTrustedProxies {
+		if proxy = strings.TrimSpace(proxy); proxy == "" {
+			continue
+		}
+		if _, err := netip.ParsePrefix(proxy); err != nil {
+			if _, err := netip.ParseAddr(proxy); err != nil {
+				return nil, fmt.Errorf("HTTP_TRUSTED_PROXIES must hold addresses or CIDRs, got %q", proxy)
+			}
+		}
+		proxies = append(proxies, proxy)
+	}
+	return proxies, nil
+}
+
+// WebhookAllowedPrefixes parses WEBHOOK_ALLOWED_NETWORKS.
+func (c *Config) WebhookAllowedPrefixes() ([]netip.Prefix, error) {
+	prefixes := []netip.Prefix{}
+	for _, network := range c.WebhookAllowedNetworks {
+		if network = strings.TrimSpace(network); network == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return nil, fmt.Errorf("WEBHOOK_ALLOWED_NETWORKS must hold CIDRs, got %q", network)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
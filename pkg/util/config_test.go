@@ -0,0 +1,144 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadConfig loads the config from an app.env holding the given lines
+func loadConfig(t *testing.T, lines ...string) (Config, error) {
+	t.Helper()
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "app.env"), []byte(strings.Join(lines, "\n")+"\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return LoadConfig(dir)
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	cfg, err := loadConfig(t, "DB_DRIVER=sqlite")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.DBDriver != "sqlite" || cfg.HTTPServerAddress != ":8080" {
+		t.Errorf("LoadConfig = %+v", cfg)
+	}
+}
+
+func TestLoadConfigRejectsCounts(t *testing.T) {
+	settings := []string{
+		"JOBS_CONCURRENCY",
+		"LIST_SOCKET_RATE_LIMIT",
+		"LIST_SOCKET_RATE_BURST",
+		"OUTBOX_MAX_ATTEMPTS",
+		"WEBHOOK_MAX_ATTEMPTS",
+	}
+	for _, setting := range settings {
+		for _, value := range []string{"0", "-1"} {
+			t.Run(setting+"="+value, func(t *testing.T) {
+				_, err := loadConfig(t, setting+"="+value)
+				if err == nil || !strings.Contains(err.Error(), setting+" must be positive") {
+					t.Errorf("LoadConfig = %v, want an error about %s", err, setting)
+				}
+			})
+		}
+	}
+}
+
+func TestLoadConfigRejectsIntervals(t *testing.T) {
+	settings := []string{
+		"SESSION_LAST_USED_FLUSH_EVERY",
+		"AUTH_REVOCATION_SYNC_EVERY",
+		"JOBS_POLL_INTERVAL",
+		"JOBS_LOCK_TIMEOUT",
+		"SCHEDULER_RUN_TIMEOUT",
+		"TODO_EVENTS_HEARTBEAT",
+		"TODO_EVENTS_MAX_DURATION",
+		"TODO_EVENTS_GAP_TIMEOUT",
+		"OUTBOX_POLL_INTERVAL",
+		"OUTBOX_GAP_TIMEOUT",
+		"WEBHOOK_TIMEOUT",
+		"SESSION_RETENTION",
+		"SCHEDULER_HISTORY_RETENTION",
+		"TODO_TRASH_RETENTION",
+		"TODO_EVENTS_RETENTION",
+		"WEBHOOK_DELIVERY_RETENTION",
+		"OUTBOX_RETENTION",
+	}
+	for _, setting := range settings {
+		for _, value := range []string{"0s", "-1s"} {
+			t.Run(setting+"="+value, func(t *testing.T) {
+				_, err := loadConfig(t, setting+"="+value)
+				if err == nil || !strings.Contains(err.Error(), setting) {
+					t.Errorf("LoadConfig = %v, want an error about %s", err, setting)
+				}
+			})
+		}
+	}
+}
+
+func TestLoadConfigWebhookAllowedNetworks(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		cfg, err := loadConfig(t, "WEBHOOK_ALLOWED_NETWORKS=10.1.0.0/16, fd00::/8")
+		if err != nil {
+			t.Fatalf("LoadConfig: %v", err)
+		}
+		prefixes, err := cfg.WebhookAllowedPrefixes()
+		if err != nil || len(prefixes) != 2 || prefixes[0].String() != "10.1.0.0/16" || prefixes[1].String() != "fd00::/8" {
+			t.Errorf("WebhookAllowedPrefixes = %v, %v", prefixes, err)
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		_, err := loadConfig(t, "WEBHOOK_ALLOWED_NETWORKS=10.1.0.0")
+		if err == nil || !strings.Contains(err.Error(), "WEBHOOK_ALLOWED_NETWORKS") {
+			t.Errorf("LoadConfig = %v, want an error about WEBHOOK_ALLOWED_NETWORKS", err)
+		}
+	})
+}
+
+func TestLoadConfigTrustedProxies(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		cfg, err := loadConfig(t, "HTTP_TRUSTED_PROXIES=10.0.0.1, 172.16.0.0/12")
+		if err != nil {
+			t.Fatalf("LoadConfig: %v", err)
+		}
+		proxies, err := cfg.TrustedProxies()
+		if err != nil || len(proxies) != 2 || proxies[0] != "10.0.0.1" || proxies[1] != "172.16.0.0/12" {
+			t.Errorf("TrustedProxies = %v, %v", proxies, err)
+		}
+	})
+	t.Run("none by default", func(t *testing.T) {
+		cfg, err := loadConfig(t)
+		if err != nil {
+			t.Fatalf("LoadConfig: %v", err)
+		}
+		if proxies, err := cfg.TrustedProxies(); err != nil || len(proxies) != 0 {
+			t.Errorf("TrustedProxies = %v, %v, want none", proxies, err)
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		_, err := loadConfig(t, "HTTP_TRUSTED_PROXIES=proxy.internal")
+		if err == nil || !strings.Contains(err.Error(), "HTTP_TRUSTED_PROXIES") {
+			t.Errorf("LoadConfig = %v, want an error about HTTP_TRUSTED_PROXIES", err)
+		}
+	})
+}
+
+func TestLoadConfigStatelessAccessTokenExpiry(t *testing.T) {
+	for _, value := range []string{"0s", "-1s"} {
+		t.Run(value, func(t *testing.T) {
+			_, err := loadConfig(t, "AUTH_STATELESS_ACCESS_TOKENS=true", "AUTH_STATELESS_ACCESS_TOKEN_EXPIRY="+value)
+			if err == nil || !strings.Contains(err.Error(), "AUTH_STATELESS_ACCESS_TOKEN_EXPIRY must be positive") {
+				t.Errorf("LoadConfig = %v, want an error about AUTH_STATELESS_ACCESS_TOKEN_EXPIRY", err)
+			}
+		})
+	}
+	t.Run("unused", func(t *testing.T) {
+		if _, err := loadConfig(t, "AUTH_STATELESS_ACCESS_TOKEN_EXPIRY=0s"); err != nil {
+			t.Errorf("LoadConfig without stateless access tokens = %v", err)
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"io"
 	"os"
 
 	"github.com/rs/zerolog/log"
@@ -13,6 +14,12 @@ import (
 func ConfigLogger(cfg Config) {
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 
+	level, err := zerolog.ParseLevel(cfg.LogLevel)
+	if err != nil || level == zerolog.NoLevel {
+		level = zerolog.InfoLevel
+	}
+	zerolog.SetGlobalLevel(level)
+
 	// Create a Lumberjack logger for file rotation
 	fileLogger := &lumberjack.Logger{
 		Filename:   cfg.LogFilename,   // Specify the log file name
@@ -22,12 +29,26 @@ func ConfigLogger(cfg Config) {
 		Compress:   cfg.LogCompress,   // Whether to compress old log files
 	}
 
+	// Write JSON to stdout for log collectors, or human readable output otherwise
+	var stdout io.Writer = zerolog.ConsoleWriter{Out: os.Stdout}
+	if cfg.LogFormat == "json" {
+		stdout = os.Stdout
+	}
+
 	// Create a MultiWriter that writes to both stdout and the log file
 	multiWriter := zerolog.MultiLevelWriter(
-		zerolog.ConsoleWriter{Out: os.Stdout},
+		stdout,
 		fileLogger,
 	)
 
-	// Initialize the logger with the MultiWriter
-	log.Logger = zerolog.New(multiWriter).With().Timestamp().Caller().Logger()
+	// Initialize the logger with the MultiWriter, masking secrets before
+	// anything reaches stdout or the log file
+	log.Logger = zerolog.New(NewRedactWriter(multiWriter)).With().Timestamp().Caller().Logger()
+
+	// Used by zerolog.Ctx when the context carries no request-scoped logger
+	zerolog.DefaultContextLogger = &log.Logger
+
+	if err != nil {
+		log.Warn().Str("log_level", cfg.LogLevel).Msg("Invalid log level, falling back to info")
+	}
 }
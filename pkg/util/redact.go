@@ -0,0 +1,118 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// RedactedValue replaces secrets in logs.
+const RedactedValue = "[REDACTED]"
+
+// sensitiveKeys are JSON field names whose value is never written to logs.
+var sensitiveKeys = map[string]struct{}{
+	"password":         {},
+	"confirm_password": {},
+	"new_password":     {},
+	"hashed_password":  {},
+	"access_token":     {},
+	"refresh_token":    {},
+	"token":            {},
+	"authorization":    {},
+	"cookie":           {},
+	"set-cookie":       {},
+	"secret":           {},
+}
+
+// secretPattern matches bearer credentials and PASETO tokens embedded in
+// free-form strings such as error messages.
+var secretPattern = regexp.MustCompile(`(?i)bearer\s+[a-z0-9\-._~+/]+=*|v[1-4]\.(?:local|public)\.[A-Za-z0-9_\-]+(?:\.[A-Za-z0-9_\-]+)?`)
+
+// IsSensitiveKey reports whether values stored under key must be redacted.
+func IsSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	if _, ok := sensitiveKeys[key]; ok {
+		return true
+	}
+	return strings.HasSuffix(key, "_password") || strings.HasSuffix(key, "_secret")
+}
+
+// RedactString masks bearer credentials and tokens found in s.
+func RedactString(s string) string {
+	return secretPattern.ReplaceAllString(s, RedactedValue)
+}
+
+type redactWriter struct {
+	w io.Writer
+}
+
+// NewRedactWriter wraps w so that every JSON log line written through it has
+// passwords, tokens and Authorization headers masked.
+func NewRedactWriter(w io.Writer) io.Writer {
+	return &redactWriter{w: w}
+}
+
+func (r *redactWriter) Write(p []byte) (int, error) {
+	if !r.mayContainSecret(p) {
+		return r.w.Write(p)
+	}
+
+	var event map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	decoder.UseNumber()
+	if err := decoder.Decode(&event); err != nil {
+		// Not a JSON line, mask what can be recognised
+		if _, err := r.w.Write([]byte(RedactString(string(p)))); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	redacted, err := json.Marshal(redactValue(event))
+	if err != nil {
+		return 0, err
+	}
+	if _, err := r.w.Write(append(redacted, '\n')); err != nil {
+		return 0, err
+	}
+
+	// Report the original length, zerolog treats short writes as errors
+	return len(p), nil
+}
+
+// mayContainSecret is a cheap check so lines without secrets are written
+// untouched.
+func (r *redactWriter) mayContainSecret(p []byte) bool {
+	lower := bytes.ToLower(p)
+	for _, marker := range [][]byte{[]byte("password"), []byte("token"), []byte("authorization"), []byte("cookie"), []byte("secret"), []byte("bearer")} {
+		if bytes.Contains(lower, marker) {
+			return true
+		}
+	}
+	return secretPattern.Match(p)
+}
+
+func redactValue(v any) any {
+	switch value := v.(type) {
+	case map[string]any:
+		for k, item := range value {
+			if IsSensitiveKey(k) {
+				value[k] = RedactedValue
+				continue
+			}
+			value[k] = redactValue(item)
+		}
+		return value
+	case []any:
+		for i, item := range value {
+			value[i] = redactValue(item)
+		}
+		return value
+	case string:
+		return RedactString(value)
+	default:
+		return value
+	}
+}
@@ -0,0 +1,72 @@
+package util
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRedactWriter(t *testing.T) {
+	const token = "v4.local.c2VjcmV0LXBheWxvYWQ"
+
+	tests := []struct {
+		name   string
+		line   string
+		hidden []string
+		kept   []string
+	}{
+		{
+			name:   "sensitive keys",
+			line:   `{"password":"Passw0rd!","user":{"hashed_password":"$2a$10$abc","name":"alice"},"smtp_password":"p4ss","webhook_secret":"s3cr3t"}`,
+			hidden: []string{"Passw0rd!", "$2a$10$abc", "p4ss", "s3cr3t"},
+			kept:   []string{"alice"},
+		},
+		{
+			name:   "headers",
+			line:   `{"headers":{"Authorization":"Bearer abc.def","Cookie":"access_token=abc"},"path":"/v1/todos"}`,
+			hidden: []string{"abc.def", "access_token=abc"},
+			kept:   []string{"/v1/todos"},
+		},
+		{
+			name:   "tokens in messages",
+			line:   `{"error":"could not parse ` + token + `","message":"got bearer abc123 from client"}`,
+			hidden: []string{token, "abc123"},
+			kept:   []string{"could not parse", "from client"},
+		},
+		{
+			name:   "lines that are not JSON",
+			line:   "Authorization: Bearer abc123 for " + token,
+			hidden: []string{"abc123", token},
+			kept:   []string{"Authorization:"},
+		},
+		{
+			name: "lines without secrets",
+			line: `{"level":"info","message":"Request completed"}`,
+			kept: []string{`{"level":"info","message":"Request completed"}`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			n, err := NewRedactWriter(&buf).Write([]byte(tt.line + "\n"))
+			if err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			if n != len(tt.line)+1 {
+				t.Errorf("Write = %d, want the length of the line %d", n, len(tt.line)+1)
+			}
+
+			got := buf.String()
+			for _, secret := range tt.hidden {
+				if strings.Contains(got, secret) {
+					t.Errorf("%q is not redacted in %s", secret, got)
+				}
+			}
+			for _, s := range tt.kept {
+				if !strings.Contains(got, s) {
+					t.Errorf("%q is missing from %s", s, got)
+				}
+			}
+		})
+	}
+}
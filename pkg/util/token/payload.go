@@ -4,9 +4,16 @@ import (
 	"github.com/google/uuid"
 )
 
+// Token types, tokens issued before types were introduced have none.
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
 type TokenPayload struct {
-	TokenID uuid.UUID `json:"id"`
-	UserId  uint      `json:"user_id"`
+	TokenID   uuid.UUID `json:"id"`
+	UserId    uint      `json:"user_id"`
+	TokenType string    `json:"token_type,omitempty"`
 }
 
 func NewPayload(userId uint) *TokenPayload {
@@ -15,3 +22,10 @@ func NewPayload(userId uint) *TokenPayload {
 		UserId:  userId,
 	}
 }
+
+// WithType returns a copy of the payload for a token of the given type.
+func (p *TokenPayload) WithType(tokenType string) *TokenPayload {
+	copied := *p
+	copied.TokenType = tokenType
+	return &copied
+}
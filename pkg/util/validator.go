@@ -1,9 +1,14 @@
 package util
 
 import (
+	"reflect"
 	"regexp"
+	"strings"
 	"unicode"
 	"unicode/utf8"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
 )
 
 // IsValidEmail checks if the email provided is valid by regex.
@@ -36,3 +41,20 @@ func IsValidPassword(password string) bool {
 
 	return hasNumber && hasUpper && hasLower && hasSpecial
 }
+
+// UseJSONFieldNames makes gin's validator report rejected fields by their
+// JSON name instead of the Go struct field name.
+func UseJSONFieldNames() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+}